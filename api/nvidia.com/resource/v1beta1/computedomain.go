@@ -25,11 +25,31 @@ const (
 	ComputeDomainStatusNotReady = "NotReady"
 )
 
+// Condition types of a ComputeDomain.
+const (
+	// ComputeDomainConditionDaemonSetCreated is True once the DaemonSet
+	// running the IMEX daemons of the ComputeDomain has been created.
+	ComputeDomainConditionDaemonSetCreated = "DaemonSetCreated"
+	// ComputeDomainConditionAllNodesJoined is True once the daemons on at
+	// least minNodes nodes have added themselves to the ComputeDomain.
+	ComputeDomainConditionAllNodesJoined = "AllNodesJoined"
+	// ComputeDomainConditionIMEXQuorum is True once the IMEX daemons on at
+	// least minNodes nodes report being ready.
+	ComputeDomainConditionIMEXQuorum = "IMEXQuorum"
+	// ComputeDomainConditionReady is True once workloads can run in the
+	// ComputeDomain, i.e. when all other conditions are True.
+	ComputeDomainConditionReady = "Ready"
+)
+
 // +genclient
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
 // +k8s:openapi-gen=true
 // +kubebuilder:resource:scope=Namespaced
 // +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Status",type=string,JSONPath=`.status.status`
+// +kubebuilder:printcolumn:name="Ready Nodes",type=integer,JSONPath=`.status.readyNodes`
+// +kubebuilder:printcolumn:name="Expected Nodes",type=integer,JSONPath=`.status.expectedNodes`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
 
 // ComputeDomain prepares a set of nodes to run a multi-node workload in.
 type ComputeDomain struct {
@@ -50,12 +70,30 @@ type ComputeDomainList struct {
 	Items []ComputeDomain `json:"items"`
 }
 
-// +kubebuilder:validation:XValidation:rule="self == oldSelf", message="A computeDomain.spec is immutable"
+// +kubebuilder:validation:XValidation:rule="!has(self.minNodes) || self.minNodes <= self.numNodes", message="minNodes must not be greater than numNodes"
 
 // ComputeDomainSpec provides the spec for a ComputeDomain.
 type ComputeDomainSpec struct {
-	NumNodes int                       `json:"numNodes"`
-	Channel  *ComputeDomainChannelSpec `json:"channel"`
+	// NumNodes is the number of nodes the ComputeDomain spans. It can be
+	// changed while the ComputeDomain is in use to scale it up or down.
+	NumNodes int `json:"numNodes"`
+	// MinNodes is the number of nodes that need to have joined the
+	// ComputeDomain for it to become Ready. Further nodes join it as they
+	// come, up to NumNodes. Defaults to NumNodes.
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	MinNodes *int `json:"minNodes,omitempty"`
+	// +kubebuilder:validation:XValidation:rule="self == oldSelf", message="A computeDomain.spec.channel is immutable"
+	Channel *ComputeDomainChannelSpec `json:"channel"`
+}
+
+// GetMinNodes returns the number of nodes that need to have joined the
+// ComputeDomain for it to become Ready.
+func (s *ComputeDomainSpec) GetMinNodes() int {
+	if s.MinNodes != nil {
+		return *s.MinNodes
+	}
+	return s.NumNodes
 }
 
 // ComputeDomainChannelSpec provides the spec for a channel used to run a workload inside a ComputeDomain.
@@ -73,6 +111,24 @@ type ComputeDomainStatus struct {
 	// +kubebuilder:validation:Enum=Ready;NotReady
 	// +kubebuilder:default=NotReady
 	Status string `json:"status"`
+	// ObservedGeneration is the generation of the ComputeDomain the status
+	// was last computed for.
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// Conditions describe the progress of the ComputeDomain towards being
+	// ready, pointing at the step and nodes it is waiting for.
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+	// ExpectedNodes is the number of nodes the ComputeDomain spans.
+	// +optional
+	ExpectedNodes int `json:"expectedNodes,omitempty"`
+	// ReadyNodes is the number of nodes whose IMEX daemon is ready.
+	// +optional
+	ReadyNodes int `json:"readyNodes,omitempty"`
+	// Nodes are the nodes that joined the ComputeDomain, aggregated from its
+	// ComputeDomainMembers.
 	// +listType=map
 	// +listMapKey=name
 	Nodes []*ComputeDomainNode `json:"nodes,omitempty"`
@@ -83,4 +139,13 @@ type ComputeDomainNode struct {
 	Name      string `json:"name"`
 	IPAddress string `json:"ipAddress"`
 	CliqueID  string `json:"cliqueID"`
+	// Status is the status of the IMEX daemon on the node.
+	// +optional
+	// +kubebuilder:validation:Enum=Ready;NotReady
+	Status string `json:"status,omitempty"`
+	// LastHeartbeatTime is when the daemon on the node last reported its
+	// status, as of the last periodic status update of the ComputeDomain.
+	// Nodes that stop reporting are removed from the ComputeDomain.
+	// +optional
+	LastHeartbeatTime *metav1.Time `json:"lastHeartbeatTime,omitempty"`
 }
@@ -0,0 +1,72 @@
+/*
+ * Copyright (c) 2025 NVIDIA CORPORATION.  All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package v1beta1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// +genclient
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+// +k8s:openapi-gen=true
+// +kubebuilder:resource:scope=Namespaced
+// +kubebuilder:printcolumn:name="ComputeDomain",type=string,JSONPath=`.spec.computeDomainName`
+// +kubebuilder:printcolumn:name="Node",type=string,JSONPath=`.spec.nodeName`
+// +kubebuilder:printcolumn:name="IP Address",type=string,JSONPath=`.spec.ipAddress`
+// +kubebuilder:printcolumn:name="Status",type=string,JSONPath=`.spec.status`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// ComputeDomainMember records a node that joined a ComputeDomain. The daemon
+// on each node only ever writes its own ComputeDomainMember, and the
+// compute-domain-controller aggregates them into the node entries of the
+// ComputeDomain status.
+type ComputeDomainMember struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec ComputeDomainMemberSpec `json:"spec"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// ComputeDomainMemberList provides a list of ComputeDomainMembers.
+type ComputeDomainMemberList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+
+	Items []ComputeDomainMember `json:"items"`
+}
+
+// +kubebuilder:validation:XValidation:rule="self.computeDomainName == oldSelf.computeDomainName && self.nodeName == oldSelf.nodeName", message="computeDomainName and nodeName are immutable"
+
+// ComputeDomainMemberSpec provides the spec for a ComputeDomainMember.
+type ComputeDomainMemberSpec struct {
+	ComputeDomainName string `json:"computeDomainName"`
+	NodeName          string `json:"nodeName"`
+	IPAddress         string `json:"ipAddress"`
+	CliqueID          string `json:"cliqueID"`
+	// Status is the status of the IMEX daemon on the node.
+	// +optional
+	// +kubebuilder:validation:Enum=Ready;NotReady
+	Status string `json:"status,omitempty"`
+	// LastHeartbeatTime is when the daemon on the node last reported its
+	// status, by the clock of its node. It is informational: members that
+	// stop reporting are removed based on when the compute-domain-controller
+	// last saw them updated, which does not depend on the clocks of the nodes.
+	// +optional
+	LastHeartbeatTime *metav1.Time `json:"lastHeartbeatTime,omitempty"`
+}
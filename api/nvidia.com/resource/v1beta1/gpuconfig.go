@@ -29,6 +29,36 @@ import (
 type GpuConfig struct {
 	metav1.TypeMeta `json:",inline"`
 	Sharing         *GpuSharing `json:"sharing,omitempty"`
+	// PowerLimitWatts sets the power management limit of the GPUs for as
+	// long as the claim is prepared.
+	PowerLimitWatts *int `json:"powerLimitWatts,omitempty"`
+	// Clocks configures the clocks of the GPUs for as long as the claim is
+	// prepared.
+	Clocks *GpuClocks `json:"clocks,omitempty"`
+	// PersistenceMode enables or disables persistence mode on the GPUs for
+	// as long as the claim is prepared.
+	PersistenceMode *bool `json:"persistenceMode,omitempty"`
+}
+
+// GpuClocks configures the clocks of a GPU. Locked clocks take precedence
+// over application clocks.
+type GpuClocks struct {
+	LockedGpuClocks    *ClockRange        `json:"lockedGpuClocks,omitempty"`
+	LockedMemoryClocks *ClockRange        `json:"lockedMemoryClocks,omitempty"`
+	ApplicationClocks  *ApplicationClocks `json:"applicationClocks,omitempty"`
+}
+
+// ClockRange is a range of clock frequencies in MHz.
+type ClockRange struct {
+	MinMHz int `json:"minMHz"`
+	MaxMHz int `json:"maxMHz"`
+}
+
+// ApplicationClocks are the GPU and memory clock frequencies in MHz at which
+// applications run.
+type ApplicationClocks struct {
+	GpuMHz    int `json:"gpuMHz"`
+	MemoryMHz int `json:"memoryMHz"`
 }
 
 // DefaultGpuConfig provides the default GPU configuration.
@@ -70,5 +100,19 @@ func (c *GpuConfig) Validate() error {
 	if c.Sharing == nil {
 		return fmt.Errorf("no sharing strategy set")
 	}
+	if c.PowerLimitWatts != nil && *c.PowerLimitWatts <= 0 {
+		return fmt.Errorf("power limit must be positive")
+	}
+	if c.Clocks != nil {
+		if err := c.Clocks.Validate(); err != nil {
+			return err
+		}
+	}
 	return c.Sharing.Validate()
 }
+
+// HasGpuSettings checks if any power, clock or persistence mode settings are
+// configured.
+func (c *GpuConfig) HasGpuSettings() bool {
+	return c.PowerLimitWatts != nil || c.Clocks != nil || c.PersistenceMode != nil
+}
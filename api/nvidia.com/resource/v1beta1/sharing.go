@@ -73,6 +73,8 @@ type MigDeviceSharing struct {
 }
 
 // TimeSlicingSettings provides the settings for CUDA time-slicing.
+// The interval is applied with `nvidia-smi compute-policy --set-timeslice`,
+// as NVML has no API for it outside of vGPU hosts.
 type TimeSlicingConfig struct {
 	Interval *TimeSliceInterval `json:"interval,omitempty"`
 }
@@ -65,6 +65,45 @@ func (c *MpsConfig) Validate() error {
 	return nil
 }
 
+// Validate ensures that ClockRange has a valid set of values.
+func (r *ClockRange) Validate() error {
+	if r.MinMHz <= 0 {
+		return fmt.Errorf("minimum clock must be positive")
+	}
+	if r.MaxMHz < r.MinMHz {
+		return fmt.Errorf("maximum clock must not be less than minimum clock")
+	}
+	return nil
+}
+
+// Validate ensures that ApplicationClocks has a valid set of values.
+func (c *ApplicationClocks) Validate() error {
+	if c.GpuMHz <= 0 || c.MemoryMHz <= 0 {
+		return fmt.Errorf("application clocks must be positive")
+	}
+	return nil
+}
+
+// Validate ensures that GpuClocks has a valid set of values.
+func (c *GpuClocks) Validate() error {
+	if c.LockedGpuClocks != nil {
+		if err := c.LockedGpuClocks.Validate(); err != nil {
+			return fmt.Errorf("invalid locked GPU clocks: %w", err)
+		}
+	}
+	if c.LockedMemoryClocks != nil {
+		if err := c.LockedMemoryClocks.Validate(); err != nil {
+			return fmt.Errorf("invalid locked memory clocks: %w", err)
+		}
+	}
+	if c.ApplicationClocks != nil {
+		if err := c.ApplicationClocks.Validate(); err != nil {
+			return fmt.Errorf("invalid application clocks: %w", err)
+		}
+	}
+	return nil
+}
+
 // Validate ensures that GpuSharing has a valid set of values.
 func (s *GpuSharing) Validate() error {
 	if err := s.Strategy.Validate(); err != nil {
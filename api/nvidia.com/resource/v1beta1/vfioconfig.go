@@ -0,0 +1,50 @@
+/*
+ * Copyright (c) 2025, NVIDIA CORPORATION.  All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package v1beta1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// VfioGpuConfig requests GPUs to be passed through to virtual machines. The
+// GPUs are bound to the vfio-pci driver instead of the NVIDIA driver for as
+// long as the claim is prepared.
+type VfioGpuConfig struct {
+	metav1.TypeMeta `json:",inline"`
+}
+
+// DefaultVfioGpuConfig provides the default VFIO GPU configuration.
+func DefaultVfioGpuConfig() *VfioGpuConfig {
+	return &VfioGpuConfig{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: GroupName + "/" + Version,
+			Kind:       VfioGpuConfigKind,
+		},
+	}
+}
+
+// Normalize updates a VfioGpuConfig config with implied default values based on other settings.
+func (c *VfioGpuConfig) Normalize() error {
+	return nil
+}
+
+// Validate ensures that VfioGpuConfig has a valid set of values.
+func (c *VfioGpuConfig) Validate() error {
+	return nil
+}
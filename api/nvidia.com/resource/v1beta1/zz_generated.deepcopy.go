@@ -21,9 +21,40 @@
 package v1beta1
 
 import (
+	"k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ApplicationClocks) DeepCopyInto(out *ApplicationClocks) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ApplicationClocks.
+func (in *ApplicationClocks) DeepCopy() *ApplicationClocks {
+	if in == nil {
+		return nil
+	}
+	out := new(ApplicationClocks)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClockRange) DeepCopyInto(out *ClockRange) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClockRange.
+func (in *ClockRange) DeepCopy() *ClockRange {
+	if in == nil {
+		return nil
+	}
+	out := new(ClockRange)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ComputeDomain) DeepCopyInto(out *ComputeDomain) {
 	*out = *in
@@ -147,9 +178,90 @@ func (in *ComputeDomainList) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ComputeDomainMember) DeepCopyInto(out *ComputeDomainMember) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ComputeDomainMember.
+func (in *ComputeDomainMember) DeepCopy() *ComputeDomainMember {
+	if in == nil {
+		return nil
+	}
+	out := new(ComputeDomainMember)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ComputeDomainMember) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ComputeDomainMemberList) DeepCopyInto(out *ComputeDomainMemberList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]ComputeDomainMember, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ComputeDomainMemberList.
+func (in *ComputeDomainMemberList) DeepCopy() *ComputeDomainMemberList {
+	if in == nil {
+		return nil
+	}
+	out := new(ComputeDomainMemberList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ComputeDomainMemberList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ComputeDomainMemberSpec) DeepCopyInto(out *ComputeDomainMemberSpec) {
+	*out = *in
+	if in.LastHeartbeatTime != nil {
+		in, out := &in.LastHeartbeatTime, &out.LastHeartbeatTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ComputeDomainMemberSpec.
+func (in *ComputeDomainMemberSpec) DeepCopy() *ComputeDomainMemberSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ComputeDomainMemberSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ComputeDomainNode) DeepCopyInto(out *ComputeDomainNode) {
 	*out = *in
+	if in.LastHeartbeatTime != nil {
+		in, out := &in.LastHeartbeatTime, &out.LastHeartbeatTime
+		*out = (*in).DeepCopy()
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ComputeDomainNode.
@@ -180,6 +292,11 @@ func (in *ComputeDomainResourceClaimTemplate) DeepCopy() *ComputeDomainResourceC
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ComputeDomainSpec) DeepCopyInto(out *ComputeDomainSpec) {
 	*out = *in
+	if in.MinNodes != nil {
+		in, out := &in.MinNodes, &out.MinNodes
+		*out = new(int)
+		**out = **in
+	}
 	if in.Channel != nil {
 		in, out := &in.Channel, &out.Channel
 		*out = new(ComputeDomainChannelSpec)
@@ -200,6 +317,13 @@ func (in *ComputeDomainSpec) DeepCopy() *ComputeDomainSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ComputeDomainStatus) DeepCopyInto(out *ComputeDomainStatus) {
 	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 	if in.Nodes != nil {
 		in, out := &in.Nodes, &out.Nodes
 		*out = make([]*ComputeDomainNode, len(*in))
@@ -207,7 +331,7 @@ func (in *ComputeDomainStatus) DeepCopyInto(out *ComputeDomainStatus) {
 			if (*in)[i] != nil {
 				in, out := &(*in)[i], &(*out)[i]
 				*out = new(ComputeDomainNode)
-				**out = **in
+				(*in).DeepCopyInto(*out)
 			}
 		}
 	}
@@ -223,6 +347,36 @@ func (in *ComputeDomainStatus) DeepCopy() *ComputeDomainStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GpuClocks) DeepCopyInto(out *GpuClocks) {
+	*out = *in
+	if in.LockedGpuClocks != nil {
+		in, out := &in.LockedGpuClocks, &out.LockedGpuClocks
+		*out = new(ClockRange)
+		**out = **in
+	}
+	if in.LockedMemoryClocks != nil {
+		in, out := &in.LockedMemoryClocks, &out.LockedMemoryClocks
+		*out = new(ClockRange)
+		**out = **in
+	}
+	if in.ApplicationClocks != nil {
+		in, out := &in.ApplicationClocks, &out.ApplicationClocks
+		*out = new(ApplicationClocks)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GpuClocks.
+func (in *GpuClocks) DeepCopy() *GpuClocks {
+	if in == nil {
+		return nil
+	}
+	out := new(GpuClocks)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *GpuConfig) DeepCopyInto(out *GpuConfig) {
 	*out = *in
@@ -232,6 +386,21 @@ func (in *GpuConfig) DeepCopyInto(out *GpuConfig) {
 		*out = new(GpuSharing)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.PowerLimitWatts != nil {
+		in, out := &in.PowerLimitWatts, &out.PowerLimitWatts
+		*out = new(int)
+		**out = **in
+	}
+	if in.Clocks != nil {
+		in, out := &in.Clocks, &out.Clocks
+		*out = new(GpuClocks)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.PersistenceMode != nil {
+		in, out := &in.PersistenceMode, &out.PersistenceMode
+		*out = new(bool)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GpuConfig.
@@ -398,3 +567,27 @@ func (in *TimeSlicingConfig) DeepCopy() *TimeSlicingConfig {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VfioGpuConfig) DeepCopyInto(out *VfioGpuConfig) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VfioGpuConfig.
+func (in *VfioGpuConfig) DeepCopy() *VfioGpuConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(VfioGpuConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *VfioGpuConfig) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
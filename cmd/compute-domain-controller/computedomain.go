@@ -84,6 +84,31 @@ func NewComputeDomainManager(config *ManagerConfig) *ComputeDomainManager {
 	return m
 }
 
+// StartInformers starts the informers of the ComputeDomainManager and all of
+// its sub-managers and waits for their caches to sync, without acting on any
+// of the objects yet. This keeps the caches of standby replicas warm so that
+// they can take over quickly once they become the leader.
+func (m *ComputeDomainManager) StartInformers(ctx context.Context) error {
+	m.factory.Start(ctx.Done())
+	if !cache.WaitForCacheSync(ctx.Done(), m.informer.HasSynced) {
+		return fmt.Errorf("informer cache sync for ComputeDomains failed")
+	}
+
+	if err := m.daemonSetManager.StartInformers(ctx); err != nil {
+		return fmt.Errorf("error starting DaemonSet manager informers: %w", err)
+	}
+
+	if err := m.resourceClaimTemplateManager.StartInformers(ctx); err != nil {
+		return fmt.Errorf("error starting ResourceClaimTemplate manager informers: %w", err)
+	}
+
+	if err := m.nodeManager.StartInformers(ctx); err != nil {
+		return fmt.Errorf("error starting Node manager informers: %w", err)
+	}
+
+	return nil
+}
+
 // Start starts a ComputeDomainManager.
 func (m *ComputeDomainManager) Start(ctx context.Context) (rerr error) {
 	ctx, cancel := context.WithCancel(ctx)
@@ -282,5 +307,11 @@ func (m *ComputeDomainManager) onAddOrUpdate(ctx context.Context, obj any) error
 		return fmt.Errorf("error creating ResourceClaimTemplate '%s/%s': %w", cd.Namespace, cd.Spec.Channel.ResourceClaimTemplate.Name, err)
 	}
 
+	// Node entries added or updated by the daemons change the readiness of
+	// the ComputeDomain as well.
+	if err := m.daemonSetManager.UpdateComputeDomainStatus(ctx, string(cd.UID)); err != nil {
+		return fmt.Errorf("error updating ComputeDomain status: %w", err)
+	}
+
 	return nil
 }
@@ -0,0 +1,305 @@
+/*
+ * Copyright (c) 2025 NVIDIA CORPORATION.  All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+	"k8s.io/klog/v2"
+
+	nvapi "github.com/NVIDIA/k8s-dra-driver-gpu/api/nvidia.com/resource/v1beta1"
+	nvinformers "github.com/NVIDIA/k8s-dra-driver-gpu/pkg/nvidia.com/informers/externalversions"
+)
+
+const (
+	// memberBatchPeriod is how long changes to the ComputeDomainMembers of a
+	// ComputeDomain are collected before its status gets updated. This turns
+	// the members of a large ComputeDomain joining at about the same time into
+	// a few status updates instead of one per member.
+	memberBatchPeriod = time.Second
+)
+
+type UpdateComputeDomainStatusFunc func(ctx context.Context, cdUID string) error
+
+// memberObservation records when a version of a ComputeDomainMember was
+// first seen.
+type memberObservation struct {
+	resourceVersion string
+	time            time.Time
+}
+
+// ComputeDomainMemberManager watches the ComputeDomainMembers written by the
+// daemons and triggers batched updates of the status of their ComputeDomains.
+type ComputeDomainMemberManager struct {
+	config        *ManagerConfig
+	waitGroup     sync.WaitGroup
+	cancelContext context.CancelFunc
+
+	factory  nvinformers.SharedInformerFactory
+	informer cache.SharedIndexInformer
+
+	// statusQueue holds the UIDs of the ComputeDomains whose status needs
+	// to be updated. Unlike the main work queue, it deduplicates them.
+	statusQueue  workqueue.TypedRateLimitingInterface[string]
+	updateStatus UpdateComputeDomainStatusFunc
+
+	// observations tracks when the current version of each member was first
+	// seen. Like the node lifecycle controller does for node Leases, this
+	// measures the freshness of heartbeats by the clock of the controller
+	// rather than by the clocks of the nodes writing them.
+	observationsMutex sync.Mutex
+	observations      map[types.UID]memberObservation
+	now               func() time.Time
+}
+
+// NewComputeDomainMemberManager creates a new ComputeDomainMemberManager.
+func NewComputeDomainMemberManager(config *ManagerConfig, updateStatus UpdateComputeDomainStatusFunc) *ComputeDomainMemberManager {
+	labelSelector := &metav1.LabelSelector{
+		MatchExpressions: []metav1.LabelSelectorRequirement{
+			{
+				Key:      computeDomainLabelKey,
+				Operator: metav1.LabelSelectorOpExists,
+			},
+		},
+	}
+
+	factory := nvinformers.NewSharedInformerFactoryWithOptions(
+		config.clientsets.Nvidia,
+		informerResyncPeriod,
+		nvinformers.WithTweakListOptions(func(opts *metav1.ListOptions) {
+			opts.LabelSelector = metav1.FormatLabelSelector(labelSelector)
+		}),
+	)
+	informer := factory.Resource().V1beta1().ComputeDomainMembers().Informer()
+
+	m := &ComputeDomainMemberManager{
+		config:       config,
+		factory:      factory,
+		informer:     informer,
+		updateStatus: updateStatus,
+		observations: make(map[types.UID]memberObservation),
+		now:          time.Now,
+	}
+
+	return m
+}
+
+// StartInformers starts the informers of the ComputeDomainMemberManager and
+// waits for their caches to sync.
+func (m *ComputeDomainMemberManager) StartInformers(ctx context.Context) error {
+	m.factory.Start(ctx.Done())
+	if !cache.WaitForCacheSync(ctx.Done(), m.informer.HasSynced) {
+		return fmt.Errorf("informer cache sync for ComputeDomainMembers failed")
+	}
+	return nil
+}
+
+func (m *ComputeDomainMemberManager) Start(ctx context.Context) (rerr error) {
+	ctx, cancel := context.WithCancel(ctx)
+	m.cancelContext = cancel
+
+	defer func() {
+		if rerr != nil {
+			if err := m.Stop(); err != nil {
+				klog.Errorf("error stopping ComputeDomainMember manager: %v", err)
+			}
+		}
+	}()
+
+	m.statusQueue = workqueue.NewTypedRateLimitingQueue(workqueue.DefaultTypedControllerRateLimiter[string]())
+
+	if err := addComputeDomainLabelIndexer[*nvapi.ComputeDomainMember](m.informer); err != nil {
+		return fmt.Errorf("error adding indexer for ComputeDomainMember ComputeDomain label: %w", err)
+	}
+
+	_, err := m.informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj any) {
+			m.observe(obj)
+			m.enqueueStatusUpdate(obj)
+		},
+		UpdateFunc: func(objOld, objNew any) {
+			m.observe(objNew)
+			// Heartbeats alone do not change the membership or readiness of
+			// the ComputeDomain. They get aggregated by the periodic status
+			// update instead, which keeps the number of ComputeDomain updates
+			// (each of which goes out to all daemons) independent of the
+			// number of members.
+			if onlyHeartbeatChanged(objOld, objNew) {
+				return
+			}
+			m.enqueueStatusUpdate(objNew)
+		},
+		DeleteFunc: func(obj any) {
+			if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+				obj = tombstone.Obj
+			}
+			m.forget(obj)
+			m.enqueueStatusUpdate(obj)
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("error adding event handlers for ComputeDomainMember informer: %w", err)
+	}
+
+	m.waitGroup.Add(1)
+	go func() {
+		defer m.waitGroup.Done()
+		m.factory.Start(ctx.Done())
+	}()
+
+	if !cache.WaitForCacheSync(ctx.Done(), m.informer.HasSynced) {
+		return fmt.Errorf("informer cache sync for ComputeDomainMembers failed")
+	}
+
+	m.waitGroup.Add(1)
+	go func() {
+		defer m.waitGroup.Done()
+		<-ctx.Done()
+		m.statusQueue.ShutDown()
+	}()
+
+	m.waitGroup.Add(1)
+	go func() {
+		defer m.waitGroup.Done()
+		for m.processNextStatusUpdate(ctx) {
+		}
+	}()
+
+	return nil
+}
+
+func (m *ComputeDomainMemberManager) Stop() error {
+	if m.cancelContext != nil {
+		m.cancelContext()
+	}
+	m.waitGroup.Wait()
+	return nil
+}
+
+// List returns the ComputeDomainMembers of the ComputeDomain with the given
+// UID.
+func (m *ComputeDomainMemberManager) List(ctx context.Context, cdUID string) ([]*nvapi.ComputeDomainMember, error) {
+	return getByComputeDomainUID[*nvapi.ComputeDomainMember](ctx, m.informer.GetIndexer(), cdUID)
+}
+
+// Delete deletes a ComputeDomainMember, removing its node from the
+// ComputeDomain.
+func (m *ComputeDomainMemberManager) Delete(ctx context.Context, member *nvapi.ComputeDomainMember) error {
+	err := m.config.clientsets.Nvidia.ResourceV1beta1().ComputeDomainMembers(member.Namespace).Delete(ctx, member.Name, metav1.DeleteOptions{
+		Preconditions: &metav1.Preconditions{UID: &member.UID},
+	})
+	if err != nil && !errors.IsNotFound(err) {
+		return fmt.Errorf("error deleting ComputeDomainMember %s/%s: %w", member.Namespace, member.Name, err)
+	}
+	return nil
+}
+
+// LastObserved returns when the current version of a ComputeDomainMember was
+// first seen by this controller. Every heartbeat of its daemon creates a new
+// version. When the controller starts acting on ComputeDomains, e.g. after a
+// restart or when it takes over as the leader, all members count as fresh.
+func (m *ComputeDomainMemberManager) LastObserved(member *nvapi.ComputeDomainMember) time.Time {
+	m.observe(member)
+
+	m.observationsMutex.Lock()
+	defer m.observationsMutex.Unlock()
+	return m.observations[member.UID].time
+}
+
+func (m *ComputeDomainMemberManager) observe(obj any) {
+	member, ok := obj.(*nvapi.ComputeDomainMember)
+	if !ok {
+		klog.Warningf("unexpected object type %T: ComputeDomainMember required", obj)
+		return
+	}
+
+	m.observationsMutex.Lock()
+	defer m.observationsMutex.Unlock()
+	if observation, exists := m.observations[member.UID]; exists && observation.resourceVersion == member.ResourceVersion {
+		return
+	}
+	m.observations[member.UID] = memberObservation{
+		resourceVersion: member.ResourceVersion,
+		time:            m.now(),
+	}
+}
+
+func (m *ComputeDomainMemberManager) forget(obj any) {
+	member, ok := obj.(*nvapi.ComputeDomainMember)
+	if !ok {
+		klog.Warningf("unexpected object type %T: ComputeDomainMember required", obj)
+		return
+	}
+
+	m.observationsMutex.Lock()
+	defer m.observationsMutex.Unlock()
+	delete(m.observations, member.UID)
+}
+
+func (m *ComputeDomainMemberManager) enqueueStatusUpdate(obj any) {
+	member, ok := obj.(*nvapi.ComputeDomainMember)
+	if !ok {
+		klog.Warningf("unexpected object type %T: ComputeDomainMember required", obj)
+		return
+	}
+	if cdUID := member.Labels[computeDomainLabelKey]; cdUID != "" {
+		m.statusQueue.AddAfter(cdUID, memberBatchPeriod)
+	}
+}
+
+func (m *ComputeDomainMemberManager) processNextStatusUpdate(ctx context.Context) bool {
+	cdUID, shutdown := m.statusQueue.Get()
+	if shutdown {
+		return false
+	}
+	defer m.statusQueue.Done(cdUID)
+
+	if err := m.updateStatus(ctx, cdUID); err != nil {
+		klog.Errorf("Failed to update status of ComputeDomain %s: %v", cdUID, err)
+		m.statusQueue.AddRateLimited(cdUID)
+		return true
+	}
+
+	m.statusQueue.Forget(cdUID)
+	return true
+}
+
+func onlyHeartbeatChanged(objOld, objNew any) bool {
+	oldMember, ok := objOld.(*nvapi.ComputeDomainMember)
+	if !ok {
+		return false
+	}
+	newMember, ok := objNew.(*nvapi.ComputeDomainMember)
+	if !ok {
+		return false
+	}
+
+	oldSpec := oldMember.Spec
+	newSpec := newMember.Spec
+	oldSpec.LastHeartbeatTime = nil
+	newSpec.LastHeartbeatTime = nil
+	return equality.Semantic.DeepEqual(oldSpec, newSpec)
+}
@@ -0,0 +1,55 @@
+/*
+ * Copyright (c) 2025 NVIDIA CORPORATION.  All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	nvapi "github.com/NVIDIA/k8s-dra-driver-gpu/api/nvidia.com/resource/v1beta1"
+)
+
+func TestComputeDomainMemberLastObserved(t *testing.T) {
+	m := NewComputeDomainMemberManager(&ManagerConfig{}, nil)
+	now := time.Now()
+	m.now = func() time.Time { return now }
+
+	member := &nvapi.ComputeDomainMember{
+		ObjectMeta: metav1.ObjectMeta{UID: "member", ResourceVersion: "1"},
+	}
+	m.observe(member)
+	observed := now
+
+	// Versions that have been seen already do not count as heartbeats.
+	now = now.Add(time.Minute)
+	require.Equal(t, observed, m.LastObserved(member))
+
+	// New versions do, whatever the daemon wrote into them.
+	member = member.DeepCopy()
+	member.ResourceVersion = "2"
+	member.Spec.LastHeartbeatTime = &metav1.Time{Time: now.Add(-time.Hour)}
+	m.observe(member)
+	require.Equal(t, now, m.LastObserved(member))
+
+	// Deleted members are forgotten, and count as fresh when seen again.
+	m.forget(member)
+	now = now.Add(time.Minute)
+	require.Equal(t, now, m.LastObserved(member))
+}
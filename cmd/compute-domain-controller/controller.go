@@ -58,7 +58,9 @@ func NewController(config *Config) *Controller {
 
 // Run starts the controller's main loop and manages the lifecycle of its components.
 // It initializes the work queue, starts the ComputeDomain manager, and handles
-// graceful shutdown when the context is cancelled.
+// graceful shutdown when the context is cancelled. With leader election
+// enabled, the informers of all managers are started right away but objects
+// are only acted upon once this replica has become the leader.
 func (c *Controller) Run(ctx context.Context) error {
 	workQueue := workqueue.New(workqueue.DefaultControllerRateLimiter())
 
@@ -72,15 +74,27 @@ func (c *Controller) Run(ctx context.Context) error {
 
 	cdManager := NewComputeDomainManager(managerConfig)
 
-	if err := cdManager.Start(ctx); err != nil {
-		return fmt.Errorf("error starting ComputeDomain manager: %w", err)
+	run := func(ctx context.Context) error {
+		if err := cdManager.Start(ctx); err != nil {
+			return fmt.Errorf("error starting ComputeDomain manager: %w", err)
+		}
+
+		workQueue.Run(ctx)
+
+		if err := cdManager.Stop(); err != nil {
+			return fmt.Errorf("error stopping ComputeDomain manager: %w", err)
+		}
+
+		return nil
 	}
 
-	workQueue.Run(ctx)
+	if !c.config.flags.leaderElection.Enabled {
+		return run(ctx)
+	}
 
-	if err := cdManager.Stop(); err != nil {
-		return fmt.Errorf("error stopping ComputeDomain manager: %w", err)
+	if err := cdManager.StartInformers(ctx); err != nil {
+		return fmt.Errorf("error starting ComputeDomain manager informers: %w", err)
 	}
 
-	return nil
+	return runAsLeader(ctx, c.config, run)
 }
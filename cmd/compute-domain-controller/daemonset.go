@@ -22,13 +22,16 @@ import (
 	"fmt"
 	"sync"
 	"text/template"
+	"time"
 
 	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/apimachinery/pkg/util/yaml"
 	"k8s.io/client-go/informers"
 	"k8s.io/client-go/tools/cache"
@@ -39,6 +42,11 @@ import (
 
 const (
 	DaemonSetTemplatePath = "/templates/compute-domain-daemon.tmpl.yaml"
+
+	// statusUpdateInterval is how often the status of all ComputeDomains is
+	// recomputed, so that nodes whose heartbeat expired get removed even if
+	// nothing else changes.
+	statusUpdateInterval = 30 * time.Second
 )
 
 type DaemonSetTemplateData struct {
@@ -62,8 +70,10 @@ type DaemonSetManager struct {
 	factory       informers.SharedInformerFactory
 	informer      cache.SharedIndexInformer
 	mutationCache cache.MutationCache
+	podInformer   cache.SharedIndexInformer
 
 	resourceClaimTemplateManager *DaemonSetResourceClaimTemplateManager
+	memberManager                *ComputeDomainMemberManager
 	cleanupManager               *CleanupManager[*appsv1.DaemonSet]
 }
 
@@ -86,19 +96,42 @@ func NewDaemonSetManager(config *ManagerConfig, getComputeDomain GetComputeDomai
 	)
 
 	informer := factory.Apps().V1().DaemonSets().Informer()
+	// The daemon pods carry the same label as their DaemonSet.
+	podInformer := factory.Core().V1().Pods().Informer()
 
 	m := &DaemonSetManager{
 		config:           config,
 		getComputeDomain: getComputeDomain,
 		factory:          factory,
 		informer:         informer,
+		podInformer:      podInformer,
 	}
 	m.resourceClaimTemplateManager = NewDaemonSetResourceClaimTemplateManager(config, getComputeDomain)
+	m.memberManager = NewComputeDomainMemberManager(config, m.UpdateComputeDomainStatus)
 	m.cleanupManager = NewCleanupManager[*appsv1.DaemonSet](informer, getComputeDomain, m.cleanup)
 
 	return m
 }
 
+// StartInformers starts the informers of the DaemonSetManager and waits for
+// their caches to sync.
+func (m *DaemonSetManager) StartInformers(ctx context.Context) error {
+	m.factory.Start(ctx.Done())
+	if !cache.WaitForCacheSync(ctx.Done(), m.informer.HasSynced, m.podInformer.HasSynced) {
+		return fmt.Errorf("informer cache sync for DaemonSet failed")
+	}
+
+	if err := m.resourceClaimTemplateManager.StartInformers(ctx); err != nil {
+		return fmt.Errorf("error starting ResourceClaimTemplate manager informers: %w", err)
+	}
+
+	if err := m.memberManager.StartInformers(ctx); err != nil {
+		return fmt.Errorf("error starting ComputeDomainMember manager informers: %w", err)
+	}
+
+	return nil
+}
+
 func (m *DaemonSetManager) Start(ctx context.Context) (rerr error) {
 	ctx, cancel := context.WithCancel(ctx)
 	m.cancelContext = cancel
@@ -114,6 +147,9 @@ func (m *DaemonSetManager) Start(ctx context.Context) (rerr error) {
 	if err := addComputeDomainLabelIndexer[*appsv1.DaemonSet](m.informer); err != nil {
 		return fmt.Errorf("error adding indexer for MulitNodeEnvironment label: %w", err)
 	}
+	if err := addComputeDomainLabelIndexer[*corev1.Pod](m.podInformer); err != nil {
+		return fmt.Errorf("error adding indexer for daemon pod ComputeDomain label: %w", err)
+	}
 
 	m.mutationCache = cache.NewIntegerResourceVersionMutationCache(
 		klog.Background(),
@@ -135,20 +171,43 @@ func (m *DaemonSetManager) Start(ctx context.Context) (rerr error) {
 		return fmt.Errorf("error adding event handlers for DaemonSet informer: %w", err)
 	}
 
+	// A daemon pod going away means that its node left the ComputeDomain.
+	_, err = m.podInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		DeleteFunc: func(obj any) {
+			if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+				obj = tombstone.Obj
+			}
+			m.config.workQueue.Enqueue(obj, m.onPodDelete)
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("error adding event handlers for daemon pod informer: %w", err)
+	}
+
 	m.waitGroup.Add(1)
 	go func() {
 		defer m.waitGroup.Done()
 		m.factory.Start(ctx.Done())
 	}()
 
-	if !cache.WaitForCacheSync(ctx.Done(), m.informer.HasSynced) {
+	if !cache.WaitForCacheSync(ctx.Done(), m.informer.HasSynced, m.podInformer.HasSynced) {
 		return fmt.Errorf("informer cache sync for DaemonSet failed")
 	}
 
+	m.waitGroup.Add(1)
+	go func() {
+		defer m.waitGroup.Done()
+		m.periodicStatusUpdate(ctx)
+	}()
+
 	if err := m.resourceClaimTemplateManager.Start(ctx); err != nil {
 		return fmt.Errorf("error starting ResourceClaimTemplate manager: %w", err)
 	}
 
+	if err := m.memberManager.Start(ctx); err != nil {
+		return fmt.Errorf("error starting ComputeDomainMember manager: %w", err)
+	}
+
 	if err := m.cleanupManager.Start(ctx); err != nil {
 		return fmt.Errorf("error starting cleanup manager: %w", err)
 	}
@@ -160,6 +219,9 @@ func (m *DaemonSetManager) Stop() error {
 	if err := m.resourceClaimTemplateManager.Stop(); err != nil {
 		return fmt.Errorf("error stopping ResourceClaimTemplate manager: %w", err)
 	}
+	if err := m.memberManager.Stop(); err != nil {
+		return fmt.Errorf("error stopping ComputeDomainMember manager: %w", err)
+	}
 	m.cancelContext()
 	m.waitGroup.Wait()
 	return nil
@@ -339,27 +401,112 @@ func (m *DaemonSetManager) onAddOrUpdate(ctx context.Context, obj any) error {
 
 	klog.Infof("Processing added or updated DaemonSet: %s/%s", d.Namespace, d.Name)
 
-	cd, err := m.getComputeDomain(d.Labels[computeDomainLabelKey])
-	if err != nil {
-		return fmt.Errorf("error getting ComputeDomain: %w", err)
+	if err := m.UpdateComputeDomainStatus(ctx, d.Labels[computeDomainLabelKey]); err != nil {
+		return fmt.Errorf("error updating ComputeDomain status: %w", err)
 	}
-	if cd == nil {
-		return nil
+
+	return nil
+}
+
+func (m *DaemonSetManager) onPodDelete(ctx context.Context, obj any) error {
+	p, ok := obj.(*corev1.Pod)
+	if !ok {
+		return fmt.Errorf("failed to cast to Pod")
 	}
 
-	if int(d.Status.NumberReady) != cd.Spec.NumNodes {
+	// Only process events from the driver namespace
+	if p.Namespace != m.config.driverNamespace {
 		return nil
 	}
 
-	newCD := cd.DeepCopy()
-	newCD.Status.Status = nvapi.ComputeDomainStatusReady
-	if _, err = m.config.clientsets.Nvidia.ResourceV1beta1().ComputeDomains(newCD.Namespace).UpdateStatus(ctx, newCD, metav1.UpdateOptions{}); err != nil {
-		return fmt.Errorf("error updating nodes in ComputeDomain status: %w", err)
+	klog.Infof("Processing deleted daemon pod: %s/%s on node %s", p.Namespace, p.Name, p.Spec.NodeName)
+
+	if err := m.UpdateComputeDomainStatus(ctx, p.Labels[computeDomainLabelKey]); err != nil {
+		return fmt.Errorf("error updating ComputeDomain status: %w", err)
 	}
 
 	return nil
 }
 
+// UpdateComputeDomainStatus removes the ComputeDomainMembers of departed
+// nodes and updates the status of the ComputeDomain from its DaemonSet and
+// remaining members. The heartbeats of the nodes are left as they are until
+// the next periodic status update.
+func (m *DaemonSetManager) UpdateComputeDomainStatus(ctx context.Context, cdUID string) error {
+	return m.syncComputeDomainStatus(ctx, cdUID, false)
+}
+
+func (m *DaemonSetManager) syncComputeDomainStatus(ctx context.Context, cdUID string, refreshHeartbeats bool) error {
+	ds, err := getByComputeDomainUID[*appsv1.DaemonSet](ctx, m.mutationCache, cdUID)
+	if err != nil {
+		return fmt.Errorf("error retrieving DaemonSet: %w", err)
+	}
+	if len(ds) > 1 {
+		return fmt.Errorf("more than one DaemonSet found with same ComputeDomain UID")
+	}
+	var d *appsv1.DaemonSet
+	if len(ds) == 1 {
+		d = ds[0]
+	}
+
+	daemonNodes, err := m.getDaemonNodes(ctx, cdUID)
+	if err != nil {
+		return fmt.Errorf("error getting nodes of daemon pods: %w", err)
+	}
+
+	members, err := m.memberManager.List(ctx, cdUID)
+	if err != nil {
+		return fmt.Errorf("error getting ComputeDomainMembers: %w", err)
+	}
+
+	members, pruned := pruneComputeDomainMembers(members, daemonNodes, m.memberManager.LastObserved, time.Now())
+	for _, member := range pruned {
+		klog.Infof("Removing departed node %s from ComputeDomain %s", member.Spec.NodeName, cdUID)
+		if err := m.memberManager.Delete(ctx, member); err != nil {
+			return fmt.Errorf("error removing departed node: %w", err)
+		}
+	}
+
+	return updateComputeDomainStatus(ctx, m.config, m.getComputeDomain, cdUID, d, members, refreshHeartbeats)
+}
+
+// getDaemonNodes returns the nodes on which a daemon pod of the ComputeDomain
+// is running. Pods that are being deleted are not taken into account, as
+// their node is about to leave the ComputeDomain.
+func (m *DaemonSetManager) getDaemonNodes(ctx context.Context, cdUID string) (sets.Set[string], error) {
+	pods, err := getByComputeDomainUID[*corev1.Pod](ctx, m.podInformer.GetIndexer(), cdUID)
+	if err != nil {
+		return nil, err
+	}
+
+	nodes := sets.New[string]()
+	for _, p := range pods {
+		if p.Namespace != m.config.driverNamespace || p.Spec.NodeName == "" || p.DeletionTimestamp != nil {
+			continue
+		}
+		nodes.Insert(p.Spec.NodeName)
+	}
+	return nodes, nil
+}
+
+// periodicStatusUpdate regularly updates the status of the ComputeDomains of
+// all DaemonSets.
+func (m *DaemonSetManager) periodicStatusUpdate(ctx context.Context) {
+	ticker := time.NewTicker(statusUpdateInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for _, obj := range m.informer.GetStore().List() {
+				m.config.workQueue.Enqueue(obj, m.onPeriodicStatusUpdate)
+			}
+		}
+	}
+}
+
 func (m *DaemonSetManager) cleanup(ctx context.Context, cdUID string) error {
 	if err := m.Delete(ctx, cdUID); err != nil {
 		return fmt.Errorf("error deleting DaemonSet: %w", err)
@@ -369,3 +516,20 @@ func (m *DaemonSetManager) cleanup(ctx context.Context, cdUID string) error {
 	}
 	return nil
 }
+
+func (m *DaemonSetManager) onPeriodicStatusUpdate(ctx context.Context, obj any) error {
+	d, ok := obj.(*appsv1.DaemonSet)
+	if !ok {
+		return fmt.Errorf("failed to cast to DaemonSet")
+	}
+
+	if d.Namespace != m.config.driverNamespace {
+		return nil
+	}
+
+	if err := m.syncComputeDomainStatus(ctx, d.Labels[computeDomainLabelKey], true); err != nil {
+		return fmt.Errorf("error updating ComputeDomain status: %w", err)
+	}
+
+	return nil
+}
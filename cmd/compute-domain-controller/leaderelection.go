@@ -0,0 +1,123 @@
+/*
+ * Copyright (c) 2025 NVIDIA CORPORATION.  All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+	"k8s.io/klog/v2"
+
+	"github.com/NVIDIA/k8s-dra-driver-gpu/pkg/metrics"
+)
+
+// runAsLeader waits until this replica has been elected as the leader and
+// then calls run until either the context is canceled or leadership is lost.
+// The Lease is only released once run has returned, so that the next leader
+// never overlaps with this one. Losing leadership is reported as an error so
+// that the process restarts and rejoins the election as a standby replica.
+//
+// Only run is limited to the leader. The webhook and the metrics endpoint are
+// served by all replicas: the webhook is stateless, so it stays available
+// while leadership changes hands, and the leader election metric tells the
+// metrics of the leader apart from those of standby replicas.
+func runAsLeader(ctx context.Context, config *Config, run func(ctx context.Context) error) error {
+	le := config.flags.leaderElection
+	leader := metrics.LeaderElectionLeader.WithLabelValues(le.LeaseName)
+	leader.Set(0)
+
+	lock := &resourcelock.LeaseLock{
+		LeaseMeta: metav1.ObjectMeta{
+			Name:      le.LeaseName,
+			Namespace: config.flags.namespace,
+		},
+		Client: config.clientsets.Core.CoordinationV1(),
+		LockConfig: resourcelock.ResourceLockConfig{
+			Identity: config.flags.podName,
+		},
+	}
+
+	leading := make(chan struct{})
+	elector, err := leaderelection.NewLeaderElector(leaderelection.LeaderElectionConfig{
+		Lock:            lock,
+		LeaseDuration:   le.LeaseDuration,
+		RenewDeadline:   le.RenewDeadline,
+		RetryPeriod:     le.RetryPeriod,
+		ReleaseOnCancel: true,
+		Name:            le.LeaseName,
+		Callbacks: leaderelection.LeaderCallbacks{
+			OnStartedLeading: func(context.Context) {
+				leader.Set(1)
+				close(leading)
+			},
+			OnStoppedLeading: func() {
+				leader.Set(0)
+				klog.Infof("Stopped leading")
+			},
+			OnNewLeader: func(identity string) {
+				klog.Infof("Current leader: %s", identity)
+			},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("error creating leader elector: %w", err)
+	}
+
+	// The elector gets its own context so that the Lease is held until run
+	// has returned, even if ctx is canceled first.
+	electorCtx, cancelElector := context.WithCancel(context.WithoutCancel(ctx))
+	defer cancelElector()
+	electorDone := make(chan struct{})
+	go func() {
+		defer close(electorDone)
+		klog.Infof("Waiting to become the leader through Lease %s/%s", config.flags.namespace, le.LeaseName)
+		elector.Run(electorCtx)
+	}()
+
+	select {
+	case <-leading:
+	case <-ctx.Done():
+		cancelElector()
+		<-electorDone
+		return nil
+	}
+	klog.Infof("Started leading")
+
+	runCtx, cancelRun := context.WithCancel(ctx)
+	defer cancelRun()
+	go func() {
+		<-electorDone
+		cancelRun()
+	}()
+
+	runErr := run(runCtx)
+
+	lost := ctx.Err() == nil
+	cancelElector()
+	<-electorDone
+
+	if runErr != nil {
+		return runErr
+	}
+	if lost {
+		return fmt.Errorf("lost leadership")
+	}
+	return nil
+}
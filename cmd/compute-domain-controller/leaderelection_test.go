@@ -0,0 +1,194 @@
+/*
+ * Copyright (c) 2025 NVIDIA CORPORATION.  All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	coreclientset "k8s.io/client-go/kubernetes"
+	coordinationv1client "k8s.io/client-go/kubernetes/typed/coordination/v1"
+	"k8s.io/utils/ptr"
+
+	"github.com/NVIDIA/k8s-dra-driver-gpu/pkg/flags"
+)
+
+// fakeLeases stores a single Lease in memory, with the optimistic concurrency
+// of the API server. It only implements what leader election needs.
+type fakeLeases struct {
+	coreclientset.Interface
+	coordinationv1client.CoordinationV1Interface
+	coordinationv1client.LeaseInterface
+
+	sync.Mutex
+	lease *coordinationv1.Lease
+}
+
+func (f *fakeLeases) CoordinationV1() coordinationv1client.CoordinationV1Interface {
+	return f
+}
+
+func (f *fakeLeases) Leases(namespace string) coordinationv1client.LeaseInterface {
+	return f
+}
+
+func (f *fakeLeases) Get(ctx context.Context, name string, opts metav1.GetOptions) (*coordinationv1.Lease, error) {
+	f.Lock()
+	defer f.Unlock()
+	if f.lease == nil {
+		return nil, apierrors.NewNotFound(coordinationv1.Resource("leases"), name)
+	}
+	return f.lease.DeepCopy(), nil
+}
+
+func (f *fakeLeases) Create(ctx context.Context, lease *coordinationv1.Lease, opts metav1.CreateOptions) (*coordinationv1.Lease, error) {
+	f.Lock()
+	defer f.Unlock()
+	if f.lease != nil {
+		return nil, apierrors.NewAlreadyExists(coordinationv1.Resource("leases"), lease.Name)
+	}
+	return f.store(lease), nil
+}
+
+func (f *fakeLeases) Update(ctx context.Context, lease *coordinationv1.Lease, opts metav1.UpdateOptions) (*coordinationv1.Lease, error) {
+	f.Lock()
+	defer f.Unlock()
+	if f.lease == nil || f.lease.ResourceVersion != lease.ResourceVersion {
+		return nil, apierrors.NewConflict(coordinationv1.Resource("leases"), lease.Name, nil)
+	}
+	return f.store(lease), nil
+}
+
+// store saves a copy of the lease with a new resource version. It must be
+// called with the lock held.
+func (f *fakeLeases) store(lease *coordinationv1.Lease) *coordinationv1.Lease {
+	version := 0
+	if f.lease != nil {
+		version, _ = strconv.Atoi(f.lease.ResourceVersion)
+	}
+	f.lease = lease.DeepCopy()
+	f.lease.ResourceVersion = strconv.Itoa(version + 1)
+	return f.lease.DeepCopy()
+}
+
+func (f *fakeLeases) holder() string {
+	f.Lock()
+	defer f.Unlock()
+	if f.lease == nil {
+		return ""
+	}
+	return ptr.Deref(f.lease.Spec.HolderIdentity, "")
+}
+
+// steal makes another replica the holder of the Lease.
+func (f *fakeLeases) steal() {
+	f.Lock()
+	defer f.Unlock()
+	lease := f.lease.DeepCopy()
+	lease.Spec.HolderIdentity = ptr.To("other")
+	lease.Spec.LeaseDurationSeconds = ptr.To(int32(60))
+	lease.Spec.RenewTime = &metav1.MicroTime{Time: time.Now()}
+	f.store(lease)
+}
+
+func TestRunAsLeader(t *testing.T) {
+	testCases := []struct {
+		name string
+		// stop ends leadership after it has been acquired.
+		stop func(cancel context.CancelFunc, leases *fakeLeases)
+
+		expectedError  string
+		expectedHolder string
+	}{
+		{
+			name: "lease released on cancel",
+			stop: func(cancel context.CancelFunc, _ *fakeLeases) {
+				cancel()
+			},
+			expectedHolder: "",
+		},
+		{
+			name: "lost leadership is an error",
+			stop: func(_ context.CancelFunc, leases *fakeLeases) {
+				leases.steal()
+			},
+			expectedError:  "lost leadership",
+			expectedHolder: "other",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			leases := &fakeLeases{}
+			config := &Config{
+				flags: &Flags{
+					podName:   "controller-0",
+					namespace: "nvidia",
+					leaderElection: flags.LeaderElectionConfig{
+						Enabled:       true,
+						LeaseName:     "compute-domain-controller",
+						LeaseDuration: 600 * time.Millisecond,
+						RenewDeadline: 400 * time.Millisecond,
+						RetryPeriod:   100 * time.Millisecond,
+					},
+				},
+				clientsets: flags.ClientSets{Core: leases},
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			running := make(chan struct{})
+			errs := make(chan error, 1)
+			go func() {
+				errs <- runAsLeader(ctx, config, func(ctx context.Context) error {
+					close(running)
+					<-ctx.Done()
+					return nil
+				})
+			}()
+
+			select {
+			case <-running:
+			case <-time.After(10 * time.Second):
+				require.FailNow(t, "timed out waiting to become the leader")
+			}
+			require.Equal(t, "controller-0", leases.holder())
+
+			tc.stop(cancel, leases)
+
+			select {
+			case err := <-errs:
+				if tc.expectedError != "" {
+					require.ErrorContains(t, err, tc.expectedError)
+				} else {
+					require.NoError(t, err)
+				}
+			case <-time.After(10 * time.Second):
+				require.FailNow(t, "timed out waiting for runAsLeader to return")
+			}
+			require.Equal(t, tc.expectedHolder, leases.holder())
+		})
+	}
+}
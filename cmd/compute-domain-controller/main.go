@@ -19,19 +19,12 @@ package main
 import (
 	"context"
 	"fmt"
-	"net"
 	"net/http"
-	"net/http/pprof"
 	"os"
 	"os/signal"
-	"path"
 	"syscall"
 
-	"github.com/prometheus/client_golang/prometheus"
-	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/urfave/cli/v2"
-
-	"k8s.io/component-base/metrics/legacyregistry"
 	"k8s.io/klog/v2"
 
 	_ "k8s.io/component-base/metrics/prometheus/restclient" // for client metric registration
@@ -40,6 +33,7 @@ import (
 
 	"github.com/NVIDIA/k8s-dra-driver-gpu/internal/info"
 	"github.com/NVIDIA/k8s-dra-driver-gpu/pkg/flags"
+	"github.com/NVIDIA/k8s-dra-driver-gpu/pkg/metrics"
 )
 
 const (
@@ -47,16 +41,20 @@ const (
 )
 
 type Flags struct {
-	kubeClientConfig flags.KubeClientConfig
-	loggingConfig    *flags.LoggingConfig
+	kubeClientConfig   flags.KubeClientConfig
+	loggingConfig      *flags.LoggingConfig
+	httpEndpointConfig flags.HTTPEndpointConfig
+	leaderElection     flags.LeaderElectionConfig
 
 	podName   string
 	namespace string
 	imageName string
 
-	httpEndpoint string
-	metricsPath  string
-	profilePath  string
+	computeDomains bool
+
+	webhookEndpoint    string
+	webhookTLSCertFile string
+	webhookTLSKeyFile  string
 }
 
 type Config struct {
@@ -99,31 +97,41 @@ func newApp() *cli.App {
 			Destination: &flags.imageName,
 			EnvVars:     []string{"IMAGE_NAME"},
 		},
+		&cli.BoolFlag{
+			Name:        "compute-domains",
+			Usage:       "Manage ComputeDomains. If disabled, the controller only serves the webhook and metrics.",
+			Value:       true,
+			Destination: &flags.computeDomains,
+			EnvVars:     []string{"COMPUTE_DOMAINS"},
+		},
 		&cli.StringFlag{
-			Category:    "HTTP server:",
-			Name:        "http-endpoint",
-			Usage:       "The TCP network `address` where the HTTP server for diagnostics, including pprof and metrics will listen (example: `:8080`). The default is the empty string, which means the server is disabled.",
-			Destination: &flags.httpEndpoint,
-			EnvVars:     []string{"HTTP_ENDPOINT"},
+			Category:    "Webhook:",
+			Name:        "webhook-endpoint",
+			Usage:       "The TCP network `address` where the validating admission webhook for ResourceClaims and ResourceClaimTemplates will listen (example: `:8443`). The default is the empty string, which means the webhook is disabled.",
+			Destination: &flags.webhookEndpoint,
+			EnvVars:     []string{"WEBHOOK_ENDPOINT"},
 		},
 		&cli.StringFlag{
-			Category:    "HTTP server:",
-			Name:        "metrics-path",
-			Usage:       "The HTTP `path` where Prometheus metrics will be exposed, disabled if empty.",
-			Value:       "/metrics",
-			Destination: &flags.metricsPath,
-			EnvVars:     []string{"METRICS_PATH"},
+			Category:    "Webhook:",
+			Name:        "webhook-tls-cert-file",
+			Usage:       "The `file` containing the TLS certificate the webhook serves with.",
+			Value:       "/etc/webhook/tls/tls.crt",
+			Destination: &flags.webhookTLSCertFile,
+			EnvVars:     []string{"WEBHOOK_TLS_CERT_FILE"},
 		},
 		&cli.StringFlag{
-			Category:    "HTTP server:",
-			Name:        "pprof-path",
-			Usage:       "The HTTP `path` where pprof profiling will be available, disabled if empty.",
-			Destination: &flags.profilePath,
-			EnvVars:     []string{"PPROF_PATH"},
+			Category:    "Webhook:",
+			Name:        "webhook-tls-key-file",
+			Usage:       "The `file` containing the private key of the webhook TLS certificate.",
+			Value:       "/etc/webhook/tls/tls.key",
+			Destination: &flags.webhookTLSKeyFile,
+			EnvVars:     []string{"WEBHOOK_TLS_KEY_FILE"},
 		},
 	}
 
 	cliFlags = append(cliFlags, flags.kubeClientConfig.Flags()...)
+	cliFlags = append(cliFlags, flags.httpEndpointConfig.Flags()...)
+	cliFlags = append(cliFlags, flags.leaderElection.Flags()...)
 	cliFlags = append(cliFlags, flags.loggingConfig.Flags()...)
 
 	app := &cli.App{
@@ -136,6 +144,9 @@ func newApp() *cli.App {
 			if c.Args().Len() > 0 {
 				return fmt.Errorf("arguments not supported: %v", c.Args().Slice())
 			}
+			if err := flags.leaderElection.Validate(); err != nil {
+				return err
+			}
 			return flags.loggingConfig.Apply()
 		},
 		Action: func(c *cli.Context) error {
@@ -153,16 +164,33 @@ func newApp() *cli.App {
 				driverName: DriverName,
 			}
 
-			if flags.httpEndpoint != "" {
-				err = SetupHTTPEndpoint(config)
-				if err != nil {
-					return fmt.Errorf("create http endpoint: %w", err)
+			metrics.Register()
+			if err := flags.httpEndpointConfig.SetupHTTPEndpoint(mux); err != nil {
+				return fmt.Errorf("create http endpoint: %w", err)
+			}
+
+			if flags.webhookEndpoint != "" {
+				webhook := NewWebhook(config)
+				if err := webhook.Start(); err != nil {
+					return fmt.Errorf("start webhook: %w", err)
 				}
+				defer func() {
+					if err := webhook.Stop(); err != nil {
+						klog.Errorf("Error stopping webhook: %v", err)
+					}
+				}()
 			}
 
 			sigs := make(chan os.Signal, 1)
 			signal.Notify(sigs, syscall.SIGTERM, syscall.SIGINT)
 
+			// Without ComputeDomains to manage there is nothing to do but
+			// serve the webhook until we are told to stop.
+			if !flags.computeDomains {
+				<-sigs
+				return nil
+			}
+
 			errChan := make(chan error, 1)
 			controller := NewController(config)
 			ctx, cancel := context.WithCancel(c.Context)
@@ -190,52 +218,3 @@ func newApp() *cli.App {
 
 	return app
 }
-
-func SetupHTTPEndpoint(config *Config) error {
-	if config.flags.metricsPath != "" {
-		// To collect metrics data from the metric handler itself, we
-		// let it register itself and then collect from that registry.
-		reg := prometheus.NewRegistry()
-		gatherers := prometheus.Gatherers{
-			// Include Go runtime and process metrics:
-			// https://github.com/kubernetes/kubernetes/blob/9780d88cb6a4b5b067256ecb4abf56892093ee87/staging/src/k8s.io/component-base/metrics/legacyregistry/registry.go#L46-L49
-			legacyregistry.DefaultGatherer,
-		}
-		gatherers = append(gatherers, reg)
-
-		actualPath := path.Join("/", config.flags.metricsPath)
-		klog.InfoS("Starting metrics", "path", actualPath)
-		// This is similar to k8s.io/component-base/metrics HandlerWithReset
-		// except that we gather from multiple sources.
-		config.mux.Handle(actualPath,
-			promhttp.InstrumentMetricHandler(
-				reg,
-				promhttp.HandlerFor(gatherers, promhttp.HandlerOpts{})))
-	}
-
-	if config.flags.profilePath != "" {
-		actualPath := path.Join("/", config.flags.profilePath)
-		klog.InfoS("Starting profiling", "path", actualPath)
-		config.mux.HandleFunc(actualPath, pprof.Index)
-		config.mux.HandleFunc(path.Join(actualPath, "cmdline"), pprof.Cmdline)
-		config.mux.HandleFunc(path.Join(actualPath, "profile"), pprof.Profile)
-		config.mux.HandleFunc(path.Join(actualPath, "symbol"), pprof.Symbol)
-		config.mux.HandleFunc(path.Join(actualPath, "trace"), pprof.Trace)
-	}
-
-	listener, err := net.Listen("tcp", config.flags.httpEndpoint)
-	if err != nil {
-		return fmt.Errorf("listen on HTTP endpoint: %w", err)
-	}
-
-	go func() {
-		klog.InfoS("Starting HTTP server", "endpoint", config.flags.httpEndpoint)
-		err := http.Serve(listener, config.mux)
-		if err != nil {
-			klog.ErrorS(err, "HTTP server failed")
-			klog.FlushAndExit(klog.ExitFlushTimeout, 1)
-		}
-	}()
-
-	return nil
-}
@@ -68,6 +68,16 @@ func NewNodeManager(config *ManagerConfig, getComputeDomain GetComputeDomainFunc
 	return m
 }
 
+// StartInformers starts the informers of the NodeManager and waits for their
+// caches to sync.
+func (m *NodeManager) StartInformers(ctx context.Context) error {
+	m.factory.Start(ctx.Done())
+	if !cache.WaitForCacheSync(ctx.Done(), m.informer.HasSynced) {
+		return fmt.Errorf("NodeManager: informer cache sync failed")
+	}
+	return nil
+}
+
 func (m *NodeManager) Start(ctx context.Context) (rerr error) {
 	ctx, cancel := context.WithCancel(ctx)
 	m.cancelContext = cancel
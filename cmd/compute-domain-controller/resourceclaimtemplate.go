@@ -100,6 +100,16 @@ func newBaseResourceClaimTemplateManager(config *ManagerConfig, getComputeDomain
 	return m
 }
 
+// StartInformers starts the informers of the ResourceClaimTemplate manager and
+// waits for their caches to sync.
+func (m *BaseResourceClaimTemplateManager) StartInformers(ctx context.Context) error {
+	m.factory.Start(ctx.Done())
+	if !cache.WaitForCacheSync(ctx.Done(), m.informer.HasSynced) {
+		return fmt.Errorf("informer cache sync for ResourceClaimTemplate failed")
+	}
+	return nil
+}
+
 func (m *BaseResourceClaimTemplateManager) Start(ctx context.Context) (rerr error) {
 	ctx, cancel := context.WithCancel(ctx)
 	m.cancelContext = cancel
@@ -0,0 +1,231 @@
+/*
+ * Copyright (c) 2025 NVIDIA CORPORATION.  All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	nvapi "github.com/NVIDIA/k8s-dra-driver-gpu/api/nvidia.com/resource/v1beta1"
+)
+
+const (
+	// maxNodesInConditionMessage limits the number of node names listed in a
+	// condition message to keep it readable for large ComputeDomains.
+	maxNodesInConditionMessage = 10
+
+	// nodeHeartbeatTimeout is how long after its last observed heartbeat a
+	// node is considered gone and removed from the ComputeDomain. The daemons
+	// renew their heartbeat every minute.
+	nodeHeartbeatTimeout = 3 * time.Minute
+
+	// nodeJoinGracePeriod is how long after its last observed heartbeat a node
+	// is kept in the ComputeDomain even though no daemon pod is known for it.
+	// This covers the pod informer lagging behind a daemon that just joined.
+	nodeJoinGracePeriod = 30 * time.Second
+)
+
+// updateComputeDomainStatus aggregates the ComputeDomainMembers into the node
+// entries of the ComputeDomain, recomputes its conditions and node counts from
+// them and its DaemonSet (which is nil if it has not been created yet) and
+// writes them if they changed. The heartbeats of the nodes are only refreshed
+// if refreshHeartbeats is set, see getComputeDomainNodes. The ComputeDomain is
+// looked up on every call, so that a retry after a conflict picks up the
+// latest version rather than the one originally enqueued.
+func updateComputeDomainStatus(ctx context.Context, config *ManagerConfig, getComputeDomain GetComputeDomainFunc, cdUID string, ds *appsv1.DaemonSet, members []*nvapi.ComputeDomainMember, refreshHeartbeats bool) error {
+	cd, err := getComputeDomain(cdUID)
+	if err != nil {
+		return fmt.Errorf("error getting ComputeDomain: %w", err)
+	}
+	if cd == nil || cd.GetDeletionTimestamp() != nil {
+		return nil
+	}
+
+	newCD := cd.DeepCopy()
+	newCD.Status.Nodes = getComputeDomainNodes(cd.Namespace, members, cd.Status.Nodes, refreshHeartbeats)
+	setComputeDomainStatus(newCD, ds)
+
+	if equality.Semantic.DeepEqual(cd.Status, newCD.Status) {
+		return nil
+	}
+
+	if _, err := config.clientsets.Nvidia.ResourceV1beta1().ComputeDomains(newCD.Namespace).UpdateStatus(ctx, newCD, metav1.UpdateOptions{}); err != nil {
+		return fmt.Errorf("error updating ComputeDomain status: %w", err)
+	}
+
+	return nil
+}
+
+// getComputeDomainNodes returns the node entries for the ComputeDomainMembers
+// of a ComputeDomain, sorted by node name. Only members in the namespace of the
+// ComputeDomain are taken into account. Unless refreshHeartbeats is set, nodes
+// that are already listed in previous keep their heartbeat from there, so that
+// every heartbeat does not cause an update of the ComputeDomain, which goes
+// out to all its daemons.
+func getComputeDomainNodes(namespace string, members []*nvapi.ComputeDomainMember, previous []*nvapi.ComputeDomainNode, refreshHeartbeats bool) []*nvapi.ComputeDomainNode {
+	previousHeartbeats := make(map[string]*metav1.Time)
+	for _, node := range previous {
+		previousHeartbeats[node.Name] = node.LastHeartbeatTime
+	}
+
+	var nodes []*nvapi.ComputeDomainNode
+	for _, member := range members {
+		if member.Namespace != namespace {
+			continue
+		}
+		heartbeat, known := previousHeartbeats[member.Spec.NodeName]
+		if refreshHeartbeats || !known {
+			heartbeat = member.Spec.LastHeartbeatTime
+		}
+		nodes = append(nodes, &nvapi.ComputeDomainNode{
+			Name:              member.Spec.NodeName,
+			IPAddress:         member.Spec.IPAddress,
+			CliqueID:          member.Spec.CliqueID,
+			Status:            member.Spec.Status,
+			LastHeartbeatTime: heartbeat.DeepCopy(),
+		})
+	}
+	slices.SortFunc(nodes, func(a, b *nvapi.ComputeDomainNode) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return nodes
+}
+
+// pruneComputeDomainMembers splits the ComputeDomainMembers of a ComputeDomain
+// into the ones to keep and the ones to remove because their heartbeat
+// expired or their daemon pod is gone. Removing them makes the daemons on the
+// remaining nodes drop them from their IMEX daemon's config. lastObserved
+// returns when the controller last saw a member updated, which is compared to
+// now.
+func pruneComputeDomainMembers(members []*nvapi.ComputeDomainMember, daemonNodes sets.Set[string], lastObserved func(*nvapi.ComputeDomainMember) time.Time, now time.Time) (remaining, pruned []*nvapi.ComputeDomainMember) {
+	for _, member := range members {
+		sinceHeartbeat := now.Sub(lastObserved(member))
+
+		switch {
+		case sinceHeartbeat > nodeHeartbeatTimeout:
+		case !daemonNodes.Has(member.Spec.NodeName) && sinceHeartbeat > nodeJoinGracePeriod:
+		default:
+			remaining = append(remaining, member)
+			continue
+		}
+
+		pruned = append(pruned, member)
+	}
+	return remaining, pruned
+}
+
+// setComputeDomainStatus sets the conditions, node counts and overall status
+// of the ComputeDomain from its node entries, which are left untouched.
+func setComputeDomainStatus(cd *nvapi.ComputeDomain, ds *appsv1.DaemonSet) {
+	status := &cd.Status
+	status.ObservedGeneration = cd.Generation
+	status.ExpectedNodes = cd.Spec.NumNodes
+	minNodes := cd.Spec.GetMinNodes()
+
+	var notReadyNodes []string
+	status.ReadyNodes = 0
+	for _, node := range status.Nodes {
+		if node.Status == nvapi.ComputeDomainStatusReady {
+			status.ReadyNodes++
+		} else {
+			notReadyNodes = append(notReadyNodes, node.Name)
+		}
+	}
+	slices.Sort(notReadyNodes)
+
+	setCondition := func(conditionType string, ok bool, reason, message string) {
+		conditionStatus := metav1.ConditionFalse
+		if ok {
+			conditionStatus = metav1.ConditionTrue
+		}
+		meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+			Type:               conditionType,
+			Status:             conditionStatus,
+			ObservedGeneration: cd.Generation,
+			Reason:             reason,
+			Message:            message,
+		})
+	}
+
+	// Walk through the steps towards readiness in order. The Ready condition
+	// reports the first one that is not done yet.
+	ready := true
+	readyReason, readyMessage := "Ready", "ComputeDomain is ready"
+	step := func(conditionType string, ok bool, reason, message string) {
+		setCondition(conditionType, ok, reason, message)
+		if ready && !ok {
+			ready = false
+			readyReason, readyMessage = reason, message
+		}
+	}
+
+	if ds != nil {
+		step(nvapi.ComputeDomainConditionDaemonSetCreated, true, "DaemonSetCreated",
+			fmt.Sprintf("DaemonSet %s/%s created", ds.Namespace, ds.Name))
+	} else {
+		step(nvapi.ComputeDomainConditionDaemonSetCreated, false, "DaemonSetNotCreated",
+			"waiting for the DaemonSet to be created")
+	}
+
+	joinedMessage := fmt.Sprintf("%d of %d nodes joined", len(status.Nodes), cd.Spec.NumNodes)
+	if minNodes != cd.Spec.NumNodes {
+		joinedMessage += fmt.Sprintf(", at least %d required", minNodes)
+	}
+	if ds != nil {
+		joinedMessage += fmt.Sprintf(" (%d daemon pods scheduled, %d ready)", ds.Status.CurrentNumberScheduled, ds.Status.NumberReady)
+	}
+	if len(status.Nodes) >= minNodes {
+		step(nvapi.ComputeDomainConditionAllNodesJoined, true, "AllNodesJoined", joinedMessage)
+	} else {
+		step(nvapi.ComputeDomainConditionAllNodesJoined, false, "WaitingForNodes", joinedMessage)
+	}
+
+	quorumMessage := fmt.Sprintf("%d of %d IMEX daemons ready", status.ReadyNodes, len(status.Nodes))
+	if len(notReadyNodes) > 0 {
+		quorumMessage += "; not ready: " + formatNodeNames(notReadyNodes)
+	}
+	if status.ReadyNodes >= minNodes {
+		step(nvapi.ComputeDomainConditionIMEXQuorum, true, "QuorumReached", quorumMessage)
+	} else {
+		step(nvapi.ComputeDomainConditionIMEXQuorum, false, "WaitingForIMEXDaemons", quorumMessage)
+	}
+
+	setCondition(nvapi.ComputeDomainConditionReady, ready, readyReason, readyMessage)
+
+	// Keep the plain status field for existing consumers.
+	if ready {
+		status.Status = nvapi.ComputeDomainStatusReady
+	} else {
+		status.Status = nvapi.ComputeDomainStatusNotReady
+	}
+}
+
+func formatNodeNames(names []string) string {
+	if len(names) <= maxNodesInConditionMessage {
+		return strings.Join(names, ", ")
+	}
+	return fmt.Sprintf("%s and %d more", strings.Join(names[:maxNodesInConditionMessage], ", "), len(names)-maxNodesInConditionMessage)
+}
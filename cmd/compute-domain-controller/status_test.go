@@ -0,0 +1,251 @@
+/*
+ * Copyright (c) 2025 NVIDIA CORPORATION.  All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/utils/ptr"
+
+	nvapi "github.com/NVIDIA/k8s-dra-driver-gpu/api/nvidia.com/resource/v1beta1"
+)
+
+func TestSetComputeDomainStatus(t *testing.T) {
+	ds := &appsv1.DaemonSet{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "nvidia", Name: "cd-abcde"},
+		Status:     appsv1.DaemonSetStatus{CurrentNumberScheduled: 3, NumberReady: 2},
+	}
+	node := func(name, status string) *nvapi.ComputeDomainNode {
+		return &nvapi.ComputeDomainNode{Name: name, Status: status}
+	}
+
+	testCases := []struct {
+		name               string
+		minNodes           *int
+		ds                 *appsv1.DaemonSet
+		nodes              []*nvapi.ComputeDomainNode
+		expectedStatus     string
+		expectedReadyNodes int
+		expectedConditions map[string]metav1.ConditionStatus
+		expectedReason     string
+		expectedMessage    string
+	}{
+		{
+			name:               "no DaemonSet",
+			expectedStatus:     nvapi.ComputeDomainStatusNotReady,
+			expectedConditions: map[string]metav1.ConditionStatus{nvapi.ComputeDomainConditionDaemonSetCreated: metav1.ConditionFalse},
+			expectedReason:     "DaemonSetNotCreated",
+		},
+		{
+			name:  "waiting for nodes",
+			ds:    ds,
+			nodes: []*nvapi.ComputeDomainNode{node("node-a", nvapi.ComputeDomainStatusNotReady)},
+			expectedConditions: map[string]metav1.ConditionStatus{
+				nvapi.ComputeDomainConditionDaemonSetCreated: metav1.ConditionTrue,
+				nvapi.ComputeDomainConditionAllNodesJoined:   metav1.ConditionFalse,
+				nvapi.ComputeDomainConditionIMEXQuorum:       metav1.ConditionFalse,
+			},
+			expectedStatus:  nvapi.ComputeDomainStatusNotReady,
+			expectedReason:  "WaitingForNodes",
+			expectedMessage: "1 of 3 nodes joined (3 daemon pods scheduled, 2 ready)",
+		},
+		{
+			name: "waiting for IMEX daemons",
+			ds:   ds,
+			nodes: []*nvapi.ComputeDomainNode{
+				node("node-c", ""),
+				node("node-a", nvapi.ComputeDomainStatusReady),
+				node("node-b", nvapi.ComputeDomainStatusNotReady),
+			},
+			expectedReadyNodes: 1,
+			expectedConditions: map[string]metav1.ConditionStatus{
+				nvapi.ComputeDomainConditionAllNodesJoined: metav1.ConditionTrue,
+				nvapi.ComputeDomainConditionIMEXQuorum:     metav1.ConditionFalse,
+			},
+			expectedStatus:  nvapi.ComputeDomainStatusNotReady,
+			expectedReason:  "WaitingForIMEXDaemons",
+			expectedMessage: "1 of 3 IMEX daemons ready; not ready: node-b, node-c",
+		},
+		{
+			name: "ready",
+			ds:   ds,
+			nodes: []*nvapi.ComputeDomainNode{
+				node("node-a", nvapi.ComputeDomainStatusReady),
+				node("node-b", nvapi.ComputeDomainStatusReady),
+				node("node-c", nvapi.ComputeDomainStatusReady),
+			},
+			expectedReadyNodes: 3,
+			expectedConditions: map[string]metav1.ConditionStatus{
+				nvapi.ComputeDomainConditionDaemonSetCreated: metav1.ConditionTrue,
+				nvapi.ComputeDomainConditionAllNodesJoined:   metav1.ConditionTrue,
+				nvapi.ComputeDomainConditionIMEXQuorum:       metav1.ConditionTrue,
+			},
+			expectedStatus:  nvapi.ComputeDomainStatusReady,
+			expectedReason:  "Ready",
+			expectedMessage: "ComputeDomain is ready",
+		},
+		{
+			name:     "elastic, waiting for minimum number of nodes",
+			minNodes: ptr.To(2),
+			ds:       ds,
+			nodes:    []*nvapi.ComputeDomainNode{node("node-a", nvapi.ComputeDomainStatusReady)},
+			expectedConditions: map[string]metav1.ConditionStatus{
+				nvapi.ComputeDomainConditionAllNodesJoined: metav1.ConditionFalse,
+			},
+			expectedReadyNodes: 1,
+			expectedStatus:     nvapi.ComputeDomainStatusNotReady,
+			expectedReason:     "WaitingForNodes",
+			expectedMessage:    "1 of 3 nodes joined, at least 2 required (3 daemon pods scheduled, 2 ready)",
+		},
+		{
+			name:     "elastic, ready with minimum number of nodes",
+			minNodes: ptr.To(2),
+			ds:       ds,
+			nodes: []*nvapi.ComputeDomainNode{
+				node("node-a", nvapi.ComputeDomainStatusReady),
+				node("node-b", nvapi.ComputeDomainStatusReady),
+			},
+			expectedConditions: map[string]metav1.ConditionStatus{
+				nvapi.ComputeDomainConditionAllNodesJoined: metav1.ConditionTrue,
+				nvapi.ComputeDomainConditionIMEXQuorum:     metav1.ConditionTrue,
+			},
+			expectedReadyNodes: 2,
+			expectedStatus:     nvapi.ComputeDomainStatusReady,
+			expectedReason:     "Ready",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cd := &nvapi.ComputeDomain{
+				ObjectMeta: metav1.ObjectMeta{Generation: 2},
+				Spec:       nvapi.ComputeDomainSpec{NumNodes: 3, MinNodes: tc.minNodes},
+				Status:     nvapi.ComputeDomainStatus{Nodes: tc.nodes},
+			}
+			setComputeDomainStatus(cd, tc.ds)
+
+			require.Equal(t, tc.expectedStatus, cd.Status.Status)
+			require.EqualValues(t, 2, cd.Status.ObservedGeneration)
+			require.Equal(t, 3, cd.Status.ExpectedNodes)
+			require.Equal(t, tc.expectedReadyNodes, cd.Status.ReadyNodes)
+			for conditionType, status := range tc.expectedConditions {
+				condition := meta.FindStatusCondition(cd.Status.Conditions, conditionType)
+				require.NotNil(t, condition, conditionType)
+				require.Equal(t, status, condition.Status, conditionType)
+			}
+
+			ready := meta.FindStatusCondition(cd.Status.Conditions, nvapi.ComputeDomainConditionReady)
+			require.NotNil(t, ready)
+			require.Equal(t, tc.expectedStatus == nvapi.ComputeDomainStatusReady, ready.Status == metav1.ConditionTrue)
+			require.EqualValues(t, 2, ready.ObservedGeneration)
+			require.Equal(t, tc.expectedReason, ready.Reason)
+			if tc.expectedMessage != "" {
+				require.Equal(t, tc.expectedMessage, ready.Message)
+			}
+		})
+	}
+}
+
+func TestGetComputeDomainNodes(t *testing.T) {
+	heartbeat := metav1.Now()
+	member := func(namespace, node string) *nvapi.ComputeDomainMember {
+		return &nvapi.ComputeDomainMember{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespace},
+			Spec: nvapi.ComputeDomainMemberSpec{
+				NodeName:          node,
+				IPAddress:         "10.0.0.1",
+				CliqueID:          "clique",
+				Status:            nvapi.ComputeDomainStatusReady,
+				LastHeartbeatTime: &heartbeat,
+			},
+		}
+	}
+
+	members := []*nvapi.ComputeDomainMember{
+		member("default", "node-b"),
+		member("other", "node-c"),
+		member("default", "node-a"),
+	}
+
+	nodes := getComputeDomainNodes("default", members, nil, false)
+	require.Len(t, nodes, 2)
+	require.Equal(t, "node-a", nodes[0].Name)
+	require.Equal(t, "node-b", nodes[1].Name)
+	require.Equal(t, &nvapi.ComputeDomainNode{
+		Name:              "node-a",
+		IPAddress:         "10.0.0.1",
+		CliqueID:          "clique",
+		Status:            nvapi.ComputeDomainStatusReady,
+		LastHeartbeatTime: &heartbeat,
+	}, nodes[0])
+
+	// Nodes that are already listed keep their heartbeat until it is
+	// refreshed, nodes that just joined get theirs right away.
+	previousHeartbeat := metav1.NewTime(heartbeat.Add(-time.Minute))
+	previous := []*nvapi.ComputeDomainNode{
+		{Name: "node-a", LastHeartbeatTime: &previousHeartbeat},
+	}
+	nodes = getComputeDomainNodes("default", members, previous, false)
+	require.Equal(t, &previousHeartbeat, nodes[0].LastHeartbeatTime)
+	require.Equal(t, &heartbeat, nodes[1].LastHeartbeatTime)
+
+	nodes = getComputeDomainNodes("default", members, previous, true)
+	require.Equal(t, &heartbeat, nodes[0].LastHeartbeatTime)
+	require.Equal(t, &heartbeat, nodes[1].LastHeartbeatTime)
+}
+
+func TestPruneComputeDomainMembers(t *testing.T) {
+	now := time.Now()
+	lastObserved := make(map[string]time.Time)
+	member := func(node string, sinceHeartbeat time.Duration) *nvapi.ComputeDomainMember {
+		lastObserved[node] = now.Add(-sinceHeartbeat)
+		// The heartbeats written by the daemons are not relied upon, as the
+		// clocks of their nodes may be off.
+		return &nvapi.ComputeDomainMember{Spec: nvapi.ComputeDomainMemberSpec{
+			NodeName:          node,
+			LastHeartbeatTime: ptr.To(metav1.NewTime(now.Add(time.Hour))),
+		}}
+	}
+
+	members := []*nvapi.ComputeDomainMember{
+		member("alive", time.Minute),
+		member("heartbeat-expired", nodeHeartbeatTimeout+time.Second),
+		member("just-joined", time.Second),
+		member("pod-gone", time.Minute),
+	}
+	daemonNodes := sets.New("alive", "heartbeat-expired")
+
+	remaining, pruned := pruneComputeDomainMembers(members, daemonNodes, func(member *nvapi.ComputeDomainMember) time.Time {
+		return lastObserved[member.Spec.NodeName]
+	}, now)
+
+	nodeNames := func(members []*nvapi.ComputeDomainMember) []string {
+		var names []string
+		for _, m := range members {
+			names = append(names, m.Spec.NodeName)
+		}
+		return names
+	}
+	require.Equal(t, []string{"alive", "just-joined"}, nodeNames(remaining))
+	require.Equal(t, []string{"heartbeat-expired", "pod-gone"}, nodeNames(pruned))
+}
@@ -0,0 +1,284 @@
+/*
+ * Copyright (c) 2025 NVIDIA CORPORATION.  All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	resourceapi "k8s.io/api/resource/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/klog/v2"
+
+	configapi "github.com/NVIDIA/k8s-dra-driver-gpu/api/nvidia.com/resource/v1beta1"
+)
+
+const (
+	GpuDriverName = "gpu.nvidia.com"
+
+	// WebhookValidatePath is the path at which ResourceClaims and
+	// ResourceClaimTemplates are validated.
+	WebhookValidatePath = "/validate-resource-claim-parameters"
+)
+
+var (
+	resourceClaimResource = metav1.GroupVersionResource{
+		Group:    resourceapi.SchemeGroupVersion.Group,
+		Version:  resourceapi.SchemeGroupVersion.Version,
+		Resource: "resourceclaims",
+	}
+	resourceClaimTemplateResource = metav1.GroupVersionResource{
+		Group:    resourceapi.SchemeGroupVersion.Group,
+		Version:  resourceapi.SchemeGroupVersion.Version,
+		Resource: "resourceclaimtemplates",
+	}
+)
+
+// Webhook is a validating admission webhook that rejects ResourceClaims and
+// ResourceClaimTemplates with opaque configs for our drivers that would only
+// fail once the claim gets prepared on a node.
+type Webhook struct {
+	waitGroup sync.WaitGroup
+	server    *http.Server
+
+	address     string
+	certificate *keyPairLoader
+}
+
+// NewWebhook creates a new Webhook serving on the configured address.
+func NewWebhook(config *Config) *Webhook {
+	mux := http.NewServeMux()
+	w := &Webhook{
+		address: config.flags.webhookEndpoint,
+		certificate: &keyPairLoader{
+			certFile: config.flags.webhookTLSCertFile,
+			keyFile:  config.flags.webhookTLSKeyFile,
+		},
+	}
+	w.server = &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+		TLSConfig: &tls.Config{
+			MinVersion:     tls.VersionTLS12,
+			GetCertificate: w.certificate.GetCertificate,
+		},
+	}
+	mux.HandleFunc("POST "+WebhookValidatePath, w.serveValidate)
+	return w
+}
+
+// Start serves the webhook in the background until Stop is called.
+func (w *Webhook) Start() error {
+	if _, err := w.certificate.GetCertificate(nil); err != nil {
+		return err
+	}
+
+	listener, err := net.Listen("tcp", w.address)
+	if err != nil {
+		return fmt.Errorf("listen on webhook endpoint: %w", err)
+	}
+
+	w.waitGroup.Add(1)
+	go func() {
+		defer w.waitGroup.Done()
+		klog.InfoS("Starting webhook server", "endpoint", w.address)
+		err := w.server.ServeTLS(listener, "", "")
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			klog.ErrorS(err, "Webhook server failed")
+			klog.FlushAndExit(klog.ExitFlushTimeout, 1)
+		}
+	}()
+
+	return nil
+}
+
+// Stop shuts down the webhook server, waiting for in-flight requests.
+func (w *Webhook) Stop() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err := w.server.Shutdown(ctx)
+	w.waitGroup.Wait()
+	return err
+}
+
+// keyPairLoader serves the TLS key pair of the webhook, reloading it whenever
+// its files change. The chart may regenerate the certificate whenever it is
+// rendered, and the kubelet then updates the mounted Secret in place, so the
+// controller picks up the new certificate without restarting.
+type keyPairLoader struct {
+	sync.Mutex
+	certFile string
+	keyFile  string
+
+	certificate *tls.Certificate
+	certModTime time.Time
+	keyModTime  time.Time
+}
+
+// GetCertificate returns the current key pair, for use as
+// tls.Config.GetCertificate. If the files have changed but cannot be loaded,
+// e.g. while they are being updated, the previous key pair is kept.
+func (l *keyPairLoader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	l.Lock()
+	defer l.Unlock()
+
+	certInfo, certErr := os.Stat(l.certFile)
+	keyInfo, keyErr := os.Stat(l.keyFile)
+	if err := errors.Join(certErr, keyErr); err != nil {
+		return l.keep(fmt.Errorf("error reading webhook TLS key pair: %w", err))
+	}
+	if l.certificate != nil && certInfo.ModTime().Equal(l.certModTime) && keyInfo.ModTime().Equal(l.keyModTime) {
+		return l.certificate, nil
+	}
+
+	certificate, err := tls.LoadX509KeyPair(l.certFile, l.keyFile)
+	if err != nil {
+		return l.keep(fmt.Errorf("error loading webhook TLS key pair: %w", err))
+	}
+	klog.InfoS("Loaded webhook TLS key pair", "certFile", l.certFile, "keyFile", l.keyFile)
+	l.certificate = &certificate
+	l.certModTime = certInfo.ModTime()
+	l.keyModTime = keyInfo.ModTime()
+	return l.certificate, nil
+}
+
+// keep returns the previously loaded key pair after failing to reload it, or
+// the error if there is none. It must be called with the lock held.
+func (l *keyPairLoader) keep(err error) (*tls.Certificate, error) {
+	if l.certificate == nil {
+		return nil, err
+	}
+	klog.ErrorS(err, "Keeping previous webhook TLS key pair")
+	return l.certificate, nil
+}
+
+func (w *Webhook) serveValidate(rw http.ResponseWriter, r *http.Request) {
+	var review admissionv1.AdmissionReview
+	if err := json.NewDecoder(r.Body).Decode(&review); err != nil {
+		http.Error(rw, fmt.Sprintf("error decoding AdmissionReview: %v", err), http.StatusBadRequest)
+		return
+	}
+	if review.Request == nil {
+		http.Error(rw, "AdmissionReview has no request", http.StatusBadRequest)
+		return
+	}
+
+	response := &admissionv1.AdmissionResponse{
+		UID:     review.Request.UID,
+		Allowed: true,
+	}
+	if err := validateAdmissionRequest(review.Request); err != nil {
+		klog.V(4).Infof("Rejecting %v %s/%s: %v", review.Request.Resource.Resource, review.Request.Namespace, review.Request.Name, err)
+		response.Allowed = false
+		response.Result = &metav1.Status{
+			Status:  metav1.StatusFailure,
+			Reason:  metav1.StatusReasonInvalid,
+			Code:    http.StatusUnprocessableEntity,
+			Message: err.Error(),
+		}
+	}
+
+	review.Request = nil
+	review.Response = response
+	rw.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(rw).Encode(&review); err != nil {
+		klog.Errorf("Error writing AdmissionReview response: %v", err)
+	}
+}
+
+// validateAdmissionRequest validates the opaque configs of the ResourceClaim
+// or ResourceClaimTemplate in the request.
+func validateAdmissionRequest(request *admissionv1.AdmissionRequest) error {
+	var spec *resourceapi.ResourceClaimSpec
+	switch request.Resource {
+	case resourceClaimResource:
+		var claim resourceapi.ResourceClaim
+		if err := json.Unmarshal(request.Object.Raw, &claim); err != nil {
+			return fmt.Errorf("error decoding ResourceClaim: %w", err)
+		}
+		spec = &claim.Spec
+	case resourceClaimTemplateResource:
+		var template resourceapi.ResourceClaimTemplate
+		if err := json.Unmarshal(request.Object.Raw, &template); err != nil {
+			return fmt.Errorf("error decoding ResourceClaimTemplate: %w", err)
+		}
+		spec = &template.Spec.Spec
+	default:
+		return fmt.Errorf("unexpected resource: %v", request.Resource)
+	}
+
+	return validateDeviceClaimConfigs(spec.Devices.Config)
+}
+
+// validateDeviceClaimConfigs decodes, normalizes and validates all opaque
+// configs for our drivers, the same way the kubelet plugins do when preparing
+// a claim. Configs for other drivers are skipped.
+func validateDeviceClaimConfigs(configs []resourceapi.DeviceClaimConfiguration) error {
+	var errs []error
+	for i, config := range configs {
+		if config.Opaque == nil {
+			continue
+		}
+		if config.Opaque.Driver != GpuDriverName && config.Opaque.Driver != DriverName {
+			continue
+		}
+		if err := validateOpaqueConfig(config.Opaque); err != nil {
+			errs = append(errs, fmt.Errorf("spec.devices.config[%d].opaque.parameters: %w", i, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func validateOpaqueConfig(opaque *resourceapi.OpaqueDeviceConfiguration) error {
+	decoded, err := runtime.Decode(configapi.Decoder, opaque.Parameters.Raw)
+	if err != nil {
+		return fmt.Errorf("error decoding config: %w", err)
+	}
+
+	// Only accept the configs that the kubelet plugin of the driver knows how
+	// to apply.
+	var driver string
+	switch decoded.(type) {
+	case *configapi.GpuConfig, *configapi.MigDeviceConfig, *configapi.VfioGpuConfig:
+		driver = GpuDriverName
+	case *configapi.ComputeDomainChannelConfig, *configapi.ComputeDomainDaemonConfig:
+		driver = DriverName
+	}
+	kind := decoded.GetObjectKind().GroupVersionKind().Kind
+	if driver != opaque.Driver {
+		return fmt.Errorf("%v is not supported by driver %v", kind, opaque.Driver)
+	}
+	config := decoded.(configapi.Interface)
+
+	if err := config.Normalize(); err != nil {
+		return fmt.Errorf("error normalizing config: %w", err)
+	}
+	if err := config.Validate(); err != nil {
+		return fmt.Errorf("error validating config: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,200 @@
+/*
+ * Copyright (c) 2025 NVIDIA CORPORATION.  All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admission/v1"
+	resourceapi "k8s.io/api/resource/v1beta1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestValidateAdmissionRequest(t *testing.T) {
+	newConfig := func(driver, parameters string) resourceapi.DeviceClaimConfiguration {
+		return resourceapi.DeviceClaimConfiguration{
+			DeviceConfiguration: resourceapi.DeviceConfiguration{
+				Opaque: &resourceapi.OpaqueDeviceConfiguration{
+					Driver:     driver,
+					Parameters: runtime.RawExtension{Raw: []byte(parameters)},
+				},
+			},
+		}
+	}
+
+	testCases := []struct {
+		name          string
+		configs       []resourceapi.DeviceClaimConfiguration
+		expectedError string
+	}{
+		{
+			name: "no configs",
+		},
+		{
+			name: "valid configs",
+			configs: []resourceapi.DeviceClaimConfiguration{
+				newConfig(GpuDriverName, `{"apiVersion":"resource.nvidia.com/v1beta1","kind":"GpuConfig","sharing":{"strategy":"MPS","mpsConfig":{"defaultActiveThreadPercentage":50}}}`),
+				newConfig(DriverName, `{"apiVersion":"resource.nvidia.com/v1beta1","kind":"ComputeDomainChannelConfig","domainID":"abc"}`),
+			},
+		},
+		{
+			name: "configs for other drivers are ignored",
+			configs: []resourceapi.DeviceClaimConfiguration{
+				newConfig("other.example.com", `{"foo":"bar"}`),
+			},
+		},
+		{
+			name: "invalid sharing strategy",
+			configs: []resourceapi.DeviceClaimConfiguration{
+				newConfig(GpuDriverName, `{"apiVersion":"resource.nvidia.com/v1beta1","kind":"GpuConfig","sharing":{"strategy":"Foo"}}`),
+			},
+			expectedError: "spec.devices.config[0].opaque.parameters: error validating config",
+		},
+		{
+			name: "thread percentage over 100",
+			configs: []resourceapi.DeviceClaimConfiguration{
+				newConfig(GpuDriverName, `{"apiVersion":"resource.nvidia.com/v1beta1","kind":"MigDeviceConfig"}`),
+				newConfig(GpuDriverName, `{"apiVersion":"resource.nvidia.com/v1beta1","kind":"GpuConfig","sharing":{"strategy":"MPS","mpsConfig":{"defaultActiveThreadPercentage":150}}}`),
+			},
+			expectedError: "spec.devices.config[1].opaque.parameters: error validating config",
+		},
+		{
+			name: "unknown field",
+			configs: []resourceapi.DeviceClaimConfiguration{
+				newConfig(GpuDriverName, `{"apiVersion":"resource.nvidia.com/v1beta1","kind":"GpuConfig","foo":"bar"}`),
+			},
+			expectedError: "error decoding config",
+		},
+		{
+			name: "config for the wrong driver",
+			configs: []resourceapi.DeviceClaimConfiguration{
+				newConfig(DriverName, `{"apiVersion":"resource.nvidia.com/v1beta1","kind":"GpuConfig"}`),
+			},
+			expectedError: "GpuConfig is not supported by driver " + DriverName,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			template := resourceapi.ResourceClaimTemplate{
+				Spec: resourceapi.ResourceClaimTemplateSpec{
+					Spec: resourceapi.ResourceClaimSpec{
+						Devices: resourceapi.DeviceClaim{
+							Config: tc.configs,
+						},
+					},
+				},
+			}
+			raw, err := json.Marshal(template)
+			require.NoError(t, err)
+
+			review := admissionv1.AdmissionReview{
+				Request: &admissionv1.AdmissionRequest{
+					UID:      "1234",
+					Resource: resourceClaimTemplateResource,
+					Object:   runtime.RawExtension{Raw: raw},
+				},
+			}
+			body, err := json.Marshal(review)
+			require.NoError(t, err)
+
+			w := &Webhook{}
+			recorder := httptest.NewRecorder()
+			w.serveValidate(recorder, httptest.NewRequest(http.MethodPost, WebhookValidatePath, bytes.NewReader(body)))
+			require.Equal(t, http.StatusOK, recorder.Code)
+
+			var response admissionv1.AdmissionReview
+			require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &response))
+			require.NotNil(t, response.Response)
+			require.EqualValues(t, "1234", response.Response.UID)
+			if tc.expectedError == "" {
+				require.True(t, response.Response.Allowed)
+				return
+			}
+			require.False(t, response.Response.Allowed)
+			require.Contains(t, response.Response.Result.Message, tc.expectedError)
+		})
+	}
+}
+
+func TestKeyPairLoader(t *testing.T) {
+	dir := t.TempDir()
+	loader := &keyPairLoader{
+		certFile: filepath.Join(dir, "tls.crt"),
+		keyFile:  filepath.Join(dir, "tls.key"),
+	}
+
+	// writeKeyPair writes a new self-signed key pair as if the Secret had
+	// been updated at the given time and returns its certificate.
+	writeKeyPair := func(name string, modTime time.Time) []byte {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		require.NoError(t, err)
+		template := &x509.Certificate{
+			SerialNumber: big.NewInt(1),
+			Subject:      pkix.Name{CommonName: name},
+			NotBefore:    time.Now(),
+			NotAfter:     time.Now().Add(time.Hour),
+		}
+		cert, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+		require.NoError(t, err)
+		keyDER, err := x509.MarshalECPrivateKey(key)
+		require.NoError(t, err)
+
+		require.NoError(t, os.WriteFile(loader.certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert}), 0600))
+		require.NoError(t, os.WriteFile(loader.keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600))
+		require.NoError(t, os.Chtimes(loader.certFile, modTime, modTime))
+		require.NoError(t, os.Chtimes(loader.keyFile, modTime, modTime))
+		return cert
+	}
+
+	_, err := loader.GetCertificate(nil)
+	require.ErrorContains(t, err, "error reading webhook TLS key pair")
+
+	now := time.Now()
+	first := writeKeyPair("first", now)
+	certificate, err := loader.GetCertificate(nil)
+	require.NoError(t, err)
+	require.Equal(t, first, certificate.Certificate[0])
+
+	// A regenerated key pair is picked up on the next handshake.
+	second := writeKeyPair("second", now.Add(time.Minute))
+	certificate, err = loader.GetCertificate(nil)
+	require.NoError(t, err)
+	require.Equal(t, second, certificate.Certificate[0])
+
+	// A key pair that cannot be loaded does not replace the current one.
+	require.NoError(t, os.WriteFile(loader.certFile, []byte("invalid"), 0600))
+	require.NoError(t, os.Chtimes(loader.certFile, now.Add(2*time.Minute), now.Add(2*time.Minute)))
+	certificate, err = loader.GetCertificate(nil)
+	require.NoError(t, err)
+	require.Equal(t, second, certificate.Certificate[0])
+}
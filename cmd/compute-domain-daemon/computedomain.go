@@ -23,6 +23,7 @@ import (
 	"sync"
 	"time"
 
+	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/tools/cache"
 	"k8s.io/klog/v2"
@@ -33,12 +34,19 @@ import (
 
 const (
 	informerResyncPeriod = 10 * time.Minute
+
+	// nodeHeartbeatPeriod is how often the daemon refreshes the heartbeat in
+	// its ComputeDomainMember when nothing else about it changes.
+	nodeHeartbeatPeriod = time.Minute
+
+	computeDomainLabelKey = "resource.nvidia.com/computeDomain"
 )
 
 type IPSet map[string]struct{}
 
-// ComputeDomainManager watches compute domains and updates their status with
-// info about the ComputeDomain daemon running on this node.
+// ComputeDomainManager watches compute domains and maintains the
+// ComputeDomainMember with info about the ComputeDomain daemon running on this
+// node.
 type ComputeDomainManager struct {
 	config        *ManagerConfig
 	waitGroup     sync.WaitGroup
@@ -49,6 +57,13 @@ type ComputeDomainManager struct {
 
 	previousNodes    []*nvapi.ComputeDomainNode
 	updatedNodesChan chan []*nvapi.ComputeDomainNode
+
+	nodeStatusMutex sync.Mutex
+	nodeStatus      string
+
+	// member is the ComputeDomainMember of this node as last written. It is
+	// only accessed from the work queue, which processes one item at a time.
+	member *nvapi.ComputeDomainMember
 }
 
 // NewComputeDomainManager creates a new ComputeDomainManager instance.
@@ -69,6 +84,7 @@ func NewComputeDomainManager(config *ManagerConfig) *ComputeDomainManager {
 		informer:         informer,
 		previousNodes:    []*nvapi.ComputeDomainNode{},
 		updatedNodesChan: make(chan []*nvapi.ComputeDomainNode),
+		nodeStatus:       nvapi.ComputeDomainStatusNotReady,
 	}
 
 	return m
@@ -132,72 +148,138 @@ func (m *ComputeDomainManager) onAddOrUpdate(ctx context.Context, obj any) error
 		return nil
 	}
 
-	// Update node info in ComputeDomain
-	if err := m.UpdateComputeDomainNodeInfo(ctx, cd); err != nil {
-		return fmt.Errorf("error updating node info in ComputeDomain: %w", err)
+	// The IMEX daemon follows the nodes aggregated into the ComputeDomain
+	// status, whether or not updating the member of this node succeeds
+	m.MaybePushNodesUpdate(cd)
+
+	// Update the ComputeDomainMember of this node
+	if err := m.UpdateComputeDomainMember(ctx, cd); err != nil {
+		return fmt.Errorf("error updating ComputeDomainMember: %w", err)
 	}
 
 	return nil
 }
 
-// UpdateComputeDomainNodeInfo updates the Nodes field in the ComputeDomain
-// with info about the ComputeDomain daemon running on this node.
-func (m *ComputeDomainManager) UpdateComputeDomainNodeInfo(ctx context.Context, cd *nvapi.ComputeDomain) error {
-	var nodeInfo *nvapi.ComputeDomainNode
+// SetNodeStatus sets the status of the IMEX daemon on this node and
+// reprocesses the ComputeDomain, so that the ComputeDomainMember gets updated
+// if the status changed or its heartbeat is due.
+func (m *ComputeDomainManager) SetNodeStatus(status string) {
+	m.nodeStatusMutex.Lock()
+	m.nodeStatus = status
+	m.nodeStatusMutex.Unlock()
 
-	// Create a deep copy of the ComputeDomain to avoid modifying the original
-	newCD := cd.DeepCopy()
+	for _, obj := range m.informer.GetStore().List() {
+		m.config.workQueue.Enqueue(obj, m.onAddOrUpdate)
+	}
+}
 
-	defer func() {
-		m.MaybePushNodesUpdate(newCD)
-	}()
+func (m *ComputeDomainManager) getNodeStatus() string {
+	m.nodeStatusMutex.Lock()
+	defer m.nodeStatusMutex.Unlock()
+	return m.nodeStatus
+}
 
-	// Try to find an existing entry for the current k8s node
-	for _, node := range newCD.Status.Nodes {
-		if node.Name == m.config.nodeName {
-			nodeInfo = node
-			break
+// UpdateComputeDomainMember creates or updates the ComputeDomainMember with
+// info about the ComputeDomain daemon running on this node. Each daemon only
+// writes its own member; the compute-domain-controller aggregates them into the
+// node entries of the ComputeDomain status.
+func (m *ComputeDomainManager) UpdateComputeDomainMember(ctx context.Context, cd *nvapi.ComputeDomain) error {
+	members := m.config.clientsets.Nvidia.ResourceV1beta1().ComputeDomainMembers(cd.Namespace)
+
+	// Look up the member if it is not known yet, e.g. after a restart of
+	// the daemon or a failed update
+	if m.member == nil {
+		member, err := members.Get(ctx, m.memberName(), metav1.GetOptions{})
+		switch {
+		case errors.IsNotFound(err):
+		case err != nil:
+			return fmt.Errorf("error getting ComputeDomainMember: %w", err)
+		default:
+			m.member = member
 		}
 	}
 
-	// If there is one and its IP is the same as this one, we are done
-	if nodeInfo != nil && nodeInfo.IPAddress == m.config.podIP {
+	// If it is up to date and its heartbeat is not due yet, we are done
+	nodeStatus := m.getNodeStatus()
+	if m.member != nil &&
+		m.member.Spec.IPAddress == m.config.podIP &&
+		m.member.Spec.Status == nodeStatus &&
+		m.member.Spec.LastHeartbeatTime != nil &&
+		time.Since(m.member.Spec.LastHeartbeatTime.Time) < nodeHeartbeatPeriod {
 		return nil
 	}
 
-	// If there isn't one, create one and append it to the list
-	if nodeInfo == nil {
-		nodeInfo = &nvapi.ComputeDomainNode{
-			Name:     m.config.nodeName,
-			CliqueID: m.config.cliqueID,
-		}
-		newCD.Status.Nodes = append(newCD.Status.Nodes, nodeInfo)
+	var newMember *nvapi.ComputeDomainMember
+	if m.member != nil {
+		newMember = m.member.DeepCopy()
+	} else {
+		newMember = m.newComputeDomainMember(cd)
 	}
 
-	// Unconditionally update its IP address. Note that the nodeInfo.IPAddress
-	// as of now translates into a pod IP address and may therefore change
-	// across pod restarts.
-	nodeInfo.IPAddress = m.config.podIP
-
-	// Conditionally update its status
-	if newCD.Status.Status == "" {
-		newCD.Status.Status = nvapi.ComputeDomainStatusNotReady
+	// Unconditionally update its IP address. Note that the IP address as of
+	// now translates into a pod IP address and may therefore change across
+	// pod restarts.
+	newMember.Spec.IPAddress = m.config.podIP
+	newMember.Spec.Status = nodeStatus
+	now := metav1.Now()
+	newMember.Spec.LastHeartbeatTime = &now
+
+	var err error
+	if m.member != nil {
+		newMember, err = members.Update(ctx, newMember, metav1.UpdateOptions{})
+	} else {
+		newMember, err = members.Create(ctx, newMember, metav1.CreateOptions{})
 	}
-
-	// Update the status
-	if _, err := m.config.clientsets.Nvidia.ResourceV1beta1().ComputeDomains(newCD.Namespace).UpdateStatus(ctx, newCD, metav1.UpdateOptions{}); err != nil {
-		return fmt.Errorf("error updating nodes in ComputeDomain status: %w", err)
+	if err != nil {
+		// Look the member up again on the next attempt
+		m.member = nil
+		return fmt.Errorf("error writing ComputeDomainMember: %w", err)
 	}
 
+	m.member = newMember
 	return nil
 }
 
-// If we've reached the expected number of nodes and if there was actually a
+// newComputeDomainMember returns a new ComputeDomainMember for this node. It
+// is owned by the ComputeDomain, so that it gets garbage collected along with
+// it.
+func (m *ComputeDomainManager) newComputeDomainMember(cd *nvapi.ComputeDomain) *nvapi.ComputeDomainMember {
+	return &nvapi.ComputeDomainMember{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      m.memberName(),
+			Namespace: cd.Namespace,
+			Labels: map[string]string{
+				computeDomainLabelKey: string(cd.UID),
+			},
+			OwnerReferences: []metav1.OwnerReference{
+				{
+					APIVersion: nvapi.SchemeGroupVersion.String(),
+					Kind:       nvapi.ComputeDomainKind,
+					Name:       cd.Name,
+					UID:        cd.UID,
+				},
+			},
+		},
+		Spec: nvapi.ComputeDomainMemberSpec{
+			ComputeDomainName: cd.Name,
+			NodeName:          m.config.nodeName,
+			CliqueID:          m.config.cliqueID,
+		},
+	}
+}
+
+func (m *ComputeDomainManager) memberName() string {
+	return fmt.Sprintf("%s.%s", m.config.computeDomainUUID, m.config.nodeName)
+}
+
+// If we've reached the minimum number of nodes and if there was actually a
 // change compared to the previously known set of nodes: pass info to IMEX
-// daemon controller.
+// daemon controller. Once the first set of nodes has been passed on, any
+// later change is passed on as well, so that the IMEX daemon follows nodes
+// joining or leaving the ComputeDomain as it is scaled up or down.
 func (m *ComputeDomainManager) MaybePushNodesUpdate(cd *nvapi.ComputeDomain) {
-	if len(cd.Status.Nodes) != cd.Spec.NumNodes {
-		klog.Infof("numNodes: %d, nodes seen: %d", cd.Spec.NumNodes, len(cd.Status.Nodes))
+	if len(m.previousNodes) == 0 && len(cd.Status.Nodes) < cd.Spec.GetMinNodes() {
+		klog.V(6).Infof("minNodes: %d, nodes seen: %d", cd.Spec.GetMinNodes(), len(cd.Status.Nodes))
 		return
 	}
 
@@ -207,20 +289,30 @@ func (m *ComputeDomainManager) MaybePushNodesUpdate(cd *nvapi.ComputeDomain) {
 	// Compare sets (i.e., without paying attention to order). Note: the order
 	// of IP addresses written to the IMEX daemon's config file might matter (in
 	// the sense that if across config files the set is equal but the order is
-	// not: that may lead to an IMEX daemon startup error). Maybe we should
-	// perform a stable sort of IP addresses before writing them to the nodes
-	// config file.
+	// not: that may lead to an IMEX daemon startup error). That's why they are
+	// sorted before being written to the nodes config file.
 	if !maps.Equal(newIPs, previousIPs) {
 		klog.Infof("IP set changed: previous: %v; new: %v", previousIPs, newIPs)
 		m.previousNodes = cd.Status.Nodes
 		m.updatedNodesChan <- cd.Status.Nodes
 	} else {
-		klog.Infof("IP set did not change")
+		klog.V(6).Infof("IP set did not change")
+	}
+}
+
+// RemoveComputeDomainMember deletes the ComputeDomainMember of this node, so
+// that the daemons on the remaining nodes drop it from their IMEX daemon's
+// config.
+func (m *ComputeDomainManager) RemoveComputeDomainMember(ctx context.Context) error {
+	err := m.config.clientsets.Nvidia.ResourceV1beta1().ComputeDomainMembers(m.config.computeDomainNamespace).Delete(ctx, m.memberName(), metav1.DeleteOptions{})
+	if err != nil && !errors.IsNotFound(err) {
+		return fmt.Errorf("error deleting ComputeDomainMember: %w", err)
 	}
+	return nil
 }
 
 func (m *ComputeDomainManager) GetNodesUpdateChan() chan []*nvapi.ComputeDomainNode {
-	// Yields numNodes-size nodes updates.
+	// Yields nodes updates once at least minNodes nodes are present.
 	return m.updatedNodesChan
 }
 
@@ -0,0 +1,96 @@
+/*
+ * Copyright (c) 2025 NVIDIA CORPORATION.  All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"k8s.io/utils/ptr"
+
+	nvapi "github.com/NVIDIA/k8s-dra-driver-gpu/api/nvidia.com/resource/v1beta1"
+)
+
+func TestMaybePushNodesUpdate(t *testing.T) {
+	nodes := func(ips ...string) []*nvapi.ComputeDomainNode {
+		var nodes []*nvapi.ComputeDomainNode
+		for _, ip := range ips {
+			nodes = append(nodes, &nvapi.ComputeDomainNode{IPAddress: ip})
+		}
+		return nodes
+	}
+
+	// Each step updates the nodes of a ComputeDomain with 3 nodes, of
+	// which at least 2 are required.
+	steps := []struct {
+		name     string
+		nodes    []*nvapi.ComputeDomainNode
+		expected []*nvapi.ComputeDomainNode
+	}{
+		{
+			name:  "below minNodes",
+			nodes: nodes("10.0.0.1"),
+		},
+		{
+			name:     "minNodes reached",
+			nodes:    nodes("10.0.0.1", "10.0.0.2"),
+			expected: nodes("10.0.0.1", "10.0.0.2"),
+		},
+		{
+			name:  "same IPs in another order",
+			nodes: nodes("10.0.0.2", "10.0.0.1"),
+		},
+		{
+			name:     "node joins",
+			nodes:    nodes("10.0.0.1", "10.0.0.2", "10.0.0.3"),
+			expected: nodes("10.0.0.1", "10.0.0.2", "10.0.0.3"),
+		},
+		{
+			name:     "nodes leave below minNodes",
+			nodes:    nodes("10.0.0.3"),
+			expected: nodes("10.0.0.3"),
+		},
+		{
+			name:     "node changes its IP",
+			nodes:    nodes("10.0.0.4"),
+			expected: nodes("10.0.0.4"),
+		},
+	}
+
+	m := &ComputeDomainManager{
+		previousNodes:    []*nvapi.ComputeDomainNode{},
+		updatedNodesChan: make(chan []*nvapi.ComputeDomainNode, 1),
+	}
+	cd := &nvapi.ComputeDomain{
+		Spec: nvapi.ComputeDomainSpec{
+			NumNodes: 3,
+			MinNodes: ptr.To(2),
+		},
+	}
+
+	for _, step := range steps {
+		cd.Status.Nodes = step.nodes
+		m.MaybePushNodesUpdate(cd)
+
+		var pushed []*nvapi.ComputeDomainNode
+		select {
+		case pushed = <-m.updatedNodesChan:
+		default:
+		}
+		require.Equal(t, step.expected, pushed, step.name)
+	}
+}
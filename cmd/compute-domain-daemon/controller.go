@@ -19,12 +19,21 @@ package main
 import (
 	"context"
 	"fmt"
+	"time"
+
+	"k8s.io/klog/v2"
 
 	nvapi "github.com/NVIDIA/k8s-dra-driver-gpu/api/nvidia.com/resource/v1beta1"
 	"github.com/NVIDIA/k8s-dra-driver-gpu/pkg/flags"
 	"github.com/NVIDIA/k8s-dra-driver-gpu/pkg/workqueue"
 )
 
+const (
+	// memberRemovalTimeout bounds how long removing the ComputeDomainMember
+	// of this node may delay the shutdown of the daemon.
+	memberRemovalTimeout = 10 * time.Second
+)
+
 // ManagerConfig holds the configuration for the compute domain manager.
 type ManagerConfig struct {
 	workQueue              *workqueue.WorkQueue
@@ -94,6 +103,15 @@ func (c *Controller) Run(ctx context.Context) error {
 	// Start processing the workqueue
 	c.workQueue.Run(ctx)
 
+	// Leave the ComputeDomain, so that the remaining nodes reconfigure their
+	// IMEX daemons without this node. The context is done at this point, so
+	// give the removal its own deadline.
+	removeCtx, cancel := context.WithTimeout(context.Background(), memberRemovalTimeout)
+	defer cancel()
+	if err := c.computeDomainManager.RemoveComputeDomainMember(removeCtx); err != nil {
+		klog.Errorf("error removing ComputeDomainMember: %v", err)
+	}
+
 	// Stop the compute domain manager
 	if err := c.computeDomainManager.Stop(); err != nil {
 		return fmt.Errorf("failed to stop compute domain manager: %v", err)
@@ -102,9 +120,15 @@ func (c *Controller) Run(ctx context.Context) error {
 	return nil
 }
 
-// GetNodesUpdateChan() returns a channel that only ever yields a full set of nodes,
-// i.e. during startup this blocks until the expected number of nodes is present
-// in CD status.
+// GetNodesUpdateChan() returns a channel that yields the set of nodes whenever
+// it changes, i.e. during startup this blocks until the minimum number of nodes
+// is present in CD status.
 func (c *Controller) GetNodesUpdateChan() chan []*nvapi.ComputeDomainNode {
 	return c.computeDomainManager.GetNodesUpdateChan()
 }
+
+// SetNodeStatus sets the status reported in the entry of this node in the
+// ComputeDomain status.
+func (c *Controller) SetNodeStatus(status string) {
+	c.computeDomainManager.SetNodeStatus(status)
+}
@@ -23,9 +23,11 @@ import (
 	"os/exec"
 	"os/signal"
 	"path/filepath"
+	"slices"
 	"strings"
 	"sync"
 	"syscall"
+	"time"
 
 	"k8s.io/klog/v2"
 
@@ -41,6 +43,10 @@ const (
 	imexConfigPath  = "/etc/nvidia-imex/config.cfg"
 	imexBinaryPath  = "/usr/bin/nvidia-imex"
 	imexCtlPath     = "/usr/bin/nvidia-imex-ctl"
+
+	// imexStatusCheckPeriod is how often the readiness of the IMEX daemon is
+	// checked and reported in the node entry of the ComputeDomain.
+	imexStatusCheckPeriod = 10 * time.Second
 )
 
 type Flags struct {
@@ -198,7 +204,7 @@ func run(ctx context.Context, cancel context.CancelFunc, flags *Flags) error {
 	}()
 
 	// Start IMEXDaemonUpdateLoop() in goroutine (watches for CD status
-	// changes, and restarts the IMEX daemon as needed).
+	// changes, and starts or reloads the IMEX daemon as needed).
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
@@ -208,6 +214,14 @@ func run(ctx context.Context, cancel context.CancelFunc, flags *Flags) error {
 		}
 	}()
 
+	// Start IMEXDaemonStatusLoop() in goroutine (periodically reports the
+	// readiness of the IMEX daemon in the ComputeDomain status).
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		IMEXDaemonStatusLoop(ctx, controller)
+	}()
+
 	// Start child process watchdog in goroutine.
 	wg.Add(1)
 	go func() {
@@ -227,7 +241,11 @@ func run(ctx context.Context, cancel context.CancelFunc, flags *Flags) error {
 }
 
 // IMEXDaemonUpdateLoop() reacts to ComputeDomain status changes by updating the
-// IMEX daemon nodes config file and (re)starting the IMEX daemon process.
+// IMEX daemon nodes config file. The first update starts the IMEX daemon
+// process. Later ones, e.g. for nodes joining or leaving as the ComputeDomain
+// is scaled, make it reload the nodes config with SIGHUP rather than
+// restarting it, which would drop the connections to all other nodes and make
+// it not ready until they have been established again.
 func IMEXDaemonUpdateLoop(ctx context.Context, controller *Controller, cliqueID string, pm *ProcessManager) error {
 	for {
 		klog.Infof("wait for nodes update")
@@ -240,16 +258,56 @@ func IMEXDaemonUpdateLoop(ctx context.Context, controller *Controller, cliqueID
 				return fmt.Errorf("writeNodesConfig failed: %w", err)
 			}
 
-			klog.Infof("Got update, (re)start IMEX daemon")
-			if err := pm.Restart(); err != nil {
+			klog.Infof("Got update, start or reload IMEX daemon")
+			if err := pm.Reload(); err != nil {
 				// This might be a permanent problem, and retrying upon next update
 				// might be pointless. Terminate us.
-				return fmt.Errorf("error (re)starting IMEX daemon: %w", err)
+				return fmt.Errorf("error starting or reloading IMEX daemon: %w", err)
 			}
 		}
 	}
 }
 
+// IMEXDaemonStatusLoop() periodically checks if the IMEX daemon is ready and
+// passes the result on to the controller, which reports it in the node entry
+// of the ComputeDomain.
+func IMEXDaemonStatusLoop(ctx context.Context, controller *Controller) {
+	ticker := time.NewTicker(imexStatusCheckPeriod)
+	defer ticker.Stop()
+
+	var previousErr error
+	for {
+		err := checkIMEXDaemonReady(ctx)
+		if ctx.Err() != nil {
+			klog.Infof("shutdown: stop IMEXDaemonStatusLoop")
+			return
+		}
+
+		// Only log transitions, as the daemon is expected to be not ready
+		// until all nodes have joined.
+		if err != nil && (previousErr == nil || err.Error() != previousErr.Error()) {
+			klog.Infof("IMEX daemon not ready: %v", err)
+		}
+		if err == nil && previousErr != nil {
+			klog.Infof("IMEX daemon ready")
+		}
+		previousErr = err
+
+		if err != nil {
+			controller.SetNodeStatus(nvapi.ComputeDomainStatusNotReady)
+		} else {
+			controller.SetNodeStatus(nvapi.ComputeDomainStatusReady)
+		}
+
+		select {
+		case <-ctx.Done():
+			klog.Infof("shutdown: stop IMEXDaemonStatusLoop")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
 // check verifies if the node is IMEX capable and if so, checks if the IMEX daemon is ready.
 // It returns an error if any step fails.
 func check(ctx context.Context, cancel context.CancelFunc, flags *Flags) error {
@@ -258,6 +316,11 @@ func check(ctx context.Context, cancel context.CancelFunc, flags *Flags) error {
 		return nil
 	}
 
+	return checkIMEXDaemonReady(ctx)
+}
+
+// checkIMEXDaemonReady returns an error if the IMEX daemon is not ready.
+func checkIMEXDaemonReady(ctx context.Context) error {
 	// Get IMEX version to determine which flags to use
 	v, err := getIMEXVersion(ctx)
 	if err != nil {
@@ -303,15 +366,20 @@ func writeNodesConfig(cliqueID string, nodes []*nvapi.ComputeDomainNode) error {
 	}
 	defer f.Close()
 
-	// Write IPs for nodes in the same clique
+	// Write IPs for nodes in the same clique, in a stable order
 	//
 	// Note(JP): wo we need to apply this type of filtering also in the logic
 	// that checks if an IMEX daemon restart is required?
+	var ips []string
 	for _, node := range nodes {
 		if node.CliqueID == cliqueID {
-			if _, err := fmt.Fprintf(f, "%s\n", node.IPAddress); err != nil {
-				return fmt.Errorf("failed to write to nodes config file: %w", err)
-			}
+			ips = append(ips, node.IPAddress)
+		}
+	}
+	slices.Sort(ips)
+	for _, ip := range ips {
+		if _, err := fmt.Fprintf(f, "%s\n", ip); err != nil {
+			return fmt.Errorf("failed to write to nodes config file: %w", err)
 		}
 	}
 
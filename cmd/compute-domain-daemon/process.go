@@ -56,6 +56,24 @@ func (m *ProcessManager) Restart() error {
 	return m.start()
 }
 
+// Reload() starts the process if it has not been started yet. Otherwise, it
+// sends SIGHUP to the process to make it reload its configuration in place,
+// without interrupting it.
+func (m *ProcessManager) Reload() error {
+	m.Lock()
+	if m.handle == nil {
+		m.Unlock()
+		return m.start()
+	}
+	defer m.Unlock()
+
+	klog.Infof("Reload: send SIGHUP to pid %d", m.handle.Process.Pid)
+	if err := m.handle.Process.Signal(syscall.SIGHUP); err != nil {
+		return fmt.Errorf("pm: reload: could not send SIGHUP to child: %w", err)
+	}
+	return nil
+}
+
 func (m *ProcessManager) start() error {
 	m.Lock()
 	defer m.Unlock()
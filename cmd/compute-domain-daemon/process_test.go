@@ -0,0 +1,53 @@
+/*
+ * Copyright (c) 2025 NVIDIA CORPORATION.  All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestProcessManagerReload(t *testing.T) {
+	// The script records its start and every reload in a file.
+	events := filepath.Join(t.TempDir(), "events")
+	script := `trap 'echo reload >> "$0"' HUP; echo start >> "$0"; while :; do sleep 0.01; done`
+	m := NewProcessManager([]string{"sh", "-c", script, events})
+	waitForEvents := func(expected string) {
+		require.Eventually(t, func() bool {
+			content, err := os.ReadFile(events)
+			return err == nil && string(content) == expected
+		}, 10*time.Second, 10*time.Millisecond)
+	}
+
+	// The first reload starts the process.
+	require.NoError(t, m.Reload())
+	defer func() {
+		require.NoError(t, m.stop())
+	}()
+	pid := m.handle.Process.Pid
+	waitForEvents("start\n")
+
+	// Later ones make it reload without restarting it.
+	require.NoError(t, m.Reload())
+	waitForEvents("start\nreload\n")
+	require.Equal(t, pid, m.handle.Process.Pid)
+	require.False(t, m.lost())
+}
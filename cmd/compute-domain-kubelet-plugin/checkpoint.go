@@ -21,6 +21,8 @@ type CheckpointV1 struct {
 type PreparedClaimsByUID map[string]PreparedClaim
 
 type PreparedClaim struct {
+	Name            string                          `json:"name,omitempty"`
+	Namespace       string                          `json:"namespace,omitempty"`
 	Status          resourceapi.ResourceClaimStatus `json:"status,omitempty"`
 	PreparedDevices PreparedDevices                 `json:"preparedDevices,omitempty"`
 }
@@ -26,6 +26,7 @@ import (
 	"text/template"
 	"time"
 
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/tools/cache"
 	"k8s.io/klog/v2"
@@ -240,10 +241,29 @@ func (m *ComputeDomainManager) AssertComputeDomainReady(ctx context.Context, cdU
 	}
 
 	if cd.Status.Status != nvapi.ComputeDomainStatusReady {
+		if ready := meta.FindStatusCondition(cd.Status.Conditions, nvapi.ComputeDomainConditionReady); ready != nil && ready.Message != "" {
+			return fmt.Errorf("ComputeDomain not Ready: %s", ready.Message)
+		}
 		return fmt.Errorf("ComputeDomain not Ready")
 	}
 
-	return nil
+	// An elastic ComputeDomain may already be Ready while the daemon on this
+	// node has not joined it yet, so wait for the IMEX daemon on this node as
+	// well. Nodes without a clique ID never run one.
+	if m.cliqueID == "" {
+		return nil
+	}
+	for _, node := range cd.Status.Nodes {
+		if node.Name != m.config.flags.nodeName {
+			continue
+		}
+		if node.Status != nvapi.ComputeDomainStatusReady {
+			return fmt.Errorf("IMEX daemon on node %s not Ready", node.Name)
+		}
+		return nil
+	}
+
+	return fmt.Errorf("node %s has not joined the ComputeDomain yet", m.config.flags.nodeName)
 }
 
 func (m *ComputeDomainManager) AssertComputeDomainNamespace(ctx context.Context, claimNamespace, cdUID string) error {
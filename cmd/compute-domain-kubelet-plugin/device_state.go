@@ -31,6 +31,7 @@ import (
 	cdiapi "tags.cncf.io/container-device-interface/pkg/cdi"
 
 	configapi "github.com/NVIDIA/k8s-dra-driver-gpu/api/nvidia.com/resource/v1beta1"
+	"github.com/NVIDIA/k8s-dra-driver-gpu/pkg/metrics"
 )
 
 type OpaqueDeviceConfig struct {
@@ -57,7 +58,7 @@ type DeviceState struct {
 
 func NewDeviceState(ctx context.Context, config *Config) (*DeviceState, error) {
 	containerDriverRoot := root(config.flags.containerDriverRoot)
-	nvdevlib, err := newDeviceLib(containerDriverRoot)
+	nvdevlib, err := newDeviceLib(containerDriverRoot, config.nvmllib)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create device library: %w", err)
 	}
@@ -117,6 +118,12 @@ func NewDeviceState(ctx context.Context, config *Config) (*DeviceState, error) {
 
 	for _, c := range checkpoints {
 		if c == DriverPluginCheckpointFileBasename {
+			checkpoint := newCheckpoint()
+			if err := state.checkpointManager.GetCheckpoint(DriverPluginCheckpointFileBasename, checkpoint); err != nil {
+				klog.Errorf("Unable to count prepared claims: unable to get checkpoint: %v", err)
+				return state, nil
+			}
+			setPreparedClaimsMetric(checkpoint.V1.PreparedClaims)
 			return state, nil
 		}
 	}
@@ -125,6 +132,7 @@ func NewDeviceState(ctx context.Context, config *Config) (*DeviceState, error) {
 	if err := state.checkpointManager.CreateCheckpoint(DriverPluginCheckpointFileBasename, checkpoint); err != nil {
 		return nil, fmt.Errorf("unable to sync to checkpoint: %v", err)
 	}
+	setPreparedClaimsMetric(checkpoint.V1.PreparedClaims)
 
 	return state, nil
 }
@@ -137,7 +145,7 @@ func (s *DeviceState) Prepare(ctx context.Context, claim *resourceapi.ResourceCl
 
 	checkpoint := newCheckpoint()
 	if err := s.checkpointManager.GetCheckpoint(DriverPluginCheckpointFileBasename, checkpoint); err != nil {
-		return nil, fmt.Errorf("unable to get checkpoint: %w", err)
+		return nil, metrics.WithErrorReason(metrics.ErrorReasonCheckpoint, fmt.Errorf("unable to get checkpoint: %w", err))
 	}
 
 	preparedClaim, exists := checkpoint.V1.PreparedClaims[claimUID]
@@ -151,23 +159,26 @@ func (s *DeviceState) Prepare(ctx context.Context, claim *resourceapi.ResourceCl
 
 	preparedDevices, err := s.prepareDevices(ctx, claim)
 	if err != nil {
-		return nil, fmt.Errorf("prepare devices failed: %w", err)
+		return nil, metrics.WithErrorReason(metrics.ErrorReasonDevice, fmt.Errorf("prepare devices failed: %w", err))
 	}
 
 	if err := s.cdi.CreateClaimSpecFile(claimUID, preparedDevices); err != nil {
-		return nil, fmt.Errorf("unable to create CDI spec file for claim: %w", err)
+		return nil, metrics.WithErrorReason(metrics.ErrorReasonCDI, fmt.Errorf("unable to create CDI spec file for claim: %w", err))
 	}
 
 	// Add ResourceClaimStatus API object to node-local checkpoint: the
 	// 'unprepare' code path must use local state exclusively (ResourceClaim
 	// object might have been deleted from the API server).
 	checkpoint.V1.PreparedClaims[claimUID] = PreparedClaim{
+		Name:            claim.Name,
+		Namespace:       claim.Namespace,
 		Status:          claim.Status,
 		PreparedDevices: preparedDevices,
 	}
 	if err := s.checkpointManager.CreateCheckpoint(DriverPluginCheckpointFileBasename, checkpoint); err != nil {
-		return nil, fmt.Errorf("unable to create checkpoint: %w", err)
+		return nil, metrics.WithErrorReason(metrics.ErrorReasonCheckpoint, fmt.Errorf("unable to create checkpoint: %w", err))
 	}
+	setPreparedClaimsMetric(checkpoint.V1.PreparedClaims)
 	klog.V(6).Infof("checkpoint written for claim %v", claimUID)
 
 	return preparedDevices.GetDevices(), nil
@@ -182,7 +193,7 @@ func (s *DeviceState) Unprepare(ctx context.Context, claimRef kubeletplugin.Name
 	// Rely on local checkpoint state for ability to clean up.
 	checkpoint := newCheckpoint()
 	if err := s.checkpointManager.GetCheckpoint(DriverPluginCheckpointFileBasename, checkpoint); err != nil {
-		return fmt.Errorf("unable to get checkpoint: %w", err)
+		return metrics.WithErrorReason(metrics.ErrorReasonCheckpoint, fmt.Errorf("unable to get checkpoint: %w", err))
 	}
 
 	pc, exists := checkpoint.V1.PreparedClaims[claimUID]
@@ -208,33 +219,47 @@ func (s *DeviceState) Unprepare(ctx context.Context, claimRef kubeletplugin.Name
 			metav1.GetOptions{})
 
 		if err != nil {
-			return permanentError{fmt.Errorf("failed to fetch ResourceClaim %s: %w", claimRef.String(), err)}
+			return metrics.WithErrorReason(metrics.ErrorReasonInvalidClaim, permanentError{fmt.Errorf("failed to fetch ResourceClaim %s: %w", claimRef.String(), err)})
 		}
 		if claim.Status.Allocation == nil {
-			return permanentError{fmt.Errorf("no allocation set in ResourceClaim %s", claim.String())}
+			return metrics.WithErrorReason(metrics.ErrorReasonInvalidClaim, permanentError{fmt.Errorf("no allocation set in ResourceClaim %s", claim.String())})
 		}
 		pc.Status = claim.Status
 	}
 
 	if err := s.unprepareDevices(ctx, &pc.Status); err != nil {
-		return fmt.Errorf("unprepare devices failed: %w", err)
+		return metrics.WithErrorReason(metrics.ErrorReasonDevice, fmt.Errorf("unprepare devices failed: %w", err))
 	}
 
 	err := s.cdi.DeleteClaimSpecFile(claimUID)
 	if err != nil {
-		return fmt.Errorf("unable to delete CDI spec file for claim: %w", err)
+		return metrics.WithErrorReason(metrics.ErrorReasonCDI, fmt.Errorf("unable to delete CDI spec file for claim: %w", err))
 	}
 
 	// Write new checkpoint reflecting that all devices for this claim have been
 	// unprepared (by virtue of removing its UID from all mappings).
 	delete(checkpoint.V1.PreparedClaims, claimUID)
 	if err := s.checkpointManager.CreateCheckpoint(DriverPluginCheckpointFileBasename, checkpoint); err != nil {
-		return fmt.Errorf("create checkpoint failed: %w", err)
+		return metrics.WithErrorReason(metrics.ErrorReasonCheckpoint, fmt.Errorf("create checkpoint failed: %w", err))
 	}
+	setPreparedClaimsMetric(checkpoint.V1.PreparedClaims)
 
 	return nil
 }
 
+// GetPreparedClaims returns all claims currently recorded in the checkpoint.
+func (s *DeviceState) GetPreparedClaims() (PreparedClaimsByUID, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	checkpoint := newCheckpoint()
+	if err := s.checkpointManager.GetCheckpoint(DriverPluginCheckpointFileBasename, checkpoint); err != nil {
+		return nil, fmt.Errorf("unable to get checkpoint: %w", err)
+	}
+
+	return checkpoint.V1.PreparedClaims, nil
+}
+
 func (s *DeviceState) prepareDevices(ctx context.Context, claim *resourceapi.ResourceClaim) (PreparedDevices, error) {
 	// Generate a mapping of each OpaqueDeviceConfigs to the Device.Results it applies to
 	configResultsMap, err := s.getConfigResultsMap(&claim.Status)
@@ -572,3 +597,7 @@ func GetOpaqueDeviceConfigs(
 
 	return resultConfigs, nil
 }
+
+func setPreparedClaimsMetric(claims PreparedClaimsByUID) {
+	metrics.PreparedClaims.WithLabelValues(DriverName).Set(float64(len(claims)))
+}
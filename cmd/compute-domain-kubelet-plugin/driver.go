@@ -20,6 +20,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"net/http"
 	"sync"
 	"time"
 
@@ -31,6 +32,8 @@ import (
 	"k8s.io/klog/v2"
 
 	"github.com/NVIDIA/k8s-dra-driver-gpu/pkg/flock"
+	"github.com/NVIDIA/k8s-dra-driver-gpu/pkg/localserver"
+	"github.com/NVIDIA/k8s-dra-driver-gpu/pkg/metrics"
 	"github.com/NVIDIA/k8s-dra-driver-gpu/pkg/workqueue"
 )
 
@@ -45,6 +48,9 @@ const (
 	// that calls to nodePrepareResource() / nodeUnprepareResource() never
 	// interleave, node-globally.
 	DriverPrepUprepFlockPath = DriverPluginPath + "/pu.lock"
+	// DriverPluginLocalSocketPath is the path to a unix socket serving a
+	// node-local HTTP API for operating the plugin.
+	DriverPluginLocalSocketPath = DriverPluginPath + "/local.sock"
 )
 
 // permanentError defines an error indicating that it is permanent.
@@ -58,9 +64,11 @@ func isPermanentError(err error) bool {
 
 type driver struct {
 	client       coreclientset.Interface
+	nodeName     string
 	pluginhelper *kubeletplugin.Helper
 	state        *DeviceState
 	pulock       *flock.Flock
+	localServer  *localserver.Server
 }
 
 func NewDriver(ctx context.Context, config *Config) (*driver, error) {
@@ -70,9 +78,10 @@ func NewDriver(ctx context.Context, config *Config) (*driver, error) {
 	}
 
 	driver := &driver{
-		client: config.clientsets.Core,
-		state:  state,
-		pulock: flock.NewFlock(DriverPrepUprepFlockPath),
+		client:   config.clientsets.Core,
+		nodeName: config.flags.nodeName,
+		state:    state,
+		pulock:   flock.NewFlock(DriverPrepUprepFlockPath),
 	}
 
 	helper, err := kubeletplugin.Start(
@@ -103,6 +112,7 @@ func NewDriver(ctx context.Context, config *Config) (*driver, error) {
 			continue
 		}
 		resourceSlice.Devices = append(resourceSlice.Devices, device.GetDevice())
+		metrics.AllocatableDevices.WithLabelValues(DriverName, device.Type(), metrics.DeviceHealthy).Inc()
 	}
 
 	resources := resourceslice.DriverResources{
@@ -119,6 +129,13 @@ func NewDriver(ctx context.Context, config *Config) (*driver, error) {
 		return nil, err
 	}
 
+	// Allow the state of the plugin to be inspected through the local API.
+	driver.localServer = localserver.NewServer(DriverPluginLocalSocketPath)
+	driver.localServer.Handle("GET /status", http.HandlerFunc(driver.serveStatus))
+	if err := driver.localServer.Start(ctx); err != nil {
+		return nil, fmt.Errorf("error starting local server: %w", err)
+	}
+
 	return driver, nil
 }
 
@@ -126,6 +143,11 @@ func (d *driver) Shutdown() error {
 	if d == nil {
 		return nil
 	}
+	if d.localServer != nil {
+		if err := d.localServer.Stop(); err != nil {
+			klog.Errorf("Error stopping local server: %v", err)
+		}
+	}
 	if err := d.state.computeDomainManager.Stop(); err != nil {
 		return fmt.Errorf("error stopping ComputeDomainManager: %w", err)
 	}
@@ -140,12 +162,14 @@ func (d *driver) PrepareResourceClaims(ctx context.Context, claims []*resourceap
 	ctx, cancel := context.WithTimeout(ctx, ErrorRetryMaxTimeout)
 	workQueue := workqueue.New(workqueue.DefaultControllerRateLimiter())
 	results := make(map[types.UID]kubeletplugin.PrepareResult)
+	start := time.Now()
 
 	for _, claim := range claims {
 		wg.Add(1)
 		workQueue.EnqueueRaw(claim, func(ctx context.Context, obj any) error {
 			done, res := d.nodePrepareResource(ctx, claim)
 			if done {
+				metrics.ObservePrepare(DriverName, start, res.Err)
 				results[claim.UID] = res
 				wg.Done()
 				return nil
@@ -160,6 +184,14 @@ func (d *driver) PrepareResourceClaims(ctx context.Context, claims []*resourceap
 	}()
 
 	workQueue.Run(ctx)
+
+	// Claims that are not done yet have run out of retries.
+	for _, claim := range claims {
+		if _, done := results[claim.UID]; !done {
+			metrics.ObservePrepare(DriverName, start, ctx.Err())
+		}
+	}
+
 	return results, nil
 }
 
@@ -170,12 +202,14 @@ func (d *driver) UnprepareResourceClaims(ctx context.Context, claimRefs []kubele
 	ctx, cancel := context.WithTimeout(ctx, ErrorRetryMaxTimeout)
 	workQueue := workqueue.New(workqueue.DefaultControllerRateLimiter())
 	results := make(map[types.UID]error)
+	start := time.Now()
 
 	for _, claim := range claimRefs {
 		wg.Add(1)
 		workQueue.EnqueueRaw(claim, func(ctx context.Context, obj any) error {
 			done, err := d.nodeUnprepareResource(ctx, claim)
 			if done {
+				metrics.ObserveUnprepare(DriverName, start, err)
 				results[claim.UID] = err
 				wg.Done()
 				return nil
@@ -191,22 +225,26 @@ func (d *driver) UnprepareResourceClaims(ctx context.Context, claimRefs []kubele
 
 	workQueue.Run(ctx)
 
+	// Claims that are not done yet have run out of retries.
+	for _, claim := range claimRefs {
+		if _, done := results[claim.UID]; !done {
+			metrics.ObserveUnprepare(DriverName, start, ctx.Err())
+		}
+	}
+
 	return results, nil
 }
 
 func (d *driver) nodePrepareResource(ctx context.Context, claim *resourceapi.ResourceClaim) (bool, kubeletplugin.PrepareResult) {
-	release, err := d.pulock.Acquire(ctx, flock.WithTimeout(10*time.Second))
+	release, err := d.acquirePULock(ctx)
 	if err != nil {
-		res := kubeletplugin.PrepareResult{
-			Err: fmt.Errorf("error acquiring prep/unprep lock: %w", err),
-		}
-		return false, res
+		return false, kubeletplugin.PrepareResult{Err: err}
 	}
 	defer release()
 
 	if claim.Status.Allocation == nil {
 		res := kubeletplugin.PrepareResult{
-			Err: fmt.Errorf("no allocation set in ResourceClaim %s in namespace %s", claim.Name, claim.Namespace),
+			Err: metrics.WithErrorReason(metrics.ErrorReasonInvalidClaim, fmt.Errorf("no allocation set in ResourceClaim %s in namespace %s", claim.Name, claim.Namespace)),
 		}
 		return true, res
 	}
@@ -224,9 +262,9 @@ func (d *driver) nodePrepareResource(ctx context.Context, claim *resourceapi.Res
 }
 
 func (d *driver) nodeUnprepareResource(ctx context.Context, claimRef kubeletplugin.NamespacedObject) (bool, error) {
-	release, err := d.pulock.Acquire(ctx, flock.WithTimeout(10*time.Second))
+	release, err := d.acquirePULock(ctx)
 	if err != nil {
-		return false, fmt.Errorf("error acquiring prep/unprep lock: %w", err)
+		return false, err
 	}
 	defer release()
 
@@ -238,6 +276,18 @@ func (d *driver) nodeUnprepareResource(ctx context.Context, claimRef kubeletplug
 	return true, nil
 }
 
+// acquirePULock acquires the node-global prep/unprep lock, recording how long
+// it took.
+func (d *driver) acquirePULock(ctx context.Context) (func(), error) {
+	start := time.Now()
+	release, err := d.pulock.Acquire(ctx, flock.WithTimeout(10*time.Second))
+	metrics.FlockWaitDuration.WithLabelValues(DriverName).Observe(time.Since(start).Seconds())
+	if err != nil {
+		return nil, metrics.WithErrorReason(metrics.ErrorReasonLock, fmt.Errorf("error acquiring prep/unprep lock: %w", err))
+	}
+	return release, nil
+}
+
 // TODO: implement loop to remove CDI files from the CDI path for claimUIDs
 //       that have been removed from the AllocatedClaims map.
 // func (d *driver) cleanupCDIFiles(wg *sync.WaitGroup) chan error {
@@ -19,17 +19,23 @@ package main
 import (
 	"context"
 	"fmt"
+	"net/http"
 	"os"
 	"os/signal"
 	"path/filepath"
 	"syscall"
 
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
 	"github.com/urfave/cli/v2"
 
 	"k8s.io/klog/v2"
 
+	_ "k8s.io/component-base/metrics/prometheus/restclient" // for client metric registration
+	_ "k8s.io/component-base/metrics/prometheus/version"    // for version metric registration
+
 	"github.com/NVIDIA/k8s-dra-driver-gpu/internal/info"
 	"github.com/NVIDIA/k8s-dra-driver-gpu/pkg/flags"
+	"github.com/NVIDIA/k8s-dra-driver-gpu/pkg/metrics"
 )
 
 const (
@@ -39,8 +45,10 @@ const (
 )
 
 type Flags struct {
-	kubeClientConfig flags.KubeClientConfig
-	loggingConfig    *flags.LoggingConfig
+	kubeClientConfig   flags.KubeClientConfig
+	loggingConfig      *flags.LoggingConfig
+	nvmlConfig         flags.NvmlConfig
+	httpEndpointConfig flags.HTTPEndpointConfig
 
 	nodeName            string
 	namespace           string
@@ -53,6 +61,7 @@ type Flags struct {
 type Config struct {
 	flags      *Flags
 	clientsets flags.ClientSets
+	nvmllib    nvml.Interface
 }
 
 func main() {
@@ -70,7 +79,6 @@ func newApp() *cli.App {
 		&cli.StringFlag{
 			Name:        "node-name",
 			Usage:       "The name of the node to be worked on.",
-			Required:    true,
 			Destination: &flags.nodeName,
 			EnvVars:     []string{"NODE_NAME"},
 		},
@@ -112,6 +120,8 @@ func newApp() *cli.App {
 	}
 	cliFlags = append(cliFlags, flags.kubeClientConfig.Flags()...)
 	cliFlags = append(cliFlags, flags.loggingConfig.Flags()...)
+	cliFlags = append(cliFlags, flags.nvmlConfig.Flags()...)
+	cliFlags = append(cliFlags, flags.httpEndpointConfig.Flags()...)
 
 	app := &cli.App{
 		Name:            "compute-domain-kubelet-plugin",
@@ -119,8 +129,11 @@ func newApp() *cli.App {
 		ArgsUsage:       " ",
 		HideHelpCommand: true,
 		Flags:           cliFlags,
+		Commands: []*cli.Command{
+			newStatusCommand(),
+		},
 		Before: func(c *cli.Context) error {
-			if c.Args().Len() > 0 {
+			if c.Args().Len() > 0 && !isCommand(c) {
 				return fmt.Errorf("arguments not supported: %v", c.Args().Slice())
 			}
 			return flags.loggingConfig.Apply()
@@ -128,14 +141,34 @@ func newApp() *cli.App {
 		Action: func(c *cli.Context) error {
 			ctx := c.Context
 
+			// These flags are only needed for running the plugin, so they
+			// are checked here rather than marked as required, which would
+			// apply to the subcommands as well.
+			for _, name := range []string{"node-name"} {
+				if !c.IsSet(name) {
+					return fmt.Errorf("required flag %q not set", name)
+				}
+			}
+
 			clientSets, err := flags.kubeClientConfig.NewClientSets()
 			if err != nil {
 				return fmt.Errorf("create client: %w", err)
 			}
 
+			nvmllib, err := flags.nvmlConfig.NewMockInterface()
+			if err != nil {
+				return fmt.Errorf("create mock NVML: %w", err)
+			}
+
 			config := &Config{
 				flags:      flags,
 				clientsets: clientSets,
+				nvmllib:    nvmllib,
+			}
+
+			metrics.Register()
+			if err := flags.httpEndpointConfig.SetupHTTPEndpoint(http.NewServeMux()); err != nil {
+				return fmt.Errorf("create http endpoint: %w", err)
 			}
 
 			return StartPlugin(ctx, config)
@@ -152,6 +185,12 @@ func newApp() *cli.App {
 	return app
 }
 
+// isCommand returns true if the first argument names one of the commands of
+// the app rather than being a stray argument.
+func isCommand(c *cli.Context) bool {
+	return c.Args().Present() && c.App.Command(c.Args().First()) != nil
+}
+
 // StartPlugin initializes and runs the compute domain kubelet plugin.
 func StartPlugin(ctx context.Context, config *Config) error {
 	// Create the plugin directory
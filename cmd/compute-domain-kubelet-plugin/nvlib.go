@@ -61,7 +61,19 @@ type nvcapDeviceInfo struct {
 	path   string
 }
 
-func newDeviceLib(driverRoot root) (*deviceLib, error) {
+// newDeviceLib returns a deviceLib backed by the given NVML library. If
+// nvmllib is nil, the NVML library of the driver installed under driverRoot
+// is used.
+func newDeviceLib(driverRoot root, nvmllib nvml.Interface) (*deviceLib, error) {
+	if nvmllib != nil {
+		d := deviceLib{
+			Interface: nvdev.New(nvmllib),
+			nvmllib:   nvmllib,
+			devRoot:   driverRoot.getDevRoot(),
+		}
+		return &d, nil
+	}
+
 	driverLibraryPath, err := driverRoot.getDriverLibraryPath()
 	if err != nil {
 		return nil, fmt.Errorf("failed to locate driver libraries: %w", err)
@@ -74,7 +86,7 @@ func newDeviceLib(driverRoot root) (*deviceLib, error) {
 
 	// We construct an NVML library specifying the path to libnvidia-ml.so.1
 	// explicitly so that we don't have to rely on the library path.
-	nvmllib := nvml.New(
+	nvmllib = nvml.New(
 		nvml.WithLibraryPath(driverLibraryPath),
 	)
 	d := deviceLib{
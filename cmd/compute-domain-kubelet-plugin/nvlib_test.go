@@ -0,0 +1,88 @@
+/*
+ * Copyright (c) 2025, NVIDIA CORPORATION.  All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/NVIDIA/k8s-dra-driver-gpu/pkg/nvmlmock"
+)
+
+func TestGetCliqueID(t *testing.T) {
+	testCases := []struct {
+		description      string
+		profile          string
+		modify           func(*nvmlmock.Profile)
+		expectedCliqueID string
+		expectedError    bool
+	}{
+		{
+			description:      "fabric attached",
+			profile:          "gb200",
+			expectedCliqueID: "7a9c3b2e-4f61-4d0a-9e8b-1c2d3e4f5a6b.1",
+		},
+		{
+			description: "not fabric attached",
+			profile:     "l4",
+		},
+		{
+			description: "GPUs in different cliques",
+			profile:     "gb200",
+			modify: func(p *nvmlmock.Profile) {
+				other := p.Gpus[0]
+				other.Fabric = &nvmlmock.FabricProfile{
+					ClusterUUID: other.Fabric.ClusterUUID,
+					CliqueID:    2,
+				}
+				p.Gpus = append(p.Gpus, other)
+			},
+			expectedError: true,
+		},
+		{
+			description: "fabric info unavailable",
+			profile:     "gb200",
+			modify: func(p *nvmlmock.Profile) {
+				p.Failures = nvmlmock.Failures{"GetGpuFabricInfo": "ERROR_UNKNOWN"}
+			},
+			expectedError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			profile, err := nvmlmock.LoadProfile(tc.profile)
+			require.NoError(t, err)
+			if tc.modify != nil {
+				tc.modify(profile)
+			}
+			server, err := nvmlmock.New(profile)
+			require.NoError(t, err)
+			nvdevlib, err := newDeviceLib(root(t.TempDir()), server)
+			require.NoError(t, err)
+
+			cliqueID, err := nvdevlib.getCliqueID()
+			if tc.expectedError {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expectedCliqueID, cliqueID)
+		})
+	}
+}
@@ -20,6 +20,8 @@ import (
 	"slices"
 
 	resourceapi "k8s.io/api/resource/v1beta1"
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/util/sets"
 )
 
 type AllocatableDevices map[string]*AllocatableDevice
@@ -59,6 +61,40 @@ func (d *AllocatableDevice) CanonicalIndex() string {
 	panic("unexpected type for AllocatableDevice")
 }
 
+// UUID returns the UUID of the device, which is empty for MIG devices that do
+// not exist yet.
+func (d *AllocatableDevice) UUID() string {
+	switch d.Type() {
+	case GpuDeviceType:
+		return d.Gpu.UUID
+	case MigDeviceType:
+		return d.Mig.UUID
+	}
+	panic("unexpected type for AllocatableDevice")
+}
+
+// ParentUUID returns the UUID of the full GPU backing the device.
+func (d *AllocatableDevice) ParentUUID() string {
+	switch d.Type() {
+	case GpuDeviceType:
+		return d.Gpu.UUID
+	case MigDeviceType:
+		return d.Mig.parent.UUID
+	}
+	panic("unexpected type for AllocatableDevice")
+}
+
+// ParentGpu returns the info of the full GPU backing the device.
+func (d *AllocatableDevice) ParentGpu() *GpuInfo {
+	switch d.Type() {
+	case GpuDeviceType:
+		return d.Gpu
+	case MigDeviceType:
+		return d.Mig.parent
+	}
+	panic("unexpected type for AllocatableDevice")
+}
+
 func (d *AllocatableDevice) GetDevice() resourceapi.Device {
 	switch d.Type() {
 	case GpuDeviceType:
@@ -69,6 +105,84 @@ func (d *AllocatableDevice) GetDevice() resourceapi.Device {
 	panic("unexpected type for AllocatableDevice")
 }
 
+// RenamedGpus returns the UUIDs of the GPUs in other that are named
+// differently in d or whose name d gives to a different GPU.
+func (d AllocatableDevices) RenamedGpus(other AllocatableDevices) sets.Set[string] {
+	names := make(map[string]string)
+	for name, device := range d {
+		if device.Type() == GpuDeviceType {
+			names[device.Gpu.UUID] = name
+		}
+	}
+
+	renamed := sets.New[string]()
+	for name, device := range other {
+		if device.Type() != GpuDeviceType {
+			continue
+		}
+		if existing, exists := d[name]; exists && existing.ParentUUID() != device.Gpu.UUID {
+			renamed.Insert(device.Gpu.UUID)
+		}
+		if existing, exists := names[device.Gpu.UUID]; exists && existing != name {
+			renamed.Insert(device.Gpu.UUID)
+		}
+	}
+	return renamed
+}
+
+// SkipGpuIndices renumbers the GPUs of freshly enumerated devices as if the
+// GPUs with the given indices, which are hidden from NVML, were still there.
+// It returns the devices under the names that result from their new indices.
+func (d AllocatableDevices) SkipGpuIndices(hidden []int) AllocatableDevices {
+	hidden = slices.Sorted(slices.Values(hidden))
+	indices := make(map[*GpuInfo]int)
+	for _, device := range d {
+		if device.Type() != GpuDeviceType {
+			continue
+		}
+		index := device.Gpu.index
+		for _, h := range hidden {
+			if h <= index {
+				index++
+			}
+		}
+		indices[device.Gpu] = index
+	}
+	for gpu, index := range indices {
+		gpu.index = index
+	}
+
+	renamed := make(AllocatableDevices)
+	for _, device := range d {
+		renamed[device.CanonicalName()] = device
+	}
+	return renamed
+}
+
+// Diff returns the names of the devices that were added, removed or changed
+// in other when compared to d.
+func (d AllocatableDevices) Diff(other AllocatableDevices) (added, removed, changed []string) {
+	for name, device := range other {
+		existing, exists := d[name]
+		if !exists {
+			added = append(added, name)
+			continue
+		}
+		if !apiequality.Semantic.DeepEqual(existing.GetDevice(), device.GetDevice()) {
+			changed = append(changed, name)
+		}
+	}
+	for name := range d {
+		if _, exists := other[name]; !exists {
+			removed = append(removed, name)
+		}
+	}
+	slices.Sort(added)
+	slices.Sort(removed)
+	slices.Sort(changed)
+	return added, removed, changed
+}
+
 func (d AllocatableDevices) GpuUUIDs() []string {
 	var uuids []string
 	for _, device := range d {
@@ -83,7 +197,7 @@ func (d AllocatableDevices) GpuUUIDs() []string {
 func (d AllocatableDevices) MigDeviceUUIDs() []string {
 	var uuids []string
 	for _, device := range d {
-		if device.Type() == MigDeviceType {
+		if device.Type() == MigDeviceType && device.Mig.Exists() {
 			uuids = append(uuids, device.Mig.UUID)
 		}
 	}
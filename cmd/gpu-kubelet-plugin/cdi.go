@@ -19,6 +19,10 @@ package main
 import (
 	"fmt"
 	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 
 	"github.com/sirupsen/logrus"
 
@@ -62,6 +66,10 @@ type CDIHandler struct {
 	vendor      string
 	deviceClass string
 	claimClass  string
+
+	// baseSpecMutex serializes regenerating the base spec, which claims
+	// being unprepared concurrently may do.
+	baseSpecMutex sync.Mutex
 }
 
 func NewCDIHandler(opts ...cdiOption) (*CDIHandler, error) {
@@ -139,7 +147,13 @@ func NewCDIHandler(opts ...cdiOption) (*CDIHandler, error) {
 	return h, nil
 }
 
+// CreateStandardDeviceSpecFile regenerates the base spec with the standard
+// device specs of the given devices. Concurrent calls are serialized, so that
+// the spec written last reflects the devices as they are at that time.
 func (cdi *CDIHandler) CreateStandardDeviceSpecFile(allocatable AllocatableDevices) error {
+	cdi.baseSpecMutex.Lock()
+	defer cdi.baseSpecMutex.Unlock()
+
 	// Initialize NVML in order to get the device edits.
 	if r := cdi.nvml.Init(); r != nvml.SUCCESS {
 		return fmt.Errorf("failed to initialize NVML: %v", r)
@@ -163,10 +177,21 @@ func (cdi *CDIHandler) CreateStandardDeviceSpecFile(allocatable AllocatableDevic
 		commonEdits.Env,
 		"NVIDIA_VISIBLE_DEVICES=void")
 
-	// Generate device specs for all full GPUs and MIG devices.
+	// Generate device specs for all full GPUs and MIG devices that NVML can
+	// see. GPUs passed through to virtual machines cannot be seen and shift
+	// the indices of all GPUs after them, so devices are looked up by UUID.
+	// MIG devices that are created on demand get their device specs in the
+	// claim spec.
 	var deviceSpecs []cdispec.Device
 	for _, device := range allocatable {
-		dspecs, err := cdi.nvcdiDevice.GetDeviceSpecsByID(device.CanonicalIndex())
+		if device.Mig != nil && !device.Mig.Exists() {
+			continue
+		}
+		if _, r := cdi.nvml.DeviceGetHandleByUUID(device.ParentUUID()); r == nvml.ERROR_NOT_FOUND {
+			klog.V(4).Infof("Leaving %s out of the base CDI spec: its GPU is not visible", device.CanonicalName())
+			continue
+		}
+		dspecs, err := cdi.nvcdiDevice.GetDeviceSpecsByID(device.UUID())
 		if err != nil {
 			return fmt.Errorf("unable to get device spec for %s: %w", device.CanonicalName(), err)
 		}
@@ -208,19 +233,47 @@ func (cdi *CDIHandler) CreateStandardDeviceSpecFile(allocatable AllocatableDevic
 }
 
 func (cdi *CDIHandler) CreateClaimSpecFile(claimUID string, preparedDevices PreparedDevices) error {
-	// Generate claim specific specs for each device.
-	var deviceSpecs []cdispec.Device
+	// MIG devices created as part of preparing this claim are not part of
+	// the base spec, so their device edits need to be generated here.
+	var created []string
 	for _, group := range preparedDevices {
-		// If there are no edits passed back as part of the device config state, skip it
-		if group.ConfigState.containerEdits == nil {
-			continue
+		for _, device := range group.Devices.MigDevices() {
+			if device.Mig.Created {
+				created = append(created, device.Mig.Info.UUID)
+			}
 		}
+	}
+
+	var commonEdits *cdiapi.ContainerEdits
+	createdEdits := make(map[string]*cdiapi.ContainerEdits)
+	if len(created) > 0 {
+		var err error
+		commonEdits, createdEdits, err = cdi.getCreatedMigDeviceEdits(created)
+		if err != nil {
+			return err
+		}
+	}
 
-		// Apply any edits passed back as part of the device config state to all devices
+	// Generate claim specific specs for each device.
+	var deviceSpecs []cdispec.Device
+	for _, group := range preparedDevices {
 		for _, device := range group.Devices {
+			var edits *cdiapi.ContainerEdits
+			if device.Mig != nil && device.Mig.Created {
+				edits = edits.Append(createdEdits[device.Mig.Info.UUID])
+			}
+
+			// Apply any edits passed back as part of the device config state to all devices
+			edits = edits.Append(group.ConfigState.containerEdits)
+
+			// If there are no claim specific edits for this device, skip it
+			if edits == nil {
+				continue
+			}
+
 			deviceSpec := cdispec.Device{
 				Name:           fmt.Sprintf("%s-%s", claimUID, device.CanonicalName()),
-				ContainerEdits: *group.ConfigState.containerEdits.ContainerEdits,
+				ContainerEdits: *edits.ContainerEdits,
 			}
 
 			deviceSpecs = append(deviceSpecs, deviceSpec)
@@ -232,11 +285,18 @@ func (cdi *CDIHandler) CreateClaimSpecFile(claimUID string, preparedDevices Prep
 		return nil
 	}
 
+	var specOptions []spec.Option
+	if commonEdits != nil {
+		specOptions = append(specOptions, spec.WithEdits(*commonEdits.ContainerEdits))
+	}
+
 	// Generate the claim specific device spec for this driver.
 	spec, err := spec.New(
-		spec.WithVendor(cdiVendor),
-		spec.WithClass(cdiClaimClass),
-		spec.WithDeviceSpecs(deviceSpecs),
+		append([]spec.Option{
+			spec.WithVendor(cdiVendor),
+			spec.WithClass(cdiClaimClass),
+			spec.WithDeviceSpecs(deviceSpecs),
+		}, specOptions...)...,
 	)
 	if err != nil {
 		return fmt.Errorf("failed to creat CDI spec: %w", err)
@@ -264,17 +324,75 @@ func (cdi *CDIHandler) CreateClaimSpecFile(claimUID string, preparedDevices Prep
 	return cdi.cache.WriteSpec(spec.Raw(), specName)
 }
 
+// getCreatedMigDeviceEdits returns the common edits and the per-device edits
+// for a set of MIG devices that were created after the base spec was written.
+func (cdi *CDIHandler) getCreatedMigDeviceEdits(uuids []string) (*cdiapi.ContainerEdits, map[string]*cdiapi.ContainerEdits, error) {
+	// Initialize NVML in order to get the device edits.
+	if r := cdi.nvml.Init(); r != nvml.SUCCESS {
+		return nil, nil, fmt.Errorf("failed to initialize NVML: %v", r)
+	}
+	defer func() {
+		if r := cdi.nvml.Shutdown(); r != nvml.SUCCESS {
+			klog.Warningf("failed to shutdown NVML: %v", r)
+		}
+	}()
+
+	commonEdits, err := cdi.nvcdiClaim.GetCommonEdits()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get common CDI spec edits: %w", err)
+	}
+	commonEdits.Env = append(
+		commonEdits.Env,
+		"NVIDIA_VISIBLE_DEVICES=void")
+
+	edits := make(map[string]*cdiapi.ContainerEdits)
+	for _, uuid := range uuids {
+		dspecs, err := cdi.nvcdiClaim.GetDeviceSpecsByID(uuid)
+		if err != nil {
+			return nil, nil, fmt.Errorf("unable to get device spec for %s: %w", uuid, err)
+		}
+		edits[uuid] = &cdiapi.ContainerEdits{ContainerEdits: &dspecs[0].ContainerEdits}
+	}
+
+	return commonEdits, edits, nil
+}
+
 func (cdi *CDIHandler) DeleteClaimSpecFile(claimUID string) error {
 	specName := cdiapi.GenerateTransientSpecName(cdiVendor, cdiClaimClass, claimUID)
 	return cdi.cache.RemoveSpec(specName)
 }
 
+// ListClaimSpecFiles returns the claim UIDs of all claim spec files in the
+// CDI root.
+func (cdi *CDIHandler) ListClaimSpecFiles() ([]string, error) {
+	entries, err := os.ReadDir(cdi.cdiRoot)
+	if err != nil {
+		return nil, fmt.Errorf("error reading CDI root '%s': %w", cdi.cdiRoot, err)
+	}
+
+	prefix := cdiapi.GenerateTransientSpecName(cdiVendor, cdiClaimClass, "")
+	var claimUIDs []string
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		claimUID := strings.TrimSuffix(strings.TrimPrefix(name, prefix), filepath.Ext(name))
+		claimUIDs = append(claimUIDs, claimUID)
+	}
+
+	return claimUIDs, nil
+}
+
 func (cdi *CDIHandler) GetStandardDevice(device *AllocatableDevice) string {
+	if device.Mig != nil && device.Mig.dynamic {
+		return ""
+	}
 	return cdiparser.QualifiedName(cdiVendor, cdiDeviceClass, device.CanonicalName())
 }
 
 func (cdi *CDIHandler) GetClaimDevice(claimUID string, device *AllocatableDevice, containerEdits *cdiapi.ContainerEdits) string {
-	if containerEdits == nil {
+	if containerEdits == nil && (device.Mig == nil || !device.Mig.dynamic) {
 		return ""
 	}
 	return cdiparser.QualifiedName(cdiVendor, cdiClaimClass, fmt.Sprintf("%s-%s", claimUID, device.CanonicalName()))
@@ -2,8 +2,13 @@ package main
 
 import (
 	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
 
 	resourceapi "k8s.io/api/resource/v1beta1"
+	"k8s.io/kubernetes/pkg/kubelet/checkpointmanager"
 	"k8s.io/kubernetes/pkg/kubelet/checkpointmanager/checksum"
 )
 
@@ -20,8 +25,75 @@ type CheckpointV1 struct {
 type PreparedClaimsByUID map[string]PreparedClaim
 
 type PreparedClaim struct {
+	Name            string                          `json:"name,omitempty"`
+	Namespace       string                          `json:"namespace,omitempty"`
 	Status          resourceapi.ResourceClaimStatus `json:"status,omitempty"`
 	PreparedDevices PreparedDevices                 `json:"preparedDevices,omitempty"`
+	// ScrubPending is set once the devices of the claim have been
+	// unprepared, for as long as scrubbing them has not succeeded.
+	ScrubPending bool `json:"scrubPending,omitempty"`
+}
+
+// DeviceHolders returns the UIDs of all claims holding the GPU or MIG device
+// with the given UUID.
+func (c PreparedClaimsByUID) DeviceHolders(uuid string) []string {
+	var claimUIDs []string
+	for claimUID, pc := range c {
+		if slices.Contains(pc.PreparedDevices.UUIDs(), uuid) {
+			claimUIDs = append(claimUIDs, claimUID)
+		}
+	}
+	slices.Sort(claimUIDs)
+	return claimUIDs
+}
+
+// ScrubPendingHolders returns the UIDs of all claims waiting for the given
+// device, or the full GPU it is on, to be scrubbed.
+func (c PreparedClaimsByUID) ScrubPendingHolders(device *AllocatableDevice) []string {
+	var claimUIDs []string
+	for claimUID, pc := range c {
+		if !pc.ScrubPending {
+			continue
+		}
+		uuid := device.UUID()
+		if (uuid != "" && slices.Contains(pc.PreparedDevices.UUIDs(), uuid)) || slices.Contains(pc.PreparedDevices.GpuUUIDs(), device.ParentUUID()) {
+			claimUIDs = append(claimUIDs, claimUID)
+		}
+	}
+	slices.Sort(claimUIDs)
+	return claimUIDs
+}
+
+// HasVfioDevices returns true if any claim has GPUs passed through to virtual
+// machines.
+func (c PreparedClaimsByUID) HasVfioDevices() bool {
+	for _, pc := range c {
+		for _, group := range pc.PreparedDevices {
+			if len(group.ConfigState.VfioPciBusIDs) > 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// VfioGpuNames returns the names of all GPUs passed through to virtual
+// machines, keyed by their UUIDs.
+func (c PreparedClaimsByUID) VfioGpuNames() map[string]string {
+	names := make(map[string]string)
+	for _, pc := range c {
+		for _, group := range pc.PreparedDevices {
+			if len(group.ConfigState.VfioPciBusIDs) == 0 {
+				continue
+			}
+			for _, device := range group.Devices {
+				if device.Gpu != nil {
+					names[device.Gpu.Info.UUID] = device.Gpu.Device.DeviceName
+				}
+			}
+		}
+	}
+	return names
 }
 
 func newCheckpoint() *Checkpoint {
@@ -60,3 +132,73 @@ func (cp *Checkpoint) VerifyChecksum() error {
 	}
 	return ck.Verify(out)
 }
+
+// CheckpointCache keeps the prepared claims of the node-local checkpoint in
+// memory, so that the checkpoint is read from disk only once. Updates are
+// serialized and only become visible once they have been written to disk.
+type CheckpointCache struct {
+	sync.Mutex
+	manager checkpointmanager.CheckpointManager
+	claims  PreparedClaimsByUID
+}
+
+// NewCheckpointCache loads the checkpoint through the given manager, creating
+// an empty checkpoint if none exists yet.
+func NewCheckpointCache(manager checkpointmanager.CheckpointManager) (*CheckpointCache, error) {
+	checkpoints, err := manager.ListCheckpoints()
+	if err != nil {
+		return nil, fmt.Errorf("unable to list checkpoints: %v", err)
+	}
+
+	checkpoint := newCheckpoint()
+	if slices.Contains(checkpoints, DriverPluginCheckpointFileBasename) {
+		if err := manager.GetCheckpoint(DriverPluginCheckpointFileBasename, checkpoint); err != nil {
+			return nil, fmt.Errorf("unable to sync from checkpoint: %v", err)
+		}
+	} else {
+		if err := manager.CreateCheckpoint(DriverPluginCheckpointFileBasename, checkpoint); err != nil {
+			return nil, fmt.Errorf("unable to sync to checkpoint: %v", err)
+		}
+	}
+	setPreparedClaimsMetric(checkpoint.V1.PreparedClaims)
+
+	cache := &CheckpointCache{
+		manager: manager,
+		claims:  checkpoint.V1.PreparedClaims,
+	}
+	return cache, nil
+}
+
+// Get returns the prepared claim with the given UID, if any.
+func (c *CheckpointCache) Get(claimUID string) (PreparedClaim, bool) {
+	c.Lock()
+	defer c.Unlock()
+	pc, exists := c.claims[claimUID]
+	return pc, exists
+}
+
+// PreparedClaims returns a snapshot of all prepared claims.
+func (c *CheckpointCache) PreparedClaims() PreparedClaimsByUID {
+	c.Lock()
+	defer c.Unlock()
+	return maps.Clone(c.claims)
+}
+
+// Update applies update to a copy of the prepared claims and writes the
+// result to disk. The cache is left untouched if writing fails.
+func (c *CheckpointCache) Update(update func(PreparedClaimsByUID)) error {
+	c.Lock()
+	defer c.Unlock()
+
+	checkpoint := newCheckpoint()
+	checkpoint.V1.PreparedClaims = maps.Clone(c.claims)
+	update(checkpoint.V1.PreparedClaims)
+
+	if err := c.manager.CreateCheckpoint(DriverPluginCheckpointFileBasename, checkpoint); err != nil {
+		return fmt.Errorf("unable to sync to checkpoint: %v", err)
+	}
+	c.claims = checkpoint.V1.PreparedClaims
+	setPreparedClaimsMetric(c.claims)
+
+	return nil
+}
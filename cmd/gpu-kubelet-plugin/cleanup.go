@@ -0,0 +1,137 @@
+/*
+ * Copyright (c) 2025, NVIDIA CORPORATION.  All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/dynamic-resource-allocation/kubeletplugin"
+	"k8s.io/klog/v2"
+
+	"github.com/NVIDIA/k8s-dra-driver-gpu/pkg/metrics"
+)
+
+const cleanupInterval = 10 * time.Minute
+
+func (d *driver) periodicCleanup(ctx context.Context) {
+	ticker := time.NewTicker(cleanupInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			klog.V(6).Infof("Running periodic cleanup of stale claims and orphaned artifacts")
+			d.cleanup(ctx)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (d *driver) cleanup(ctx context.Context) {
+	if err := d.cleanupStaleClaims(ctx); err != nil {
+		klog.Errorf("Error cleaning up stale claims: %v", err)
+	}
+	if err := d.state.CleanupOrphanedArtifacts(ctx); err != nil {
+		klog.Errorf("Error cleaning up orphaned artifacts: %v", err)
+	}
+}
+
+// cleanupStaleClaims unprepares all claims in the checkpoint whose
+// ResourceClaim no longer exists in the API server and that are not
+// referenced by any pod on this node anymore. Kubelet normally unprepares
+// these itself, but never does so if the node crashed or a pod was force
+// deleted while the claim was prepared.
+func (d *driver) cleanupStaleClaims(ctx context.Context) error {
+	claims, err := d.state.GetPreparedClaims()
+	if err != nil {
+		return fmt.Errorf("error getting prepared claims: %w", err)
+	}
+	if len(claims) == 0 {
+		return nil
+	}
+
+	pods, err := d.client.CoreV1().Pods("").List(ctx, metav1.ListOptions{
+		FieldSelector: fields.OneTermEqualSelector("spec.nodeName", d.nodeName).String(),
+	})
+	if err != nil {
+		return fmt.Errorf("error listing pods on node: %w", err)
+	}
+
+	for claimUID, pc := range claims {
+		if isClaimReferenced(pc, pods.Items) {
+			continue
+		}
+
+		// Claims prepared by older versions of the plugin did not record
+		// their name, so we cannot tell whether they still exist.
+		if pc.Name == "" {
+			klog.V(6).Infof("Skipping cleanup check for claim %v without a recorded name", claimUID)
+			continue
+		}
+
+		claim, err := d.client.ResourceV1beta1().ResourceClaims(pc.Namespace).Get(ctx, pc.Name, metav1.GetOptions{})
+		if err != nil && !errors.IsNotFound(err) {
+			klog.Errorf("Error getting ResourceClaim %s/%s: %v", pc.Namespace, pc.Name, err)
+			continue
+		}
+		if err == nil && string(claim.UID) == claimUID {
+			continue
+		}
+
+		klog.Infof("Unpreparing stale claim %s/%s (%v)", pc.Namespace, pc.Name, claimUID)
+		claimRef := kubeletplugin.NamespacedObject{
+			NamespacedName: types.NamespacedName{Namespace: pc.Namespace, Name: pc.Name},
+			UID:            types.UID(claimUID),
+		}
+		start := time.Now()
+		_, err = d.nodeUnprepareResource(ctx, claimRef)
+		metrics.ObserveUnprepare(DriverName, start, err)
+		if err != nil {
+			klog.Errorf("Error unpreparing stale claim %v: %v", claimUID, err)
+		}
+	}
+
+	return nil
+}
+
+// isClaimReferenced returns true if any of the pods is a consumer of the claim.
+func isClaimReferenced(pc PreparedClaim, pods []corev1.Pod) bool {
+	for _, pod := range pods {
+		for _, consumer := range pc.Status.ReservedFor {
+			if consumer.UID == pod.UID {
+				return true
+			}
+		}
+		if pc.Name == "" || pod.Namespace != pc.Namespace {
+			continue
+		}
+		for _, status := range pod.Status.ResourceClaimStatuses {
+			if status.ResourceClaimName != nil && *status.ResourceClaimName == pc.Name {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,254 @@
+/*
+ * Copyright (c) 2025, NVIDIA CORPORATION.  All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	resourceapi "k8s.io/api/resource/v1beta1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/informers"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/yaml"
+
+	configapi "github.com/NVIDIA/k8s-dra-driver-gpu/api/nvidia.com/resource/v1beta1"
+)
+
+const (
+	// DefaultConfigsKey is the key of the ConfigMap entry holding the
+	// cluster-wide default configs.
+	DefaultConfigsKey = "defaults.yaml"
+
+	defaultConfigsResyncPeriod = 10 * time.Minute
+)
+
+// DefaultConfigs are the cluster-wide default configs set by the cluster
+// administrator. Each of them applies to all devices of the claims it
+// selects, at a lower precedence than any config coming from the DeviceClass
+// or the claim itself. Later entries take precedence over earlier ones.
+type DefaultConfigs struct {
+	Defaults []DefaultConfig `json:"defaults"`
+}
+
+// DefaultConfig is a single default config along with the claims it applies
+// to. Empty selectors match everything.
+type DefaultConfig struct {
+	// Namespaces selects claims in any of the given namespaces.
+	Namespaces []string `json:"namespaces,omitempty"`
+	// DeviceClasses selects the requests of a claim for devices of any of
+	// the given DeviceClasses.
+	DeviceClasses []string `json:"deviceClasses,omitempty"`
+	// NodeSelector selects claims prepared on nodes with matching labels.
+	NodeSelector *metav1.LabelSelector `json:"nodeSelector,omitempty"`
+	// Config is a GpuConfig, MigDeviceConfig or VfioGpuConfig.
+	Config runtime.RawExtension `json:"config"`
+}
+
+// DefaultConfigManager watches the ConfigMap holding the default configs and
+// the node the plugin runs on.
+type DefaultConfigManager struct {
+	waitGroup     sync.WaitGroup
+	cancelContext context.CancelFunc
+
+	name     string
+	nodeName string
+
+	configMapFactory  informers.SharedInformerFactory
+	configMapInformer cache.SharedIndexInformer
+	configMapLister   corelisters.ConfigMapNamespaceLister
+	nodeFactory       informers.SharedInformerFactory
+	nodeInformer      cache.SharedIndexInformer
+	nodeLister        corelisters.NodeLister
+}
+
+func NewDefaultConfigManager(config *Config) *DefaultConfigManager {
+	name := config.flags.defaultConfigMap
+
+	configMapFactory := informers.NewSharedInformerFactoryWithOptions(
+		config.clientsets.Core,
+		defaultConfigsResyncPeriod,
+		informers.WithNamespace(config.flags.namespace),
+		informers.WithTweakListOptions(func(opts *metav1.ListOptions) {
+			opts.FieldSelector = fields.OneTermEqualSelector("metadata.name", name).String()
+		}),
+	)
+	configMaps := configMapFactory.Core().V1().ConfigMaps()
+
+	nodeFactory := informers.NewSharedInformerFactoryWithOptions(
+		config.clientsets.Core,
+		defaultConfigsResyncPeriod,
+		informers.WithTweakListOptions(func(opts *metav1.ListOptions) {
+			opts.FieldSelector = fields.OneTermEqualSelector("metadata.name", config.flags.nodeName).String()
+		}),
+	)
+	nodes := nodeFactory.Core().V1().Nodes()
+
+	m := &DefaultConfigManager{
+		name:              name,
+		nodeName:          config.flags.nodeName,
+		configMapFactory:  configMapFactory,
+		configMapInformer: configMaps.Informer(),
+		configMapLister:   configMaps.Lister().ConfigMaps(config.flags.namespace),
+		nodeFactory:       nodeFactory,
+		nodeInformer:      nodes.Informer(),
+		nodeLister:        nodes.Lister(),
+	}
+
+	return m
+}
+
+func (m *DefaultConfigManager) Start(ctx context.Context) (rerr error) {
+	ctx, cancel := context.WithCancel(ctx)
+	m.cancelContext = cancel
+
+	defer func() {
+		if rerr != nil {
+			if err := m.Stop(); err != nil {
+				klog.Errorf("error stopping DefaultConfigManager: %v", err)
+			}
+		}
+	}()
+
+	m.waitGroup.Add(1)
+	go func() {
+		defer m.waitGroup.Done()
+		m.configMapFactory.Start(ctx.Done())
+	}()
+
+	m.waitGroup.Add(1)
+	go func() {
+		defer m.waitGroup.Done()
+		m.nodeFactory.Start(ctx.Done())
+	}()
+
+	if !cache.WaitForCacheSync(ctx.Done(), m.configMapInformer.HasSynced, m.nodeInformer.HasSynced) {
+		return fmt.Errorf("informer cache sync for default configs failed")
+	}
+
+	return nil
+}
+
+func (m *DefaultConfigManager) Stop() error {
+	if m.cancelContext != nil {
+		m.cancelContext()
+	}
+	m.waitGroup.Wait()
+	return nil
+}
+
+// GetDeviceConfigs returns the default configs that apply to the claim in
+// order of precedence (from lowest to highest). They are returned as configs
+// coming from the DeviceClass, so that they can be merged with the configs
+// of the claim by GetOpaqueDeviceConfigs().
+func (m *DefaultConfigManager) GetDeviceConfigs(claim *resourceapi.ResourceClaim) ([]resourceapi.DeviceAllocationConfiguration, error) {
+	configMap, err := m.configMapLister.Get(m.name)
+	if errors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error getting ConfigMap %v: %w", m.name, err)
+	}
+
+	var defaults DefaultConfigs
+	if err := yaml.Unmarshal([]byte(configMap.Data[DefaultConfigsKey]), &defaults); err != nil {
+		return nil, fmt.Errorf("error parsing default configs in ConfigMap %v: %w", m.name, err)
+	}
+
+	node, err := m.nodeLister.Get(m.nodeName)
+	if err != nil {
+		return nil, fmt.Errorf("error getting node %v: %w", m.nodeName, err)
+	}
+
+	configs, err := defaults.Select(claim, node.Labels)
+	if err != nil {
+		return nil, fmt.Errorf("error selecting default configs from ConfigMap %v: %w", m.name, err)
+	}
+	return configs, nil
+}
+
+// Select returns the default configs that apply to the claim when prepared
+// on a node with the given labels.
+func (d *DefaultConfigs) Select(claim *resourceapi.ResourceClaim, nodeLabels labels.Set) ([]resourceapi.DeviceAllocationConfiguration, error) {
+	// Map the names of all requests, including subrequests, to their
+	// DeviceClass.
+	deviceClasses := make(map[string]string)
+	for _, request := range claim.Spec.Devices.Requests {
+		deviceClasses[request.Name] = request.DeviceClassName
+		for _, subRequest := range request.FirstAvailable {
+			deviceClasses[request.Name+"/"+subRequest.Name] = subRequest.DeviceClassName
+		}
+	}
+
+	var configs []resourceapi.DeviceAllocationConfiguration
+	for i, dc := range d.Defaults {
+		if len(dc.Namespaces) > 0 && !slices.Contains(dc.Namespaces, claim.Namespace) {
+			continue
+		}
+
+		if dc.NodeSelector != nil {
+			selector, err := metav1.LabelSelectorAsSelector(dc.NodeSelector)
+			if err != nil {
+				return nil, fmt.Errorf("invalid node selector in default config %d: %w", i, err)
+			}
+			if !selector.Matches(nodeLabels) {
+				continue
+			}
+		}
+
+		// Make sure that broken default configs are caught here rather than
+		// being reported as broken configs of the claim.
+		if _, err := runtime.Decode(configapi.Decoder, dc.Config.Raw); err != nil {
+			return nil, fmt.Errorf("error decoding default config %d: %w", i, err)
+		}
+
+		var requests []string
+		if len(dc.DeviceClasses) > 0 {
+			for _, request := range slices.Sorted(maps.Keys(deviceClasses)) {
+				if slices.Contains(dc.DeviceClasses, deviceClasses[request]) {
+					requests = append(requests, request)
+				}
+			}
+			if len(requests) == 0 {
+				continue
+			}
+		}
+
+		configs = append(configs, resourceapi.DeviceAllocationConfiguration{
+			Source:   resourceapi.AllocationConfigSourceClass,
+			Requests: requests,
+			DeviceConfiguration: resourceapi.DeviceConfiguration{
+				Opaque: &resourceapi.OpaqueDeviceConfiguration{
+					Driver:     DriverName,
+					Parameters: dc.Config,
+				},
+			},
+		})
+	}
+
+	return configs, nil
+}
@@ -0,0 +1,138 @@
+/*
+ * Copyright (c) 2025, NVIDIA CORPORATION.  All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	resourceapi "k8s.io/api/resource/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/yaml"
+
+	configapi "github.com/NVIDIA/k8s-dra-driver-gpu/api/nvidia.com/resource/v1beta1"
+)
+
+func TestDefaultConfigsSelect(t *testing.T) {
+	const defaultsYAML = `
+defaults:
+- config:
+    apiVersion: resource.nvidia.com/v1beta1
+    kind: GpuConfig
+    sharing:
+      strategy: TimeSlicing
+- namespaces: [inference]
+  deviceClasses: [gpu.nvidia.com]
+  config:
+    apiVersion: resource.nvidia.com/v1beta1
+    kind: GpuConfig
+    sharing:
+      strategy: MPS
+      mpsConfig:
+        defaultActiveThreadPercentage: 50
+- nodeSelector:
+    matchLabels:
+      pool: training
+  config:
+    apiVersion: resource.nvidia.com/v1beta1
+    kind: MigDeviceConfig
+`
+
+	var defaults DefaultConfigs
+	require.NoError(t, yaml.Unmarshal([]byte(defaultsYAML), &defaults))
+
+	newClaim := func(namespace string) *resourceapi.ResourceClaim {
+		return &resourceapi.ResourceClaim{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespace},
+			Spec: resourceapi.ResourceClaimSpec{
+				Devices: resourceapi.DeviceClaim{
+					Requests: []resourceapi.DeviceRequest{
+						{Name: "mig", DeviceClassName: "mig.nvidia.com"},
+						{
+							Name: "any",
+							FirstAvailable: []resourceapi.DeviceSubRequest{
+								{Name: "gpu", DeviceClassName: "gpu.nvidia.com"},
+								{Name: "mig", DeviceClassName: "mig.nvidia.com"},
+							},
+						},
+						{Name: "gpu", DeviceClassName: "gpu.nvidia.com"},
+					},
+				},
+			},
+		}
+	}
+
+	testCases := []struct {
+		name             string
+		claim            *resourceapi.ResourceClaim
+		nodeLabels       labels.Set
+		expectedRequests [][]string
+		expectedKinds    []string
+	}{
+		{
+			name:             "only unrestricted defaults",
+			claim:            newClaim("default"),
+			expectedRequests: [][]string{nil},
+			expectedKinds:    []string{configapi.GpuConfigKind},
+		},
+		{
+			name:             "namespace and device class",
+			claim:            newClaim("inference"),
+			expectedRequests: [][]string{nil, {"any/gpu", "gpu"}},
+			expectedKinds:    []string{configapi.GpuConfigKind, configapi.GpuConfigKind},
+		},
+		{
+			name:             "node selector",
+			claim:            newClaim("default"),
+			nodeLabels:       labels.Set{"pool": "training"},
+			expectedRequests: [][]string{nil, nil},
+			expectedKinds:    []string{configapi.GpuConfigKind, configapi.MigDeviceConfigKind},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			configs, err := defaults.Select(tc.claim, tc.nodeLabels)
+			require.NoError(t, err)
+			require.Len(t, configs, len(tc.expectedKinds))
+
+			var requests [][]string
+			var kinds []string
+			for _, c := range configs {
+				require.EqualValues(t, resourceapi.AllocationConfigSourceClass, c.Source)
+				require.Equal(t, DriverName, c.Opaque.Driver)
+				var meta metav1.TypeMeta
+				require.NoError(t, yaml.Unmarshal(c.Opaque.Parameters.Raw, &meta))
+				requests = append(requests, c.Requests)
+				kinds = append(kinds, meta.Kind)
+			}
+			require.Equal(t, tc.expectedRequests, requests)
+			require.Equal(t, tc.expectedKinds, kinds)
+
+			// The defaults must be usable as regular device class configs.
+			_, err = GetOpaqueDeviceConfigs(configapi.Decoder, DriverName, configs)
+			require.NoError(t, err)
+		})
+	}
+
+	invalid := DefaultConfigs{
+		Defaults: []DefaultConfig{{}},
+	}
+	_, err := invalid.Select(newClaim("default"), nil)
+	require.Error(t, err)
+}
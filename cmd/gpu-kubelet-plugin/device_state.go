@@ -18,18 +18,24 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"maps"
+	"path/filepath"
 	"slices"
 	"sync"
+	"time"
 
 	resourceapi "k8s.io/api/resource/v1beta1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/dynamic-resource-allocation/kubeletplugin"
 	"k8s.io/klog/v2"
 	"k8s.io/kubernetes/pkg/kubelet/checkpointmanager"
 	cdiapi "tags.cncf.io/container-device-interface/pkg/cdi"
 
 	configapi "github.com/NVIDIA/k8s-dra-driver-gpu/api/nvidia.com/resource/v1beta1"
+	"github.com/NVIDIA/k8s-dra-driver-gpu/pkg/metrics"
 )
 
 type OpaqueDeviceConfig struct {
@@ -38,44 +44,69 @@ type OpaqueDeviceConfig struct {
 }
 
 type DeviceConfigState struct {
-	MpsControlDaemonID string `json:"mpsControlDaemonID"`
-	containerEdits     *cdiapi.ContainerEdits
+	MpsControlDaemonID string                       `json:"mpsControlDaemonID"`
+	TimeSlicingConfig  *configapi.TimeSlicingConfig `json:"timeSlicingConfig,omitempty"`
+	MpsConfig          *configapi.MpsConfig         `json:"mpsConfig,omitempty"`
+	// GpuSettings holds the original settings of the GPUs reconfigured
+	// according to GpuConfig, which is the GpuConfig applied without its
+	// sharing settings.
+	GpuSettings    map[string]*GpuSettingsState `json:"gpuSettings,omitempty"`
+	GpuConfig      *configapi.GpuConfig         `json:"gpuConfig,omitempty"`
+	VfioPciBusIDs  []string                     `json:"vfioPciBusIDs,omitempty"`
+	containerEdits *cdiapi.ContainerEdits
 }
 
+// DeviceLockTimeout is how long preparing or unpreparing a claim waits for
+// other claims on the same devices before giving up.
+const DeviceLockTimeout = 10 * time.Second
+
 type DeviceState struct {
-	sync.Mutex
+	// Guards the set of allocatable devices.
+	sync.RWMutex
 	cdi         *CDIHandler
 	tsManager   *TimeSlicingManager
 	mpsManager  *MpsManager
+	scrubber    *GpuScrubber
 	allocatable AllocatableDevices
 	config      *Config
 
-	nvdevlib          *deviceLib
-	checkpointManager checkpointmanager.CheckpointManager
+	nvdevlib       *deviceLib
+	checkpoint     *CheckpointCache
+	defaultConfigs *DefaultConfigManager
+
+	// Claims are prepared and unprepared concurrently, holding opsLock for
+	// reading and the device locks of the claim and its GPUs. Operations that
+	// need a consistent view of the node as a whole hold opsLock for writing.
+	opsLock     sync.RWMutex
+	deviceLocks *DeviceLocks
+
+	// beforeRebind and afterRebind are called around binding GPUs to and
+	// from vfio-pci, see OnRebind.
+	beforeRebind func(pciBusID string)
+	afterRebind  func(pciBusID string)
+
+	// sharedCounters is true if the API server supports the shared counters
+	// that keep overlapping MIG placements from being allocated together.
+	// MIG devices are only created on demand if it does.
+	sharedCounters bool
 }
 
 func NewDeviceState(ctx context.Context, config *Config) (*DeviceState, error) {
 	containerDriverRoot := root(config.flags.containerDriverRoot)
-	nvdevlib, err := newDeviceLib(containerDriverRoot)
+	nvdevlib, err := newDeviceLib(containerDriverRoot, config.nvmllib)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create device library: %w", err)
 	}
 
-	allocatable, err := nvdevlib.enumerateAllPossibleDevices(config)
-	if err != nil {
-		return nil, fmt.Errorf("error enumerating all possible devices: %w", err)
-	}
-
 	devRoot := containerDriverRoot.getDevRoot()
 	klog.Infof("using devRoot=%v", devRoot)
 
-	hostDriverRoot := config.flags.hostDriverRoot
 	cdi, err := NewCDIHandler(
 		WithNvml(nvdevlib.nvmllib),
 		WithDeviceLib(nvdevlib),
 		WithDriverRoot(string(containerDriverRoot)),
 		WithDevRoot(devRoot),
-		WithTargetDriverRoot(hostDriverRoot),
+		WithTargetDriverRoot(config.flags.hostDriverRoot),
 		WithNVIDIACDIHookPath(config.flags.nvidiaCDIHookPath),
 		WithCDIRoot(config.flags.cdiRoot),
 		WithVendor(cdiVendor),
@@ -84,59 +115,109 @@ func NewDeviceState(ctx context.Context, config *Config) (*DeviceState, error) {
 		return nil, fmt.Errorf("unable to create CDI handler: %w", err)
 	}
 
-	tsManager := NewTimeSlicingManager(nvdevlib)
-	mpsManager := NewMpsManager(config, nvdevlib, MpsRoot, hostDriverRoot, MpsControlDaemonTemplatePath)
+	return newDeviceState(ctx, config, nvdevlib, cdi)
+}
 
-	if err := cdi.CreateStandardDeviceSpecFile(allocatable); err != nil {
-		return nil, fmt.Errorf("unable to create base CDI spec file: %v", err)
+// newDeviceState creates the device state on top of a device library and a
+// CDI handler. The checkpoint and the files of MPS control daemons are kept in
+// the plugin directory of the config.
+func newDeviceState(ctx context.Context, config *Config, nvdevlib *deviceLib, cdi *CDIHandler) (*DeviceState, error) {
+	allocatable, err := nvdevlib.enumerateAllPossibleDevices(config)
+	if err != nil {
+		return nil, fmt.Errorf("error enumerating all possible devices: %w", err)
 	}
 
-	checkpointManager, err := checkpointmanager.NewCheckpointManager(DriverPluginPath)
+	mpsRoot := filepath.Join(config.DriverPluginPath(), MpsRootDirName)
+	tsManager := NewTimeSlicingManager(nvdevlib)
+	mpsManager := NewMpsManager(config, nvdevlib, mpsRoot, config.flags.hostDriverRoot, MpsControlDaemonTemplatePath)
+	scrubber := NewGpuScrubber(nvdevlib, GpuScrubPolicy(config.flags.gpuScrubPolicy), config.flags.gpuScrubTimeout)
+
+	checkpointManager, err := checkpointmanager.NewCheckpointManager(config.DriverPluginPath())
 	if err != nil {
 		return nil, fmt.Errorf("unable to create checkpoint manager: %v", err)
 	}
 
-	state := &DeviceState{
-		cdi:               cdi,
-		tsManager:         tsManager,
-		mpsManager:        mpsManager,
-		allocatable:       allocatable,
-		config:            config,
-		nvdevlib:          nvdevlib,
-		checkpointManager: checkpointManager,
+	checkpoint, err := NewCheckpointCache(checkpointManager)
+	if err != nil {
+		return nil, fmt.Errorf("unable to load checkpoint: %w", err)
 	}
 
-	checkpoints, err := state.checkpointManager.ListCheckpoints()
-	if err != nil {
-		return nil, fmt.Errorf("unable to list checkpoints: %v", err)
+	// GPUs passed through to virtual machines are invisible to NVML, which
+	// shifts the indices, and with them the names, of all GPUs after them.
+	// The names of the passed-through GPUs are recorded in the checkpoint,
+	// so that all other GPUs keep the names they were published under.
+	if hidden := hiddenVfioGpuIndices(checkpoint.PreparedClaims(), allocatable); len(hidden) > 0 {
+		klog.Infof("Skipping indices of GPUs bound to %v: %v", VfioPciDriver, hidden)
+		allocatable = allocatable.SkipGpuIndices(hidden)
 	}
 
-	for _, c := range checkpoints {
-		if c == DriverPluginCheckpointFileBasename {
-			return state, nil
-		}
+	if err := cdi.CreateStandardDeviceSpecFile(allocatable); err != nil {
+		return nil, fmt.Errorf("unable to create base CDI spec file: %v", err)
+	}
+
+	state := &DeviceState{
+		cdi:         cdi,
+		tsManager:   tsManager,
+		mpsManager:  mpsManager,
+		scrubber:    scrubber,
+		allocatable: allocatable,
+		config:      config,
+		nvdevlib:    nvdevlib,
+		checkpoint:  checkpoint,
+		deviceLocks: NewDeviceLocks(),
 	}
 
-	checkpoint := newCheckpoint()
-	if err := state.checkpointManager.CreateCheckpoint(DriverPluginCheckpointFileBasename, checkpoint); err != nil {
-		return nil, fmt.Errorf("unable to sync to checkpoint: %v", err)
+	if config.flags.defaultConfigMap != "" {
+		state.defaultConfigs = NewDefaultConfigManager(config)
 	}
 
+	state.restoreSharingState(ctx)
+
 	return state, nil
 }
 
+// hiddenVfioGpuIndices returns the indices of the GPUs passed through to
+// virtual machines by the given claims that are missing from the enumerated
+// devices.
+func hiddenVfioGpuIndices(claims PreparedClaimsByUID, enumerated AllocatableDevices) []int {
+	visible := sets.New(enumerated.GpuUUIDs()...)
+	var indices []int
+	for uuid, name := range claims.VfioGpuNames() {
+		if visible.Has(uuid) {
+			continue
+		}
+		var index int
+		if _, err := fmt.Sscanf(name, "gpu-%d", &index); err != nil {
+			klog.Warningf("Unable to get index of GPU %v bound to %v from its name %q: %v", uuid, VfioPciDriver, name, err)
+			continue
+		}
+		indices = append(indices, index)
+	}
+	slices.Sort(indices)
+	return indices
+}
+
 func (s *DeviceState) Prepare(ctx context.Context, claim *resourceapi.ResourceClaim) ([]kubeletplugin.Device, error) {
-	s.Lock()
-	defer s.Unlock()
+	s.opsLock.RLock()
+	defer s.opsLock.RUnlock()
 
 	claimUID := string(claim.UID)
 
-	checkpoint := newCheckpoint()
-	if err := s.checkpointManager.GetCheckpoint(DriverPluginCheckpointFileBasename, checkpoint); err != nil {
-		return nil, fmt.Errorf("unable to sync from checkpoint: %v", err)
+	var deviceNames []string
+	if claim.Status.Allocation != nil {
+		for _, result := range claim.Status.Allocation.Devices.Results {
+			if result.Driver == DriverName {
+				deviceNames = append(deviceNames, result.Device)
+			}
+		}
+	}
+	release, err := s.lockDevices(ctx, claimUID, deviceNames)
+	if err != nil {
+		return nil, err
 	}
+	defer release()
 
-	preparedClaim, exists := checkpoint.V1.PreparedClaims[claimUID]
+	preparedClaim, exists := s.checkpoint.Get(claimUID)
 	if exists {
 		// Make this a noop. Associated device(s) has/ave been prepared by us.
 		// Prepare() must be idempotent, as it may be invoked more than once per
@@ -145,40 +226,46 @@ func (s *DeviceState) Prepare(ctx context.Context, claim *resourceapi.ResourceCl
 		return preparedClaim.PreparedDevices.GetDevices(), nil
 	}
 
+	if err := s.checkPendingScrubs(claim); err != nil {
+		return nil, metrics.WithErrorReason(metrics.ErrorReasonScrub, err)
+	}
+
 	preparedDevices, err := s.prepareDevices(ctx, claim)
 	if err != nil {
-		return nil, fmt.Errorf("prepare devices failed: %w", err)
+		reason := metrics.ErrorReasonDevice
+		if isPermanentError(err) {
+			reason = metrics.ErrorReasonInvalidClaim
+		}
+		return nil, metrics.WithErrorReason(reason, fmt.Errorf("prepare devices failed: %w", err))
 	}
 
 	if err := s.cdi.CreateClaimSpecFile(claimUID, preparedDevices); err != nil {
-		return nil, fmt.Errorf("unable to create CDI spec file for claim: %w", err)
+		return nil, metrics.WithErrorReason(metrics.ErrorReasonCDI, fmt.Errorf("unable to create CDI spec file for claim: %w", err))
 	}
 
 	// Reflect this preparation in node-local checkpoint: the 'unprepare' code
 	// path must use local state exclusively (ResourceClaim object might have
 	// been deleted from the API server).
-	checkpoint.V1.PreparedClaims[claimUID] = PreparedClaim{
-		Status:          claim.Status,
-		PreparedDevices: preparedDevices,
-	}
-
-	if err := s.checkpointManager.CreateCheckpoint(DriverPluginCheckpointFileBasename, checkpoint); err != nil {
-		return nil, fmt.Errorf("unable to sync to checkpoint: %v", err)
+	err = s.checkpoint.Update(func(claims PreparedClaimsByUID) {
+		claims[claimUID] = PreparedClaim{
+			Name:            claim.Name,
+			Namespace:       claim.Namespace,
+			Status:          claim.Status,
+			PreparedDevices: preparedDevices,
+		}
+	})
+	if err != nil {
+		return nil, metrics.WithErrorReason(metrics.ErrorReasonCheckpoint, err)
 	}
 
 	return preparedDevices.GetDevices(), nil
 }
 
 func (s *DeviceState) Unprepare(ctx context.Context, claimUID string) error {
-	s.Lock()
-	defer s.Unlock()
+	s.opsLock.RLock()
+	defer s.opsLock.RUnlock()
 
-	checkpoint := newCheckpoint()
-	if err := s.checkpointManager.GetCheckpoint(DriverPluginCheckpointFileBasename, checkpoint); err != nil {
-		return fmt.Errorf("unable to sync from checkpoint: %v", err)
-	}
-
-	pc, exists := checkpoint.V1.PreparedClaims[claimUID]
+	pc, exists := s.checkpoint.Get(claimUID)
 	if !exists {
 		// Not an error: if this claim UID is not in the checkpoint then this
 		// device was never prepared or has already been unprepared (assume that
@@ -188,37 +275,442 @@ func (s *DeviceState) Unprepare(ctx context.Context, claimUID string) error {
 		return nil
 	}
 
-	if err := s.unprepareDevices(ctx, claimUID, pc.PreparedDevices); err != nil {
-		return fmt.Errorf("unprepare devices failed: %w", err)
+	var deviceNames []string
+	for _, device := range pc.PreparedDevices.GetDevices() {
+		deviceNames = append(deviceNames, device.DeviceName)
+	}
+	release, err := s.lockDevices(ctx, claimUID, deviceNames)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	// The claim may have been unprepared while waiting for its devices.
+	if _, exists := s.checkpoint.Get(claimUID); !exists {
+		klog.Infof("unprepare noop: claim not found in checkpoint data: %v", claimUID)
+		return nil
+	}
+
+	// Other claims can only be prepared on or unprepared from these devices
+	// while holding their locks, so this view of them stays accurate.
+	claims := s.checkpoint.PreparedClaims()
+
+	// The claim stays in the checkpoint until its devices have been scrubbed,
+	// so that they are not handed to another claim in the meantime. If
+	// scrubbing fails, it is retried the next time the claim is unprepared.
+	if !pc.ScrubPending {
+		if err := s.unprepareDevices(ctx, claimUID, pc.PreparedDevices); err != nil {
+			return metrics.WithErrorReason(metrics.ErrorReasonDevice, fmt.Errorf("unprepare devices failed: %w", err))
+		}
+		pc.ScrubPending = true
+		err = s.checkpoint.Update(func(claims PreparedClaimsByUID) {
+			claims[claimUID] = pc
+		})
+		if err != nil {
+			return metrics.WithErrorReason(metrics.ErrorReasonCheckpoint, err)
+		}
+	}
+
+	if err := s.scrubDevices(ctx, claimUID, pc.PreparedDevices, claims); err != nil {
+		return metrics.WithErrorReason(metrics.ErrorReasonScrub, fmt.Errorf("scrub devices failed: %w", err))
 	}
 
-	err := s.cdi.DeleteClaimSpecFile(claimUID)
+	err = s.cdi.DeleteClaimSpecFile(claimUID)
 	if err != nil {
-		return fmt.Errorf("unable to delete CDI spec file for claim: %w", err)
+		return metrics.WithErrorReason(metrics.ErrorReasonCDI, fmt.Errorf("unable to delete CDI spec file for claim: %w", err))
 	}
 
 	// Unprepare succeeded; reflect that in the node-local checkpoint data.
-	delete(checkpoint.V1.PreparedClaims, claimUID)
-	if err := s.checkpointManager.CreateCheckpoint(DriverPluginCheckpointFileBasename, checkpoint); err != nil {
-		return fmt.Errorf("unable to sync to checkpoint: %v", err)
+	err = s.checkpoint.Update(func(claims PreparedClaimsByUID) {
+		delete(claims, claimUID)
+	})
+	if err != nil {
+		return metrics.WithErrorReason(metrics.ErrorReasonCheckpoint, err)
+	}
+
+	return nil
+}
+
+// checkPendingScrubs returns an error if any device allocated to the claim is
+// still waiting to be scrubbed after a previous claim, which must not leak
+// anything to this one. The error is not permanent, as scrubbing is retried
+// whenever the previous claim is unprepared again.
+func (s *DeviceState) checkPendingScrubs(claim *resourceapi.ResourceClaim) error {
+	if claim.Status.Allocation == nil {
+		return nil
+	}
+	claims := s.checkpoint.PreparedClaims()
+	for _, result := range claim.Status.Allocation.Devices.Results {
+		if result.Driver != DriverName {
+			continue
+		}
+		device, exists := s.allocatableDevice(result.Device)
+		if !exists {
+			continue
+		}
+		if holders := claims.ScrubPendingHolders(device); len(holders) > 0 {
+			return fmt.Errorf("device %v is still waiting to be scrubbed after claims %v", result.Device, holders)
+		}
+	}
+	return nil
+}
+
+// lockDevices acquires the device locks for a claim and the full GPUs backing
+// the named devices, recording how long it took. All MIG devices of a GPU
+// share its lock.
+func (s *DeviceState) lockDevices(ctx context.Context, claimUID string, deviceNames []string) (func(), error) {
+	keys := []string{claimUID}
+	s.RLock()
+	for _, name := range deviceNames {
+		if device, exists := s.allocatable[name]; exists {
+			keys = append(keys, device.ParentUUID())
+		} else {
+			keys = append(keys, name)
+		}
+	}
+	s.RUnlock()
+
+	ctx, cancel := context.WithTimeout(ctx, DeviceLockTimeout)
+	defer cancel()
+
+	start := time.Now()
+	release, err := s.deviceLocks.Acquire(ctx, keys)
+	metrics.DeviceLockWaitDuration.WithLabelValues(DriverName).Observe(time.Since(start).Seconds())
+	if err != nil {
+		return nil, metrics.WithErrorReason(metrics.ErrorReasonLock, fmt.Errorf("error acquiring device locks: %w", err))
+	}
+	return release, nil
+}
+
+// Reconcile re-enumerates all devices on the node and, if anything has
+// changed, regenerates the base CDI spec and updates the set of allocatable
+// devices. Devices backing prepared claims are never withdrawn, even if they
+// have disappeared from the node. It returns true if the set of allocatable
+// devices changed.
+func (s *DeviceState) Reconcile(ctx context.Context) (bool, error) {
+	s.opsLock.Lock()
+	defer s.opsLock.Unlock()
+
+	claims := s.checkpoint.PreparedClaims()
+
+	enumerated, err := s.nvdevlib.enumerateAllPossibleDevices(s.config)
+	if err != nil {
+		return false, fmt.Errorf("error enumerating all possible devices: %w", err)
+	}
+
+	// GPUs passed through to virtual machines are invisible to NVML, which
+	// shifts the indices, and with them the names, of all GPUs after them.
+	// The devices of GPUs enumerated under a different name are kept as they
+	// are until the passed-through GPUs are handed back, while all other
+	// devices are reconciled.
+	allocatable := maps.Clone(enumerated)
+	if claims.HasVfioDevices() {
+		renamed := s.allocatable.RenamedGpus(enumerated)
+		if len(renamed) > 0 {
+			klog.V(4).Infof("Keeping devices of GPUs renamed while GPUs are bound to %v: %v", VfioPciDriver, sets.List(renamed))
+		}
+		maps.DeleteFunc(allocatable, func(_ string, device *AllocatableDevice) bool {
+			return renamed.Has(device.ParentUUID())
+		})
+		for name, device := range s.allocatable {
+			if renamed.Has(device.ParentUUID()) {
+				allocatable[name] = device
+			}
+		}
+	}
+
+	for claimUID, pc := range claims {
+		for _, device := range pc.PreparedDevices.GetDevices() {
+			if _, exists := allocatable[device.DeviceName]; exists {
+				continue
+			}
+			if d, exists := s.allocatable[device.DeviceName]; exists {
+				klog.Warningf("Device %v backing prepared claim %v has disappeared; keeping it allocatable", device.DeviceName, claimUID)
+				allocatable[device.DeviceName] = d
+			}
+		}
+	}
+
+	added, removed, changed := s.allocatable.Diff(allocatable)
+	if len(added) == 0 && len(removed) == 0 && len(changed) == 0 {
+		klog.V(6).Infof("Device reconciliation found no changes")
+		return false, nil
+	}
+	klog.Infof("Device reconciliation found changes: added=%v, removed=%v, changed=%v", added, removed, changed)
+
+	if err := s.cdi.CreateStandardDeviceSpecFile(allocatable); err != nil {
+		return false, fmt.Errorf("unable to create base CDI spec file: %w", err)
+	}
+
+	s.Lock()
+	s.allocatable = allocatable
+	s.Unlock()
+	return true, nil
+}
+
+// GetPreparedClaims returns all claims currently recorded in the checkpoint.
+func (s *DeviceState) GetPreparedClaims() (PreparedClaimsByUID, error) {
+	return s.checkpoint.PreparedClaims(), nil
+}
+
+// CleanupOrphanedArtifacts removes claim CDI spec files and MPS control
+// daemons that do not belong to any claim in the checkpoint. These are left
+// behind if the plugin dies in the middle of preparing or unpreparing a claim.
+func (s *DeviceState) CleanupOrphanedArtifacts(ctx context.Context) error {
+	// Claims being prepared have their CDI spec files and MPS control daemons
+	// before they show up in the checkpoint.
+	s.opsLock.Lock()
+	defer s.opsLock.Unlock()
+
+	claims := s.checkpoint.PreparedClaims()
+
+	mpsControlDaemonIDs := make(map[string]bool)
+	for _, pc := range claims {
+		for _, group := range pc.PreparedDevices {
+			if id := group.ConfigState.MpsControlDaemonID; id != "" {
+				mpsControlDaemonIDs[id] = true
+			}
+		}
+	}
+
+	claimUIDs, err := s.cdi.ListClaimSpecFiles()
+	if err != nil {
+		return fmt.Errorf("error listing claim CDI spec files: %w", err)
+	}
+	for _, claimUID := range claimUIDs {
+		if _, exists := claims[claimUID]; exists {
+			continue
+		}
+		klog.Infof("Removing orphaned CDI spec file for claim %v", claimUID)
+		if err := s.cdi.DeleteClaimSpecFile(claimUID); err != nil {
+			return fmt.Errorf("unable to delete CDI spec file for claim %v: %w", claimUID, err)
+		}
+	}
+
+	if err := s.mpsManager.CleanupOrphanedControlDaemons(ctx, mpsControlDaemonIDs); err != nil {
+		return fmt.Errorf("error cleaning up MPS control daemons: %w", err)
+	}
+
+	return nil
+}
+
+// restoreSharingState re-applies the state recorded in the checkpoint for
+// every prepared claim. MIG devices created for a claim do not survive a node
+// reboot, and neither do GPU settings such as the compute mode and time-slice,
+// the tmpfs mounts of MPS control daemons or the bindings of GPUs to
+// vfio-pci, while the pods consuming the claims keep running. Errors are
+// logged rather than returned so that a single broken claim does not keep the
+// plugin from serving all others.
+func (s *DeviceState) restoreSharingState(ctx context.Context) {
+	for claimUID, pc := range s.checkpoint.PreparedClaims() {
+		// The devices of claims waiting to be scrubbed are already unprepared.
+		if pc.ScrubPending {
+			continue
+		}
+		recreated, err := s.restoreMigDevices(pc.PreparedDevices)
+		if err != nil {
+			klog.Errorf("Unable to recreate MIG devices for claim %v, dropping it so that it is prepared again: %v", claimUID, err)
+			s.dropPreparedClaim(claimUID)
+			continue
+		}
+
+		for _, group := range pc.PreparedDevices {
+			if err := s.restoreDeviceGroupSharingState(ctx, claimUID, group); err != nil {
+				klog.Errorf("Unable to restore sharing state for claim %v: %v", claimUID, err)
+			}
+		}
+
+		if !recreated {
+			continue
+		}
+
+		// Recreated MIG devices have new UUIDs and device nodes.
+		if err := s.cdi.CreateClaimSpecFile(claimUID, pc.PreparedDevices); err != nil {
+			klog.Errorf("Unable to update CDI spec file for claim %v: %v", claimUID, err)
+		}
+		err = s.checkpoint.Update(func(claims PreparedClaimsByUID) {
+			claims[claimUID] = pc
+		})
+		if err != nil {
+			klog.Errorf("Unable to update checkpoint for claim %v: %v", claimUID, err)
+		}
+	}
+}
+
+// restoreMigDevices recreates the MIG devices created for a claim that no
+// longer exist. They are recreated with the same profile and placement, so
+// they keep their names but get new UUIDs, which are updated in place. It
+// returns true if any MIG device was recreated. If recreating any of them
+// fails, the ones recreated so far are deleted again.
+func (s *DeviceState) restoreMigDevices(devices PreparedDevices) (_ bool, rerr error) {
+	var recreated []*MigDeviceInfo
+	defer func() {
+		if rerr != nil {
+			if err := s.unprepareMigDevices(recreated); err != nil {
+				klog.Errorf("error cleaning up MIG devices: %v", err)
+			}
+		}
+	}()
+
+	for _, group := range devices {
+		for _, device := range group.Devices.MigDevices() {
+			if !device.Mig.Created {
+				continue
+			}
+			exists, err := s.nvdevlib.migDeviceExists(device.Mig.Info.UUID)
+			if err != nil {
+				return false, err
+			}
+			if exists {
+				continue
+			}
+
+			name := device.Mig.Device.DeviceName
+			allocatable, ok := s.allocatableDevice(name)
+			if !ok || allocatable.Type() != MigDeviceType || allocatable.Mig.Exists() {
+				return false, fmt.Errorf("MIG device %v cannot be recreated: its placement is not available", name)
+			}
+			migInfo, err := s.nvdevlib.createMigDevice(allocatable.Mig)
+			if err != nil {
+				return false, fmt.Errorf("error recreating MIG device %v: %w", name, err)
+			}
+			klog.Infof("Recreated MIG device %v with UUID %v", name, migInfo.UUID)
+			recreated = append(recreated, migInfo)
+			device.Mig.Info = migInfo
+		}
+	}
+
+	return len(recreated) > 0, nil
+}
+
+// OnRebind registers functions to be called around binding a GPU to or from
+// the vfio-pci driver. before must release anything that this process holds
+// open on the GPU, as unbinding the NVIDIA driver waits for all of it to be
+// closed. after is called once the GPU has been bound or binding failed.
+func (s *DeviceState) OnRebind(before, after func(pciBusID string)) {
+	s.beforeRebind = before
+	s.afterRebind = after
+}
+
+// bindVfio binds a GPU to vfio-pci in between the functions registered with
+// OnRebind.
+func (s *DeviceState) bindVfio(pciBusID string) (string, error) {
+	if s.beforeRebind != nil {
+		s.beforeRebind(pciBusID)
+	}
+	if s.afterRebind != nil {
+		defer s.afterRebind(pciBusID)
+	}
+	return s.nvdevlib.bindVfio(pciBusID)
+}
+
+// unbindVfio binds a GPU back to the NVIDIA driver in between the functions
+// registered with OnRebind.
+func (s *DeviceState) unbindVfio(pciBusID string) error {
+	if s.beforeRebind != nil {
+		s.beforeRebind(pciBusID)
+	}
+	if s.afterRebind != nil {
+		defer s.afterRebind(pciBusID)
+	}
+	return s.nvdevlib.unbindVfio(pciBusID)
+}
+
+// dropPreparedClaim removes a claim that cannot be restored from the
+// checkpoint, so that preparing it again starts from scratch and reports the
+// error to the kubelet rather than handing out devices that are gone.
+func (s *DeviceState) dropPreparedClaim(claimUID string) {
+	if err := s.cdi.DeleteClaimSpecFile(claimUID); err != nil {
+		klog.Errorf("Unable to delete CDI spec file for claim %v: %v", claimUID, err)
+	}
+	err := s.checkpoint.Update(func(claims PreparedClaimsByUID) {
+		delete(claims, claimUID)
+	})
+	if err != nil {
+		klog.Errorf("Unable to remove claim %v from checkpoint: %v", claimUID, err)
+	}
+}
+
+func (s *DeviceState) restoreDeviceGroupSharingState(ctx context.Context, claimUID string, group *PreparedDeviceGroup) error {
+	var vfioGroups []string
+	for _, pciBusID := range group.ConfigState.VfioPciBusIDs {
+		vfioGroup, err := s.bindVfio(pciBusID)
+		if err != nil {
+			return fmt.Errorf("error binding GPU %v to %v: %w", pciBusID, VfioPciDriver, err)
+		}
+		vfioGroups = append(vfioGroups, vfioGroup)
+	}
+	if len(vfioGroups) > 0 {
+		group.ConfigState.containerEdits = getVfioContainerEdits(vfioGroups)
+	}
+
+	// The original settings recorded when the claim was prepared are kept,
+	// so that unpreparing the claim still restores them.
+	if config := group.ConfigState.GpuConfig; config != nil {
+		uuids := slices.Sorted(maps.Keys(group.ConfigState.GpuSettings))
+		if _, err := s.nvdevlib.applyGpuSettings(uuids, config); err != nil {
+			return fmt.Errorf("error applying GPU settings: %w", err)
+		}
+	}
+
+	if tsc := group.ConfigState.TimeSlicingConfig; tsc != nil {
+		if err := s.tsManager.SetTimeSlice(group.Devices, tsc); err != nil {
+			return fmt.Errorf("error setting timeslice config: %w", err)
+		}
+	}
+
+	if id := group.ConfigState.MpsControlDaemonID; id != "" {
+		klog.V(4).Infof("Restoring MPS control daemon %v for claim %v", id, claimUID)
+		mpsControlDaemon := s.mpsManager.newMpsControlDaemon(id, group)
+		if err := mpsControlDaemon.Start(ctx, group.ConfigState.MpsConfig); err != nil {
+			return fmt.Errorf("error restoring MPS control daemon: %w", err)
+		}
+		group.ConfigState.containerEdits = mpsControlDaemon.GetCDIContainerEdits()
 	}
 
 	return nil
 }
 
-func (s *DeviceState) prepareDevices(ctx context.Context, claim *resourceapi.ResourceClaim) (PreparedDevices, error) {
+// Allocatable returns a snapshot of the current set of allocatable devices.
+func (s *DeviceState) Allocatable() AllocatableDevices {
+	s.RLock()
+	defer s.RUnlock()
+	return maps.Clone(s.allocatable)
+}
+
+// allocatableDevice returns the allocatable device with the given name.
+func (s *DeviceState) allocatableDevice(name string) (*AllocatableDevice, bool) {
+	s.RLock()
+	defer s.RUnlock()
+	device, exists := s.allocatable[name]
+	return device, exists
+}
+
+func (s *DeviceState) prepareDevices(ctx context.Context, claim *resourceapi.ResourceClaim) (_ PreparedDevices, rerr error) {
 	if claim.Status.Allocation == nil {
-		return nil, fmt.Errorf("claim not yet allocated")
+		return nil, permanentError{fmt.Errorf("claim not yet allocated")}
+	}
+
+	// Retrieve the cluster-wide default configs that apply to this claim. They
+	// are put in front of the configs from the DeviceClass and the claim,
+	// giving them a lower precedence than either.
+	possibleConfigs := claim.Status.Allocation.Devices.Config
+	if s.defaultConfigs != nil {
+		defaultConfigs, err := s.defaultConfigs.GetDeviceConfigs(claim)
+		if err != nil {
+			return nil, fmt.Errorf("error getting default configs: %w", err)
+		}
+		possibleConfigs = slices.Concat(defaultConfigs, possibleConfigs)
 	}
 
 	// Retrieve the full set of device configs for the driver.
 	configs, err := GetOpaqueDeviceConfigs(
 		configapi.Decoder,
 		DriverName,
-		claim.Status.Allocation.Devices.Config,
+		possibleConfigs,
 	)
 	if err != nil {
-		return nil, fmt.Errorf("error getting opaque device configs: %v", err)
+		return nil, permanentError{fmt.Errorf("error getting opaque device configs: %v", err)}
 	}
 
 	// Add the default GPU and MIG device Configs to the front of the config
@@ -240,17 +732,23 @@ func (s *DeviceState) prepareDevices(ctx context.Context, claim *resourceapi.Res
 		if result.Driver != DriverName {
 			continue
 		}
-		device, exists := s.allocatable[result.Device]
+		device, exists := s.allocatableDevice(result.Device)
 		if !exists {
-			return nil, fmt.Errorf("requested device is not allocatable: %v", result.Device)
+			return nil, permanentError{fmt.Errorf("requested device is not allocatable: %v", result.Device)}
+		}
+		if device.Type() == MigDeviceType && !device.Mig.Exists() && !s.sharedCounters {
+			return nil, permanentError{fmt.Errorf("MIG device %v does not exist and cannot be created on demand: the API server does not support shared counters (DRAPartitionableDevices feature gate disabled?)", result.Device)}
 		}
 		for _, c := range slices.Backward(configs) {
 			if slices.Contains(c.Requests, result.Request) {
 				if _, ok := c.Config.(*configapi.GpuConfig); ok && device.Type() != GpuDeviceType {
-					return nil, fmt.Errorf("cannot apply GPU config to request: %v", result.Request)
+					return nil, permanentError{fmt.Errorf("cannot apply GPU config to request: %v", result.Request)}
 				}
 				if _, ok := c.Config.(*configapi.MigDeviceConfig); ok && device.Type() != MigDeviceType {
-					return nil, fmt.Errorf("cannot apply MIG device config to request: %v", result.Request)
+					return nil, permanentError{fmt.Errorf("cannot apply MIG device config to request: %v", result.Request)}
+				}
+				if _, ok := c.Config.(*configapi.VfioGpuConfig); ok && device.Type() != GpuDeviceType {
+					return nil, permanentError{fmt.Errorf("cannot apply VFIO GPU config to request: %v", result.Request)}
 				}
 				configResultsMap[c.Config] = append(configResultsMap[c.Config], &result)
 				break
@@ -262,12 +760,30 @@ func (s *DeviceState) prepareDevices(ctx context.Context, claim *resourceapi.Res
 				if _, ok := c.Config.(*configapi.MigDeviceConfig); ok && device.Type() != MigDeviceType {
 					continue
 				}
+				if _, ok := c.Config.(*configapi.VfioGpuConfig); ok && device.Type() != GpuDeviceType {
+					continue
+				}
 				configResultsMap[c.Config] = append(configResultsMap[c.Config], &result)
 				break
 			}
 		}
 	}
 
+	// Resolve the devices backing each allocation result, creating any MIG
+	// devices that do not exist yet. MIG devices created here are destroyed
+	// again if preparing the claim fails further down.
+	devices, created, err := s.prepareMigDevices(claim.Status.Allocation.Devices.Results)
+	if err != nil {
+		return nil, fmt.Errorf("error preparing MIG devices: %w", err)
+	}
+	defer func() {
+		if rerr != nil {
+			if err := s.unprepareMigDevices(created); err != nil {
+				klog.Errorf("error cleaning up MIG devices for claim %v: %v", claim.UID, err)
+			}
+		}
+	}()
+
 	// Normalize, validate, and apply all configs associated with devices that
 	// need to be prepared. Track device group configs generated from applying the
 	// config to the set of device allocation results.
@@ -280,22 +796,24 @@ func (s *DeviceState) prepareDevices(ctx context.Context, claim *resourceapi.Res
 			config = castConfig
 		case *configapi.MigDeviceConfig:
 			config = castConfig
+		case *configapi.VfioGpuConfig:
+			config = castConfig
 		default:
-			return nil, fmt.Errorf("runtime object is not a recognized configuration")
+			return nil, permanentError{fmt.Errorf("runtime object is not a recognized configuration")}
 		}
 
 		// Normalize the config to set any implied defaults.
 		if err := config.Normalize(); err != nil {
-			return nil, fmt.Errorf("error normalizing GPU config: %w", err)
+			return nil, permanentError{fmt.Errorf("error normalizing GPU config: %w", err)}
 		}
 
 		// Validate the config to ensure its integrity.
 		if err := config.Validate(); err != nil {
-			return nil, fmt.Errorf("error validating GPU config: %w", err)
+			return nil, permanentError{fmt.Errorf("error validating GPU config: %w", err)}
 		}
 
 		// Apply the config to the list of results associated with it.
-		configState, err := s.applyConfig(ctx, config, claim, results)
+		configState, err := s.applyConfig(ctx, config, claim, results, devices)
 		if err != nil {
 			return nil, fmt.Errorf("error applying GPU config: %w", err)
 		}
@@ -313,11 +831,13 @@ func (s *DeviceState) prepareDevices(ctx context.Context, claim *resourceapi.Res
 		}
 
 		for _, result := range results {
+			// GPUs passed through to virtual machines are no longer bound to
+			// the NVIDIA driver, so their standard device edits do not apply.
 			cdiDevices := []string{}
-			if d := s.cdi.GetStandardDevice(s.allocatable[result.Device]); d != "" {
+			if d := s.cdi.GetStandardDevice(devices[result.Device]); d != "" && len(preparedDeviceGroup.ConfigState.VfioPciBusIDs) == 0 {
 				cdiDevices = append(cdiDevices, d)
 			}
-			if d := s.cdi.GetClaimDevice(string(claim.UID), s.allocatable[result.Device], preparedDeviceGroupConfigState[c].containerEdits); d != "" {
+			if d := s.cdi.GetClaimDevice(string(claim.UID), devices[result.Device], preparedDeviceGroupConfigState[c].containerEdits); d != "" {
 				cdiDevices = append(cdiDevices, d)
 			}
 
@@ -329,16 +849,17 @@ func (s *DeviceState) prepareDevices(ctx context.Context, claim *resourceapi.Res
 			}
 
 			var preparedDevice PreparedDevice
-			switch s.allocatable[result.Device].Type() {
+			switch devices[result.Device].Type() {
 			case GpuDeviceType:
 				preparedDevice.Gpu = &PreparedGpu{
-					Info:   s.allocatable[result.Device].Gpu,
+					Info:   devices[result.Device].Gpu,
 					Device: device,
 				}
 			case MigDeviceType:
 				preparedDevice.Mig = &PreparedMigDevice{
-					Info:   s.allocatable[result.Device].Mig,
-					Device: device,
+					Info:    devices[result.Device].Mig,
+					Device:  device,
+					Created: devices[result.Device].Mig.dynamic,
 				}
 			}
 
@@ -350,8 +871,64 @@ func (s *DeviceState) prepareDevices(ctx context.Context, claim *resourceapi.Res
 	return preparedDevices, nil
 }
 
+func (s *DeviceState) prepareMigDevices(results []resourceapi.DeviceRequestAllocationResult) (AllocatableDevices, []*MigDeviceInfo, error) {
+	devices := make(AllocatableDevices)
+	var created []*MigDeviceInfo
+	for _, result := range results {
+		if result.Driver != DriverName {
+			continue
+		}
+		device, _ := s.allocatableDevice(result.Device)
+		if device.Type() != MigDeviceType || device.Mig.Exists() {
+			devices[result.Device] = device
+			continue
+		}
+		migInfo, err := s.nvdevlib.createMigDevice(device.Mig)
+		if err != nil {
+			if err := s.unprepareMigDevices(created); err != nil {
+				klog.Errorf("error cleaning up MIG devices: %v", err)
+			}
+			return nil, nil, fmt.Errorf("error creating MIG device %v: %w", result.Device, err)
+		}
+		created = append(created, migInfo)
+		devices[result.Device] = &AllocatableDevice{
+			Mig: migInfo,
+		}
+	}
+	return devices, created, nil
+}
+
+func (s *DeviceState) unprepareMigDevices(devices []*MigDeviceInfo) error {
+	for _, device := range devices {
+		if err := s.nvdevlib.deleteMigDevice(device.UUID); err != nil {
+			return fmt.Errorf("error deleting MIG device %v: %w", device.UUID, err)
+		}
+	}
+	return nil
+}
+
 func (s *DeviceState) unprepareDevices(ctx context.Context, claimUID string, devices PreparedDevices) error {
 	for _, group := range devices {
+		// Hand GPUs passed through to virtual machines back to the NVIDIA
+		// driver. Their device nodes may have changed in the meantime, so the
+		// base CDI spec is regenerated.
+		if len(group.ConfigState.VfioPciBusIDs) > 0 {
+			for _, pciBusID := range group.ConfigState.VfioPciBusIDs {
+				if err := s.unbindVfio(pciBusID); err != nil {
+					return fmt.Errorf("error unbinding GPU %v from %v: %w", pciBusID, VfioPciDriver, err)
+				}
+			}
+			if err := s.cdi.CreateStandardDeviceSpecFile(s.Allocatable()); err != nil {
+				return fmt.Errorf("unable to create base CDI spec file: %w", err)
+			}
+			continue
+		}
+
+		// Restore the original settings of any GPUs reconfigured for the claim.
+		if err := s.nvdevlib.restoreGpuSettings(group.ConfigState.GpuSettings); err != nil {
+			return fmt.Errorf("error restoring GPU settings: %w", err)
+		}
+
 		// Stop any MPS control daemons started for each group of prepared devices.
 		mpsControlDaemon := s.mpsManager.NewMpsControlDaemon(claimUID, group)
 		if err := mpsControlDaemon.Stop(ctx); err != nil {
@@ -359,26 +936,153 @@ func (s *DeviceState) unprepareDevices(ctx context.Context, claimUID string, dev
 		}
 
 		// Go back to default time-slicing for all full GPUs.
-		tsc := configapi.DefaultGpuConfig().Sharing.TimeSlicingConfig
-		if err := s.tsManager.SetTimeSlice(group.Devices.Gpus(), tsc); err != nil {
-			return fmt.Errorf("error setting timeslice for devices: %w", err)
+		if err := s.tsManager.ResetTimeSlice(group.Devices.Gpus(), group.ConfigState.TimeSlicingConfig); err != nil {
+			return fmt.Errorf("error resetting timeslice for devices: %w", err)
+		}
+
+		// Destroy any MIG devices that were created when preparing the claim.
+		for _, device := range group.Devices.MigDevices() {
+			if !device.Mig.Created {
+				continue
+			}
+			if err := s.nvdevlib.deleteMigDevice(device.Mig.Info.UUID); err != nil {
+				return fmt.Errorf("error deleting MIG device %v: %w", device.Mig.Info.UUID, err)
+			}
+			// The device may have been enumerated as an existing MIG device
+			// after a restart; make it available for on demand creation again.
+			name := device.Mig.Device.DeviceName
+			s.Lock()
+			if d, exists := s.allocatable[name]; exists && d.Mig != nil && !d.Mig.dynamic {
+				s.allocatable[name] = &AllocatableDevice{
+					Mig: newDynamicMigDeviceInfo(d.Mig.parent, d.Mig.migProfile, d.Mig.placement),
+				}
+			}
+			s.Unlock()
 		}
 	}
 	return nil
 }
 
-func (s *DeviceState) applyConfig(ctx context.Context, config configapi.Interface, claim *resourceapi.ResourceClaim, results []*resourceapi.DeviceRequestAllocationResult) (*DeviceConfigState, error) {
+// scrubDevices scrubs the devices of a claim once it has been unprepared.
+// Devices still held by other claims are left alone, and MIG devices created
+// for the claim are already gone. Full GPUs are reset only if no other claim holds them.
+func (s *DeviceState) scrubDevices(ctx context.Context, claimUID string, devices PreparedDevices, claims PreparedClaimsByUID) error {
+	var created []string
+	for _, group := range devices {
+		for _, device := range group.Devices.MigDevices() {
+			if device.Mig.Created {
+				created = append(created, device.Mig.Info.UUID)
+			}
+		}
+	}
+
+	var uuids []string
+	for _, uuid := range devices.UUIDs() {
+		if slices.Contains(created, uuid) {
+			continue
+		}
+		holders := slices.DeleteFunc(claims.DeviceHolders(uuid), func(uid string) bool {
+			return uid == claimUID
+		})
+		if len(holders) > 0 {
+			klog.Infof("Not scrubbing device %v: still in use by claims %v", uuid, holders)
+			continue
+		}
+		uuids = append(uuids, uuid)
+	}
+
+	err := s.scrubber.Scrub(ctx, uuids, devices.GpuUUIDs())
+
+	// GPUs that may have been reset come back with new device nodes, so the
+	// base CDI spec is regenerated whether or not scrubbing succeeded.
+	if s.scrubber.policy == GpuScrubPolicyReset && slices.ContainsFunc(uuids, func(uuid string) bool {
+		return slices.Contains(devices.GpuUUIDs(), uuid)
+	}) {
+		if cdiErr := s.cdi.CreateStandardDeviceSpecFile(s.Allocatable()); cdiErr != nil {
+			err = errors.Join(err, fmt.Errorf("unable to create base CDI spec file: %w", cdiErr))
+		}
+	}
+
+	return err
+}
+
+func (s *DeviceState) applyConfig(ctx context.Context, config configapi.Interface, claim *resourceapi.ResourceClaim, results []*resourceapi.DeviceRequestAllocationResult, devices AllocatableDevices) (*DeviceConfigState, error) {
 	switch castConfig := config.(type) {
 	case *configapi.GpuConfig:
-		return s.applySharingConfig(ctx, castConfig.Sharing, claim, results)
+		return s.applyGpuConfig(ctx, castConfig, claim, results, devices)
 	case *configapi.MigDeviceConfig:
-		return s.applySharingConfig(ctx, castConfig.Sharing, claim, results)
+		return s.applySharingConfig(ctx, castConfig.Sharing, claim, results, devices)
+	case *configapi.VfioGpuConfig:
+		return s.applyVfioConfig(claim, results, devices)
 	default:
 		return nil, fmt.Errorf("unknown config type: %T", castConfig)
 	}
 }
 
-func (s *DeviceState) applySharingConfig(ctx context.Context, config configapi.Sharing, claim *resourceapi.ResourceClaim, results []*resourceapi.DeviceRequestAllocationResult) (*DeviceConfigState, error) {
+// applyGpuConfig applies the power, clock and persistence mode settings of a
+// GpuConfig followed by its sharing configuration. The settings and the
+// original GPU settings are recorded in the returned state, so that they can
+// be re-applied after a node reboot and restored when the claim is
+// unprepared.
+func (s *DeviceState) applyGpuConfig(ctx context.Context, config *configapi.GpuConfig, claim *resourceapi.ResourceClaim, results []*resourceapi.DeviceRequestAllocationResult, devices AllocatableDevices) (*DeviceConfigState, error) {
+	allocatableDevices := make(AllocatableDevices)
+	for _, r := range results {
+		allocatableDevices[r.Device] = devices[r.Device]
+	}
+
+	gpuSettings, err := s.nvdevlib.applyGpuSettings(allocatableDevices.GpuUUIDs(), config)
+	if err != nil {
+		return nil, fmt.Errorf("error applying GPU settings for claim '%v': %w", claim.UID, err)
+	}
+
+	configState, err := s.applySharingConfig(ctx, config.Sharing, claim, results, devices)
+	if err != nil {
+		if err := s.nvdevlib.restoreGpuSettings(gpuSettings); err != nil {
+			klog.Errorf("error restoring GPU settings for claim '%v': %v", claim.UID, err)
+		}
+		return nil, err
+	}
+	if gpuSettings != nil {
+		configState.GpuSettings = gpuSettings
+		configState.GpuConfig = config.DeepCopy()
+		configState.GpuConfig.Sharing = nil
+	}
+
+	return configState, nil
+}
+
+// applyVfioConfig binds the GPUs of a claim to the vfio-pci driver so that
+// they can be passed through to virtual machines. If binding any GPU fails,
+// all GPUs are bound back to the NVIDIA driver.
+func (s *DeviceState) applyVfioConfig(claim *resourceapi.ResourceClaim, results []*resourceapi.DeviceRequestAllocationResult, devices AllocatableDevices) (_ *DeviceConfigState, rerr error) {
+	var configState DeviceConfigState
+	defer func() {
+		if rerr == nil {
+			return
+		}
+		for _, pciBusID := range configState.VfioPciBusIDs {
+			if err := s.unbindVfio(pciBusID); err != nil {
+				klog.Errorf("error unbinding GPU %v from %v for claim '%v': %v", pciBusID, VfioPciDriver, claim.UID, err)
+			}
+		}
+	}()
+
+	var groups []string
+	for _, r := range results {
+		gpu := devices[r.Device].Gpu
+		group, err := s.bindVfio(gpu.pciBusID)
+		if err != nil {
+			return nil, fmt.Errorf("error binding GPU %v to %v for claim '%v': %w", gpu.pciBusID, VfioPciDriver, claim.UID, err)
+		}
+		configState.VfioPciBusIDs = append(configState.VfioPciBusIDs, gpu.pciBusID)
+		groups = append(groups, group)
+	}
+	configState.containerEdits = getVfioContainerEdits(groups)
+
+	return &configState, nil
+}
+
+func (s *DeviceState) applySharingConfig(ctx context.Context, config configapi.Sharing, claim *resourceapi.ResourceClaim, results []*resourceapi.DeviceRequestAllocationResult, devices AllocatableDevices) (*DeviceConfigState, error) {
 	// Get the list of claim requests this config is being applied over.
 	var requests []string
 	for _, r := range results {
@@ -388,7 +1092,7 @@ func (s *DeviceState) applySharingConfig(ctx context.Context, config configapi.S
 	// Get the list of allocatable devices this config is being applied over.
 	allocatableDevices := make(AllocatableDevices)
 	for _, r := range results {
-		allocatableDevices[r.Device] = s.allocatable[r.Device]
+		allocatableDevices[r.Device] = devices[r.Device]
 	}
 
 	// Declare a device group state object to populate.
@@ -405,6 +1109,7 @@ func (s *DeviceState) applySharingConfig(ctx context.Context, config configapi.S
 			if err != nil {
 				return nil, fmt.Errorf("error setting timeslice config for requests '%v' in claim '%v': %w", requests, claim.UID, err)
 			}
+			configState.TimeSlicingConfig = tsc
 		}
 	}
 
@@ -422,6 +1127,7 @@ func (s *DeviceState) applySharingConfig(ctx context.Context, config configapi.S
 			return nil, fmt.Errorf("MPS control daemon is not yet ready: %w", err)
 		}
 		configState.MpsControlDaemonID = mpsControlDaemon.GetID()
+		configState.MpsConfig = mpsc
 		configState.containerEdits = mpsControlDaemon.GetCDIContainerEdits()
 	}
 
@@ -434,7 +1140,8 @@ func (s *DeviceState) applySharingConfig(ctx context.Context, config configapi.S
 // class associated with the request. Configs coming directly from the resource
 // claim take precedence over configs coming from the device class. Moreover,
 // configs found later in the list of configs attached to its source take
-// precedence over configs found earlier in the list for that source.
+// precedence over configs found earlier in the list for that source. Default
+// configs are passed in as device class configs in front of all others.
 //
 // All of the configs relevant to the driver from the list of possibleConfigs
 // will be returned in order of precedence (from lowest to highest). If no
@@ -494,50 +1201,6 @@ func GetOpaqueDeviceConfigs(
 	return resultConfigs, nil
 }
 
-// TODO: Dynamic MIG is not yet supported with structured parameters.
-// Refactor this to allow for the allocation of statically partitioned MIG
-// devices.
-//
-// func (s *DeviceState) prepareMigDevices(claimUID string, allocated *nascrd.AllocatedMigDevices) (*PreparedMigDevices, error) {
-// 	prepared := &PreparedMigDevices{}
-//
-// 	for _, device := range allocated.Devices {
-// 		if _, exists := s.allocatable[device.ParentUUID]; !exists {
-// 			return nil, fmt.Errorf("allocated GPU does not exist: %v", device.ParentUUID)
-// 		}
-//
-// 		parent := s.allocatable[device.ParentUUID]
-//
-// 		if !parent.migEnabled {
-// 			return nil, fmt.Errorf("cannot prepare a GPU with MIG mode disabled: %v", device.ParentUUID)
-// 		}
-//
-// 		if _, exists := parent.migProfiles[device.Profile]; !exists {
-// 			return nil, fmt.Errorf("MIG profile %v does not exist on GPU: %v", device.Profile, device.ParentUUID)
-// 		}
-//
-// 		placement := nvml.GpuInstancePlacement{
-// 			Start: uint32(device.Placement.Start),
-// 			Size:  uint32(device.Placement.Size),
-// 		}
-//
-// 		migInfo, err := s.nvdevlib.createMigDevice(parent.GpuInfo, parent.migProfiles[device.Profile].profile, &placement)
-// 		if err != nil {
-// 			return nil, fmt.Errorf("error creating MIG device: %w", err)
-// 		}
-//
-// 		prepared.Devices = append(prepared.Devices, migInfo)
-// 	}
-//
-// 	return prepared, nil
-// }
-//
-// func (s *DeviceState) unprepareMigDevices(claimUID string, devices *PreparedDevices) error {
-// 	for _, device := range devices.Mig.Devices {
-// 		err := s.nvdevlib.deleteMigDevice(device)
-// 		if err != nil {
-// 			return fmt.Errorf("error deleting MIG device for %v: %w", device.uuid, err)
-// 		}
-// 	}
-// 	return nil
-//}
+func setPreparedClaimsMetric(claims PreparedClaimsByUID) {
+	metrics.PreparedClaims.WithLabelValues(DriverName).Set(float64(len(claims)))
+}
@@ -0,0 +1,564 @@
+/*
+ * Copyright (c) 2025, NVIDIA CORPORATION.  All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+	"github.com/NVIDIA/nvidia-container-toolkit/pkg/nvcdi"
+	"github.com/stretchr/testify/require"
+	resourceapi "k8s.io/api/resource/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/dynamic-resource-allocation/kubeletplugin"
+	"k8s.io/utils/ptr"
+	cdiapi "tags.cncf.io/container-device-interface/pkg/cdi"
+	cdispec "tags.cncf.io/container-device-interface/specs-go"
+
+	configapi "github.com/NVIDIA/k8s-dra-driver-gpu/api/nvidia.com/resource/v1beta1"
+	"github.com/NVIDIA/k8s-dra-driver-gpu/pkg/nvmlmock"
+)
+
+// fakeNvcdi generates CDI device edits without looking at the driver
+// installation of the host, which the mock NVML backend does not provide.
+type fakeNvcdi struct {
+	nvcdi.Interface
+}
+
+func (fakeNvcdi) GetCommonEdits() (*cdiapi.ContainerEdits, error) {
+	return &cdiapi.ContainerEdits{ContainerEdits: &cdispec.ContainerEdits{}}, nil
+}
+
+func (fakeNvcdi) GetDeviceSpecsByID(ids ...string) ([]cdispec.Device, error) {
+	var devices []cdispec.Device
+	for _, id := range ids {
+		devices = append(devices, cdispec.Device{
+			Name: id,
+			ContainerEdits: cdispec.ContainerEdits{
+				Env: []string{"NVIDIA_DEVICE=" + id},
+			},
+		})
+	}
+	return devices, nil
+}
+
+// newMockDeviceState creates a DeviceState on top of a mock NVML profile. Its
+// plugin directory and CDI root are temporary directories.
+func newMockDeviceState(t *testing.T, profile *nvmlmock.Profile) (*DeviceState, *nvmlmock.Server) {
+	server, err := nvmlmock.New(profile)
+	require.NoError(t, err)
+	nvdevlib, err := newDeviceLib(root(t.TempDir()), server)
+	require.NoError(t, err)
+	nvdevlib.sysfsRoot = t.TempDir()
+	fakeNvidiaSMI(t, nvdevlib, 0)
+
+	config := &Config{
+		flags: &Flags{
+			kubeletPluginsDirectoryPath: t.TempDir(),
+			cdiRoot:                     t.TempDir(),
+			gpuScrubPolicy:              string(GpuScrubPolicyNone),
+		},
+		nvmllib: server,
+	}
+	require.NoError(t, os.MkdirAll(config.DriverPluginPath(), 0750))
+
+	cdi, err := NewCDIHandler(
+		WithNvml(server),
+		WithDeviceLib(nvdevlib),
+		WithCDIRoot(config.flags.cdiRoot),
+	)
+	require.NoError(t, err)
+	cdi.nvcdiDevice = fakeNvcdi{}
+	cdi.nvcdiClaim = fakeNvcdi{}
+
+	state, err := newDeviceState(context.Background(), config, nvdevlib, cdi)
+	require.NoError(t, err)
+	state.sharedCounters = true
+	return state, server
+}
+
+func newTestClaim(t *testing.T, devices []string, config runtime.Object) *resourceapi.ResourceClaim {
+	claim := &resourceapi.ResourceClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "claim",
+			Namespace: "default",
+			UID:       types.UID("claim-uid"),
+		},
+		Status: resourceapi.ResourceClaimStatus{
+			Allocation: &resourceapi.AllocationResult{},
+		},
+	}
+	for _, device := range devices {
+		claim.Status.Allocation.Devices.Results = append(claim.Status.Allocation.Devices.Results, resourceapi.DeviceRequestAllocationResult{
+			Request: "request",
+			Driver:  DriverName,
+			Pool:    "node",
+			Device:  device,
+		})
+	}
+	if config != nil {
+		raw, err := json.Marshal(config)
+		require.NoError(t, err)
+		claim.Status.Allocation.Devices.Config = append(claim.Status.Allocation.Devices.Config, resourceapi.DeviceAllocationConfiguration{
+			Source: resourceapi.AllocationConfigSourceClaim,
+			DeviceConfiguration: resourceapi.DeviceConfiguration{
+				Opaque: &resourceapi.OpaqueDeviceConfiguration{
+					Driver:     DriverName,
+					Parameters: runtime.RawExtension{Raw: raw},
+				},
+			},
+		})
+	}
+	return claim
+}
+
+func TestPrepareAndUnprepare(t *testing.T) {
+	hasClaimSpecFile := func(t *testing.T, state *DeviceState) bool {
+		claimUIDs, err := state.cdi.ListClaimSpecFiles()
+		require.NoError(t, err)
+		return slices.Contains(claimUIDs, "claim-uid")
+	}
+	gpuUUIDs := func(state *DeviceState, names ...string) []string {
+		var uuids []string
+		for _, name := range names {
+			device, _ := state.allocatableDevice(name)
+			uuids = append(uuids, device.Gpu.UUID)
+		}
+		return uuids
+	}
+	migExists := func(t *testing.T, state *DeviceState, name string) bool {
+		devices, err := state.nvdevlib.enumerateAllPossibleDevices(state.config)
+		require.NoError(t, err)
+		require.Contains(t, devices, name)
+		return devices[name].Mig.Exists()
+	}
+
+	testCases := []struct {
+		name     string
+		failures nvmlmock.Failures
+		devices  []string
+		config   runtime.Object
+		// noSharedCounters simulates an API server without shared counters.
+		noSharedCounters bool
+
+		expectedError   string
+		expectedCDIIDs  []string
+		checkPrepared   func(*testing.T, *DeviceState, *nvmlmock.Server)
+		checkUnprepared func(*testing.T, *DeviceState, *nvmlmock.Server)
+	}{
+		{
+			name:           "full GPU",
+			devices:        []string{"gpu-0"},
+			expectedCDIIDs: []string{"k8s.gpu.nvidia.com/device=gpu-0"},
+			checkPrepared: func(t *testing.T, state *DeviceState, _ *nvmlmock.Server) {
+				require.False(t, hasClaimSpecFile(t, state))
+			},
+		},
+		{
+			name:           "existing MIG device",
+			devices:        []string{"gpu-4-mig-0-0-1"},
+			expectedCDIIDs: []string{"k8s.gpu.nvidia.com/device=gpu-4-mig-0-0-1"},
+			checkUnprepared: func(t *testing.T, state *DeviceState, _ *nvmlmock.Server) {
+				require.True(t, migExists(t, state, "gpu-4-mig-0-0-1"))
+			},
+		},
+		{
+			name:           "dynamic MIG device",
+			devices:        []string{"gpu-4-mig-0-2-1"},
+			expectedCDIIDs: []string{"k8s.gpu.nvidia.com/claim=claim-uid-gpu-4-mig-0-2-1"},
+			checkPrepared: func(t *testing.T, state *DeviceState, _ *nvmlmock.Server) {
+				require.True(t, migExists(t, state, "gpu-4-mig-0-2-1"))
+				require.True(t, hasClaimSpecFile(t, state))
+			},
+			checkUnprepared: func(t *testing.T, state *DeviceState, _ *nvmlmock.Server) {
+				require.False(t, migExists(t, state, "gpu-4-mig-0-2-1"))
+			},
+		},
+		{
+			name:             "dynamic MIG device without shared counters",
+			devices:          []string{"gpu-4-mig-0-2-1"},
+			noSharedCounters: true,
+			expectedError:    "cannot be created on demand",
+			checkPrepared: func(t *testing.T, state *DeviceState, _ *nvmlmock.Server) {
+				require.False(t, migExists(t, state, "gpu-4-mig-0-2-1"))
+			},
+		},
+		{
+			name:    "time-slicing",
+			devices: []string{"gpu-0", "gpu-1"},
+			config: &configapi.GpuConfig{
+				TypeMeta: metav1.TypeMeta{
+					APIVersion: configapi.GroupName + "/" + configapi.Version,
+					Kind:       configapi.GpuConfigKind,
+				},
+				Sharing: &configapi.GpuSharing{
+					Strategy: configapi.TimeSlicingStrategy,
+					TimeSlicingConfig: &configapi.TimeSlicingConfig{
+						Interval: ptr.To(configapi.LongTimeSlice),
+					},
+				},
+			},
+			expectedCDIIDs: []string{
+				"k8s.gpu.nvidia.com/device=gpu-0",
+				"k8s.gpu.nvidia.com/device=gpu-1",
+			},
+			checkPrepared: func(t *testing.T, state *DeviceState, _ *nvmlmock.Server) {
+				for _, uuid := range gpuUUIDs(state, "gpu-0", "gpu-1") {
+					require.Equal(t, configapi.LongTimeSlice.Int(), recordedTimeSlice(t, state.nvdevlib, uuid))
+				}
+			},
+			checkUnprepared: func(t *testing.T, state *DeviceState, _ *nvmlmock.Server) {
+				for _, uuid := range gpuUUIDs(state, "gpu-0", "gpu-1") {
+					require.Equal(t, 0, recordedTimeSlice(t, state.nvdevlib, uuid))
+				}
+			},
+		},
+		{
+			name:          "failure rolls back created MIG devices",
+			failures:      nvmlmock.Failures{"SetComputeMode": "ERROR_NO_PERMISSION"},
+			devices:       []string{"gpu-0", "gpu-4-mig-0-2-1"},
+			expectedError: "ERROR_NO_PERMISSION",
+			checkPrepared: func(t *testing.T, state *DeviceState, _ *nvmlmock.Server) {
+				require.False(t, migExists(t, state, "gpu-4-mig-0-2-1"))
+				require.False(t, hasClaimSpecFile(t, state))
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			profile, err := nvmlmock.LoadProfile("dgx-a100")
+			require.NoError(t, err)
+			profile.Gpus[0].Failures = tc.failures
+			state, server := newMockDeviceState(t, profile)
+			state.sharedCounters = !tc.noSharedCounters
+			ctx := context.Background()
+
+			claim := newTestClaim(t, tc.devices, tc.config)
+			prepared, err := state.Prepare(ctx, claim)
+			if tc.expectedError != "" {
+				require.ErrorContains(t, err, tc.expectedError)
+				_, exists := state.checkpoint.Get(string(claim.UID))
+				require.False(t, exists)
+			} else {
+				require.NoError(t, err)
+				require.Len(t, prepared, len(tc.devices))
+				var cdiIDs []string
+				for _, device := range prepared {
+					cdiIDs = append(cdiIDs, device.CDIDeviceIDs...)
+				}
+				require.ElementsMatch(t, tc.expectedCDIIDs, cdiIDs)
+				_, exists := state.checkpoint.Get(string(claim.UID))
+				require.True(t, exists)
+
+				// Preparing a claim again returns the same devices.
+				again, err := state.Prepare(ctx, claim)
+				require.NoError(t, err)
+				require.ElementsMatch(t, prepared, again)
+			}
+			if tc.checkPrepared != nil {
+				tc.checkPrepared(t, state, server)
+			}
+			if tc.expectedError != "" {
+				return
+			}
+
+			require.NoError(t, state.Unprepare(ctx, string(claim.UID)))
+			_, exists := state.checkpoint.Get(string(claim.UID))
+			require.False(t, exists)
+			require.False(t, hasClaimSpecFile(t, state))
+			if tc.checkUnprepared != nil {
+				tc.checkUnprepared(t, state, server)
+			}
+		})
+	}
+}
+
+func TestRestoreCreatedMigDevices(t *testing.T) {
+	testCases := []struct {
+		name string
+		// occupy names a MIG device created while the plugin is down.
+		occupy string
+
+		expectRestored bool
+	}{
+		{
+			name:           "placement available",
+			expectRestored: true,
+		},
+		{
+			name:           "placement taken",
+			occupy:         "gpu-4-mig-1-2-2",
+			expectRestored: false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			profile, err := nvmlmock.LoadProfile("dgx-a100")
+			require.NoError(t, err)
+			state, _ := newMockDeviceState(t, profile)
+			ctx := context.Background()
+
+			claim := newTestClaim(t, []string{"gpu-4-mig-0-2-1"}, nil)
+			_, err = state.Prepare(ctx, claim)
+			require.NoError(t, err)
+			pc, exists := state.checkpoint.Get(string(claim.UID))
+			require.True(t, exists)
+			uuid := pc.PreparedDevices.MigDeviceUUIDs()[0]
+
+			// Simulate a node reboot, which destroys all MIG devices.
+			require.NoError(t, state.nvdevlib.deleteMigDevice(uuid))
+			if tc.occupy != "" {
+				device, _ := state.allocatableDevice(tc.occupy)
+				_, err := state.nvdevlib.createMigDevice(device.Mig)
+				require.NoError(t, err)
+			}
+
+			restarted, err := newDeviceState(ctx, state.config, state.nvdevlib, state.cdi)
+			require.NoError(t, err)
+			pc, exists = restarted.checkpoint.Get(string(claim.UID))
+			require.Equal(t, tc.expectRestored, exists)
+			claimUIDs, err := restarted.cdi.ListClaimSpecFiles()
+			require.NoError(t, err)
+			require.Equal(t, tc.expectRestored, slices.Contains(claimUIDs, string(claim.UID)))
+			if !tc.expectRestored {
+				return
+			}
+
+			newUUID := pc.PreparedDevices.MigDeviceUUIDs()[0]
+			require.NotEqual(t, uuid, newUUID)
+			exists, err = restarted.nvdevlib.migDeviceExists(newUUID)
+			require.NoError(t, err)
+			require.True(t, exists)
+
+			require.NoError(t, restarted.Unprepare(ctx, string(claim.UID)))
+			exists, err = restarted.nvdevlib.migDeviceExists(newUUID)
+			require.NoError(t, err)
+			require.False(t, exists)
+		})
+	}
+}
+
+func TestRestoreGpuSettings(t *testing.T) {
+	profile, err := nvmlmock.LoadProfile("dgx-a100")
+	require.NoError(t, err)
+	state, server := newMockDeviceState(t, profile)
+	ctx := context.Background()
+
+	device, _ := state.allocatableDevice("gpu-0")
+	handle, ret := server.DeviceGetHandleByUUID(device.Gpu.UUID)
+	require.Equal(t, nvml.SUCCESS, ret)
+	powerLimit := func() uint32 {
+		limit, ret := handle.GetPowerManagementLimit()
+		require.Equal(t, nvml.SUCCESS, ret)
+		return limit
+	}
+
+	claim := newTestClaim(t, []string{"gpu-0"}, &configapi.GpuConfig{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: configapi.GroupName + "/" + configapi.Version,
+			Kind:       configapi.GpuConfigKind,
+		},
+		PowerLimitWatts: ptr.To(300),
+	})
+	_, err = state.Prepare(ctx, claim)
+	require.NoError(t, err)
+	require.Equal(t, uint32(300000), powerLimit())
+
+	// Simulate a node reboot, which resets the power limit to its default.
+	require.Equal(t, nvml.SUCCESS, handle.SetPowerManagementLimit(400000))
+
+	restarted, err := newDeviceState(ctx, state.config, state.nvdevlib, state.cdi)
+	require.NoError(t, err)
+	require.Equal(t, uint32(300000), powerLimit())
+
+	require.NoError(t, restarted.Unprepare(ctx, string(claim.UID)))
+	require.Equal(t, uint32(400000), powerLimit())
+}
+
+func TestUnprepareWithFailedScrub(t *testing.T) {
+	profile, err := nvmlmock.LoadProfile("dgx-a100")
+	require.NoError(t, err)
+	state, server := newMockDeviceState(t, profile)
+	ctx := context.Background()
+
+	// A process that cannot be killed keeps gpu-0 from being scrubbed.
+	device, _ := state.allocatableDevice("gpu-0")
+	require.NoError(t, server.StartProcess(device.Gpu.UUID, 1000))
+	state.scrubber = NewGpuScrubber(state.nvdevlib, GpuScrubPolicyKillProcesses, 10*time.Millisecond)
+	state.scrubber.pollInterval = time.Millisecond
+	state.scrubber.kill = func(int) error { return nil }
+
+	claim := newTestClaim(t, []string{"gpu-0"}, nil)
+	_, err = state.Prepare(ctx, claim)
+	require.NoError(t, err)
+
+	err = state.Unprepare(ctx, string(claim.UID))
+	require.ErrorContains(t, err, "processes still running")
+	pc, exists := state.checkpoint.Get(string(claim.UID))
+	require.True(t, exists)
+	require.True(t, pc.ScrubPending)
+
+	// The GPU is not handed to the next claim until it has been scrubbed.
+	next := newTestClaim(t, []string{"gpu-0"}, nil)
+	next.UID = "next-claim-uid"
+	_, err = state.Prepare(ctx, next)
+	require.ErrorContains(t, err, "still waiting to be scrubbed after claims [claim-uid]")
+
+	// Unpreparing the claim again only retries the scrub.
+	server.StopProcess(1000)
+	require.NoError(t, state.Unprepare(ctx, string(claim.UID)))
+	_, exists = state.checkpoint.Get(string(claim.UID))
+	require.False(t, exists)
+
+	_, err = state.Prepare(ctx, next)
+	require.NoError(t, err)
+}
+
+func TestReconcileWithVfioDevices(t *testing.T) {
+	profile, err := nvmlmock.LoadProfile("dgx-a100")
+	require.NoError(t, err)
+	state, server := newMockDeviceState(t, profile)
+	ctx := context.Background()
+
+	uuids := make(map[string]string)
+	for name, device := range state.Allocatable() {
+		uuids[name] = device.UUID()
+	}
+
+	// gpu-3 is passed through to a virtual machine, while gpu-2 falls off
+	// the bus.
+	vfioGpu, _ := state.allocatableDevice("gpu-3")
+	err = state.checkpoint.Update(func(claims PreparedClaimsByUID) {
+		claims["vfio-claim-uid"] = PreparedClaim{
+			PreparedDevices: PreparedDevices{
+				{
+					Devices: PreparedDeviceList{
+						{Gpu: &PreparedGpu{Info: vfioGpu.Gpu, Device: &kubeletplugin.Device{DeviceName: "gpu-3"}}},
+					},
+					ConfigState: DeviceConfigState{VfioPciBusIDs: []string{vfioGpu.Gpu.pciBusID}},
+				},
+			},
+		}
+	})
+	require.NoError(t, err)
+	require.NoError(t, server.DetachGpu(uuids["gpu-3"]))
+	require.NoError(t, server.DetachGpu(uuids["gpu-2"]))
+
+	changed, err := state.Reconcile(ctx)
+	require.NoError(t, err)
+	require.True(t, changed)
+
+	// The GPUs after the detached ones keep their names until the
+	// passed-through GPU is handed back, only gpu-2 is gone.
+	allocatable := state.Allocatable()
+	require.NotContains(t, allocatable, "gpu-2")
+	for name, uuid := range uuids {
+		if name == "gpu-2" {
+			continue
+		}
+		require.Contains(t, allocatable, name)
+		require.Equal(t, uuid, allocatable[name].UUID(), name)
+	}
+
+	require.NoError(t, server.AttachGpu(uuids["gpu-3"]))
+	require.NoError(t, server.AttachGpu(uuids["gpu-2"]))
+	require.NoError(t, state.checkpoint.Update(func(claims PreparedClaimsByUID) {
+		delete(claims, "vfio-claim-uid")
+	}))
+
+	changed, err = state.Reconcile(ctx)
+	require.NoError(t, err)
+	require.True(t, changed)
+	allocatable = state.Allocatable()
+	for name, uuid := range uuids {
+		require.Equal(t, uuid, allocatable[name].UUID(), name)
+	}
+}
+
+func TestRestartWithVfioDevices(t *testing.T) {
+	profile, err := nvmlmock.LoadProfile("dgx-a100")
+	require.NoError(t, err)
+	state, server := newMockDeviceState(t, profile)
+	ctx := context.Background()
+
+	uuids := make(map[string]string)
+	for name, device := range state.Allocatable() {
+		uuids[name] = device.UUID()
+	}
+
+	// The plugin restarts while gpu-3 is passed through to a virtual
+	// machine.
+	vfioGpu, _ := state.allocatableDevice("gpu-3")
+	err = state.checkpoint.Update(func(claims PreparedClaimsByUID) {
+		claims["vfio-claim-uid"] = PreparedClaim{
+			PreparedDevices: PreparedDevices{
+				{
+					Devices: PreparedDeviceList{
+						{Gpu: &PreparedGpu{Info: vfioGpu.Gpu, Device: &kubeletplugin.Device{DeviceName: "gpu-3"}}},
+					},
+					ConfigState: DeviceConfigState{VfioPciBusIDs: []string{vfioGpu.Gpu.pciBusID}},
+				},
+			},
+		}
+	})
+	require.NoError(t, err)
+	require.NoError(t, server.DetachGpu(uuids["gpu-3"]))
+
+	restarted, err := newDeviceState(ctx, state.config, state.nvdevlib, state.cdi)
+	require.NoError(t, err)
+
+	// The GPUs after the passed-through one keep their names.
+	isVfioGpu := func(name string) bool {
+		return name == "gpu-3" || strings.HasPrefix(name, "gpu-3-")
+	}
+	allocatable := restarted.Allocatable()
+	for name, uuid := range uuids {
+		if isVfioGpu(name) {
+			require.NotContains(t, allocatable, name)
+			continue
+		}
+		require.Contains(t, allocatable, name)
+		require.Equal(t, uuid, allocatable[name].UUID(), name)
+	}
+
+	changed, err := restarted.Reconcile(ctx)
+	require.NoError(t, err)
+	require.False(t, changed)
+
+	require.NoError(t, server.AttachGpu(uuids["gpu-3"]))
+	require.NoError(t, restarted.checkpoint.Update(func(claims PreparedClaimsByUID) {
+		delete(claims, "vfio-claim-uid")
+	}))
+
+	changed, err = restarted.Reconcile(ctx)
+	require.NoError(t, err)
+	require.True(t, changed)
+	allocatable = restarted.Allocatable()
+	for name, uuid := range uuids {
+		require.Equal(t, uuid, allocatable[name].UUID(), name)
+	}
+}
@@ -31,6 +31,7 @@ type GpuInfo struct {
 	UUID                  string `json:"uuid"`
 	index                 int
 	minor                 int
+	migCapable            bool
 	migEnabled            bool
 	memoryBytes           uint64
 	productName           string
@@ -39,7 +40,27 @@ type GpuInfo struct {
 	cudaComputeCapability string
 	driverVersion         string
 	cudaDriverVersion     string
+	pciBusID              string
+	pcieRoot              string
+	numaNode              *int
+	nvlinkPeers           []string
+	nvlinkSwitch          bool
+	nvlinkIsland          *int
 	migProfiles           []*MigProfileInfo
+
+	// Properties that not all GPUs report. They are left at their zero
+	// value (or nil) if unsupported and are not published in that case.
+	// Settings a GpuConfig can change, such as the current power limit or
+	// the persistence mode, are deliberately left out, as the published
+	// attributes would go stale as soon as a claim is prepared.
+	serial                 string
+	vbiosVersion           string
+	eccEnabled             *bool
+	defaultPowerLimitWatts uint32
+	minPowerLimitWatts     uint32
+	maxPowerLimitWatts     uint32
+	maxGraphicsClockMHz    uint32
+	maxMemoryClockMHz      uint32
 }
 
 type MigDeviceInfo struct {
@@ -52,11 +73,16 @@ type MigDeviceInfo struct {
 	giInfo        *nvml.GpuInstanceInfo
 	ciProfileInfo *nvml.ComputeInstanceProfileInfo
 	ciInfo        *nvml.ComputeInstanceInfo
+	migProfile    *MigProfileInfo
+	// dynamic is set for MIG devices that did not exist when the node was
+	// enumerated and are instead created on demand at prepare time.
+	dynamic bool
 }
 
 type MigProfileInfo struct {
-	profile    nvdev.MigProfile
-	placements []*MigDevicePlacement
+	profile       nvdev.MigProfile
+	giProfileInfo *nvml.GpuInstanceProfileInfo
+	placements    []*MigDevicePlacement
 }
 
 type MigDevicePlacement struct {
@@ -72,7 +98,72 @@ func (d *GpuInfo) CanonicalName() string {
 }
 
 func (d *MigDeviceInfo) CanonicalName() string {
-	return fmt.Sprintf("gpu-%d-mig-%d-%d-%d", d.parent.index, d.giInfo.ProfileId, d.placement.Start, d.placement.Size)
+	return fmt.Sprintf("gpu-%d-mig-%d-%d-%d", d.parent.index, d.giProfileInfo.Id, d.placement.Start, d.placement.Size)
+}
+
+// Exists returns true if the MIG device is currently present on its parent GPU.
+func (d *MigDeviceInfo) Exists() bool {
+	return d.UUID != ""
+}
+
+// Overlaps returns true if the placements of two MIG devices on the same
+// parent GPU share at least one memory slice.
+func (p *MigDevicePlacement) Overlaps(other *MigDevicePlacement) bool {
+	return p.Start < other.Start+other.Size && other.Start < p.Start+p.Size
+}
+
+// newDynamicMigDeviceInfo returns the info for a MIG device that can be
+// created on demand with the given profile and placement on a parent GPU.
+func newDynamicMigDeviceInfo(parent *GpuInfo, profile *MigProfileInfo, placement *MigDevicePlacement) *MigDeviceInfo {
+	return &MigDeviceInfo{
+		profile:       profile.String(),
+		parent:        parent,
+		placement:     placement,
+		giProfileInfo: profile.giProfileInfo,
+		migProfile:    profile,
+		dynamic:       true,
+	}
+}
+
+// CounterSetName returns the name of the counter set shared by a GPU and all
+// MIG devices that can be carved out of it.
+func (d *GpuInfo) CounterSetName() string {
+	return fmt.Sprintf("%s-counter-set", d.CanonicalName())
+}
+
+// memorySliceCount returns the number of memory slices a GPU is partitioned
+// into by MIG, or 0 if the GPU does not support MIG.
+func (d *GpuInfo) memorySliceCount() uint32 {
+	var count uint32
+	for _, profile := range d.migProfiles {
+		for _, placement := range profile.placements {
+			count = max(count, placement.Start+placement.Size)
+		}
+	}
+	return count
+}
+
+func memorySliceCounterName(slice uint32) string {
+	return fmt.Sprintf("memory-slice-%d", slice)
+}
+
+// GetCounterSet returns the counters shared by a GPU and its MIG devices.
+// Allocating a device consumes the memory and memory slices it occupies, so
+// that the full GPU and overlapping MIG devices cannot be allocated at the
+// same time.
+func (d *GpuInfo) GetCounterSet() resourceapi.CounterSet {
+	counters := map[string]resourceapi.Counter{
+		"memory": {Value: *resource.NewQuantity(int64(d.memoryBytes), resource.BinarySI)},
+	}
+	for i := range d.memorySliceCount() {
+		counters[memorySliceCounterName(i)] = resourceapi.Counter{
+			Value: *resource.NewQuantity(1, resource.BinarySI),
+		}
+	}
+	return resourceapi.CounterSet{
+		Name:     d.CounterSetName(),
+		Counters: counters,
+	}
 }
 
 func (d *GpuInfo) CanonicalIndex() string {
@@ -118,17 +209,70 @@ func (d *GpuInfo) GetDevice() resourceapi.Device {
 				"cudaDriverVersion": {
 					VersionValue: ptr.To(semver.MustParse(d.cudaDriverVersion).String()),
 				},
+				"pciBusID": {
+					StringValue: &d.pciBusID,
+				},
+				"migCapable": {
+					BoolValue: &d.migCapable,
+				},
+				"migEnabled": {
+					BoolValue: &d.migEnabled,
+				},
 			},
 			Capacity: map[resourceapi.QualifiedName]resourceapi.DeviceCapacity{
 				"memory": {
 					Value: *resource.NewQuantity(int64(d.memoryBytes), resource.BinarySI),
 				},
 			},
+			// The full GPU consumes all of its counters.
+			ConsumesCounters: []resourceapi.DeviceCounterConsumption{
+				{
+					CounterSet: d.CounterSetName(),
+					Counters:   d.GetCounterSet().Counters,
+				},
+			},
 		},
 	}
+	d.addTopologyAttributes(&device)
+	if d.nvlinkIsland != nil {
+		device.Basic.Attributes["nvlinkIsland"] = resourceapi.DeviceAttribute{IntValue: ptr.To(int64(*d.nvlinkIsland))}
+	}
+	if d.serial != "" {
+		device.Basic.Attributes["serial"] = resourceapi.DeviceAttribute{StringValue: &d.serial}
+	}
+	if d.vbiosVersion != "" {
+		device.Basic.Attributes["vbiosVersion"] = resourceapi.DeviceAttribute{StringValue: &d.vbiosVersion}
+	}
+	if d.eccEnabled != nil {
+		device.Basic.Attributes["eccEnabled"] = resourceapi.DeviceAttribute{BoolValue: d.eccEnabled}
+	}
+	if d.defaultPowerLimitWatts != 0 {
+		device.Basic.Attributes["defaultPowerLimitWatts"] = resourceapi.DeviceAttribute{IntValue: ptr.To(int64(d.defaultPowerLimitWatts))}
+	}
+	if d.maxPowerLimitWatts != 0 {
+		device.Basic.Attributes["minPowerLimitWatts"] = resourceapi.DeviceAttribute{IntValue: ptr.To(int64(d.minPowerLimitWatts))}
+		device.Basic.Attributes["maxPowerLimitWatts"] = resourceapi.DeviceAttribute{IntValue: ptr.To(int64(d.maxPowerLimitWatts))}
+	}
+	if d.maxGraphicsClockMHz != 0 {
+		device.Basic.Attributes["maxGraphicsClockMHz"] = resourceapi.DeviceAttribute{IntValue: ptr.To(int64(d.maxGraphicsClockMHz))}
+	}
+	if d.maxMemoryClockMHz != 0 {
+		device.Basic.Attributes["maxMemoryClockMHz"] = resourceapi.DeviceAttribute{IntValue: ptr.To(int64(d.maxMemoryClockMHz))}
+	}
 	return device
 }
 
+// addTopologyAttributes adds the attributes describing where a GPU is
+// attached to the node to a device that is backed by the GPU.
+func (d *GpuInfo) addTopologyAttributes(device *resourceapi.Device) {
+	if d.pcieRoot != "" {
+		device.Basic.Attributes[PCIeRootAttribute] = resourceapi.DeviceAttribute{StringValue: &d.pcieRoot}
+	}
+	if d.numaNode != nil {
+		device.Basic.Attributes["numaNode"] = resourceapi.DeviceAttribute{IntValue: ptr.To(int64(*d.numaNode))}
+	}
+}
+
 func (d *MigDeviceInfo) GetDevice() resourceapi.Device {
 	device := resourceapi.Device{
 		Name: d.CanonicalName(),
@@ -137,15 +281,9 @@ func (d *MigDeviceInfo) GetDevice() resourceapi.Device {
 				"type": {
 					StringValue: ptr.To(MigDeviceType),
 				},
-				"uuid": {
-					StringValue: &d.UUID,
-				},
 				"parentUUID": {
 					StringValue: &d.parent.UUID,
 				},
-				"index": {
-					IntValue: ptr.To(int64(d.index)),
-				},
 				"parentIndex": {
 					IntValue: ptr.To(int64(d.parent.index)),
 				},
@@ -184,11 +322,26 @@ func (d *MigDeviceInfo) GetDevice() resourceapi.Device {
 			},
 		},
 	}
+	// The UUID and index of a MIG device are only known once it has been
+	// created, so they are omitted for devices created on demand.
+	if d.Exists() {
+		device.Basic.Attributes["uuid"] = resourceapi.DeviceAttribute{StringValue: &d.UUID}
+		device.Basic.Attributes["index"] = resourceapi.DeviceAttribute{IntValue: ptr.To(int64(d.index))}
+	}
+	d.parent.addTopologyAttributes(&device)
+	counters := map[string]resourceapi.Counter{
+		"memory": {Value: *resource.NewQuantity(int64(d.giProfileInfo.MemorySizeMB*1024*1024), resource.BinarySI)},
+	}
 	for i := d.placement.Start; i < d.placement.Start+d.placement.Size; i++ {
-		capacity := resourceapi.QualifiedName(fmt.Sprintf("memorySlice%d", i))
-		device.Basic.Capacity[capacity] = resourceapi.DeviceCapacity{
+		counters[memorySliceCounterName(i)] = resourceapi.Counter{
 			Value: *resource.NewQuantity(1, resource.BinarySI),
 		}
 	}
+	device.Basic.ConsumesCounters = []resourceapi.DeviceCounterConsumption{
+		{
+			CounterSet: d.parent.CounterSetName(),
+			Counters:   counters,
+		},
+	}
 	return device
 }
@@ -0,0 +1,100 @@
+/*
+ * Copyright (c) 2025, NVIDIA CORPORATION.  All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"testing"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+	"github.com/stretchr/testify/require"
+
+	"github.com/NVIDIA/k8s-dra-driver-gpu/pkg/nvmlmock"
+)
+
+func TestCounterConsumption(t *testing.T) {
+	nvdevlib := newMockDeviceLib(t, "dgx-a100")
+
+	devices, err := nvdevlib.enumerateAllPossibleDevices(&Config{flags: &Flags{}})
+	require.NoError(t, err)
+
+	// Every device must only consume counters from the counter set of its
+	// parent GPU, and never more than the set provides.
+	for name, device := range devices {
+		counterSet := device.ParentGpu().GetCounterSet()
+		consumes := device.GetDevice().Basic.ConsumesCounters
+		require.Len(t, consumes, 1, name)
+		require.Equal(t, counterSet.Name, consumes[0].CounterSet, name)
+		for counter, value := range consumes[0].Counters {
+			require.Contains(t, counterSet.Counters, counter, name)
+			available := counterSet.Counters[counter].Value
+			require.LessOrEqual(t, value.Value.Cmp(available), 0, name)
+		}
+	}
+
+	// Overlapping MIG devices consume the same memory slices, disjoint ones
+	// do not.
+	slices := func(name string) []string {
+		var counters []string
+		for counter := range devices[name].GetDevice().Basic.ConsumesCounters[0].Counters {
+			if counter != "memory" {
+				counters = append(counters, counter)
+			}
+		}
+		return counters
+	}
+	require.Len(t, devices["gpu-4"].Gpu.GetCounterSet().Counters, 9)
+	require.Subset(t, slices("gpu-4"), slices("gpu-4-mig-1-2-2"))
+	require.Subset(t, slices("gpu-4-mig-1-2-2"), slices("gpu-4-mig-0-3-1"))
+	require.NotSubset(t, slices("gpu-4-mig-1-2-2"), slices("gpu-4-mig-0-0-1"))
+
+	// A GPU without MIG support only has a memory counter.
+	devices, err = newMockDeviceLib(t, "l4").enumerateAllPossibleDevices(&Config{flags: &Flags{}})
+	require.NoError(t, err)
+	require.Len(t, devices["gpu-0"].Gpu.GetCounterSet().Counters, 1)
+}
+
+func TestGpuAttributes(t *testing.T) {
+	nvdevlib := newMockDeviceLib(t, "l4")
+
+	devices, err := nvdevlib.enumerateAllPossibleDevices(&Config{flags: &Flags{}})
+	require.NoError(t, err)
+
+	attributes := devices["gpu-0"].GetDevice().Basic.Attributes
+	require.Equal(t, "0000:01:00.0", *attributes["pciBusID"].StringValue)
+	require.Equal(t, "95.04.3A.00.01", *attributes["vbiosVersion"].StringValue)
+	require.NotEmpty(t, *attributes["serial"].StringValue)
+	require.True(t, *attributes["eccEnabled"].BoolValue)
+	require.NotContains(t, attributes, "persistenceModeEnabled")
+	require.False(t, *attributes["migCapable"].BoolValue)
+	require.False(t, *attributes["migEnabled"].BoolValue)
+	require.Equal(t, int64(72), *attributes["defaultPowerLimitWatts"].IntValue)
+	require.Equal(t, int64(36), *attributes["minPowerLimitWatts"].IntValue)
+	require.Equal(t, int64(72), *attributes["maxPowerLimitWatts"].IntValue)
+	require.Equal(t, int64(2040), *attributes["maxGraphicsClockMHz"].IntValue)
+	require.Equal(t, int64(6251), *attributes["maxMemoryClockMHz"].IntValue)
+	require.LessOrEqual(t, len(attributes), 32)
+
+	// Lowering the power limit, as a GpuConfig may do, does not change the
+	// published attributes.
+	server := nvdevlib.nvmllib.(*nvmlmock.Server)
+	device, ret := server.DeviceGetHandleByUUID(devices["gpu-0"].Gpu.UUID)
+	require.Equal(t, nvml.SUCCESS, ret)
+	require.Equal(t, nvml.SUCCESS, device.SetPowerManagementLimit(50000))
+	devices, err = nvdevlib.enumerateAllPossibleDevices(&Config{flags: &Flags{}})
+	require.NoError(t, err)
+	require.Equal(t, attributes, devices["gpu-0"].GetDevice().Basic.Attributes)
+}
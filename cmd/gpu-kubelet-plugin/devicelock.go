@@ -0,0 +1,106 @@
+/*
+ * Copyright (c) 2025, NVIDIA CORPORATION.  All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+)
+
+// DeviceLocks hands out exclusive locks for individual keys such as GPU or
+// claim UUIDs, so that operations on disjoint sets of devices can proceed
+// concurrently while operations on the same device are serialized.
+type DeviceLocks struct {
+	sync.Mutex
+	locks map[string]*deviceLock
+}
+
+type deviceLock struct {
+	held chan struct{}
+	refs int
+}
+
+func NewDeviceLocks() *DeviceLocks {
+	return &DeviceLocks{
+		locks: make(map[string]*deviceLock),
+	}
+}
+
+// Acquire acquires the locks for all given keys, waiting until either all of
+// them are held or the context is done. Keys are acquired in sorted order so
+// that callers locking overlapping sets of keys cannot deadlock. Returns a
+// release function that must be called to unlock all keys, typically with
+// defer().
+func (l *DeviceLocks) Acquire(ctx context.Context, keys []string) (func(), error) {
+	keys = slices.Compact(slices.Sorted(slices.Values(keys)))
+
+	var acquired []string
+	for _, key := range keys {
+		lock := l.ref(key)
+		select {
+		case lock.held <- struct{}{}:
+			acquired = append(acquired, key)
+		case <-ctx.Done():
+			l.unref(key)
+			l.release(acquired)
+			return nil, fmt.Errorf("error acquiring lock for %v: %w", key, ctx.Err())
+		}
+	}
+
+	release := func() {
+		l.release(acquired)
+	}
+	return release, nil
+}
+
+func (l *DeviceLocks) release(keys []string) {
+	for _, key := range slices.Backward(keys) {
+		l.Lock()
+		lock := l.locks[key]
+		l.Unlock()
+		<-lock.held
+		l.unref(key)
+	}
+}
+
+// ref returns the lock for key, creating it if nobody else is holding or
+// waiting for it.
+func (l *DeviceLocks) ref(key string) *deviceLock {
+	l.Lock()
+	defer l.Unlock()
+	lock, exists := l.locks[key]
+	if !exists {
+		lock = &deviceLock{held: make(chan struct{}, 1)}
+		l.locks[key] = lock
+	}
+	lock.refs++
+	return lock
+}
+
+// unref drops a reference to the lock for key, forgetting about the lock
+// once nobody is holding or waiting for it anymore.
+func (l *DeviceLocks) unref(key string) {
+	l.Lock()
+	defer l.Unlock()
+	lock := l.locks[key]
+	lock.refs--
+	if lock.refs == 0 {
+		delete(l.locks, key)
+	}
+}
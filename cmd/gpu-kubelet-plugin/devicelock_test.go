@@ -0,0 +1,92 @@
+/*
+ * Copyright (c) 2025, NVIDIA CORPORATION.  All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeviceLocks(t *testing.T) {
+	l := NewDeviceLocks()
+
+	// Disjoint keys do not block each other.
+	release0, err := l.Acquire(context.Background(), []string{"GPU-0", "GPU-1"})
+	require.NoError(t, err)
+	release1, err := l.Acquire(context.Background(), []string{"GPU-2"})
+	require.NoError(t, err)
+
+	// Overlapping keys block until released, without holding on to any
+	// of the other keys in the meantime.
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = l.Acquire(ctx, []string{"GPU-3", "GPU-1"})
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	release3, err := l.Acquire(context.Background(), []string{"GPU-3"})
+	require.NoError(t, err)
+	release3()
+
+	acquired := make(chan struct{})
+	released := make(chan struct{})
+	go func() {
+		defer close(released)
+		release, err := l.Acquire(context.Background(), []string{"GPU-1", "GPU-2", "GPU-1"})
+		require.NoError(t, err)
+		close(acquired)
+		release()
+	}()
+	release0()
+	select {
+	case <-acquired:
+		t.Fatal("lock acquired while still held")
+	case <-time.After(50 * time.Millisecond):
+	}
+	release1()
+	<-acquired
+	<-released
+
+	// Concurrent callers locking overlapping keys in different orders
+	// neither deadlock nor run at the same time.
+	var wg sync.WaitGroup
+	var holders atomic.Int32
+	for i := range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			keys := []string{"GPU-0", "GPU-1"}
+			if i%2 == 0 {
+				keys = []string{"GPU-1", "GPU-0"}
+			}
+			release, err := l.Acquire(context.Background(), keys)
+			require.NoError(t, err)
+			defer release()
+			require.Equal(t, int32(1), holders.Add(1))
+			time.Sleep(time.Millisecond)
+			holders.Add(-1)
+		}()
+	}
+	wg.Wait()
+
+	l.Lock()
+	defer l.Unlock()
+	require.Empty(t, l.locks)
+}
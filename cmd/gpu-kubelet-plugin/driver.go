@@ -18,10 +18,18 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"maps"
+	"net/http"
+	"path/filepath"
+	"slices"
+	"sync"
 	"time"
 
 	resourceapi "k8s.io/api/resource/v1beta1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	coreclientset "k8s.io/client-go/kubernetes"
 	"k8s.io/dynamic-resource-allocation/kubeletplugin"
@@ -29,29 +37,98 @@ import (
 	"k8s.io/klog/v2"
 
 	"github.com/NVIDIA/k8s-dra-driver-gpu/pkg/flock"
+	"github.com/NVIDIA/k8s-dra-driver-gpu/pkg/localserver"
+	"github.com/NVIDIA/k8s-dra-driver-gpu/pkg/metrics"
+	"github.com/NVIDIA/k8s-dra-driver-gpu/pkg/workqueue"
 )
 
-// DriverPrepUprepFlockPath is the path to a lock file used to make sure
-// that calls to nodePrepareResource() / nodeUnprepareResource() never
-// interleave, node-globally.
-const DriverPrepUprepFlockPath = DriverPluginPath + "/pu.lock"
+const (
+	// ErrorRetryMaxTimeout limits the amount of time spent in the request
+	// handlers UnprepareResourceClaims() and PrepareResourceClaims(), so that
+	// we send a response to the kubelet in a predictable amount of time. Within
+	// that deadline, retryable errors are retried (with backoff) via the
+	// workqueue abstraction.
+	ErrorRetryMaxTimeout = 45 * time.Second
+	// DriverPrepUprepFlockFileName is the name of a lock file in the plugin
+	// directory used to make sure that at most one instance of the plugin
+	// prepares and unprepares claims on a node at any given time. Each
+	// instance keeps the checkpoint in memory, so the lock is held for as
+	// long as the plugin is running.
+	DriverPrepUprepFlockFileName = "pu.lock"
+	// DriverPrepUprepFlockTimeout bounds how long to wait for another
+	// instance of the plugin on the node to release the prep/unprep lock
+	// before giving up, so that a stuck instance surfaces as an error.
+	DriverPrepUprepFlockTimeout = 5 * time.Minute
+	// DriverPluginLocalSocketFileName is the name of a unix socket in the
+	// plugin directory serving a node-local HTTP API for operating the plugin.
+	DriverPluginLocalSocketFileName = "local.sock"
+)
+
+// permanentError defines an error indicating that it is permanent.
+// By default, every error will be retried up to ErrorRetryMaxTimeout.
+// Errors marked as permanent will not be retried.
+type permanentError struct{ error }
+
+func isPermanentError(err error) bool {
+	return errors.As(err, &permanentError{})
+}
 
 type driver struct {
 	client       coreclientset.Interface
+	nodeName     string
 	pluginhelper *kubeletplugin.Helper
 	state        *DeviceState
 	pulock       *flock.Flock
+
+	releasePULock func()
+
+	healthMonitor *DeviceHealthMonitor
+	localServer   *localserver.Server
+
+	// deviceTaints is true if unhealthy devices can be tainted in the
+	// published ResourceSlices. Otherwise they are withdrawn from them.
+	deviceTaints bool
+
+	reconcileMutex    sync.Mutex
+	reconcileRequests chan struct{}
+	waitGroup         sync.WaitGroup
 }
 
 func NewDriver(ctx context.Context, config *Config) (*driver, error) {
+	driver := &driver{
+		client:            config.clientsets.Core,
+		nodeName:          config.flags.nodeName,
+		pulock:            flock.NewFlock(filepath.Join(config.DriverPluginPath(), DriverPrepUprepFlockFileName)),
+		reconcileRequests: make(chan struct{}, 1),
+	}
+
+	// Wait for any other instance of the plugin on this node (e.g. during an
+	// upgrade) to exit before loading the checkpoint.
+	release, err := driver.acquirePULock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	driver.releasePULock = release
+
 	state, err := NewDeviceState(ctx, config)
 	if err != nil {
 		return nil, err
 	}
-	driver := &driver{
-		client: config.clientsets.Core,
-		state:  state,
-		pulock: flock.NewFlock(DriverPrepUprepFlockPath),
+	driver.state = state
+
+	supported, err := sharedCountersSupported(ctx, driver.client, driver.nodeName)
+	if err != nil {
+		return nil, err
+	}
+	if !supported {
+		klog.Warningf("Shared counters are not supported by the API server (DRAPartitionableDevices feature gate disabled?), only publishing existing MIG devices and not creating any on demand")
+	}
+	state.sharedCounters = supported
+
+	if state.defaultConfigs != nil {
+		if err := state.defaultConfigs.Start(ctx); err != nil {
+			return nil, fmt.Errorf("error starting default config manager: %w", err)
+		}
 	}
 
 	helper, err := kubeletplugin.Start(
@@ -60,6 +137,7 @@ func NewDriver(ctx context.Context, config *Config) (*driver, error) {
 		kubeletplugin.KubeClient(driver.client),
 		kubeletplugin.NodeName(config.flags.nodeName),
 		kubeletplugin.DriverName(DriverName),
+		kubeletplugin.PluginDataDirectoryPath(config.DriverPluginPath()),
 		kubeletplugin.Serialize(false),
 	)
 	if err != nil {
@@ -67,22 +145,79 @@ func NewDriver(ctx context.Context, config *Config) (*driver, error) {
 	}
 	driver.pluginhelper = helper
 
-	// Enumerate the set of GPU and MIG devices and publish them
-	var resourceSlice resourceslice.Slice
-	for _, device := range state.allocatable {
-		resourceSlice.Devices = append(resourceSlice.Devices, device.GetDevice())
-	}
+	// Monitor the health of all devices and republish whenever it changes.
+	if config.flags.deviceHealthCheck {
+		supported, err := deviceTaintsSupported(driver.client.Discovery())
+		if err != nil {
+			return nil, err
+		}
+		if !supported {
+			klog.Warningf("Device taints are not supported by the API server (DRADeviceTaints feature gate disabled?), withdrawing unhealthy devices from ResourceSlices instead")
+		}
+		driver.deviceTaints = supported
 
-	resources := resourceslice.DriverResources{
-		Pools: map[string]resourceslice.Pool{
-			config.flags.nodeName: {Slices: []resourceslice.Slice{resourceSlice}},
-		},
+		driver.healthMonitor = NewDeviceHealthMonitor(state.nvdevlib, state.allocatable, func() {
+			if err := driver.publishResources(ctx); err != nil {
+				klog.Errorf("Error republishing resources after device health change: %v", err)
+			}
+		})
+		if err := driver.healthMonitor.Start(ctx); err != nil {
+			return nil, fmt.Errorf("error starting device health monitor: %w", err)
+		}
+		// Event registrations keep GPUs open and do not survive them being
+		// reset or rebound, so they are released for the duration of every
+		// reset and of every bind to or from vfio-pci.
+		state.scrubber.OnReset(
+			func(uuid string) {
+				driver.healthMonitor.PauseEvents()
+			},
+			func(uuid string) {
+				if err := driver.healthMonitor.ResumeEvents(); err != nil {
+					klog.Errorf("Error resuming health monitoring after GPU reset: %v", err)
+				}
+			},
+		)
+		state.scrubber.OnScrubbed(driver.healthMonitor.SetScrubResult)
+		state.OnRebind(
+			func(pciBusID string) {
+				driver.healthMonitor.PauseEvents()
+			},
+			func(pciBusID string) {
+				if err := driver.healthMonitor.ResumeEvents(); err != nil {
+					klog.Errorf("Error resuming health monitoring after binding GPU %v: %v", pciBusID, err)
+				}
+			},
+		)
 	}
 
-	if err := driver.pluginhelper.PublishResources(ctx, resources); err != nil {
+	if err := driver.publishResources(ctx); err != nil {
 		return nil, err
 	}
 
+	// Allow device reconciliation to be triggered and the state of the plugin
+	// to be inspected through the local API.
+	driver.localServer = localserver.NewServer(filepath.Join(config.DriverPluginPath(), DriverPluginLocalSocketFileName))
+	driver.localServer.Handle("POST /reconcile", http.HandlerFunc(driver.serveReconcile))
+	driver.localServer.Handle("GET /status", http.HandlerFunc(driver.serveStatus))
+	if err := driver.localServer.Start(ctx); err != nil {
+		return nil, fmt.Errorf("error starting local server: %w", err)
+	}
+
+	// Garbage collect anything left behind by claims that were never fully
+	// prepared or unprepared, both now and periodically from here on.
+	driver.cleanup(ctx)
+	driver.waitGroup.Add(1)
+	go func() {
+		defer driver.waitGroup.Done()
+		driver.periodicCleanup(ctx)
+	}()
+
+	driver.waitGroup.Add(1)
+	go func() {
+		defer driver.waitGroup.Done()
+		driver.reconcileLoop(ctx, config.flags.deviceReconcileInterval)
+	}()
+
 	return driver, nil
 }
 
@@ -90,17 +225,224 @@ func (d *driver) Shutdown() error {
 	if d == nil {
 		return nil
 	}
+	if d.localServer != nil {
+		if err := d.localServer.Stop(); err != nil {
+			klog.Errorf("Error stopping local server: %v", err)
+		}
+	}
+	d.waitGroup.Wait()
+	if d.healthMonitor != nil {
+		if err := d.healthMonitor.Stop(); err != nil {
+			klog.Errorf("Error stopping device health monitor: %v", err)
+		}
+	}
 	d.pluginhelper.Stop()
+	if d.state.defaultConfigs != nil {
+		if err := d.state.defaultConfigs.Stop(); err != nil {
+			klog.Errorf("Error stopping default config manager: %v", err)
+		}
+	}
+	d.releasePULock()
 	return nil
 }
 
+// Reconcile re-enumerates the devices on the node and republishes them if
+// anything has changed.
+func (d *driver) Reconcile(ctx context.Context) error {
+	d.reconcileMutex.Lock()
+	defer d.reconcileMutex.Unlock()
+
+	changed, err := d.state.Reconcile(ctx)
+	if err != nil {
+		return fmt.Errorf("error reconciling devices: %w", err)
+	}
+	if !changed {
+		return nil
+	}
+
+	if d.healthMonitor != nil {
+		if err := d.healthMonitor.UpdateDevices(d.state.Allocatable()); err != nil {
+			klog.Errorf("Error updating devices monitored for health: %v", err)
+		}
+	}
+
+	if err := d.publishResources(ctx); err != nil {
+		return fmt.Errorf("error publishing resources: %w", err)
+	}
+
+	return nil
+}
+
+// RequestReconcile asks for the devices to be reconciled in the background
+// without waiting for it. Requests made while a reconciliation is pending are
+// coalesced.
+func (d *driver) RequestReconcile() {
+	select {
+	case d.reconcileRequests <- struct{}{}:
+	default:
+	}
+}
+
+// reconcileLoop reconciles the devices whenever requested and, if interval is
+// non-zero, periodically.
+func (d *driver) reconcileLoop(ctx context.Context, interval time.Duration) {
+	var tick <-chan time.Time
+	if interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		select {
+		case <-tick:
+			klog.V(6).Infof("Running periodic device reconciliation")
+			if err := d.Reconcile(ctx); err != nil {
+				klog.Errorf("Periodic device reconciliation failed: %v", err)
+			}
+		case <-d.reconcileRequests:
+			if err := d.Reconcile(ctx); err != nil {
+				klog.Errorf("Device reconciliation failed: %v", err)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (d *driver) serveReconcile(w http.ResponseWriter, r *http.Request) {
+	klog.Infof("Device reconciliation requested through local API")
+	if err := d.Reconcile(r.Context()); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// publishResources publishes the current set of allocatable devices, tainting
+// any devices that the health monitor reports as unhealthy or, if device
+// taints are not supported, leaving them out. Each full GPU
+// gets its own slice together with its MIG devices and the counter set they
+// consume from, so that the number of devices per slice stays within the API
+// limits. Without shared counters, MIG devices that don't exist yet are left
+// out, as nothing would keep overlapping placements from being allocated.
+func (d *driver) publishResources(ctx context.Context) error {
+	d.state.RLock()
+	slicesByGpu := make(map[string]*resourceslice.Slice)
+	metrics.AllocatableDevices.Reset()
+	for name, device := range d.state.allocatable {
+		if !d.state.sharedCounters && device.Type() == MigDeviceType && !device.Mig.Exists() {
+			continue
+		}
+		parent := device.ParentUUID()
+		if _, exists := slicesByGpu[parent]; !exists {
+			slicesByGpu[parent] = &resourceslice.Slice{}
+			if d.state.sharedCounters {
+				slicesByGpu[parent].SharedCounters = []resourceapi.CounterSet{device.ParentGpu().GetCounterSet()}
+			}
+		}
+		unhealthy := d.healthMonitor != nil && d.healthMonitor.IsUnhealthy(name)
+		health := metrics.DeviceHealthy
+		if unhealthy {
+			health = metrics.DeviceUnhealthy
+		}
+		metrics.AllocatableDevices.WithLabelValues(DriverName, device.Type(), health).Inc()
+		if unhealthy && !d.deviceTaints {
+			continue
+		}
+		dev := device.GetDevice()
+		if !d.state.sharedCounters {
+			dev.Basic.ConsumesCounters = nil
+		}
+		if unhealthy {
+			dev.Basic.Taints = d.healthMonitor.GetTaints(name)
+		}
+		slicesByGpu[parent].Devices = append(slicesByGpu[parent].Devices, dev)
+	}
+	d.state.RUnlock()
+
+	var resourceSlices []resourceslice.Slice
+	for _, parent := range slices.Sorted(maps.Keys(slicesByGpu)) {
+		resourceSlices = append(resourceSlices, *slicesByGpu[parent])
+	}
+
+	resources := resourceslice.DriverResources{
+		Pools: map[string]resourceslice.Pool{
+			d.nodeName: {Slices: resourceSlices},
+		},
+	}
+
+	return d.pluginhelper.PublishResources(ctx, resources)
+}
+
+// sharedCountersSupported returns true if the API server keeps the shared
+// counters of ResourceSlices and the counters consumed by their devices. They
+// are an alpha feature behind the DRAPartitionableDevices feature gate,
+// without which the API server silently drops them. Unlike device taints, the
+// feature gate comes with no API of its own to discover, so this creates a
+// ResourceSlice with a counter set in dry-run mode and checks whether the
+// counter set survived.
+func sharedCountersSupported(ctx context.Context, client coreclientset.Interface, nodeName string) (bool, error) {
+	slice := &resourceapi.ResourceSlice{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: nodeName + "-counters-",
+		},
+		Spec: resourceapi.ResourceSliceSpec{
+			Driver:   DriverName,
+			NodeName: nodeName,
+			Pool: resourceapi.ResourcePool{
+				Name:               nodeName,
+				ResourceSliceCount: 1,
+			},
+			SharedCounters: []resourceapi.CounterSet{
+				{
+					Name: "probe",
+					Counters: map[string]resourceapi.Counter{
+						"probe": {Value: resource.MustParse("1")},
+					},
+				},
+			},
+		},
+	}
+	created, err := client.ResourceV1beta1().ResourceSlices().Create(ctx, slice, metav1.CreateOptions{DryRun: []string{metav1.DryRunAll}})
+	if err != nil {
+		return false, fmt.Errorf("error creating ResourceSlice in dry-run mode: %w", err)
+	}
+	return len(created.Spec.SharedCounters) > 0, nil
+}
+
 func (d *driver) PrepareResourceClaims(ctx context.Context, claims []*resourceapi.ResourceClaim) (map[types.UID]kubeletplugin.PrepareResult, error) {
 	klog.V(6).Infof("PrepareResourceClaims called with %d claim(s)", len(claims))
+
+	ctx, cancel := context.WithTimeout(ctx, ErrorRetryMaxTimeout)
+	defer cancel()
 	results := make(map[types.UID]kubeletplugin.PrepareResult)
+	start := time.Now()
 
+	// Claims only wait for each other if they share devices, so each of them
+	// is retried on its own.
+	var mutex sync.Mutex
+	var wg sync.WaitGroup
 	for _, claim := range claims {
-		results[claim.UID] = d.nodePrepareResource(ctx, claim)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var res kubeletplugin.PrepareResult
+			err := retryUntilDone(ctx, claim, func(ctx context.Context) (bool, error) {
+				var done bool
+				done, res = d.nodePrepareResource(ctx, claim)
+				return done, res.Err
+			})
+			if err != nil {
+				res = kubeletplugin.PrepareResult{Err: err}
+			}
+			metrics.ObservePrepare(DriverName, start, res.Err)
+			mutex.Lock()
+			results[claim.UID] = res
+			mutex.Unlock()
+		}()
 	}
+	wg.Wait()
 
 	return results, nil
 }
@@ -108,60 +450,88 @@ func (d *driver) PrepareResourceClaims(ctx context.Context, claims []*resourceap
 func (d *driver) UnprepareResourceClaims(ctx context.Context, claimRefs []kubeletplugin.NamespacedObject) (map[types.UID]error, error) {
 	klog.V(6).Infof("UnprepareResourceClaims called with %d claim(s)", len(claimRefs))
 
+	ctx, cancel := context.WithTimeout(ctx, ErrorRetryMaxTimeout)
+	defer cancel()
 	results := make(map[types.UID]error)
+	start := time.Now()
 
+	var mutex sync.Mutex
+	var wg sync.WaitGroup
 	for _, claimRef := range claimRefs {
-		results[claimRef.UID] = d.nodeUnprepareResource(ctx, claimRef)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := retryUntilDone(ctx, claimRef, func(ctx context.Context) (bool, error) {
+				return d.nodeUnprepareResource(ctx, claimRef)
+			})
+			metrics.ObserveUnprepare(DriverName, start, err)
+			mutex.Lock()
+			results[claimRef.UID] = err
+			mutex.Unlock()
+		}()
 	}
+	wg.Wait()
 
 	return results, nil
 }
 
-func (d *driver) nodePrepareResource(ctx context.Context, claim *resourceapi.ResourceClaim) kubeletplugin.PrepareResult {
-	release, err := d.pulock.Acquire(ctx, flock.WithTimeout(10*time.Second))
-	if err != nil {
-		return kubeletplugin.PrepareResult{
-			Err: fmt.Errorf("error acquiring prep/unprep lock: %w", err),
+// retryUntilDone calls f for obj until it reports being done, retrying with
+// backoff via the workqueue abstraction. f must return an error whenever it is
+// not done. It returns the error f returned last or, if f never got to run
+// before ctx was done, the error of the context.
+func retryUntilDone(ctx context.Context, obj any, f func(ctx context.Context) (bool, error)) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var done bool
+	var err error
+	workQueue := workqueue.New(workqueue.DefaultControllerRateLimiter())
+	workQueue.EnqueueRaw(obj, func(ctx context.Context, obj any) error {
+		done, err = f(ctx)
+		if done {
+			cancel()
+			return nil
 		}
+		return err
+	})
+	workQueue.Run(ctx)
+
+	if !done && err == nil {
+		return ctx.Err()
 	}
-	defer release()
+	return err
+}
 
+func (d *driver) nodePrepareResource(ctx context.Context, claim *resourceapi.ResourceClaim) (bool, kubeletplugin.PrepareResult) {
 	devs, err := d.state.Prepare(ctx, claim)
-
 	if err != nil {
-		return kubeletplugin.PrepareResult{
+		res := kubeletplugin.PrepareResult{
 			Err: fmt.Errorf("error preparing devices for claim %v: %w", claim.UID, err),
 		}
+		return isPermanentError(err), res
 	}
 
 	klog.Infof("Returning newly prepared devices for claim '%v': %v", claim.UID, devs)
-	return kubeletplugin.PrepareResult{Devices: devs}
+	return true, kubeletplugin.PrepareResult{Devices: devs}
 }
 
-func (d *driver) nodeUnprepareResource(ctx context.Context, claimNs kubeletplugin.NamespacedObject) error {
-	release, err := d.pulock.Acquire(ctx, flock.WithTimeout(10*time.Second))
-	if err != nil {
-		return fmt.Errorf("error acquiring prep/unprep lock: %w", err)
-	}
-	defer release()
-
+func (d *driver) nodeUnprepareResource(ctx context.Context, claimNs kubeletplugin.NamespacedObject) (bool, error) {
 	if err := d.state.Unprepare(ctx, string(claimNs.UID)); err != nil {
-		return fmt.Errorf("error unpreparing devices for claim %v: %w", claimNs.UID, err)
+		return isPermanentError(err), fmt.Errorf("error unpreparing devices for claim %v: %w", claimNs.UID, err)
 	}
 
-	return nil
+	return true, nil
 }
 
-// TODO: implement loop to remove CDI files from the CDI path for claimUIDs
-//       that have been removed from the AllocatedClaims map.
-// func (d *driver) cleanupCDIFiles(wg *sync.WaitGroup) chan error {
-// 	errors := make(chan error)
-// 	return errors
-// }
-//
-// TODO: implement loop to remove mpsControlDaemon folders from the mps
-//       path for claimUIDs that have been removed from the AllocatedClaims map.
-// func (d *driver) cleanupMpsControlDaemonArtifacts(wg *sync.WaitGroup) chan error {
-// 	errors := make(chan error)
-// 	return errors
-// }
+// acquirePULock acquires the node-global prep/unprep lock, recording how long
+// it took.
+func (d *driver) acquirePULock(ctx context.Context) (func(), error) {
+	klog.Infof("Acquiring node-global prep/unprep lock")
+	start := time.Now()
+	release, err := d.pulock.Acquire(ctx, flock.WithTimeout(DriverPrepUprepFlockTimeout))
+	metrics.FlockWaitDuration.WithLabelValues(DriverName).Observe(time.Since(start).Seconds())
+	if err != nil {
+		return nil, metrics.WithErrorReason(metrics.ErrorReasonLock, fmt.Errorf("error acquiring prep/unprep lock (is another instance of the plugin, e.g. a terminating pod, still running on this node?): %w", err))
+	}
+	return release, nil
+}
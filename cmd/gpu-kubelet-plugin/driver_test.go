@@ -0,0 +1,142 @@
+/*
+ * Copyright (c) 2025, NVIDIA CORPORATION.  All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	resourceapi "k8s.io/api/resource/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	coreclientset "k8s.io/client-go/kubernetes"
+	resourcev1beta1 "k8s.io/client-go/kubernetes/typed/resource/v1beta1"
+)
+
+// fakeResourceSliceClient creates ResourceSlices like an API server that
+// drops their shared counters unless the DRAPartitionableDevices feature gate
+// is enabled. Only ResourceSlice creation is implemented.
+type fakeResourceSliceClient struct {
+	coreclientset.Interface
+	resourcev1beta1.ResourceV1beta1Interface
+	resourcev1beta1.ResourceSliceInterface
+
+	partitionableDevices bool
+	created              []*resourceapi.ResourceSlice
+	dryRun               [][]string
+}
+
+func (c *fakeResourceSliceClient) ResourceV1beta1() resourcev1beta1.ResourceV1beta1Interface {
+	return c
+}
+
+func (c *fakeResourceSliceClient) ResourceSlices() resourcev1beta1.ResourceSliceInterface {
+	return c
+}
+
+func (c *fakeResourceSliceClient) Create(ctx context.Context, slice *resourceapi.ResourceSlice, opts metav1.CreateOptions) (*resourceapi.ResourceSlice, error) {
+	slice = slice.DeepCopy()
+	if !c.partitionableDevices {
+		slice.Spec.SharedCounters = nil
+	}
+	c.created = append(c.created, slice)
+	c.dryRun = append(c.dryRun, opts.DryRun)
+	return slice, nil
+}
+
+func TestSharedCountersSupported(t *testing.T) {
+	for _, enabled := range []bool{true, false} {
+		client := &fakeResourceSliceClient{partitionableDevices: enabled}
+		supported, err := sharedCountersSupported(context.Background(), client, "node")
+		require.NoError(t, err)
+		require.Equal(t, enabled, supported)
+
+		// The probe is never persisted.
+		require.Len(t, client.created, 1)
+		require.Equal(t, []string{metav1.DryRunAll}, client.dryRun[0])
+		require.Equal(t, "node", client.created[0].Spec.NodeName)
+	}
+}
+
+func TestRetryUntilDone(t *testing.T) {
+	errTransient := errors.New("transient")
+	errInvalid := errors.New("invalid")
+
+	testCases := []struct {
+		name             string
+		failures         int
+		err              error
+		timeout          time.Duration
+		expectedError    error
+		expectedAttempts int
+	}{
+		{
+			name:             "success",
+			timeout:          time.Second,
+			expectedAttempts: 1,
+		},
+		{
+			name:             "transient errors are retried",
+			failures:         3,
+			err:              errTransient,
+			timeout:          time.Second,
+			expectedAttempts: 4,
+		},
+		{
+			name:             "permanent errors are not retried",
+			failures:         3,
+			err:              permanentError{errInvalid},
+			timeout:          time.Second,
+			expectedError:    permanentError{errInvalid},
+			expectedAttempts: 1,
+		},
+		{
+			name:          "last error is returned on timeout",
+			failures:      1000,
+			err:           errTransient,
+			timeout:       200 * time.Millisecond,
+			expectedError: errTransient,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), tc.timeout)
+			defer cancel()
+
+			attempts := 0
+			err := retryUntilDone(ctx, tc.name, func(ctx context.Context) (bool, error) {
+				attempts++
+				if attempts <= tc.failures {
+					return isPermanentError(tc.err), tc.err
+				}
+				return true, nil
+			})
+
+			if tc.expectedError == nil {
+				require.NoError(t, err)
+			} else {
+				require.ErrorIs(t, err, tc.expectedError)
+			}
+			if tc.expectedAttempts > 0 {
+				require.Equal(t, tc.expectedAttempts, attempts)
+			}
+		})
+	}
+}
@@ -0,0 +1,187 @@
+/*
+ * Copyright (c) 2025, NVIDIA CORPORATION.  All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"maps"
+	"slices"
+	"sync"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+	"k8s.io/klog/v2"
+	"k8s.io/utils/ptr"
+
+	configapi "github.com/NVIDIA/k8s-dra-driver-gpu/api/nvidia.com/resource/v1beta1"
+)
+
+// GpuSettingsState records the original values of the settings that were
+// changed on a GPU when preparing a claim, so that they can be restored when
+// the claim is unprepared. Unset fields were not changed.
+type GpuSettingsState struct {
+	PowerLimitMilliwatts *uint32                      `json:"powerLimitMilliwatts,omitempty"`
+	PersistenceMode      *bool                        `json:"persistenceMode,omitempty"`
+	ApplicationClocks    *configapi.ApplicationClocks `json:"applicationClocks,omitempty"`
+	LockedGpuClocks      bool                         `json:"lockedGpuClocks,omitempty"`
+	LockedMemoryClocks   bool                         `json:"lockedMemoryClocks,omitempty"`
+}
+
+// applyGpuSettings applies the power limit, clock and persistence mode
+// settings of a GpuConfig to a set of GPUs. It returns the original settings
+// of each GPU by UUID. If applying the settings fails on any GPU, all GPUs
+// are restored to their original settings.
+func (l deviceLib) applyGpuSettings(uuids []string, config *configapi.GpuConfig) (map[string]*GpuSettingsState, error) {
+	if !config.HasGpuSettings() {
+		return nil, nil
+	}
+
+	var mutex sync.Mutex
+	states := make(map[string]*GpuSettingsState)
+	err := l.forEachDevice(uuids, "applying GPU settings", func(device nvml.Device) nvml.Return {
+		uuid, ret := device.GetUUID()
+		if ret != nvml.SUCCESS {
+			return ret
+		}
+		state := &GpuSettingsState{}
+		mutex.Lock()
+		states[uuid] = state
+		mutex.Unlock()
+		return applyDeviceGpuSettings(device, config, state)
+	})
+	if err != nil {
+		if err := l.restoreGpuSettings(states); err != nil {
+			klog.Errorf("error restoring GPU settings: %v", err)
+		}
+		return nil, err
+	}
+
+	return states, nil
+}
+
+// restoreGpuSettings restores the original settings of a set of GPUs as
+// returned by applyGpuSettings.
+func (l deviceLib) restoreGpuSettings(states map[string]*GpuSettingsState) error {
+	if len(states) == 0 {
+		return nil
+	}
+	uuids := slices.Sorted(maps.Keys(states))
+	return l.forEachDevice(uuids, "restoring GPU settings", func(device nvml.Device) nvml.Return {
+		uuid, ret := device.GetUUID()
+		if ret != nvml.SUCCESS {
+			return ret
+		}
+		return restoreDeviceGpuSettings(device, states[uuid])
+	})
+}
+
+// applyDeviceGpuSettings applies the settings of a GpuConfig to a single GPU,
+// recording the original value of each setting in state before changing it.
+func applyDeviceGpuSettings(device nvml.Device, config *configapi.GpuConfig, state *GpuSettingsState) nvml.Return {
+	if config.PowerLimitWatts != nil {
+		original, ret := device.GetPowerManagementLimit()
+		if ret != nvml.SUCCESS {
+			return ret
+		}
+		if ret := device.SetPowerManagementLimit(uint32(*config.PowerLimitWatts) * 1000); ret != nvml.SUCCESS {
+			return ret
+		}
+		state.PowerLimitMilliwatts = &original
+	}
+
+	if config.PersistenceMode != nil {
+		original, ret := device.GetPersistenceMode()
+		if ret != nvml.SUCCESS {
+			return ret
+		}
+		if ret := device.SetPersistenceMode(enableState(*config.PersistenceMode)); ret != nvml.SUCCESS {
+			return ret
+		}
+		state.PersistenceMode = ptr.To(original == nvml.FEATURE_ENABLED)
+	}
+
+	if config.Clocks == nil {
+		return nvml.SUCCESS
+	}
+
+	if clocks := config.Clocks.ApplicationClocks; clocks != nil {
+		gpuClock, ret := device.GetApplicationsClock(nvml.CLOCK_GRAPHICS)
+		if ret != nvml.SUCCESS {
+			return ret
+		}
+		memoryClock, ret := device.GetApplicationsClock(nvml.CLOCK_MEM)
+		if ret != nvml.SUCCESS {
+			return ret
+		}
+		if ret := device.SetApplicationsClocks(uint32(clocks.MemoryMHz), uint32(clocks.GpuMHz)); ret != nvml.SUCCESS {
+			return ret
+		}
+		state.ApplicationClocks = &configapi.ApplicationClocks{
+			GpuMHz:    int(gpuClock),
+			MemoryMHz: int(memoryClock),
+		}
+	}
+
+	if clocks := config.Clocks.LockedGpuClocks; clocks != nil {
+		if ret := device.SetGpuLockedClocks(uint32(clocks.MinMHz), uint32(clocks.MaxMHz)); ret != nvml.SUCCESS {
+			return ret
+		}
+		state.LockedGpuClocks = true
+	}
+
+	if clocks := config.Clocks.LockedMemoryClocks; clocks != nil {
+		if ret := device.SetMemoryLockedClocks(uint32(clocks.MinMHz), uint32(clocks.MaxMHz)); ret != nvml.SUCCESS {
+			return ret
+		}
+		state.LockedMemoryClocks = true
+	}
+
+	return nvml.SUCCESS
+}
+
+// restoreDeviceGpuSettings restores the original settings of a single GPU.
+// All settings are restored even if restoring one of them fails, in which
+// case the first failure is returned.
+func restoreDeviceGpuSettings(device nvml.Device, state *GpuSettingsState) nvml.Return {
+	var rets []nvml.Return
+	if state.LockedMemoryClocks {
+		rets = append(rets, device.ResetMemoryLockedClocks())
+	}
+	if state.LockedGpuClocks {
+		rets = append(rets, device.ResetGpuLockedClocks())
+	}
+	if clocks := state.ApplicationClocks; clocks != nil {
+		rets = append(rets, device.SetApplicationsClocks(uint32(clocks.MemoryMHz), uint32(clocks.GpuMHz)))
+	}
+	if state.PersistenceMode != nil {
+		rets = append(rets, device.SetPersistenceMode(enableState(*state.PersistenceMode)))
+	}
+	if state.PowerLimitMilliwatts != nil {
+		rets = append(rets, device.SetPowerManagementLimit(*state.PowerLimitMilliwatts))
+	}
+	for _, ret := range rets {
+		if ret != nvml.SUCCESS {
+			return ret
+		}
+	}
+	return nvml.SUCCESS
+}
+
+func enableState(enabled bool) nvml.EnableState {
+	if enabled {
+		return nvml.FEATURE_ENABLED
+	}
+	return nvml.FEATURE_DISABLED
+}
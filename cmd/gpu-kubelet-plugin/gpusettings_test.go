@@ -0,0 +1,113 @@
+/*
+ * Copyright (c) 2025, NVIDIA CORPORATION.  All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"testing"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+	"github.com/stretchr/testify/require"
+	"k8s.io/utils/ptr"
+
+	configapi "github.com/NVIDIA/k8s-dra-driver-gpu/api/nvidia.com/resource/v1beta1"
+	"github.com/NVIDIA/k8s-dra-driver-gpu/pkg/nvmlmock"
+)
+
+func TestApplyAndRestoreGpuSettings(t *testing.T) {
+	testCases := []struct {
+		description string
+		failures    nvmlmock.Failures
+		expectedErr bool
+	}{
+		{
+			description: "settings are applied and restored",
+		},
+		{
+			description: "settings are rolled back on failure",
+			failures:    nvmlmock.Failures{"SetGpuLockedClocks": "ERROR_NO_PERMISSION"},
+			expectedErr: true,
+		},
+	}
+
+	config := configapi.DefaultGpuConfig()
+	config.PowerLimitWatts = ptr.To(250)
+	config.PersistenceMode = ptr.To(false)
+	config.Clocks = &configapi.GpuClocks{
+		LockedGpuClocks:   &configapi.ClockRange{MinMHz: 1000, MaxMHz: 1200},
+		ApplicationClocks: &configapi.ApplicationClocks{GpuMHz: 1110, MemoryMHz: 1215},
+	}
+	require.NoError(t, config.Validate())
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			profile, err := nvmlmock.LoadProfile("dgx-a100")
+			require.NoError(t, err)
+			// Only the second GPU is configured to fail.
+			failing := profile.Gpus[0]
+			failing.Count = 1
+			failing.Failures = tc.failures
+			profile.Gpus[0].Count = 1
+			profile.Gpus = []nvmlmock.GpuProfile{profile.Gpus[0], failing}
+			server, err := nvmlmock.New(profile)
+			require.NoError(t, err)
+			nvdevlib, err := newDeviceLib(root(t.TempDir()), server)
+			require.NoError(t, err)
+
+			var devices []*nvmlmock.Device
+			var uuids []string
+			for i := range 2 {
+				device, ret := server.DeviceGetHandleByIndex(i)
+				require.Equal(t, nvml.SUCCESS, ret)
+				uuid, ret := device.GetUUID()
+				require.Equal(t, nvml.SUCCESS, ret)
+				devices = append(devices, device.(*nvmlmock.Device))
+				uuids = append(uuids, uuid)
+			}
+
+			states, err := nvdevlib.applyGpuSettings(uuids, config)
+			if tc.expectedErr {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+				require.Len(t, states, 2)
+				for _, device := range devices {
+					limit, _ := device.GetPowerManagementLimit()
+					require.Equal(t, uint32(250000), limit)
+					mode, _ := device.GetPersistenceMode()
+					require.Equal(t, nvml.FEATURE_DISABLED, mode)
+					clock, _ := device.GetApplicationsClock(nvml.CLOCK_GRAPHICS)
+					require.Equal(t, uint32(1110), clock)
+					clocks, locked := device.LockedClocks(nvml.CLOCK_GRAPHICS)
+					require.True(t, locked)
+					require.Equal(t, [2]uint32{1000, 1200}, clocks)
+				}
+				require.NoError(t, nvdevlib.restoreGpuSettings(states))
+			}
+
+			for _, device := range devices {
+				limit, _ := device.GetPowerManagementLimit()
+				require.Equal(t, uint32(400000), limit)
+				mode, _ := device.GetPersistenceMode()
+				require.Equal(t, nvml.FEATURE_ENABLED, mode)
+				clock, _ := device.GetApplicationsClock(nvml.CLOCK_GRAPHICS)
+				require.Equal(t, uint32(1410), clock)
+				_, locked := device.LockedClocks(nvml.CLOCK_GRAPHICS)
+				require.False(t, locked)
+			}
+		})
+	}
+}
@@ -0,0 +1,652 @@
+/*
+ * Copyright (c) 2025, NVIDIA CORPORATION.  All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+	resourceapi "k8s.io/api/resource/v1beta1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/discovery"
+	"k8s.io/klog/v2"
+)
+
+const (
+	DeviceUnhealthyTaintKey = DriverName + "/unhealthy"
+
+	healthCheckInterval    = 30 * time.Second
+	healthRecoveryPeriod   = 5 * time.Minute
+	healthEventWaitTimeout = 5000 // milliseconds
+
+	// Bounds of the backoff between attempts to wait for health events after
+	// NVML returned an error other than a timeout.
+	healthEventErrorBackoffMin = time.Second
+	healthEventErrorBackoffMax = time.Minute
+
+	// Set on events that are not scoped to a specific MIG device.
+	allGpuInstances = 0xFFFFFFFF
+)
+
+type DeviceUnhealthyReason string
+
+const (
+	XidCriticalError   DeviceUnhealthyReason = "XidCriticalError"
+	DoubleBitEccError  DeviceUnhealthyReason = "DoubleBitEccError"
+	ThermalSlowdown    DeviceUnhealthyReason = "ThermalSlowdown"
+	PowerBrakeSlowdown DeviceUnhealthyReason = "PowerBrakeSlowdown"
+	GpuLost            DeviceUnhealthyReason = "GpuLost"
+	// ScrubFailed is set on all devices of a GPU on which a device could not
+	// be scrubbed after a claim, until scrubbing it succeeds.
+	ScrubFailed DeviceUnhealthyReason = "ScrubFailed"
+)
+
+// applicationXids are XIDs that are caused by user applications rather than
+// by faulty hardware and therefore do not render a device unhealthy.
+var applicationXids = []uint64{
+	13,  // Graphics Engine Exception
+	31,  // GPU memory page fault
+	43,  // GPU stopped processing
+	45,  // Preemptive cleanup, due to previous errors
+	68,  // Video processor exception
+	109, // Context Switch Timeout Error
+}
+
+type deviceHealthStatus struct {
+	reason   DeviceUnhealthyReason
+	since    time.Time
+	lastSeen time.Time
+}
+
+// DeviceHealthMonitor watches all allocatable devices for fatal XIDs,
+// uncorrectable ECC errors and thermal or power violations. It keeps track of
+// which devices are currently unhealthy and calls onChange whenever that set
+// changes.
+type DeviceHealthMonitor struct {
+	sync.Mutex
+	nvdevlib      *deviceLib
+	devices       AllocatableDevices
+	onChange      func()
+	waitGroup     sync.WaitGroup
+	cancelContext context.CancelFunc
+
+	// eventsMutex serializes creating and freeing the event set and
+	// registering GPUs with it. eventsPaused counts the callers of
+	// PauseEvents that have not called ResumeEvents yet.
+	eventsMutex   sync.Mutex
+	eventsContext context.Context
+	cancelEvents  context.CancelFunc
+	eventsDone    chan struct{}
+	eventSet      nvml.EventSet
+	eventsPaused  int
+	registered    map[string]bool
+	unhealthy     map[string]*deviceHealthStatus
+
+	// now returns the current time; it is replaced in tests.
+	now func() time.Time
+}
+
+func NewDeviceHealthMonitor(nvdevlib *deviceLib, devices AllocatableDevices, onChange func()) *DeviceHealthMonitor {
+	return &DeviceHealthMonitor{
+		nvdevlib:   nvdevlib,
+		devices:    maps.Clone(devices),
+		onChange:   onChange,
+		registered: make(map[string]bool),
+		unhealthy:  make(map[string]*deviceHealthStatus),
+		now:        time.Now,
+	}
+}
+
+func (m *DeviceHealthMonitor) Start(ctx context.Context) (rerr error) {
+	ctx, cancel := context.WithCancel(ctx)
+	m.cancelContext = cancel
+
+	// NVML stays initialized for as long as the monitor is running.
+	if err := m.nvdevlib.Init(); err != nil {
+		cancel()
+		return err
+	}
+
+	defer func() {
+		if rerr != nil {
+			if err := m.Stop(); err != nil {
+				klog.Errorf("error stopping DeviceHealthMonitor: %v", err)
+			}
+		}
+	}()
+
+	m.eventsMutex.Lock()
+	m.eventsContext = ctx
+	err := m.startEvents()
+	m.eventsMutex.Unlock()
+	if err != nil {
+		return err
+	}
+
+	m.waitGroup.Add(1)
+	go func() {
+		defer m.waitGroup.Done()
+		m.periodicCheck(ctx)
+	}()
+
+	return nil
+}
+
+func (m *DeviceHealthMonitor) Stop() error {
+	m.cancelContext()
+	m.eventsMutex.Lock()
+	m.stopEvents()
+	m.eventsMutex.Unlock()
+	m.waitGroup.Wait()
+	m.nvdevlib.alwaysShutdown()
+	return nil
+}
+
+// PauseEvents stops waiting for health events and frees the event set. Event
+// registrations keep GPUs open, which prevents them from being removed from
+// the PCI bus, e.g. to reset them, or unbound from the NVIDIA driver. The
+// periodic checks carry on.
+func (m *DeviceHealthMonitor) PauseEvents() {
+	m.eventsMutex.Lock()
+	defer m.eventsMutex.Unlock()
+	m.eventsPaused++
+	m.stopEvents()
+}
+
+// ResumeEvents creates a new event set, registers all GPUs with it and starts
+// waiting for health events again after they have been paused. Events only
+// resume once every caller of PauseEvents has called ResumeEvents. GPUs that
+// cannot be registered are reported in the returned error, while events are
+// still delivered for all others.
+func (m *DeviceHealthMonitor) ResumeEvents() error {
+	m.eventsMutex.Lock()
+	defer m.eventsMutex.Unlock()
+	if m.eventsPaused > 0 {
+		m.eventsPaused--
+	}
+	if m.eventsPaused > 0 || m.eventSet != nil || m.eventsContext.Err() != nil {
+		return nil
+	}
+	return m.startEvents()
+}
+
+// startEvents creates the event set, registers all GPUs with it and starts
+// waiting for events. It must be called with the events mutex held.
+func (m *DeviceHealthMonitor) startEvents() error {
+	eventSet, ret := m.nvdevlib.nvmllib.EventSetCreate()
+	if ret != nvml.SUCCESS {
+		return fmt.Errorf("error creating NVML event set: %v", ret)
+	}
+	m.eventSet = eventSet
+
+	ctx, cancel := context.WithCancel(m.eventsContext)
+	m.cancelEvents = cancel
+	m.eventsDone = make(chan struct{})
+	go func() {
+		defer close(m.eventsDone)
+		m.watchEvents(ctx, eventSet)
+	}()
+
+	m.Lock()
+	m.registered = make(map[string]bool)
+	m.Unlock()
+
+	var errs []error
+	for _, uuid := range m.getDevices().GpuUUIDs() {
+		if m.isPassedThrough(uuid) {
+			klog.V(6).Infof("Not registering health events for GPU %v: bound to %v", uuid, VfioPciDriver)
+			continue
+		}
+		if err := m.registerEvents(uuid); err != nil {
+			errs = append(errs, fmt.Errorf("error registering health events for GPU %v: %w", uuid, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// stopEvents stops waiting for events and frees the event set. It must be
+// called with the events mutex held.
+func (m *DeviceHealthMonitor) stopEvents() {
+	if m.eventSet == nil {
+		return
+	}
+	m.cancelEvents()
+	<-m.eventsDone
+	if ret := m.eventSet.Free(); ret != nvml.SUCCESS {
+		klog.Warningf("error freeing NVML event set: %v", ret)
+	}
+	m.eventSet = nil
+}
+
+// IsUnhealthy returns true if the named device is currently unhealthy.
+func (m *DeviceHealthMonitor) IsUnhealthy(name string) bool {
+	m.Lock()
+	defer m.Unlock()
+	_, exists := m.unhealthy[name]
+	return exists
+}
+
+// GetTaints returns the taints to publish for the named device.
+func (m *DeviceHealthMonitor) GetTaints(name string) []resourceapi.DeviceTaint {
+	m.Lock()
+	defer m.Unlock()
+
+	status, exists := m.unhealthy[name]
+	if !exists {
+		return nil
+	}
+
+	taint := resourceapi.DeviceTaint{
+		Key:       DeviceUnhealthyTaintKey,
+		Value:     string(status.reason),
+		Effect:    resourceapi.DeviceTaintEffectNoSchedule,
+		TimeAdded: &metav1.Time{Time: status.since},
+	}
+	return []resourceapi.DeviceTaint{taint}
+}
+
+// SetScrubResult records the outcome of scrubbing a GPU or MIG device after a
+// claim. If scrubbing failed, all devices on its GPU are marked unhealthy so
+// that they get tainted or withdrawn. They only recover once scrubbing the
+// device succeeds.
+func (m *DeviceHealthMonitor) SetScrubResult(uuid string, err error) {
+	var gpu string
+	for _, d := range m.getDevices() {
+		if d.UUID() == uuid {
+			gpu = d.ParentUUID()
+			break
+		}
+	}
+	if gpu == "" {
+		return
+	}
+	names := m.devicesOnGpu(gpu)
+
+	var changed bool
+	if err != nil {
+		klog.Warningf("Scrubbing device %v failed, marking devices unhealthy: %v", uuid, names)
+		changed = m.markUnhealthy(names, ScrubFailed)
+	} else {
+		m.Lock()
+		names = slices.DeleteFunc(names, func(name string) bool {
+			status, exists := m.unhealthy[name]
+			return !exists || status.reason != ScrubFailed
+		})
+		m.Unlock()
+		changed = m.markHealthy(names)
+	}
+	if changed {
+		m.onChange()
+	}
+}
+
+// UpdateDevices replaces the set of devices being monitored, e.g. after the
+// node has been re-enumerated. Events are registered for any new GPUs and
+// devices that no longer exist are forgotten.
+func (m *DeviceHealthMonitor) UpdateDevices(devices AllocatableDevices) error {
+	m.Lock()
+	m.devices = maps.Clone(devices)
+	for name := range m.unhealthy {
+		if _, exists := devices[name]; !exists {
+			delete(m.unhealthy, name)
+		}
+	}
+	m.Unlock()
+
+	// While events are paused, all GPUs get registered once they resume.
+	m.eventsMutex.Lock()
+	defer m.eventsMutex.Unlock()
+	if m.eventSet == nil {
+		return nil
+	}
+
+	for _, uuid := range devices.GpuUUIDs() {
+		if m.isRegistered(uuid) || m.isPassedThrough(uuid) {
+			continue
+		}
+		if err := m.registerEvents(uuid); err != nil {
+			return fmt.Errorf("error registering health events for GPU %v: %w", uuid, err)
+		}
+	}
+
+	return nil
+}
+
+func (m *DeviceHealthMonitor) isRegistered(uuid string) bool {
+	m.Lock()
+	defer m.Unlock()
+	return m.registered[uuid]
+}
+
+func (m *DeviceHealthMonitor) getDevices() AllocatableDevices {
+	m.Lock()
+	defer m.Unlock()
+	return m.devices
+}
+
+func (m *DeviceHealthMonitor) registerEvents(uuid string) error {
+	device, ret := m.nvdevlib.nvmllib.DeviceGetHandleByUUID(uuid)
+	if ret != nvml.SUCCESS {
+		return fmt.Errorf("error getting GPU device handle: %v", ret)
+	}
+
+	supported, ret := device.GetSupportedEventTypes()
+	if ret == nvml.ERROR_NOT_SUPPORTED {
+		klog.Warningf("Health events not supported for GPU %v; relying on periodic checks only", uuid)
+		return nil
+	}
+	if ret != nvml.SUCCESS {
+		return fmt.Errorf("error getting supported event types: %v", ret)
+	}
+
+	eventTypes := supported & (nvml.EventTypeXidCriticalError | nvml.EventTypeDoubleBitEccError)
+	if eventTypes == 0 {
+		klog.Warningf("No health events supported for GPU %v; relying on periodic checks only", uuid)
+		return nil
+	}
+
+	ret = device.RegisterEvents(eventTypes, m.eventSet)
+	if ret != nvml.SUCCESS {
+		return fmt.Errorf("error registering events: %v", ret)
+	}
+	m.Lock()
+	m.registered[uuid] = true
+	m.Unlock()
+
+	return nil
+}
+
+func (m *DeviceHealthMonitor) watchEvents(ctx context.Context, eventSet nvml.EventSet) {
+	backoff := healthEventErrorBackoffMin
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		default:
+		}
+
+		event, ret := eventSet.Wait(healthEventWaitTimeout)
+		if ret == nvml.ERROR_TIMEOUT {
+			continue
+		}
+		if ret != nvml.SUCCESS {
+			// Errors other than timeouts tend to be returned immediately,
+			// so back off rather than spinning on them.
+			klog.Warningf("Error waiting for NVML health events, retrying in %v: %v", backoff, ret)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(backoff):
+			}
+			backoff = min(2*backoff, healthEventErrorBackoffMax)
+			continue
+		}
+		backoff = healthEventErrorBackoffMin
+
+		m.handleEvent(event)
+	}
+}
+
+func (m *DeviceHealthMonitor) handleEvent(event nvml.EventData) {
+	var reason DeviceUnhealthyReason
+	switch event.EventType {
+	case nvml.EventTypeXidCriticalError:
+		if slices.Contains(applicationXids, event.EventData) {
+			klog.V(6).Infof("Ignoring application XID %d", event.EventData)
+			return
+		}
+		reason = XidCriticalError
+	case nvml.EventTypeDoubleBitEccError:
+		reason = DoubleBitEccError
+	default:
+		return
+	}
+
+	uuid, ret := event.Device.GetUUID()
+	if ret != nvml.SUCCESS {
+		klog.Errorf("Error getting UUID for device with %v event: %v", reason, ret)
+		return
+	}
+
+	names, err := m.getAffectedDevices(event.Device, uuid, event.GpuInstanceId)
+	if err != nil {
+		klog.Errorf("Error determining devices affected by %v event on GPU %v: %v", reason, uuid, err)
+		return
+	}
+
+	klog.Warningf("Received %v event (data: %d) for GPU %v, marking devices unhealthy: %v", reason, event.EventData, uuid, names)
+	if m.markUnhealthy(names, reason) {
+		m.onChange()
+	}
+}
+
+// getAffectedDevices returns the names of the devices affected by an event on
+// a GPU. Events scoped to a GPU instance only affect the matching MIG device.
+func (m *DeviceHealthMonitor) getAffectedDevices(device nvml.Device, uuid string, giID uint32) ([]string, error) {
+	if giID == allGpuInstances {
+		return m.devicesOnGpu(uuid), nil
+	}
+
+	gi, ret := device.GetGpuInstanceById(int(giID))
+	if ret != nvml.SUCCESS {
+		return nil, fmt.Errorf("error getting GPU instance %d: %v", giID, ret)
+	}
+	giInfo, ret := gi.GetInfo()
+	if ret != nvml.SUCCESS {
+		return nil, fmt.Errorf("error getting GPU instance info for %d: %v", giID, ret)
+	}
+
+	var names []string
+	for name, d := range m.getDevices() {
+		if d.Type() != MigDeviceType || d.Mig.parent.UUID != uuid {
+			continue
+		}
+		if d.Mig.giProfileInfo.Id != giInfo.ProfileId {
+			continue
+		}
+		if d.Mig.placement.GpuInstancePlacement != giInfo.Placement {
+			continue
+		}
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+func (m *DeviceHealthMonitor) devicesOnGpu(uuid string) []string {
+	var names []string
+	for name, d := range m.getDevices() {
+		if d.ParentUUID() == uuid {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+	return names
+}
+
+func (m *DeviceHealthMonitor) markUnhealthy(names []string, reason DeviceUnhealthyReason) bool {
+	m.Lock()
+	defer m.Unlock()
+
+	now := m.now()
+	changed := false
+	for _, name := range names {
+		if status, exists := m.unhealthy[name]; exists && status.reason == reason {
+			status.lastSeen = now
+			continue
+		}
+		m.unhealthy[name] = &deviceHealthStatus{
+			reason:   reason,
+			since:    now,
+			lastSeen: now,
+		}
+		changed = true
+	}
+	return changed
+}
+
+func (m *DeviceHealthMonitor) markHealthy(names []string) bool {
+	m.Lock()
+	defer m.Unlock()
+
+	changed := false
+	for _, name := range names {
+		if _, exists := m.unhealthy[name]; exists {
+			delete(m.unhealthy, name)
+			changed = true
+		}
+	}
+	return changed
+}
+
+func (m *DeviceHealthMonitor) periodicCheck(ctx context.Context) {
+	ticker := time.NewTicker(healthCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			klog.V(6).Infof("Running periodic health check for all GPUs")
+			changed := false
+			for _, uuid := range m.getDevices().GpuUUIDs() {
+				if m.checkGpu(uuid) {
+					changed = true
+				}
+			}
+			if changed {
+				m.onChange()
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// checkGpu polls the current health of a GPU, marking its devices unhealthy
+// on thermal or power violations and recovering devices whose error
+// condition has cleared. It returns true if the health of any device changed.
+func (m *DeviceHealthMonitor) checkGpu(uuid string) bool {
+	names := m.devicesOnGpu(uuid)
+
+	device, ret := m.nvdevlib.nvmllib.DeviceGetHandleByUUID(uuid)
+	if ret != nvml.SUCCESS {
+		if m.isPassedThrough(uuid) {
+			klog.V(6).Infof("Skipping health check of GPU %v: bound to %v", uuid, VfioPciDriver)
+			return false
+		}
+		klog.Warningf("GPU %v is not responding: %v", uuid, ret)
+		return m.markUnhealthy(names, GpuLost)
+	}
+
+	var violation DeviceUnhealthyReason
+	reasons, ret := device.GetCurrentClocksEventReasons()
+	switch {
+	case ret != nvml.SUCCESS:
+	case reasons&nvml.ClocksThrottleReasonHwThermalSlowdown != 0:
+		violation = ThermalSlowdown
+	case reasons&nvml.ClocksThrottleReasonHwPowerBrakeSlowdown != 0:
+		violation = PowerBrakeSlowdown
+	}
+	if violation != "" {
+		klog.Warningf("GPU %v reports %v, marking devices unhealthy: %v", uuid, violation, names)
+		return m.markUnhealthy(names, violation)
+	}
+
+	var recovered []string
+	for _, name := range names {
+		if m.hasRecovered(device, name) {
+			recovered = append(recovered, name)
+		}
+	}
+	if len(recovered) > 0 {
+		klog.Infof("Devices on GPU %v have recovered: %v", uuid, recovered)
+	}
+	return m.markHealthy(recovered)
+}
+
+// isPassedThrough returns true if a GPU is bound to the vfio-pci driver to be
+// passed through to a virtual machine, which hides it from NVML.
+func (m *DeviceHealthMonitor) isPassedThrough(uuid string) bool {
+	for _, d := range m.getDevices() {
+		if d.Type() != GpuDeviceType || d.Gpu.UUID != uuid {
+			continue
+		}
+		driver, err := m.nvdevlib.getPciDriver(d.Gpu.pciBusID)
+		return err == nil && driver == VfioPciDriver
+	}
+	return false
+}
+
+func (m *DeviceHealthMonitor) hasRecovered(device nvml.Device, name string) bool {
+	m.Lock()
+	status, exists := m.unhealthy[name]
+	m.Unlock()
+	if !exists {
+		return false
+	}
+
+	switch status.reason {
+	case GpuLost:
+		// We were able to get a handle to the device again.
+		return true
+	case ThermalSlowdown, PowerBrakeSlowdown:
+		// The violation was not seen on this check, so it has cleared.
+		return true
+	case DoubleBitEccError:
+		// Volatile ECC counters are only cleared by a GPU reset.
+		count, ret := device.GetTotalEccErrors(nvml.MEMORY_ERROR_TYPE_UNCORRECTED, nvml.VOLATILE_ECC)
+		return ret == nvml.SUCCESS && count == 0
+	case XidCriticalError:
+		if m.now().Sub(status.lastSeen) < healthRecoveryPeriod {
+			return false
+		}
+		_, ret := device.GetMemoryInfo()
+		return ret == nvml.SUCCESS
+	}
+	return false
+}
+
+// deviceTaintsSupported returns true if the API server keeps the taints of
+// devices in ResourceSlices. Taints are an alpha feature behind the
+// DRADeviceTaints feature gate, without which the API server silently drops
+// them. There is no direct way to query feature gates, so this checks for the
+// DeviceTaintRule API that comes with the feature gate. That API also requires
+// the resource.k8s.io/v1alpha3 API group version to be enabled, so taints may
+// be reported as unsupported although the feature gate is enabled. This errs
+// on the safe side, as unhealthy devices then get withdrawn from the
+// ResourceSlices instead of being tainted.
+func deviceTaintsSupported(client discovery.DiscoveryInterface) (bool, error) {
+	resources, err := client.ServerResourcesForGroupVersion("resource.k8s.io/v1alpha3")
+	if apierrors.IsNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("error discovering resource.k8s.io/v1alpha3 API: %w", err)
+	}
+	for _, resource := range resources.APIResources {
+		if resource.Name == "devicetaintrules" {
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,180 @@
+/*
+ * Copyright (c) 2025, NVIDIA CORPORATION.  All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+	"github.com/stretchr/testify/require"
+
+	"github.com/NVIDIA/k8s-dra-driver-gpu/pkg/nvmlmock"
+)
+
+func TestDeviceHealthMonitor(t *testing.T) {
+	nvdevlib := newMockDeviceLib(t, "gb200")
+	server := nvdevlib.nvmllib.(*nvmlmock.Server)
+
+	devices, err := nvdevlib.enumerateAllPossibleDevices(&Config{flags: &Flags{}})
+	require.NoError(t, err)
+
+	changes := make(chan struct{}, 10)
+	monitor := NewDeviceHealthMonitor(nvdevlib, devices, func() {
+		changes <- struct{}{}
+	})
+	now := time.Now()
+	monitor.now = func() time.Time { return now }
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	require.NoError(t, monitor.Start(ctx))
+	defer func() {
+		require.NoError(t, monitor.Stop())
+	}()
+
+	waitForChange := func() {
+		select {
+		case <-changes:
+		case <-time.After(10 * time.Second):
+			require.FailNow(t, "timed out waiting for health change")
+		}
+	}
+
+	gpus := devices.GpuUUIDs()
+	require.GreaterOrEqual(t, len(gpus), 2)
+	xidGpu := monitor.devicesOnGpu(gpus[0])
+	eccGpu := monitor.devicesOnGpu(gpus[1])
+
+	// XIDs caused by applications are ignored. Events are delivered in
+	// order, so once the fatal XID has been handled, so has the application
+	// XID before it.
+	require.NoError(t, server.InjectEvent(gpus[1], nvml.EventTypeXidCriticalError, 13))
+	require.NoError(t, server.InjectEvent(gpus[0], nvml.EventTypeXidCriticalError, 79))
+	waitForChange()
+	for _, name := range xidGpu {
+		require.True(t, monitor.IsUnhealthy(name), name)
+		taints := monitor.GetTaints(name)
+		require.Len(t, taints, 1)
+		require.Equal(t, DeviceUnhealthyTaintKey, taints[0].Key)
+		require.Equal(t, string(XidCriticalError), taints[0].Value)
+	}
+	for _, name := range eccGpu {
+		require.False(t, monitor.IsUnhealthy(name), name)
+	}
+
+	require.NoError(t, server.InjectEvent(gpus[1], nvml.EventTypeDoubleBitEccError, 0))
+	waitForChange()
+	for _, name := range eccGpu {
+		require.Equal(t, string(DoubleBitEccError), monitor.GetTaints(name)[0].Value)
+	}
+
+	// Devices recover from an XID only after no further XIDs have been
+	// seen for the recovery period.
+	now = now.Add(healthRecoveryPeriod - time.Second)
+	require.False(t, monitor.checkGpu(gpus[0]))
+	require.True(t, monitor.IsUnhealthy(xidGpu[0]))
+
+	now = now.Add(2 * time.Second)
+	require.True(t, monitor.checkGpu(gpus[0]))
+	for _, name := range xidGpu {
+		require.False(t, monitor.IsUnhealthy(name), name)
+	}
+
+	// Devices recover from ECC errors once the volatile counters have been
+	// cleared, which the mock reports right away.
+	require.True(t, monitor.checkGpu(gpus[1]))
+	for _, name := range eccGpu {
+		require.False(t, monitor.IsUnhealthy(name), name)
+	}
+}
+
+func TestDeviceHealthMonitorGpuReset(t *testing.T) {
+	nvdevlib := newMockDeviceLib(t, "l4")
+	server := nvdevlib.nvmllib.(*nvmlmock.Server)
+
+	devices, err := nvdevlib.enumerateAllPossibleDevices(&Config{flags: &Flags{}})
+	require.NoError(t, err)
+
+	changes := make(chan struct{}, 10)
+	monitor := NewDeviceHealthMonitor(nvdevlib, devices, func() {
+		changes <- struct{}{}
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	require.NoError(t, monitor.Start(ctx))
+	defer func() {
+		require.NoError(t, monitor.Stop())
+	}()
+
+	uuid := devices.GpuUUIDs()[0]
+	scrubber := NewGpuScrubber(nvdevlib, GpuScrubPolicyReset, time.Second)
+	scrubber.pollInterval = time.Millisecond
+
+	scrubber.OnScrubbed(monitor.SetScrubResult)
+	name := monitor.devicesOnGpu(uuid)[0]
+
+	// Event registrations keep the GPU open, so it cannot be reset while
+	// they are in place. The GPU is then unhealthy until it is scrubbed.
+	err = scrubber.Scrub(ctx, []string{uuid}, []string{uuid})
+	require.ErrorContains(t, err, "ERROR_IN_USE")
+	<-changes
+	require.True(t, monitor.IsUnhealthy(name))
+	require.Equal(t, string(ScrubFailed), monitor.GetTaints(name)[0].Value)
+
+	scrubber.OnReset(
+		func(string) { monitor.PauseEvents() },
+		func(string) { require.NoError(t, monitor.ResumeEvents()) },
+	)
+	require.NoError(t, scrubber.Scrub(ctx, []string{uuid}, []string{uuid}))
+	<-changes
+	require.False(t, monitor.IsUnhealthy(name))
+
+	// Events are delivered again once the GPU is back.
+	require.NoError(t, server.InjectEvent(uuid, nvml.EventTypeXidCriticalError, 79))
+	select {
+	case <-changes:
+	case <-time.After(10 * time.Second):
+		require.FailNow(t, "timed out waiting for health change")
+	}
+	require.True(t, monitor.IsUnhealthy(name))
+}
+
+func TestDeviceHealthMonitorNestedPause(t *testing.T) {
+	nvdevlib := newMockDeviceLib(t, "l4")
+	devices, err := nvdevlib.enumerateAllPossibleDevices(&Config{flags: &Flags{}})
+	require.NoError(t, err)
+
+	monitor := NewDeviceHealthMonitor(nvdevlib, devices, func() {})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	require.NoError(t, monitor.Start(ctx))
+	defer func() {
+		require.NoError(t, monitor.Stop())
+	}()
+
+	// A GPU reset and a bind to vfio-pci may overlap, so events only resume
+	// once both are done.
+	monitor.PauseEvents()
+	monitor.PauseEvents()
+	require.NoError(t, monitor.ResumeEvents())
+	require.Nil(t, monitor.eventSet)
+	require.NoError(t, monitor.ResumeEvents())
+	require.NotNil(t, monitor.eventSet)
+}
@@ -19,41 +19,64 @@ package main
 import (
 	"context"
 	"fmt"
+	"net/http"
 	"os"
 	"os/signal"
 	"path/filepath"
 	"syscall"
+	"time"
 
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
 	"github.com/urfave/cli/v2"
 
+	"k8s.io/dynamic-resource-allocation/kubeletplugin"
 	"k8s.io/klog/v2"
 
+	_ "k8s.io/component-base/metrics/prometheus/restclient" // for client metric registration
+	_ "k8s.io/component-base/metrics/prometheus/version"    // for version metric registration
+
 	"github.com/NVIDIA/k8s-dra-driver-gpu/internal/info"
 	"github.com/NVIDIA/k8s-dra-driver-gpu/pkg/flags"
+	"github.com/NVIDIA/k8s-dra-driver-gpu/pkg/metrics"
 )
 
 const (
 	DriverName                         = "gpu.nvidia.com"
-	DriverPluginPath                   = "/var/lib/kubelet/plugins/" + DriverName
 	DriverPluginCheckpointFileBasename = "checkpoint.json"
 )
 
 type Flags struct {
-	kubeClientConfig flags.KubeClientConfig
-	loggingConfig    *flags.LoggingConfig
-
-	nodeName            string
-	namespace           string
-	cdiRoot             string
-	containerDriverRoot string
-	hostDriverRoot      string
-	nvidiaCDIHookPath   string
-	imageName           string
+	kubeClientConfig   flags.KubeClientConfig
+	loggingConfig      *flags.LoggingConfig
+	nvmlConfig         flags.NvmlConfig
+	httpEndpointConfig flags.HTTPEndpointConfig
+
+	nodeName                    string
+	namespace                   string
+	kubeletPluginsDirectoryPath string
+	cdiRoot                     string
+	containerDriverRoot         string
+	hostDriverRoot              string
+	nvidiaCDIHookPath           string
+	imageName                   string
+	deviceHealthCheck           bool
+	gpuScrubPolicy              string
+	defaultConfigMap            string
+
+	deviceReconcileInterval time.Duration
+	gpuScrubTimeout         time.Duration
 }
 
 type Config struct {
 	flags      *Flags
 	clientsets flags.ClientSets
+	nvmllib    nvml.Interface
+}
+
+// DriverPluginPath returns the directory holding the state of the plugin, such
+// as its checkpoint, its sockets and the files of MPS control daemons.
+func (c *Config) DriverPluginPath() string {
+	return filepath.Join(c.flags.kubeletPluginsDirectoryPath, DriverName)
 }
 
 func main() {
@@ -71,7 +94,6 @@ func newApp() *cli.App {
 		&cli.StringFlag{
 			Name:        "node-name",
 			Usage:       "The name of the node to be worked on.",
-			Required:    true,
 			Destination: &flags.nodeName,
 			EnvVars:     []string{"NODE_NAME"},
 		},
@@ -82,6 +104,13 @@ func newApp() *cli.App {
 			Destination: &flags.namespace,
 			EnvVars:     []string{"NAMESPACE"},
 		},
+		&cli.StringFlag{
+			Name:        "kubelet-plugins-directory-path",
+			Usage:       "Absolute path to the directory where kubelet stores plugin data. The plugin keeps its state in a subdirectory named after the driver.",
+			Value:       kubeletplugin.KubeletPluginsDir,
+			Destination: &flags.kubeletPluginsDirectoryPath,
+			EnvVars:     []string{"KUBELET_PLUGINS_DIRECTORY_PATH"},
+		},
 		&cli.StringFlag{
 			Name:        "cdi-root",
 			Usage:       "Absolute path to the directory where CDI files will be generated.",
@@ -113,13 +142,51 @@ func newApp() *cli.App {
 		&cli.StringFlag{
 			Name:        "image-name",
 			Usage:       "The full image name to use for rendering templates.",
-			Required:    true,
 			Destination: &flags.imageName,
 			EnvVars:     []string{"IMAGE_NAME"},
 		},
+		&cli.BoolFlag{
+			Name:        "device-health-check",
+			Usage:       "Monitor devices for fatal XIDs, uncorrectable ECC errors and thermal or power violations, and taint unhealthy devices in the published ResourceSlices. Tainting requires the DRADeviceTaints feature gate and the resource.k8s.io/v1alpha3 API; without them, unhealthy devices are withdrawn from the ResourceSlices instead.",
+			Value:       true,
+			Destination: &flags.deviceHealthCheck,
+			EnvVars:     []string{"DEVICE_HEALTH_CHECK"},
+		},
+		&cli.DurationFlag{
+			Name:        "device-reconcile-interval",
+			Usage:       "Interval at which devices are re-enumerated and the published ResourceSlices are reconciled. Reconciliation can also be triggered with SIGHUP. Set to 0 to disable periodic reconciliation.",
+			Value:       5 * time.Minute,
+			Destination: &flags.deviceReconcileInterval,
+			EnvVars:     []string{"DEVICE_RECONCILE_INTERVAL"},
+		},
+		&cli.StringFlag{
+			Name: "gpu-scrub-policy",
+			Usage: "How devices are scrubbed after a claim is unprepared, before they are handed to the next claim. " +
+				"One of None, KillProcesses (kill processes left behind once the scrub timeout expires) or " +
+				"Reset (also reset full GPUs no other claim holds). Devices failing the health check that follows are not released.",
+			Value:       string(GpuScrubPolicyNone),
+			Destination: &flags.gpuScrubPolicy,
+			EnvVars:     []string{"GPU_SCRUB_POLICY"},
+		},
+		&cli.DurationFlag{
+			Name:        "gpu-scrub-timeout",
+			Usage:       "How long to wait for processes left behind on a device to exit before killing them, and for killed processes to go away.",
+			Value:       30 * time.Second,
+			Destination: &flags.gpuScrubTimeout,
+			EnvVars:     []string{"GPU_SCRUB_TIMEOUT"},
+		},
+		&cli.StringFlag{
+			Name: "default-config-map",
+			Usage: "The name of a ConfigMap in the driver namespace holding cluster-wide default configs under the key '" + DefaultConfigsKey + "'. " +
+				"They apply to claims without a config of their own, at lower precedence than configs from DeviceClasses and claims. Disabled if empty.",
+			Destination: &flags.defaultConfigMap,
+			EnvVars:     []string{"DEFAULT_CONFIG_MAP"},
+		},
 	}
 	cliFlags = append(cliFlags, flags.kubeClientConfig.Flags()...)
 	cliFlags = append(cliFlags, flags.loggingConfig.Flags()...)
+	cliFlags = append(cliFlags, flags.nvmlConfig.Flags()...)
+	cliFlags = append(cliFlags, flags.httpEndpointConfig.Flags()...)
 
 	app := &cli.App{
 		Name:            "gpu-kubelet-plugin",
@@ -127,23 +194,49 @@ func newApp() *cli.App {
 		ArgsUsage:       " ",
 		HideHelpCommand: true,
 		Flags:           cliFlags,
+		Commands: []*cli.Command{
+			newStatusCommand(flags),
+		},
 		Before: func(c *cli.Context) error {
-			if c.Args().Len() > 0 {
+			if c.Args().Len() > 0 && !isCommand(c) {
 				return fmt.Errorf("arguments not supported: %v", c.Args().Slice())
 			}
+			if err := GpuScrubPolicy(flags.gpuScrubPolicy).Validate(); err != nil {
+				return err
+			}
 			return flags.loggingConfig.Apply()
 		},
 		Action: func(c *cli.Context) error {
 			ctx := c.Context
 
+			// These flags are only needed for running the plugin, so they
+			// are checked here rather than marked as required, which would
+			// apply to the subcommands as well.
+			for _, name := range []string{"node-name", "image-name"} {
+				if !c.IsSet(name) {
+					return fmt.Errorf("required flag %q not set", name)
+				}
+			}
+
 			clientSets, err := flags.kubeClientConfig.NewClientSets()
 			if err != nil {
 				return fmt.Errorf("create client: %w", err)
 			}
 
+			nvmllib, err := flags.nvmlConfig.NewMockInterface()
+			if err != nil {
+				return fmt.Errorf("create mock NVML: %w", err)
+			}
+
 			config := &Config{
 				flags:      flags,
 				clientsets: clientSets,
+				nvmllib:    nvmllib,
+			}
+
+			metrics.Register()
+			if err := flags.httpEndpointConfig.SetupHTTPEndpoint(http.NewServeMux()); err != nil {
+				return fmt.Errorf("create http endpoint: %w", err)
 			}
 
 			return StartPlugin(ctx, config)
@@ -160,16 +253,22 @@ func newApp() *cli.App {
 	return app
 }
 
+// isCommand returns true if the first argument names one of the commands of
+// the app rather than being a stray argument.
+func isCommand(c *cli.Context) bool {
+	return c.Args().Present() && c.App.Command(c.Args().First()) != nil
+}
+
 // StartPlugin initializes and runs the GPU kubelet plugin.
 func StartPlugin(ctx context.Context, config *Config) error {
 	// Create the plugin directory
-	err := os.MkdirAll(DriverPluginPath, 0750)
+	err := os.MkdirAll(config.DriverPluginPath(), 0750)
 	if err != nil {
 		return err
 	}
 
 	// Setup nvidia-cdi-hook binary
-	if err := config.flags.setNvidiaCDIHookPath(); err != nil {
+	if err := config.flags.setNvidiaCDIHookPath(config.DriverPluginPath()); err != nil {
 		return fmt.Errorf("error setting up nvidia-cdi-hook: %w", err)
 	}
 
@@ -207,25 +306,33 @@ func StartPlugin(ctx context.Context, config *Config) error {
 		return fmt.Errorf("error creating driver: %w", err)
 	}
 
-	// Wait for shutdown signal
-	<-sigs
+	// Wait for shutdown signal, reconciling devices on SIGHUP. Reconciliation
+	// runs in the background, so that a shutdown signal arriving in the
+	// meantime is acted upon right away and cancels it.
+	for sig := range sigs {
+		if sig != syscall.SIGHUP {
+			break
+		}
+		klog.Infof("Received SIGHUP, reconciling devices")
+		driver.RequestReconcile()
+	}
 
 	return nil
 }
 
 // If 'f.nvidiaCDIHookPath' is already set (from the command line), do nothing.
 // If 'f.nvidiaCDIHookPath' is empty, it copies the nvidia-cdi-hook binary from
-// /usr/bin/nvidia-cdi-hook to DriverPluginPath and sets 'f.nvidiaCDIHookPath'
+// /usr/bin/nvidia-cdi-hook to the plugin directory and sets 'f.nvidiaCDIHookPath'
 // to this path. The /usr/bin/nvidia-cdi-hook is present in the current
 // container image because it is copied from the toolkit image into this
 // container at build time.
-func (f *Flags) setNvidiaCDIHookPath() error {
+func (f *Flags) setNvidiaCDIHookPath(pluginPath string) error {
 	if f.nvidiaCDIHookPath != "" {
 		return nil
 	}
 
 	sourcePath := "/usr/bin/nvidia-cdi-hook"
-	targetPath := filepath.Join(DriverPluginPath, "nvidia-cdi-hook")
+	targetPath := filepath.Join(pluginPath, "nvidia-cdi-hook")
 
 	input, err := os.ReadFile(sourcePath)
 	if err != nil {
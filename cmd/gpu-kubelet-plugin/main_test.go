@@ -0,0 +1,38 @@
+/*
+ * Copyright (c) 2025, NVIDIA CORPORATION.  All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestStatusWithoutPluginFlags checks that the status subcommand does not
+// require the flags that are only needed for running the plugin. Logging can
+// only be configured once per process, so the app is run only once.
+func TestStatusWithoutPluginFlags(t *testing.T) {
+	for _, env := range []string{"NODE_NAME", "IMAGE_NAME"} {
+		t.Setenv(env, "")
+		require.NoError(t, os.Unsetenv(env))
+	}
+
+	// There is no plugin running to talk to, which is the only error.
+	err := newApp().Run([]string{"gpu-kubelet-plugin", "--kubelet-plugins-directory-path", t.TempDir(), "status"})
+	require.ErrorContains(t, err, "error getting status")
+}
@@ -17,13 +17,16 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
+	"sync"
 
 	"k8s.io/klog/v2"
+	"k8s.io/utils/ptr"
 
 	nvdev "github.com/NVIDIA/go-nvlib/pkg/nvlib/device"
 	"github.com/NVIDIA/go-nvml/pkg/nvml"
@@ -34,30 +37,60 @@ type deviceLib struct {
 	nvmllib           nvml.Interface
 	driverLibraryPath string
 	devRoot           string
+	sysfsRoot         string
 	nvidiaSMIPath     string
 }
 
-func newDeviceLib(driverRoot root) (*deviceLib, error) {
-	driverLibraryPath, err := driverRoot.getDriverLibraryPath()
-	if err != nil {
-		return nil, fmt.Errorf("failed to locate driver libraries: %w", err)
-	}
+// DeviceOperationError is returned when an NVML operation fails on a
+// specific device. Output holds what nvidia-smi printed for operations that
+// go through it.
+type DeviceOperationError struct {
+	UUID      string
+	Operation string
+	Return    nvml.Return
+	Output    string
+}
 
-	nvidiaSMIPath, err := driverRoot.getNvidiaSMIPath()
-	if err != nil {
-		return nil, fmt.Errorf("failed to locate nvidia-smi: %w", err)
+func (e *DeviceOperationError) Error() string {
+	if e.Output != "" {
+		return fmt.Sprintf("error %s for device %s: %v: %s", e.Operation, e.UUID, e.Return, e.Output)
 	}
+	return fmt.Sprintf("error %s for device %s: %v", e.Operation, e.UUID, e.Return)
+}
+
+func (e *DeviceOperationError) Unwrap() error {
+	return e.Return
+}
+
+// newDeviceLib returns a deviceLib backed by the given NVML library. If
+// nvmllib is nil, the NVML library of the driver installed under driverRoot
+// is used.
+func newDeviceLib(driverRoot root, nvmllib nvml.Interface) (*deviceLib, error) {
+	var driverLibraryPath, nvidiaSMIPath string
+	if nvmllib == nil {
+		var err error
+		driverLibraryPath, err = driverRoot.getDriverLibraryPath()
+		if err != nil {
+			return nil, fmt.Errorf("failed to locate driver libraries: %w", err)
+		}
 
-	// We construct an NVML library specifying the path to libnvidia-ml.so.1
-	// explicitly so that we don't have to rely on the library path.
-	nvmllib := nvml.New(
-		nvml.WithLibraryPath(driverLibraryPath),
-	)
+		nvidiaSMIPath, err = driverRoot.getNvidiaSMIPath()
+		if err != nil {
+			return nil, fmt.Errorf("failed to locate nvidia-smi: %w", err)
+		}
+
+		// We construct an NVML library specifying the path to libnvidia-ml.so.1
+		// explicitly so that we don't have to rely on the library path.
+		nvmllib = nvml.New(
+			nvml.WithLibraryPath(driverLibraryPath),
+		)
+	}
 	d := deviceLib{
 		Interface:         nvdev.New(nvmllib),
 		nvmllib:           nvmllib,
 		driverLibraryPath: driverLibraryPath,
 		devRoot:           driverRoot.getDevRoot(),
+		sysfsRoot:         DefaultSysfsRoot,
 		nvidiaSMIPath:     nvidiaSMIPath,
 	}
 	return &d, nil
@@ -121,11 +154,13 @@ func (l deviceLib) enumerateGpusAndMigDevices(config *Config) (AllocatableDevice
 	defer l.alwaysShutdown()
 
 	devices := make(AllocatableDevices)
+	var gpus []*GpuInfo
 	err := l.VisitDevices(func(i int, d nvdev.Device) error {
 		gpuInfo, err := l.getGpuInfo(i, d)
 		if err != nil {
 			return fmt.Errorf("error getting info for GPU %d: %w", i, err)
 		}
+		gpus = append(gpus, gpuInfo)
 
 		deviceInfo := &AllocatableDevice{
 			Gpu: gpuInfo,
@@ -144,12 +179,24 @@ func (l deviceLib) enumerateGpusAndMigDevices(config *Config) (AllocatableDevice
 			devices[migDeviceInfo.CanonicalName()] = deviceInfo
 		}
 
+		for _, migDeviceInfo := range getDynamicMigDevices(gpuInfo, migs) {
+			if _, exists := devices[migDeviceInfo.CanonicalName()]; exists {
+				continue
+			}
+			deviceInfo := &AllocatableDevice{
+				Mig: migDeviceInfo,
+			}
+			devices[migDeviceInfo.CanonicalName()] = deviceInfo
+		}
+
 		return nil
 	})
 	if err != nil {
 		return nil, fmt.Errorf("error visiting devices: %w", err)
 	}
 
+	assignNvLinkIslands(gpus)
+
 	return devices, nil
 }
 
@@ -194,6 +241,26 @@ func (l deviceLib) getGpuInfo(index int, device nvdev.Device) (*GpuInfo, error)
 	if ret != nvml.SUCCESS {
 		return nil, fmt.Errorf("error getting CUDA driver version: %w", err)
 	}
+	pciBusID, err := device.GetPCIBusID()
+	if err != nil {
+		return nil, fmt.Errorf("error getting PCI bus ID for device %d: %w", index, err)
+	}
+	migCapable, err := device.IsMigCapable()
+	if err != nil {
+		return nil, fmt.Errorf("error checking if MIG capable for device %d: %w", index, err)
+	}
+	pcieRoot, err := l.getPCIeRoot(pciBusID)
+	if err != nil {
+		return nil, fmt.Errorf("error getting PCIe root for device %d: %w", index, err)
+	}
+	numaNode, err := l.getNumaNode(pciBusID)
+	if err != nil {
+		return nil, fmt.Errorf("error getting NUMA node for device %d: %w", index, err)
+	}
+	nvlinkPeers, nvlinkSwitch, err := l.getNvLinkPeers(device)
+	if err != nil {
+		return nil, fmt.Errorf("error getting NVLink peers for device %d: %w", index, err)
+	}
 
 	var migProfiles []*MigProfileInfo
 	for i := 0; i < nvml.GPU_INSTANCE_PROFILE_COUNT; i++ {
@@ -239,8 +306,9 @@ func (l deviceLib) getGpuInfo(index int, device nvdev.Device) (*GpuInfo, error)
 				}
 
 				profileInfo := &MigProfileInfo{
-					profile:    migProfile,
-					placements: migDevicePlacements,
+					profile:       migProfile,
+					giProfileInfo: &giProfileInfo,
+					placements:    migDevicePlacements,
 				}
 
 				migProfiles = append(migProfiles, profileInfo)
@@ -260,12 +328,95 @@ func (l deviceLib) getGpuInfo(index int, device nvdev.Device) (*GpuInfo, error)
 		cudaComputeCapability: cudaComputeCapability,
 		driverVersion:         driverVersion,
 		cudaDriverVersion:     fmt.Sprintf("%v.%v", cudaDriverVersion/1000, (cudaDriverVersion%1000)/10),
+		pciBusID:              pciBusID,
+		pcieRoot:              pcieRoot,
+		numaNode:              numaNode,
+		nvlinkPeers:           nvlinkPeers,
+		nvlinkSwitch:          nvlinkSwitch,
+		migCapable:            migCapable,
 		migProfiles:           migProfiles,
 	}
 
+	if err := l.getOptionalGpuInfo(index, device, gpuInfo); err != nil {
+		return nil, err
+	}
+
 	return gpuInfo, nil
 }
 
+// getOptionalGpuInfo fills in the properties of a GPU that are not reported
+// by all GPUs, e.g. the serial number on consumer boards. A property that is
+// not supported by the GPU is left unset.
+func (l deviceLib) getOptionalGpuInfo(index int, device nvdev.Device, gpuInfo *GpuInfo) error {
+	serial, ret := device.GetSerial()
+	if err := optionalReturn(ret); err != nil {
+		return fmt.Errorf("error getting serial number for device %d: %w", index, err)
+	}
+	if ret == nvml.SUCCESS {
+		gpuInfo.serial = serial
+	}
+
+	vbiosVersion, ret := device.GetVbiosVersion()
+	if err := optionalReturn(ret); err != nil {
+		return fmt.Errorf("error getting VBIOS version for device %d: %w", index, err)
+	}
+	if ret == nvml.SUCCESS {
+		gpuInfo.vbiosVersion = vbiosVersion
+	}
+
+	eccMode, _, ret := device.GetEccMode()
+	if err := optionalReturn(ret); err != nil {
+		return fmt.Errorf("error getting ECC mode for device %d: %w", index, err)
+	}
+	if ret == nvml.SUCCESS {
+		gpuInfo.eccEnabled = ptr.To(eccMode == nvml.FEATURE_ENABLED)
+	}
+
+	defaultPowerLimit, ret := device.GetPowerManagementDefaultLimit()
+	if err := optionalReturn(ret); err != nil {
+		return fmt.Errorf("error getting default power limit for device %d: %w", index, err)
+	}
+	if ret == nvml.SUCCESS {
+		gpuInfo.defaultPowerLimitWatts = defaultPowerLimit / 1000
+	}
+
+	minPowerLimit, maxPowerLimit, ret := device.GetPowerManagementLimitConstraints()
+	if err := optionalReturn(ret); err != nil {
+		return fmt.Errorf("error getting power limit constraints for device %d: %w", index, err)
+	}
+	if ret == nvml.SUCCESS {
+		gpuInfo.minPowerLimitWatts = minPowerLimit / 1000
+		gpuInfo.maxPowerLimitWatts = maxPowerLimit / 1000
+	}
+
+	maxGraphicsClock, ret := device.GetMaxClockInfo(nvml.CLOCK_GRAPHICS)
+	if err := optionalReturn(ret); err != nil {
+		return fmt.Errorf("error getting max graphics clock for device %d: %w", index, err)
+	}
+	if ret == nvml.SUCCESS {
+		gpuInfo.maxGraphicsClockMHz = maxGraphicsClock
+	}
+
+	maxMemoryClock, ret := device.GetMaxClockInfo(nvml.CLOCK_MEM)
+	if err := optionalReturn(ret); err != nil {
+		return fmt.Errorf("error getting max memory clock for device %d: %w", index, err)
+	}
+	if ret == nvml.SUCCESS {
+		gpuInfo.maxMemoryClockMHz = maxMemoryClock
+	}
+
+	return nil
+}
+
+// optionalReturn returns nil if an NVML call either succeeded or is not
+// supported by the device, and the returned error otherwise.
+func optionalReturn(ret nvml.Return) error {
+	if ret == nvml.SUCCESS || ret == nvml.ERROR_NOT_SUPPORTED {
+		return nil
+	}
+	return ret
+}
+
 func (l deviceLib) getMigDevices(gpuInfo *GpuInfo) (map[string]*MigDeviceInfo, error) {
 	if !gpuInfo.migEnabled {
 		return nil, nil
@@ -353,6 +504,7 @@ func (l deviceLib) getMigDevices(gpuInfo *GpuInfo) (map[string]*MigDeviceInfo, e
 			giInfo:        &giInfo,
 			ciProfileInfo: ciProfileInfo,
 			ciInfo:        &ciInfo,
+			migProfile:    migProfile,
 		}
 		return nil
 	})
@@ -367,6 +519,33 @@ func (l deviceLib) getMigDevices(gpuInfo *GpuInfo) (map[string]*MigDeviceInfo, e
 	return migInfos, nil
 }
 
+// getDynamicMigDevices returns the MIG devices that can be created on demand
+// on a MIG-enabled GPU. Placements that overlap an existing MIG device are
+// skipped since they cannot be created until that device is destroyed.
+func getDynamicMigDevices(gpuInfo *GpuInfo, existing map[string]*MigDeviceInfo) []*MigDeviceInfo {
+	if !gpuInfo.migEnabled {
+		return nil
+	}
+
+	var migInfos []*MigDeviceInfo
+	for _, profile := range gpuInfo.migProfiles {
+		for _, placement := range profile.placements {
+			overlaps := false
+			for _, mig := range existing {
+				if mig.placement.Overlaps(placement) {
+					overlaps = true
+					break
+				}
+			}
+			if overlaps {
+				continue
+			}
+			migInfos = append(migInfos, newDynamicMigDeviceInfo(gpuInfo, profile, placement))
+		}
+	}
+	return migInfos
+}
+
 func walkMigDevices(d nvml.Device, f func(i int, d nvml.Device) error) error {
 	count, ret := nvml.Device(d).GetMaxMigDeviceCount()
 	if ret != nvml.SUCCESS {
@@ -392,152 +571,268 @@ func walkMigDevices(d nvml.Device, f func(i int, d nvml.Device) error) error {
 	return nil
 }
 
-func (l deviceLib) setTimeSlice(uuids []string, timeSlice int) error {
-	for _, uuid := range uuids {
-		cmd := exec.Command(
-			l.nvidiaSMIPath,
-			"compute-policy",
-			"-i", uuid,
-			"--set-timeslice", fmt.Sprintf("%d", timeSlice))
+// setTimeSlice sets the compute time-slice of the GPUs with the given UUIDs
+// to one of the intervals of `nvidia-smi compute-policy --set-timeslice`, 0
+// being the default. NVML has no API for the compute time-slice outside of
+// the vGPU scheduler, which is not available on bare-metal or passthrough
+// hosts, so this always goes through nvidia-smi.
+func (l deviceLib) setTimeSlice(uuids []string, interval int) error {
+	errs := make([]error, len(uuids))
+	var wg sync.WaitGroup
+	for i, uuid := range uuids {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = l.runNvidiaSMISetTimeslice(uuid, interval)
+		}()
+	}
+	wg.Wait()
+
+	return errors.Join(errs...)
+}
 
-		// In order for nvidia-smi to run, we need update LD_PRELOAD to include the path to libnvidia-ml.so.1.
-		cmd.Env = setOrOverrideEnvvar(os.Environ(), "LD_PRELOAD", prependPathListEnvvar("LD_PRELOAD", l.driverLibraryPath))
+// runNvidiaSMISetTimeslice runs `nvidia-smi compute-policy --set-timeslice`
+// for a GPU. On failure, its exit code is translated into the matching NVML
+// return code of the returned DeviceOperationError.
+func (l deviceLib) runNvidiaSMISetTimeslice(uuid string, interval int) error {
+	cmd := exec.Command(
+		l.nvidiaSMIPath,
+		"compute-policy",
+		"-i", uuid,
+		"--set-timeslice", fmt.Sprintf("%d", interval))
+
+	// In order for nvidia-smi to run, we need update LD_PRELOAD to include the path to libnvidia-ml.so.1.
+	cmd.Env = setOrOverrideEnvvar(os.Environ(), "LD_PRELOAD", prependPathListEnvvar("LD_PRELOAD", l.driverLibraryPath))
+
+	output, err := cmd.CombinedOutput()
+	if err == nil {
+		return nil
+	}
 
-		output, err := cmd.CombinedOutput()
-		if err != nil {
-			klog.Errorf("\n%v", string(output))
-			return fmt.Errorf("error running nvidia-smi: %w", err)
+	ret := nvml.ERROR_UNKNOWN
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		// See RETURN VALUE in nvidia-smi(1).
+		switch exitErr.ExitCode() {
+		case 2:
+			ret = nvml.ERROR_INVALID_ARGUMENT
+		case 3:
+			ret = nvml.ERROR_NOT_SUPPORTED
+		case 4:
+			ret = nvml.ERROR_NO_PERMISSION
 		}
 	}
-	return nil
+	return &DeviceOperationError{
+		UUID:      uuid,
+		Operation: "setting time slice",
+		Return:    ret,
+		Output:    strings.TrimSpace(string(output)),
+	}
 }
 
-func (l deviceLib) setComputeMode(uuids []string, mode string) error {
-	for _, uuid := range uuids {
-		cmd := exec.Command(
-			l.nvidiaSMIPath,
-			"-i", uuid,
-			"-c", mode)
+// setComputeMode sets the compute mode of the GPUs with the given UUIDs.
+func (l deviceLib) setComputeMode(uuids []string, mode nvml.ComputeMode) error {
+	return l.forEachDevice(uuids, "setting compute mode", func(device nvml.Device) nvml.Return {
+		return device.SetComputeMode(mode)
+	})
+}
 
-		// In order for nvidia-smi to run, we need update LD_PRELOAD to include the path to libnvidia-ml.so.1.
-		cmd.Env = setOrOverrideEnvvar(os.Environ(), "LD_PRELOAD", prependPathListEnvvar("LD_PRELOAD", l.driverLibraryPath))
+// forEachDevice runs an NVML operation concurrently on the GPUs with the
+// given UUIDs. A DeviceOperationError is returned for every GPU on which the
+// operation failed.
+func (l deviceLib) forEachDevice(uuids []string, operation string, f func(nvml.Device) nvml.Return) error {
+	if err := l.Init(); err != nil {
+		return err
+	}
+	defer l.alwaysShutdown()
 
-		output, err := cmd.CombinedOutput()
-		if err != nil {
-			klog.Errorf("\n%v", string(output))
-			return fmt.Errorf("error running nvidia-smi: %w", err)
+	errs := make([]error, len(uuids))
+	var wg sync.WaitGroup
+	for i, uuid := range uuids {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			device, ret := l.nvmllib.DeviceGetHandleByUUID(uuid)
+			if ret == nvml.SUCCESS {
+				ret = f(device)
+			}
+			if ret != nvml.SUCCESS {
+				errs[i] = &DeviceOperationError{
+					UUID:      uuid,
+					Operation: operation,
+					Return:    ret,
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	return errors.Join(errs...)
+}
+
+func (l deviceLib) createMigDevice(mig *MigDeviceInfo) (*MigDeviceInfo, error) {
+	if err := l.Init(); err != nil {
+		return nil, err
+	}
+	defer l.alwaysShutdown()
+
+	profileInfo := mig.migProfile.profile.GetInfo()
+
+	device, ret := l.nvmllib.DeviceGetHandleByUUID(mig.parent.UUID)
+	if ret != nvml.SUCCESS {
+		return nil, fmt.Errorf("error getting GPU device handle: %v", ret)
+	}
+
+	placement := mig.placement.GpuInstancePlacement
+	gi, ret := device.CreateGpuInstanceWithPlacement(mig.giProfileInfo, &placement)
+	if ret != nvml.SUCCESS {
+		return nil, fmt.Errorf("error creating GPU instance for '%v': %v", mig.profile, ret)
+	}
+
+	// Make sure we do not leak a partially created MIG device on error.
+	var ci nvml.ComputeInstance
+	success := false
+	defer func() {
+		if success {
+			return
+		}
+		if ci != nil {
+			if ret := ci.Destroy(); ret != nvml.SUCCESS {
+				klog.Warningf("error destroying Compute instance for '%v': %v", mig.profile, ret)
+			}
 		}
+		if ret := gi.Destroy(); ret != nvml.SUCCESS {
+			klog.Warningf("error destroying GPU instance for '%v': %v", mig.profile, ret)
+		}
+	}()
+
+	giInfo, ret := gi.GetInfo()
+	if ret != nvml.SUCCESS {
+		return nil, fmt.Errorf("error getting GPU instance info for '%v': %v", mig.profile, ret)
 	}
-	return nil
+
+	ciProfileInfo, ret := gi.GetComputeInstanceProfileInfo(profileInfo.CIProfileID, profileInfo.CIEngProfileID)
+	if ret != nvml.SUCCESS {
+		return nil, fmt.Errorf("error getting Compute instance profile info for '%v': %v", mig.profile, ret)
+	}
+
+	ci, ret = gi.CreateComputeInstance(&ciProfileInfo)
+	if ret != nvml.SUCCESS {
+		return nil, fmt.Errorf("error creating Compute instance for '%v': %v", mig.profile, ret)
+	}
+
+	ciInfo, ret := ci.GetInfo()
+	if ret != nvml.SUCCESS {
+		return nil, fmt.Errorf("error getting Compute instance info for '%v': %v", mig.profile, ret)
+	}
+
+	uuid := ""
+	index := -1
+	err := walkMigDevices(device, func(i int, migDevice nvml.Device) error {
+		giID, ret := migDevice.GetGpuInstanceId()
+		if ret != nvml.SUCCESS {
+			return fmt.Errorf("error getting GPU instance ID for MIG device: %v", ret)
+		}
+		ciID, ret := migDevice.GetComputeInstanceId()
+		if ret != nvml.SUCCESS {
+			return fmt.Errorf("error getting Compute instance ID for MIG device: %v", ret)
+		}
+		if giID != int(giInfo.Id) || ciID != int(ciInfo.Id) {
+			return nil
+		}
+		uuid, ret = migDevice.GetUUID()
+		if ret != nvml.SUCCESS {
+			return fmt.Errorf("error getting UUID for MIG device: %v", ret)
+		}
+		index = i
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error processing MIG device for GI and CI just created: %w", err)
+	}
+	if uuid == "" {
+		return nil, fmt.Errorf("unable to find MIG device for GI and CI just created")
+	}
+
+	migInfo := &MigDeviceInfo{
+		UUID:          uuid,
+		index:         index,
+		profile:       mig.profile,
+		parent:        mig.parent,
+		placement:     mig.placement,
+		giProfileInfo: mig.giProfileInfo,
+		giInfo:        &giInfo,
+		ciProfileInfo: &ciProfileInfo,
+		ciInfo:        &ciInfo,
+		migProfile:    mig.migProfile,
+		dynamic:       true,
+	}
+
+	success = true
+	return migInfo, nil
 }
 
-// TODO: Reenable dynamic MIG functionality once it is supported in Kubernetes 1.32
-//
-// func (l deviceLib) createMigDevice(gpu *GpuInfo, profile nvdev.MigProfile, placement *nvml.GpuInstancePlacement) (*MigDeviceInfo, error) {
-// 	if err := l.Init(); err != nil {
-// 		return nil, err
-// 	}
-// 	defer l.alwaysShutdown()
-//
-// 	profileInfo := profile.GetInfo()
-//
-// 	device, ret := l.nvmllib.DeviceGetHandleByUUID(gpu.UUID)
-// 	if ret != nvml.SUCCESS {
-// 		return nil, fmt.Errorf("error getting GPU device handle: %v", ret)
-// 	}
-//
-// 	giProfileInfo, ret := device.GetGpuInstanceProfileInfo(profileInfo.GIProfileID)
-// 	if ret != nvml.SUCCESS {
-// 		return nil, fmt.Errorf("error getting GPU instance profile info for '%v': %v", profile, ret)
-// 	}
-//
-// 	gi, ret := device.CreateGpuInstanceWithPlacement(&giProfileInfo, placement)
-// 	if ret != nvml.SUCCESS {
-// 		return nil, fmt.Errorf("error creating GPU instance for '%v': %v", profile, ret)
-// 	}
-//
-// 	giInfo, ret := gi.GetInfo()
-// 	if ret != nvml.SUCCESS {
-// 		return nil, fmt.Errorf("error getting GPU instance info for '%v': %v", profile, ret)
-// 	}
-//
-// 	ciProfileInfo, ret := gi.GetComputeInstanceProfileInfo(profileInfo.CIProfileID, profileInfo.CIEngProfileID)
-// 	if ret != nvml.SUCCESS {
-// 		return nil, fmt.Errorf("error getting Compute instance profile info for '%v': %v", profile, ret)
-// 	}
-//
-// 	ci, ret := gi.CreateComputeInstance(&ciProfileInfo)
-// 	if ret != nvml.SUCCESS {
-// 		return nil, fmt.Errorf("error creating Compute instance for '%v': %v", profile, ret)
-// 	}
-//
-// 	ciInfo, ret := ci.GetInfo()
-// 	if ret != nvml.SUCCESS {
-// 		return nil, fmt.Errorf("error getting GPU instance info for '%v': %v", profile, ret)
-// 	}
-//
-// 	uuid := ""
-// 	err := walkMigDevices(device, func(i int, migDevice nvml.Device) error {
-// 		giID, ret := migDevice.GetGpuInstanceId()
-// 		if ret != nvml.SUCCESS {
-// 			return fmt.Errorf("error getting GPU instance ID for MIG device: %v", ret)
-// 		}
-// 		ciID, ret := migDevice.GetComputeInstanceId()
-// 		if ret != nvml.SUCCESS {
-// 			return fmt.Errorf("error getting Compute instance ID for MIG device: %v", ret)
-// 		}
-// 		if giID != int(giInfo.Id) || ciID != int(ciInfo.Id) {
-// 			return nil
-// 		}
-// 		uuid, ret = migDevice.GetUUID()
-// 		if ret != nvml.SUCCESS {
-// 			return fmt.Errorf("error getting UUID for MIG device: %v", ret)
-// 		}
-// 		return nil
-// 	})
-// 	if err != nil {
-// 		return nil, fmt.Errorf("error processing MIG device for GI and CI just created: %w", err)
-// 	}
-// 	if uuid == "" {
-// 		return nil, fmt.Errorf("unable to find MIG device for GI and CI just created")
-// 	}
-//
-// 	migInfo := &MigDeviceInfo{
-// 		UUID:    uuid,
-// 		parent:  gpu,
-// 		profile: profile,
-// 		giInfo:  &giInfo,
-// 		ciInfo:  &ciInfo,
-// 	}
-//
-// 	return migInfo, nil
-// }
-//
-// func (l deviceLib) deleteMigDevice(mig *MigDeviceInfo) error {
-// 	if err := l.Init(); err != nil {
-// 		return err
-// 	}
-// 	defer l.alwaysShutdown()
-//
-// 	parent, ret := l.nvmllib.DeviceGetHandleByUUID(mig.parent.UUID)
-// 	if ret != nvml.SUCCESS {
-// 		return fmt.Errorf("error getting device from UUID '%v': %v", mig.parent.UUID, ret)
-// 	}
-// 	gi, ret := parent.GetGpuInstanceById(int(mig.giInfo.Id))
-// 	if ret != nvml.SUCCESS {
-// 		return fmt.Errorf("error getting GPU instance ID for MIG device: %v", ret)
-// 	}
-// 	ci, ret := gi.GetComputeInstanceById(int(mig.ciInfo.Id))
-// 	if ret != nvml.SUCCESS {
-// 		return fmt.Errorf("error getting Compute instance ID for MIG device: %v", ret)
-// 	}
-// 	ret = ci.Destroy()
-// 	if ret != nvml.SUCCESS {
-// 		return fmt.Errorf("error destroying Compute Instance: %v", ret)
-// 	}
-// 	ret = gi.Destroy()
-// 	if ret != nvml.SUCCESS {
-// 		return fmt.Errorf("error destroying GPU Instance: %v", ret)
-// 	}
-// 	return nil
-// }
+// migDeviceExists returns true if the MIG device with the given UUID exists.
+func (l deviceLib) migDeviceExists(uuid string) (bool, error) {
+	if err := l.Init(); err != nil {
+		return false, err
+	}
+	defer l.alwaysShutdown()
+
+	_, ret := l.nvmllib.DeviceGetHandleByUUID(uuid)
+	switch ret {
+	case nvml.SUCCESS:
+		return true, nil
+	case nvml.ERROR_NOT_FOUND:
+		return false, nil
+	default:
+		return false, fmt.Errorf("error getting device from UUID '%v': %v", uuid, ret)
+	}
+}
+
+// deleteMigDevice destroys the compute instance and GPU instance backing the
+// MIG device with the given UUID. Deleting a MIG device that no longer exists
+// is not an error.
+func (l deviceLib) deleteMigDevice(uuid string) error {
+	if err := l.Init(); err != nil {
+		return err
+	}
+	defer l.alwaysShutdown()
+
+	migDevice, ret := l.nvmllib.DeviceGetHandleByUUID(uuid)
+	if ret == nvml.ERROR_NOT_FOUND {
+		return nil
+	}
+	if ret != nvml.SUCCESS {
+		return fmt.Errorf("error getting device from UUID '%v': %v", uuid, ret)
+	}
+	parent, ret := migDevice.GetDeviceHandleFromMigDeviceHandle()
+	if ret != nvml.SUCCESS {
+		return fmt.Errorf("error getting parent device for MIG device '%v': %v", uuid, ret)
+	}
+	giID, ret := migDevice.GetGpuInstanceId()
+	if ret != nvml.SUCCESS {
+		return fmt.Errorf("error getting GPU instance ID for MIG device: %v", ret)
+	}
+	ciID, ret := migDevice.GetComputeInstanceId()
+	if ret != nvml.SUCCESS {
+		return fmt.Errorf("error getting Compute instance ID for MIG device: %v", ret)
+	}
+	gi, ret := parent.GetGpuInstanceById(giID)
+	if ret != nvml.SUCCESS {
+		return fmt.Errorf("error getting GPU instance for '%v': %v", giID, ret)
+	}
+	ci, ret := gi.GetComputeInstanceById(ciID)
+	if ret != nvml.SUCCESS {
+		return fmt.Errorf("error getting Compute instance for '%v': %v", ciID, ret)
+	}
+	ret = ci.Destroy()
+	if ret != nvml.SUCCESS {
+		return fmt.Errorf("error destroying Compute Instance: %v", ret)
+	}
+	ret = gi.Destroy()
+	if ret != nvml.SUCCESS {
+		return fmt.Errorf("error destroying GPU Instance: %v", ret)
+	}
+	return nil
+}
@@ -0,0 +1,201 @@
+/*
+ * Copyright (c) 2025, NVIDIA CORPORATION.  All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+	"github.com/stretchr/testify/require"
+
+	configapi "github.com/NVIDIA/k8s-dra-driver-gpu/api/nvidia.com/resource/v1beta1"
+	"github.com/NVIDIA/k8s-dra-driver-gpu/pkg/nvmlmock"
+)
+
+func newMockDeviceLib(t *testing.T, profileName string) *deviceLib {
+	profile, err := nvmlmock.LoadProfile(profileName)
+	require.NoError(t, err)
+	server, err := nvmlmock.New(profile)
+	require.NoError(t, err)
+	nvdevlib, err := newDeviceLib(root(t.TempDir()), server)
+	require.NoError(t, err)
+	nvdevlib.sysfsRoot = t.TempDir()
+	return nvdevlib
+}
+
+// fakeNvidiaSMI points the deviceLib at a fake nvidia-smi that exits with
+// the given code and otherwise records the time slice set on each GPU, to be
+// read back with recordedTimeSlice.
+func fakeNvidiaSMI(t *testing.T, nvdevlib *deviceLib, exitCode int) {
+	dir := t.TempDir()
+	script := fmt.Sprintf(`#!/bin/sh
+# nvidia-smi compute-policy -i <uuid> --set-timeslice <interval>
+if [ %d -ne 0 ]; then
+	echo "Failed to set the time slice of $3"
+	exit %d
+fi
+echo "$5" > "%s/$3"
+`, exitCode, exitCode, dir)
+	nvdevlib.nvidiaSMIPath = filepath.Join(dir, "nvidia-smi")
+	require.NoError(t, os.WriteFile(nvdevlib.nvidiaSMIPath, []byte(script), 0755))
+}
+
+// recordedTimeSlice returns the time slice last set on a GPU through the fake
+// nvidia-smi of fakeNvidiaSMI, 0 being the default.
+func recordedTimeSlice(t *testing.T, nvdevlib *deviceLib, uuid string) int {
+	data, err := os.ReadFile(filepath.Join(filepath.Dir(nvdevlib.nvidiaSMIPath), uuid))
+	if errors.Is(err, os.ErrNotExist) {
+		return 0
+	}
+	require.NoError(t, err)
+	interval, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	require.NoError(t, err)
+	return interval
+}
+
+func TestEnumerateAllPossibleDevices(t *testing.T) {
+	nvdevlib := newMockDeviceLib(t, "dgx-a100")
+
+	devices, err := nvdevlib.enumerateAllPossibleDevices(&Config{flags: &Flags{}})
+	require.NoError(t, err)
+
+	counts := make(map[string]int)
+	existing := 0
+	for _, device := range devices {
+		counts[device.Type()]++
+		if device.Type() == MigDeviceType && device.Mig.Exists() {
+			existing++
+		}
+	}
+	require.Equal(t, 8, counts[GpuDeviceType])
+	require.Equal(t, 12, existing)
+
+	// The first MIG-enabled GPU has 1g devices on slices 0 and 1 and a 3g
+	// device on slices 4-7, leaving room for 1g devices on slices 2 and 3
+	// and a 2g device on slices 2-3.
+	require.Contains(t, devices, "gpu-4-mig-0-0-1")
+	require.Contains(t, devices, "gpu-4-mig-0-2-1")
+	require.Contains(t, devices, "gpu-4-mig-1-2-2")
+	require.NotContains(t, devices, "gpu-4-mig-1-0-2")
+	require.False(t, devices["gpu-4-mig-0-2-1"].Mig.Exists())
+	require.NotContains(t, devices, "gpu-0-mig-0-0-1")
+}
+
+func TestCreateAndDeleteMigDevice(t *testing.T) {
+	nvdevlib := newMockDeviceLib(t, "dgx-a100")
+
+	devices, err := nvdevlib.enumerateAllPossibleDevices(&Config{flags: &Flags{}})
+	require.NoError(t, err)
+
+	mig, err := nvdevlib.createMigDevice(devices["gpu-4-mig-1-2-2"].Mig)
+	require.NoError(t, err)
+	require.True(t, mig.Exists())
+	require.Equal(t, "gpu-4-mig-1-2-2", mig.CanonicalName())
+
+	// The slices are now in use, so an overlapping device cannot be created.
+	_, err = nvdevlib.createMigDevice(devices["gpu-4-mig-0-3-1"].Mig)
+	require.Error(t, err)
+
+	require.NoError(t, nvdevlib.deleteMigDevice(mig.UUID))
+	require.NoError(t, nvdevlib.deleteMigDevice(mig.UUID))
+
+	mig, err = nvdevlib.createMigDevice(devices["gpu-4-mig-0-3-1"].Mig)
+	require.NoError(t, err)
+	require.NoError(t, nvdevlib.deleteMigDevice(mig.UUID))
+}
+
+func TestSetComputeModeErrors(t *testing.T) {
+	profile, err := nvmlmock.LoadProfile("gb200")
+	require.NoError(t, err)
+	profile.Gpus[0].Failures = nvmlmock.Failures{"SetComputeMode": "ERROR_NO_PERMISSION"}
+	server, err := nvmlmock.New(profile)
+	require.NoError(t, err)
+	nvdevlib, err := newDeviceLib(root(t.TempDir()), server)
+	require.NoError(t, err)
+	fakeNvidiaSMI(t, nvdevlib, 0)
+
+	var uuids []string
+	for i := range 2 {
+		device, ret := server.DeviceGetHandleByIndex(i)
+		require.Equal(t, nvml.SUCCESS, ret)
+		uuid, ret := device.GetUUID()
+		require.Equal(t, nvml.SUCCESS, ret)
+		uuids = append(uuids, uuid)
+	}
+
+	err = nvdevlib.setComputeMode(uuids, nvml.COMPUTEMODE_EXCLUSIVE_PROCESS)
+	require.ErrorIs(t, err, nvml.ERROR_NO_PERMISSION)
+
+	var deviceErr *DeviceOperationError
+	require.True(t, errors.As(err, &deviceErr))
+	require.Contains(t, uuids, deviceErr.UUID)
+
+	require.NoError(t, nvdevlib.setTimeSlice(uuids, 2))
+	for _, uuid := range uuids {
+		require.Equal(t, 2, recordedTimeSlice(t, nvdevlib, uuid))
+	}
+}
+
+func TestSetTimeSlice(t *testing.T) {
+	timeSlicingConfig := func(interval configapi.TimeSliceInterval) *configapi.TimeSlicingConfig {
+		return &configapi.TimeSlicingConfig{Interval: &interval}
+	}
+
+	newTimeSlicingManager := func(t *testing.T, exitCode int) (*TimeSlicingManager, AllocatableDevices, func() int) {
+		nvdevlib := newMockDeviceLib(t, "l4")
+		fakeNvidiaSMI(t, nvdevlib, exitCode)
+
+		devices, err := nvdevlib.enumerateAllPossibleDevices(&Config{flags: &Flags{}})
+		require.NoError(t, err)
+		require.Contains(t, devices, "gpu-0")
+		uuid := devices["gpu-0"].Gpu.UUID
+		return NewTimeSlicingManager(nvdevlib), devices, func() int { return recordedTimeSlice(t, nvdevlib, uuid) }
+	}
+
+	t.Run("bare metal", func(t *testing.T) {
+		tsManager, devices, timeSlice := newTimeSlicingManager(t, 0)
+
+		require.NoError(t, tsManager.SetTimeSlice(devices, timeSlicingConfig(configapi.DefaultTimeSlice)))
+		require.Equal(t, 0, timeSlice())
+
+		require.NoError(t, tsManager.SetTimeSlice(devices, timeSlicingConfig(configapi.LongTimeSlice)))
+		require.Equal(t, 3, timeSlice())
+
+		require.NoError(t, tsManager.ResetTimeSlice(devices, timeSlicingConfig(configapi.LongTimeSlice)))
+		require.Equal(t, 0, timeSlice())
+	})
+
+	t.Run("time slice not supported", func(t *testing.T) {
+		// nvidia-smi exits with 3 when the operation is not supported.
+		tsManager, devices, _ := newTimeSlicingManager(t, 3)
+
+		// The default time slice never needs to be set.
+		require.NoError(t, tsManager.SetTimeSlice(devices, timeSlicingConfig(configapi.DefaultTimeSlice)))
+		require.NoError(t, tsManager.ResetTimeSlice(devices, timeSlicingConfig(configapi.DefaultTimeSlice)))
+
+		err := tsManager.SetTimeSlice(devices, timeSlicingConfig(configapi.ShortTimeSlice))
+		require.True(t, isPermanentError(err))
+		require.ErrorContains(t, err, "time slice Short is not supported")
+		require.ErrorContains(t, err, "Failed to set the time slice")
+	})
+}
@@ -38,6 +38,9 @@ type PreparedGpu struct {
 type PreparedMigDevice struct {
 	Info   *MigDeviceInfo        `json:"info"`
 	Device *kubeletplugin.Device `json:"device"`
+	// Created is set if the MIG device was created as part of preparing the
+	// claim and must therefore be destroyed again when it is unprepared.
+	Created bool `json:"created,omitempty"`
 }
 
 type PreparedDeviceGroup struct {
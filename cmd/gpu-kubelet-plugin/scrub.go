@@ -0,0 +1,395 @@
+/*
+ * Copyright (c) 2025, NVIDIA CORPORATION.  All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+	"k8s.io/klog/v2"
+)
+
+// GpuScrubPolicy selects how devices are cleaned up after a claim has been
+// unprepared and before they are handed to the next claim.
+type GpuScrubPolicy string
+
+const (
+	// GpuScrubPolicyNone returns devices to the pool as they are.
+	GpuScrubPolicyNone GpuScrubPolicy = "None"
+	// GpuScrubPolicyKillProcesses waits for processes left behind on a
+	// device to exit and kills them once the scrub timeout has expired.
+	GpuScrubPolicyKillProcesses GpuScrubPolicy = "KillProcesses"
+	// GpuScrubPolicyReset additionally resets full GPUs that are no longer
+	// held by any claim, clearing their memory and all volatile state. A GPU
+	// can only be reset if nothing but this plugin holds it open, which rules
+	// out persistence mode, nvidia-persistenced and other NVML clients such
+	// as DCGM. GPUs that cannot be reset are not handed out again.
+	GpuScrubPolicyReset GpuScrubPolicy = "Reset"
+)
+
+const gpuScrubPollInterval = time.Second
+
+var gpuScrubPolicies = []GpuScrubPolicy{
+	GpuScrubPolicyNone,
+	GpuScrubPolicyKillProcesses,
+	GpuScrubPolicyReset,
+}
+
+func (p GpuScrubPolicy) Validate() error {
+	if !slices.Contains(gpuScrubPolicies, p) {
+		return fmt.Errorf("unknown GPU scrub policy %q, must be one of %v", p, gpuScrubPolicies)
+	}
+	return nil
+}
+
+// GpuScrubber makes sure that nothing a claim left behind on its devices is
+// visible to the next claim. Devices that cannot be scrubbed or that are not
+// healthy afterwards must not be handed out again.
+type GpuScrubber struct {
+	nvdevlib     *deviceLib
+	policy       GpuScrubPolicy
+	timeout      time.Duration
+	pollInterval time.Duration
+	kill         func(pid int) error
+
+	// resetMutex serializes GPU resets, so that anything released for one
+	// of them is not taken again while another one is in progress.
+	resetMutex  sync.Mutex
+	beforeReset func(uuid string)
+	afterReset  func(uuid string)
+
+	onScrubbed func(uuid string, err error)
+}
+
+func NewGpuScrubber(nvdevlib *deviceLib, policy GpuScrubPolicy, timeout time.Duration) *GpuScrubber {
+	return &GpuScrubber{
+		nvdevlib:     nvdevlib,
+		policy:       policy,
+		timeout:      timeout,
+		pollInterval: gpuScrubPollInterval,
+		kill: func(pid int) error {
+			return syscall.Kill(pid, syscall.SIGKILL)
+		},
+	}
+}
+
+// OnReset registers functions to be called around every GPU reset. before is
+// called before the GPU is removed from the PCI bus and must release anything
+// that this process holds open on it. after is called once the reset has
+// completed or failed.
+func (s *GpuScrubber) OnReset(before, after func(uuid string)) {
+	s.beforeReset = before
+	s.afterReset = after
+}
+
+// OnScrubbed registers a function to be called with the outcome of scrubbing
+// each device, e.g. to stop handing out devices that could not be scrubbed.
+func (s *GpuScrubber) OnScrubbed(f func(uuid string, err error)) {
+	s.onScrubbed = f
+}
+
+// Scrub scrubs the GPUs and MIG devices with the given UUIDs in parallel.
+// Processes still running on any of them are terminated and, if the policy
+// asks for it, the full GPUs listed in resettable are reset. Finally, the
+// health of each device is verified. An error is returned for every device
+// for which any of these steps failed.
+func (s *GpuScrubber) Scrub(ctx context.Context, uuids []string, resettable []string) error {
+	if s.policy == GpuScrubPolicyNone || len(uuids) == 0 {
+		return nil
+	}
+
+	if err := s.nvdevlib.Init(); err != nil {
+		return err
+	}
+	defer s.nvdevlib.alwaysShutdown()
+
+	errs := make([]error, len(uuids))
+	var wg sync.WaitGroup
+	for i, uuid := range uuids {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			reset := s.policy == GpuScrubPolicyReset && slices.Contains(resettable, uuid)
+			err := s.scrubDevice(ctx, uuid, reset)
+			if s.onScrubbed != nil {
+				s.onScrubbed(uuid, err)
+			}
+			if err != nil {
+				errs[i] = fmt.Errorf("error scrubbing device %v: %w", uuid, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	return errors.Join(errs...)
+}
+
+func (s *GpuScrubber) scrubDevice(ctx context.Context, uuid string, reset bool) error {
+	klog.V(4).Infof("Scrubbing device %v (reset: %v)", uuid, reset)
+
+	if err := s.terminateProcesses(ctx, uuid); err != nil {
+		return err
+	}
+
+	if reset {
+		if err := s.resetGpu(ctx, uuid); err != nil {
+			return fmt.Errorf("error resetting GPU: %w", err)
+		}
+	}
+
+	if err := s.verifyHealth(uuid); err != nil {
+		return fmt.Errorf("device is not healthy: %w", err)
+	}
+
+	return nil
+}
+
+// terminateProcesses waits for all processes running on a device to exit,
+// killing the ones still running once the scrub timeout has expired.
+func (s *GpuScrubber) terminateProcesses(ctx context.Context, uuid string) error {
+	pids, err := s.waitForProcesses(ctx, uuid)
+	if err != nil {
+		return err
+	}
+	if len(pids) == 0 {
+		return nil
+	}
+
+	klog.Warningf("Killing processes left behind on device %v: %v", uuid, pids)
+	for _, pid := range pids {
+		if err := s.kill(int(pid)); err != nil && !errors.Is(err, syscall.ESRCH) {
+			return fmt.Errorf("error killing process %d: %w", pid, err)
+		}
+	}
+
+	pids, err = s.waitForProcesses(ctx, uuid)
+	if err != nil {
+		return err
+	}
+	if len(pids) > 0 {
+		return fmt.Errorf("processes still running after being killed: %v", pids)
+	}
+
+	return nil
+}
+
+// waitForProcesses waits for up to the scrub timeout for all processes
+// running on a device to exit. It returns the PIDs of the processes that are
+// still running.
+func (s *GpuScrubber) waitForProcesses(ctx context.Context, uuid string) ([]uint32, error) {
+	deadline := time.Now().Add(s.timeout)
+	for {
+		pids, err := s.getProcesses(uuid)
+		if err != nil || len(pids) == 0 || time.Now().After(deadline) {
+			return pids, err
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(s.pollInterval):
+		}
+	}
+}
+
+// getProcesses returns the PIDs of all compute, graphics and MPS processes
+// running on a device.
+func (s *GpuScrubber) getProcesses(uuid string) ([]uint32, error) {
+	device, ret := s.nvdevlib.nvmllib.DeviceGetHandleByUUID(uuid)
+	if ret != nvml.SUCCESS {
+		return nil, fmt.Errorf("error getting device handle: %v", ret)
+	}
+
+	getters := []struct {
+		kind string
+		get  func() ([]nvml.ProcessInfo, nvml.Return)
+	}{
+		{"compute", device.GetComputeRunningProcesses},
+		{"graphics", device.GetGraphicsRunningProcesses},
+		{"MPS", device.GetMPSComputeRunningProcesses},
+	}
+
+	var pids []uint32
+	for _, g := range getters {
+		processes, ret := g.get()
+		if ret == nvml.ERROR_NOT_SUPPORTED {
+			continue
+		}
+		if ret != nvml.SUCCESS {
+			return nil, fmt.Errorf("error getting %s processes: %v", g.kind, ret)
+		}
+		for _, p := range processes {
+			pids = append(pids, p.Pid)
+		}
+	}
+	slices.Sort(pids)
+
+	return slices.Compact(pids), nil
+}
+
+// resetGpu resets a GPU by draining it, removing it from the PCI bus and
+// rediscovering it. This wipes its memory and all volatile state, like
+// `nvidia-smi -r`, which has no NVML equivalent. GPUs with MIG enabled are
+// not reset, since that would destroy their MIG devices.
+//
+// Handles to the GPU obtained before the reset must not be used afterwards,
+// and the GPU may come back with a different minor number. Resets are
+// therefore serialized and bracketed by the functions registered with
+// OnReset, which release and re-acquire long-lived NVML state such as event
+// registrations.
+func (s *GpuScrubber) resetGpu(ctx context.Context, uuid string) error {
+	device, ret := s.nvdevlib.nvmllib.DeviceGetHandleByUUID(uuid)
+	if ret != nvml.SUCCESS {
+		return fmt.Errorf("error getting device handle: %v", ret)
+	}
+
+	migMode, _, ret := device.GetMigMode()
+	if ret == nvml.SUCCESS && migMode == nvml.DEVICE_MIG_ENABLE {
+		klog.Infof("Not resetting GPU %v: MIG mode is enabled", uuid)
+		return nil
+	}
+
+	pciInfo, ret := device.GetPciInfo()
+	if ret != nvml.SUCCESS {
+		return fmt.Errorf("error getting PCI info: %v", ret)
+	}
+
+	s.resetMutex.Lock()
+	defer s.resetMutex.Unlock()
+
+	klog.Infof("Resetting GPU %v", uuid)
+
+	if s.beforeReset != nil {
+		s.beforeReset(uuid)
+	}
+	if s.afterReset != nil {
+		defer s.afterReset(uuid)
+	}
+
+	if ret := s.nvdevlib.nvmllib.DeviceModifyDrainState(&pciInfo, nvml.FEATURE_ENABLED); ret != nvml.SUCCESS {
+		return fmt.Errorf("error draining GPU: %v", ret)
+	}
+
+	if ret := s.nvdevlib.nvmllib.DeviceRemoveGpu_v2(&pciInfo, nvml.DETACH_GPU_REMOVE, nvml.PCIE_LINK_KEEP); ret != nvml.SUCCESS {
+		if ret := s.nvdevlib.nvmllib.DeviceModifyDrainState(&pciInfo, nvml.FEATURE_DISABLED); ret != nvml.SUCCESS {
+			klog.Warningf("Error undraining GPU %v: %v", uuid, ret)
+		}
+		if ret == nvml.ERROR_IN_USE {
+			return fmt.Errorf("error removing GPU: %v (is it held open by another NVML client, nvidia-persistenced or persistence mode?)", ret)
+		}
+		return fmt.Errorf("error removing GPU: %v", ret)
+	}
+
+	if err := s.nvdevlib.rescanPciBus(); err != nil {
+		return err
+	}
+
+	if err := s.waitForGpu(ctx, uuid); err != nil {
+		return err
+	}
+
+	if ret := s.nvdevlib.nvmllib.DeviceModifyDrainState(&pciInfo, nvml.FEATURE_DISABLED); ret != nvml.SUCCESS {
+		return fmt.Errorf("error undraining GPU: %v", ret)
+	}
+
+	return nil
+}
+
+// waitForGpu waits for up to the scrub timeout for a GPU to reappear after it
+// was removed.
+func (s *GpuScrubber) waitForGpu(ctx context.Context, uuid string) error {
+	deadline := time.Now().Add(s.timeout)
+	for {
+		_, ret := s.nvdevlib.nvmllib.DeviceGetHandleByUUID(uuid)
+		if ret == nvml.SUCCESS {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("GPU did not reappear after reset: %v", ret)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(s.pollInterval):
+		}
+	}
+}
+
+// verifyHealth checks that a device is reachable, that no processes are
+// running on it and, for full GPUs, that it has no uncorrectable memory
+// errors that require a reset.
+func (s *GpuScrubber) verifyHealth(uuid string) error {
+	pids, err := s.getProcesses(uuid)
+	if err != nil {
+		return err
+	}
+	if len(pids) > 0 {
+		return fmt.Errorf("processes still running: %v", pids)
+	}
+
+	device, ret := s.nvdevlib.nvmllib.DeviceGetHandleByUUID(uuid)
+	if ret != nvml.SUCCESS {
+		return fmt.Errorf("error getting device handle: %v", ret)
+	}
+	isMig, ret := device.IsMigDeviceHandle()
+	if ret != nvml.SUCCESS {
+		return fmt.Errorf("error checking for MIG device handle: %v", ret)
+	}
+	if isMig {
+		return nil
+	}
+
+	count, ret := device.GetTotalEccErrors(nvml.MEMORY_ERROR_TYPE_UNCORRECTED, nvml.VOLATILE_ECC)
+	if err := optionalReturn(ret); err != nil {
+		return fmt.Errorf("error getting ECC errors: %w", err)
+	}
+	if count > 0 {
+		return fmt.Errorf("%d uncorrectable ECC errors since last reset", count)
+	}
+
+	_, _, pending, failed, ret := device.GetRemappedRows()
+	if err := optionalReturn(ret); err != nil {
+		return fmt.Errorf("error getting remapped rows: %w", err)
+	}
+	if pending {
+		return fmt.Errorf("row remapping pending reset")
+	}
+	if failed {
+		return fmt.Errorf("row remapping failed")
+	}
+
+	return nil
+}
+
+// rescanPciBus asks the kernel to rediscover devices removed from the PCI bus.
+func (l deviceLib) rescanPciBus() error {
+	path := filepath.Join(l.sysfsRoot, "bus", "pci", "rescan")
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return nil
+	}
+	if err := os.WriteFile(path, []byte("1"), 0200); err != nil {
+		return fmt.Errorf("error rescanning PCI bus: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,119 @@
+/*
+ * Copyright (c) 2025, NVIDIA CORPORATION.  All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+	"github.com/stretchr/testify/require"
+
+	"github.com/NVIDIA/k8s-dra-driver-gpu/pkg/nvmlmock"
+)
+
+func TestScrub(t *testing.T) {
+	testCases := []struct {
+		name           string
+		policy         GpuScrubPolicy
+		gpu            int
+		reset          bool
+		killable       bool
+		expectedError  string
+		expectedResets int
+	}{
+		{
+			name:     "none leaves processes alone",
+			policy:   GpuScrubPolicyNone,
+			killable: true,
+		},
+		{
+			name:     "processes are killed",
+			policy:   GpuScrubPolicyKillProcesses,
+			reset:    true,
+			killable: true,
+		},
+		{
+			name:          "unkillable processes fail the scrub",
+			policy:        GpuScrubPolicyKillProcesses,
+			expectedError: "processes still running after being killed: [1000]",
+		},
+		{
+			name:           "GPU is reset",
+			policy:         GpuScrubPolicyReset,
+			reset:          true,
+			killable:       true,
+			expectedResets: 1,
+		},
+		{
+			name:     "GPU not listed as resettable is not reset",
+			policy:   GpuScrubPolicyReset,
+			killable: true,
+		},
+		{
+			name:     "MIG-enabled GPU is not reset",
+			policy:   GpuScrubPolicyReset,
+			gpu:      4,
+			reset:    true,
+			killable: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			profile, err := nvmlmock.LoadProfile("dgx-a100")
+			require.NoError(t, err)
+			server, err := nvmlmock.New(profile)
+			require.NoError(t, err)
+			nvdevlib, err := newDeviceLib(root(t.TempDir()), server)
+			require.NoError(t, err)
+
+			handle, ret := server.DeviceGetHandleByIndex(tc.gpu)
+			require.Equal(t, nvml.SUCCESS, ret)
+			device := handle.(*nvmlmock.Device)
+			uuid, ret := device.GetUUID()
+			require.Equal(t, nvml.SUCCESS, ret)
+			require.NoError(t, server.StartProcess(uuid, 1000))
+
+			scrubber := NewGpuScrubber(nvdevlib, tc.policy, 10*time.Millisecond)
+			scrubber.pollInterval = time.Millisecond
+			scrubber.kill = func(pid int) error {
+				if tc.killable {
+					server.StopProcess(uint32(pid))
+				}
+				return nil
+			}
+
+			var resettable []string
+			if tc.reset {
+				resettable = []string{uuid}
+			}
+			err = scrubber.Scrub(context.Background(), []string{uuid}, resettable)
+			if tc.expectedError != "" {
+				require.ErrorContains(t, err, tc.expectedError)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expectedResets, device.Resets())
+
+			processes, ret := device.GetComputeRunningProcesses()
+			require.Equal(t, nvml.SUCCESS, ret)
+			require.Equal(t, tc.policy == GpuScrubPolicyNone, len(processes) > 0)
+		})
+	}
+}
@@ -22,6 +22,7 @@ import (
 	"context"
 	"crypto/sha256"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
@@ -32,7 +33,7 @@ import (
 	"time"
 
 	appsv1 "k8s.io/api/apps/v1"
-	"k8s.io/apimachinery/pkg/api/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/labels"
@@ -43,14 +44,16 @@ import (
 	"k8s.io/klog/v2"
 	"k8s.io/mount-utils"
 
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
 	cdiapi "tags.cncf.io/container-device-interface/pkg/cdi"
 	cdispec "tags.cncf.io/container-device-interface/specs-go"
 
 	configapi "github.com/NVIDIA/k8s-dra-driver-gpu/api/nvidia.com/resource/v1beta1"
+	"github.com/NVIDIA/k8s-dra-driver-gpu/pkg/metrics"
 )
 
 const (
-	MpsRoot                      = DriverPluginPath + "/mps"
+	MpsRootDirName               = "mps"
 	MpsControlDaemonTemplatePath = "/templates/mps-control-daemon.tmpl.yaml"
 	MpsControlDaemonNameFmt      = "mps-control-daemon-%v" // Fill with ClaimUID
 )
@@ -79,6 +82,7 @@ type MpsControlDaemon struct {
 	logDir    string
 	devices   UUIDProvider
 	manager   *MpsManager
+	created   bool
 }
 
 type MpsControlDaemonTemplateData struct {
@@ -108,13 +112,43 @@ func (t *TimeSlicingManager) SetTimeSlice(devices UUIDProvider, config *configap
 	}
 
 	// Set the compute mode of the GPU to DEFAULT.
-	err := t.nvdevlib.setComputeMode(devices.UUIDs(), "DEFAULT")
+	err := t.nvdevlib.setComputeMode(devices.UUIDs(), nvml.COMPUTEMODE_DEFAULT)
 	if err != nil {
 		return fmt.Errorf("error setting compute mode: %w", err)
 	}
 
+	// GPUs start out with the default time slice and return to it when
+	// the claim that changed it is unprepared, so there is nothing to set.
+	if config.Interval.Int() == configapi.DefaultTimeSlice.Int() {
+		return nil
+	}
+
 	// Set the time slice based on the config provided.
 	err = t.nvdevlib.setTimeSlice(devices.UUIDs(), config.Interval.Int())
+	if errors.Is(err, nvml.ERROR_NOT_SUPPORTED) {
+		return permanentError{fmt.Errorf("time slice %v is not supported: %w", *config.Interval, err)}
+	}
+	if err != nil {
+		return fmt.Errorf("error setting time slice: %w", err)
+	}
+
+	return nil
+}
+
+// ResetTimeSlice puts GPUs back into the default compute mode and, if the
+// config applied to them when they were prepared changed it, back to the
+// default time slice.
+func (t *TimeSlicingManager) ResetTimeSlice(devices UUIDProvider, applied *configapi.TimeSlicingConfig) error {
+	err := t.nvdevlib.setComputeMode(devices.UUIDs(), nvml.COMPUTEMODE_DEFAULT)
+	if err != nil {
+		return fmt.Errorf("error setting compute mode: %w", err)
+	}
+
+	if applied == nil || applied.Interval.Int() == configapi.DefaultTimeSlice.Int() {
+		return nil
+	}
+
+	err = t.nvdevlib.setTimeSlice(devices.UUIDs(), configapi.DefaultTimeSlice.Int())
 	if err != nil {
 		return fmt.Errorf("error setting time slice: %w", err)
 	}
@@ -134,7 +168,10 @@ func NewMpsManager(config *Config, deviceLib *deviceLib, controlFilesRoot, hostD
 
 func (m *MpsManager) NewMpsControlDaemon(claimUID string, devices UUIDProvider) *MpsControlDaemon {
 	id := m.GetMpsControlDaemonID(claimUID, devices)
+	return m.newMpsControlDaemon(id, devices)
+}
 
+func (m *MpsManager) newMpsControlDaemon(id string, devices UUIDProvider) *MpsControlDaemon {
 	return &MpsControlDaemon{
 		id:        id,
 		nodeName:  m.config.flags.nodeName,
@@ -155,10 +192,63 @@ func (m *MpsManager) GetMpsControlDaemonID(claimUID string, devices UUIDProvider
 	return fmt.Sprintf("%s-%s", claimUID, hex.EncodeToString(hash[:])[:5])
 }
 
+// CleanupOrphanedControlDaemons stops all MPS control daemons on this node
+// whose ID is not in the set of known IDs. This removes both their
+// Deployments and their directories (including the tmpfs mount) under the
+// control files root.
+func (m *MpsManager) CleanupOrphanedControlDaemons(ctx context.Context, known map[string]bool) error {
+	deployments, err := m.config.clientsets.Core.AppsV1().Deployments(m.config.flags.namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to list deployments: %w", err)
+	}
+
+	orphaned := make(map[string]bool)
+	prefix := fmt.Sprintf(MpsControlDaemonNameFmt, "")
+	for _, deployment := range deployments.Items {
+		if deployment.Spec.Template.Spec.NodeName != m.config.flags.nodeName {
+			continue
+		}
+		if !strings.HasPrefix(deployment.Name, prefix) {
+			continue
+		}
+		id := strings.TrimPrefix(deployment.Name, prefix)
+		if !known[id] {
+			orphaned[id] = true
+		}
+	}
+
+	entries, err := os.ReadDir(m.controlFilesRoot)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("error reading directory '%s': %w", m.controlFilesRoot, err)
+	}
+	for _, e := range entries {
+		if e.IsDir() && !known[e.Name()] {
+			orphaned[e.Name()] = true
+		}
+	}
+
+	for id := range orphaned {
+		klog.Infof("Cleaning up orphaned MPS control daemon '%v'", id)
+
+		// Stop() is a noop without a root directory, so explicitly delete
+		// any Deployment that has lost its directory.
+		err := m.config.clientsets.Core.AppsV1().Deployments(m.config.flags.namespace).Delete(ctx, fmt.Sprintf(MpsControlDaemonNameFmt, id), metav1.DeleteOptions{})
+		if err != nil && !apierrors.IsNotFound(err) {
+			return fmt.Errorf("failed to delete deployment: %w", err)
+		}
+
+		if err := m.newMpsControlDaemon(id, nil).Stop(ctx); err != nil {
+			return fmt.Errorf("error stopping MPS control daemon '%v': %w", id, err)
+		}
+	}
+
+	return nil
+}
+
 func (m *MpsManager) IsControlDaemonStarted(ctx context.Context, id string) (bool, error) {
 	name := fmt.Sprintf(MpsControlDaemonNameFmt, id)
 	_, err := m.config.clientsets.Core.AppsV1().Deployments(m.config.flags.namespace).Get(ctx, name, metav1.GetOptions{})
-	if errors.IsNotFound(err) {
+	if apierrors.IsNotFound(err) {
 		return false, nil
 	}
 	if err != nil {
@@ -170,7 +260,7 @@ func (m *MpsManager) IsControlDaemonStarted(ctx context.Context, id string) (boo
 func (m *MpsManager) IsControlDaemonStopped(ctx context.Context, id string) (bool, error) {
 	name := fmt.Sprintf(MpsControlDaemonNameFmt, id)
 	_, err := m.config.clientsets.Core.AppsV1().Deployments(m.config.flags.namespace).Get(ctx, name, metav1.GetOptions{})
-	if errors.IsNotFound(err) {
+	if apierrors.IsNotFound(err) {
 		return true, nil
 	}
 	if err != nil {
@@ -183,18 +273,52 @@ func (m *MpsControlDaemon) GetID() string {
 	return m.id
 }
 
+// Start starts the MPS control daemon. If it has been started before, only
+// the node-local state it depends on (its directories, the tmpfs mounted on
+// its shm directory and the compute mode of its GPUs) is re-applied. This
+// makes it possible to repair a daemon whose state has drifted, e.g. after
+// a node reboot.
 func (m *MpsControlDaemon) Start(ctx context.Context, config *configapi.MpsConfig) error {
 	isStarted, err := m.manager.IsControlDaemonStarted(ctx, m.id)
 	if err != nil {
 		return fmt.Errorf("error checking if control daemon already started: %w", err)
 	}
 
+	var deployment *appsv1.Deployment
+	if !isStarted {
+		klog.Infof("Starting MPS control daemon for '%v', with settings: %+v", m.id, config)
+		deployment, err = m.renderDeployment(config)
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := m.setupControlFiles(); err != nil {
+		return err
+	}
+
+	err = m.manager.nvdevlib.setComputeMode(m.devices.GpuUUIDs(), nvml.COMPUTEMODE_EXCLUSIVE_PROCESS)
+	if err != nil {
+		return fmt.Errorf("error setting compute mode: %w", err)
+	}
+
 	if isStarted {
 		return nil
 	}
 
-	klog.Infof("Starting MPS control daemon for '%v', with settings: %+v", m.id, config)
+	_, err = m.manager.config.clientsets.Core.AppsV1().Deployments(m.namespace).Create(ctx, deployment, metav1.CreateOptions{})
+	if apierrors.IsAlreadyExists(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create deployment: %w", err)
+	}
+	m.created = true
+
+	return nil
+}
 
+func (m *MpsControlDaemon) renderDeployment(config *configapi.MpsConfig) (*appsv1.Deployment, error) {
 	deviceUUIDs := m.devices.UUIDs()
 	templateData := MpsControlDaemonTemplateData{
 		NodeName:                        m.nodeName,
@@ -217,34 +341,41 @@ func (m *MpsControlDaemon) Start(ctx context.Context, config *configapi.MpsConfi
 	if config != nil {
 		limits, err := config.DefaultPerDevicePinnedMemoryLimit.Normalize(deviceUUIDs, config.DefaultPinnedDeviceMemoryLimit)
 		if err != nil {
-			return fmt.Errorf("error transforming DefaultPerDevicePinnedMemoryLimit into string: %w", err)
+			return nil, fmt.Errorf("error transforming DefaultPerDevicePinnedMemoryLimit into string: %w", err)
 		}
 		templateData.DefaultPinnedDeviceMemoryLimits = limits
 	}
 
 	tmpl, err := template.ParseFiles(m.manager.templatePath)
 	if err != nil {
-		return fmt.Errorf("failed to parse template file: %w", err)
+		return nil, fmt.Errorf("failed to parse template file: %w", err)
 	}
 
 	var deploymentYaml bytes.Buffer
 	if err := tmpl.Execute(&deploymentYaml, templateData); err != nil {
-		return fmt.Errorf("failed to execute template: %w", err)
+		return nil, fmt.Errorf("failed to execute template: %w", err)
 	}
 
 	var unstructuredObj unstructured.Unstructured
 	err = yaml.Unmarshal(deploymentYaml.Bytes(), &unstructuredObj)
 	if err != nil {
-		return fmt.Errorf("failed to unmarshal yaml: %w", err)
+		return nil, fmt.Errorf("failed to unmarshal yaml: %w", err)
 	}
 
 	var deployment appsv1.Deployment
 	err = runtime.DefaultUnstructuredConverter.FromUnstructured(unstructuredObj.UnstructuredContent(), &deployment)
 	if err != nil {
-		return fmt.Errorf("failed to convert unstructured data to typed object: %w", err)
+		return nil, fmt.Errorf("failed to convert unstructured data to typed object: %w", err)
 	}
 
-	err = os.MkdirAll(m.shmDir, 0755)
+	return &deployment, nil
+}
+
+// setupControlFiles creates the directories shared with the MPS control
+// daemon and mounts a tmpfs on its shm directory unless one is already
+// mounted there.
+func (m *MpsControlDaemon) setupControlFiles() error {
+	err := os.MkdirAll(m.shmDir, 0755)
 	if err != nil {
 		return fmt.Errorf("error creating directory %v: %w", m.shmDir, err)
 	}
@@ -265,24 +396,19 @@ func (m *MpsControlDaemon) Start(ctx context.Context, config *configapi.MpsConfi
 	}
 
 	mounter := mount.New(mountExecutable)
-	sizeArg := fmt.Sprintf("size=%v", getDefaultShmSize())
-	mountOptions := []string{"rw", "nosuid", "nodev", "noexec", "relatime", sizeArg}
-	err = mounter.Mount("shm", m.shmDir, "tmpfs", mountOptions)
-	if err != nil {
-		return fmt.Errorf("error mounting %v as tmpfs: %w", m.shmDir, err)
-	}
-
-	err = m.manager.nvdevlib.setComputeMode(m.devices.GpuUUIDs(), "EXCLUSIVE_PROCESS")
+	notMountPoint, err := mounter.IsLikelyNotMountPoint(m.shmDir)
 	if err != nil {
-		return fmt.Errorf("error setting compute mode: %w", err)
+		return fmt.Errorf("error checking if %v is a mount point: %w", m.shmDir, err)
 	}
-
-	_, err = m.manager.config.clientsets.Core.AppsV1().Deployments(m.namespace).Create(ctx, &deployment, metav1.CreateOptions{})
-	if errors.IsAlreadyExists(err) {
+	if !notMountPoint {
 		return nil
 	}
+
+	sizeArg := fmt.Sprintf("size=%v", getDefaultShmSize())
+	mountOptions := []string{"rw", "nosuid", "nodev", "noexec", "relatime", sizeArg}
+	err = mounter.Mount("shm", m.shmDir, "tmpfs", mountOptions)
 	if err != nil {
-		return fmt.Errorf("failed to create deployment: %w", err)
+		return fmt.Errorf("error mounting %v as tmpfs: %w", m.shmDir, err)
 	}
 
 	return nil
@@ -297,9 +423,14 @@ func (m *MpsControlDaemon) AssertReady(ctx context.Context) error {
 		Cap:      10 * time.Second,
 	}
 
-	return retry.OnError(
+	// The startup time is only recorded if the daemon was started or came
+	// up while waiting for it, not for daemons that had long been running.
+	var waited bool
+	var created metav1.Time
+	err := retry.OnError(
 		backoff,
 		func(error) bool {
+			waited = true
 			return true
 		},
 		func() error {
@@ -311,6 +442,7 @@ func (m *MpsControlDaemon) AssertReady(ctx context.Context) error {
 			if err != nil {
 				return fmt.Errorf("failed to get deployment: %w", err)
 			}
+			created = deployment.CreationTimestamp
 
 			if deployment.Status.ReadyReplicas != 1 {
 				return fmt.Errorf("waiting for MPS control daemon to come online")
@@ -343,6 +475,15 @@ func (m *MpsControlDaemon) AssertReady(ctx context.Context) error {
 			return nil
 		},
 	)
+	if err != nil {
+		return err
+	}
+
+	if m.created || waited {
+		metrics.MpsControlDaemonStartupDuration.WithLabelValues(DriverName).Observe(time.Since(created.Time).Seconds())
+	}
+
+	return nil
 }
 
 func (m *MpsControlDaemon) GetCDIContainerEdits() *cdiapi.ContainerEdits {
@@ -381,7 +522,7 @@ func (m *MpsControlDaemon) Stop(ctx context.Context) error {
 	}
 
 	err = m.manager.config.clientsets.Core.AppsV1().Deployments(m.namespace).Delete(ctx, m.name, deleteOptions)
-	if err != nil && !errors.IsNotFound(err) {
+	if err != nil && !apierrors.IsNotFound(err) {
 		return fmt.Errorf("failed to delete deployment: %w", err)
 	}
 
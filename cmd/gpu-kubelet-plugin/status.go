@@ -0,0 +1,182 @@
+/*
+ * Copyright (c) 2025, NVIDIA CORPORATION.  All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/urfave/cli/v2"
+	resourceapi "k8s.io/api/resource/v1beta1"
+	"k8s.io/dynamic-resource-allocation/kubeletplugin"
+	"k8s.io/klog/v2"
+
+	"github.com/NVIDIA/k8s-dra-driver-gpu/pkg/localserver"
+)
+
+// Status is what the plugin believes about its node. It is served as JSON
+// by the local API at GET /status.
+type Status struct {
+	NodeName           string         `json:"nodeName"`
+	AllocatableDevices []DeviceStatus `json:"allocatableDevices"`
+	PreparedClaims     []ClaimStatus  `json:"preparedClaims"`
+}
+
+type DeviceStatus struct {
+	Name       string                    `json:"name"`
+	Type       string                    `json:"type"`
+	UUID       string                    `json:"uuid,omitempty"`
+	ParentUUID string                    `json:"parentUUID,omitempty"`
+	Healthy    bool                      `json:"healthy"`
+	Taints     []resourceapi.DeviceTaint `json:"taints,omitempty"`
+}
+
+type ClaimStatus struct {
+	UID          string              `json:"uid"`
+	Namespace    string              `json:"namespace,omitempty"`
+	Name         string              `json:"name,omitempty"`
+	Pods         []string            `json:"pods,omitempty"`
+	DeviceGroups []DeviceGroupStatus `json:"deviceGroups"`
+}
+
+type DeviceGroupStatus struct {
+	Devices     []PreparedDeviceStatus `json:"devices"`
+	ConfigState DeviceConfigState      `json:"configState"`
+}
+
+type PreparedDeviceStatus struct {
+	Name         string   `json:"name"`
+	Type         string   `json:"type"`
+	UUID         string   `json:"uuid"`
+	Requests     []string `json:"requests"`
+	CDIDeviceIDs []string `json:"cdiDeviceIDs"`
+}
+
+// Status returns the current status of all allocatable devices and prepared
+// claims on the node.
+func (d *driver) Status() (*Status, error) {
+	status := &Status{
+		NodeName:           d.nodeName,
+		AllocatableDevices: []DeviceStatus{},
+		PreparedClaims:     []ClaimStatus{},
+	}
+
+	allocatable := d.state.Allocatable()
+	for _, name := range slices.Sorted(maps.Keys(allocatable)) {
+		device := allocatable[name]
+		ds := DeviceStatus{
+			Name:    name,
+			Type:    device.Type(),
+			Healthy: true,
+		}
+		switch device.Type() {
+		case GpuDeviceType:
+			ds.UUID = device.Gpu.UUID
+		case MigDeviceType:
+			ds.UUID = device.Mig.UUID
+			ds.ParentUUID = device.ParentUUID()
+		}
+		if d.healthMonitor != nil {
+			ds.Taints = d.healthMonitor.GetTaints(name)
+			ds.Healthy = len(ds.Taints) == 0
+		}
+		status.AllocatableDevices = append(status.AllocatableDevices, ds)
+	}
+
+	claims, err := d.state.GetPreparedClaims()
+	if err != nil {
+		return nil, fmt.Errorf("error getting prepared claims: %w", err)
+	}
+	for _, claimUID := range slices.Sorted(maps.Keys(claims)) {
+		pc := claims[claimUID]
+		cs := ClaimStatus{
+			UID:       claimUID,
+			Namespace: pc.Namespace,
+			Name:      pc.Name,
+		}
+		for _, consumer := range pc.Status.ReservedFor {
+			if consumer.Resource == "pods" {
+				cs.Pods = append(cs.Pods, pc.Namespace+"/"+consumer.Name)
+			}
+		}
+		for _, group := range pc.PreparedDevices {
+			gs := DeviceGroupStatus{
+				ConfigState: group.ConfigState,
+			}
+			for _, device := range group.Devices {
+				ds := PreparedDeviceStatus{
+					Type: device.Type(),
+				}
+				var kd *kubeletplugin.Device
+				switch device.Type() {
+				case GpuDeviceType:
+					ds.UUID = device.Gpu.Info.UUID
+					kd = device.Gpu.Device
+				case MigDeviceType:
+					ds.UUID = device.Mig.Info.UUID
+					kd = device.Mig.Device
+				}
+				ds.Name = kd.DeviceName
+				ds.Requests = kd.Requests
+				ds.CDIDeviceIDs = kd.CDIDeviceIDs
+				gs.Devices = append(gs.Devices, ds)
+			}
+			cs.DeviceGroups = append(cs.DeviceGroups, gs)
+		}
+		status.PreparedClaims = append(status.PreparedClaims, cs)
+	}
+
+	return status, nil
+}
+
+func (d *driver) serveStatus(w http.ResponseWriter, r *http.Request) {
+	status, err := d.Status()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(status); err != nil {
+		klog.Errorf("Error writing status: %v", err)
+	}
+}
+
+// newStatusCommand returns a command that prints the status of the plugin
+// running on this node, as served by its local API.
+func newStatusCommand(flags *Flags) *cli.Command {
+	return &cli.Command{
+		Name:  "status",
+		Usage: "Print the allocatable devices and prepared claims of the plugin running on this node.",
+		Action: func(c *cli.Context) error {
+			config := &Config{flags: flags}
+			socketPath := filepath.Join(config.DriverPluginPath(), DriverPluginLocalSocketFileName)
+			status, err := localserver.Get(c.Context, socketPath, "/status")
+			if err != nil {
+				return fmt.Errorf("error getting status: %w", err)
+			}
+			_, err = os.Stdout.Write(status)
+			return err
+		},
+	}
+}
@@ -0,0 +1,189 @@
+/*
+ * Copyright (c) 2025, NVIDIA CORPORATION.  All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	nvdev "github.com/NVIDIA/go-nvlib/pkg/nvlib/device"
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+)
+
+const (
+	// PCIeRootAttribute is the standard attribute used to align devices of
+	// different drivers on the same PCIe root complex.
+	PCIeRootAttribute = "resource.kubernetes.io/pcieRoot"
+
+	DefaultSysfsRoot = "/sys"
+)
+
+// getPCIeRoot returns the PCIe root complex (e.g. "pci0000:00") that the PCI
+// device with the given bus ID is attached to, or an empty string if it
+// cannot be found in sysfs.
+func (l deviceLib) getPCIeRoot(pciBusID string) (string, error) {
+	path, err := filepath.EvalSymlinks(filepath.Join(l.sysfsRoot, "bus", "pci", "devices", pciBusID))
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("error resolving sysfs path of PCI device %v: %w", pciBusID, err)
+	}
+
+	// The resolved path looks like /sys/devices/pci0000:00/0000:00:01.0/0000:01:00.0.
+	devicesRoot, err := filepath.EvalSymlinks(filepath.Join(l.sysfsRoot, "devices"))
+	if err != nil {
+		return "", fmt.Errorf("error resolving sysfs devices root: %w", err)
+	}
+	rel, err := filepath.Rel(devicesRoot, path)
+	if err != nil {
+		return "", fmt.Errorf("error resolving PCIe root of PCI device %v: %w", pciBusID, err)
+	}
+	root, _, _ := strings.Cut(rel, string(filepath.Separator))
+	if !strings.HasPrefix(root, "pci") {
+		return "", fmt.Errorf("unexpected sysfs path of PCI device %v: %v", pciBusID, path)
+	}
+	return root, nil
+}
+
+// getNumaNode returns the NUMA node of the PCI device with the given bus ID,
+// or nil if the device is not associated with a NUMA node.
+func (l deviceLib) getNumaNode(pciBusID string) (*int, error) {
+	data, err := os.ReadFile(filepath.Join(l.sysfsRoot, "bus", "pci", "devices", pciBusID, "numa_node"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading NUMA node of PCI device %v: %w", pciBusID, err)
+	}
+	node, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		return nil, fmt.Errorf("error parsing NUMA node of PCI device %v: %w", pciBusID, err)
+	}
+	if node < 0 {
+		return nil, nil
+	}
+	return &node, nil
+}
+
+// getNvLinkPeers returns the PCI bus IDs of all GPUs that a GPU is directly
+// connected to over an active NVLink, and whether any of its active links
+// connects it to an NVSwitch.
+func (l deviceLib) getNvLinkPeers(device nvdev.Device) ([]string, bool, error) {
+	var peers []string
+	nvswitch := false
+	for link := range nvml.NVLINK_MAX_LINKS {
+		state, ret := device.GetNvLinkState(link)
+		if ret == nvml.ERROR_NOT_SUPPORTED || ret == nvml.ERROR_INVALID_ARGUMENT {
+			break
+		}
+		if ret != nvml.SUCCESS {
+			return nil, false, fmt.Errorf("error getting state of NVLink %d: %v", link, ret)
+		}
+		if state != nvml.FEATURE_ENABLED {
+			continue
+		}
+
+		deviceType, ret := device.GetNvLinkRemoteDeviceType(link)
+		if ret != nvml.SUCCESS {
+			return nil, false, fmt.Errorf("error getting remote device type of NVLink %d: %v", link, ret)
+		}
+		switch deviceType {
+		case nvml.NVLINK_DEVICE_TYPE_SWITCH:
+			nvswitch = true
+		case nvml.NVLINK_DEVICE_TYPE_GPU:
+			info, ret := device.GetNvLinkRemotePciInfo(link)
+			if ret != nvml.SUCCESS {
+				return nil, false, fmt.Errorf("error getting remote PCI info of NVLink %d: %v", link, ret)
+			}
+			peers = append(peers, pciBusIDString(info))
+		}
+	}
+	return peers, nvswitch, nil
+}
+
+// assignNvLinkIslands groups GPUs that are connected over NVLink, directly or
+// through NVSwitches, into islands. Each island is identified by the lowest
+// index of its GPUs. GPUs without any active NVLink are not part of an
+// island.
+func assignNvLinkIslands(gpus []*GpuInfo) {
+	byBusID := make(map[string]*GpuInfo)
+	parent := make(map[*GpuInfo]*GpuInfo)
+	for _, gpu := range gpus {
+		byBusID[gpu.pciBusID] = gpu
+		parent[gpu] = gpu
+	}
+
+	var find func(*GpuInfo) *GpuInfo
+	find = func(gpu *GpuInfo) *GpuInfo {
+		if parent[gpu] != gpu {
+			parent[gpu] = find(parent[gpu])
+		}
+		return parent[gpu]
+	}
+	union := func(a, b *GpuInfo) {
+		ra, rb := find(a), find(b)
+		if ra.index > rb.index {
+			ra, rb = rb, ra
+		}
+		parent[rb] = ra
+	}
+
+	// All NVSwitches on a node are part of the same fabric.
+	var switched *GpuInfo
+	for _, gpu := range gpus {
+		if gpu.nvlinkSwitch {
+			if switched != nil {
+				union(switched, gpu)
+			}
+			switched = gpu
+		}
+		for _, peer := range gpu.nvlinkPeers {
+			if p, exists := byBusID[peer]; exists {
+				union(gpu, p)
+			}
+		}
+	}
+
+	for _, gpu := range gpus {
+		if !gpu.nvlinkSwitch && len(gpu.nvlinkPeers) == 0 {
+			continue
+		}
+		island := find(gpu).index
+		gpu.nvlinkIsland = &island
+	}
+}
+
+// pciBusIDString returns the bus ID of a PCI device in the same format as
+// nvdev.Device.GetPCIBusID.
+func pciBusIDString(info nvml.PciInfo) string {
+	var bytes []byte
+	for _, b := range info.BusId {
+		if byte(b) == '\x00' {
+			break
+		}
+		bytes = append(bytes, byte(b))
+	}
+	id := strings.ToLower(string(bytes))
+	if id != "0000" {
+		id = strings.TrimPrefix(id, "0000")
+	}
+	return id
+}
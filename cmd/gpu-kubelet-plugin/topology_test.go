@@ -0,0 +1,107 @@
+/*
+ * Copyright (c) 2025, NVIDIA CORPORATION.  All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/NVIDIA/k8s-dra-driver-gpu/pkg/nvmlmock"
+)
+
+func TestTopologyAttributes(t *testing.T) {
+	profile, err := nvmlmock.ParseProfile([]byte(`
+driverVersion: 550.54.15
+cudaDriverVersion: 12040
+gpus:
+- count: 2
+  productName: NVIDIA H100 NVL
+  brand: Nvidia
+  architecture: Hopper
+  cudaComputeCapability: "9.0"
+  memory: 95830Mi
+  nvlink:
+    links: 12
+- productName: NVIDIA L4
+  brand: Nvidia
+  architecture: Ada Lovelace
+  cudaComputeCapability: "8.9"
+  memory: 23034Mi
+`))
+	require.NoError(t, err)
+	server, err := nvmlmock.New(profile)
+	require.NoError(t, err)
+	nvdevlib, err := newDeviceLib(root(t.TempDir()), server)
+	require.NoError(t, err)
+
+	// Build a fake sysfs in which the H100s share a PCIe root on NUMA node 0
+	// while the L4 hangs off another root without NUMA affinity.
+	nvdevlib.sysfsRoot = t.TempDir()
+	for busID, path := range map[string]string{
+		"0000:01:00.0": "pci0000:00/0000:00:01.0/0000:01:00.0",
+		"0000:02:00.0": "pci0000:00/0000:00:02.0/0000:02:00.0",
+		"0000:03:00.0": "pci0000:80/0000:80:01.0/0000:03:00.0",
+	} {
+		dir := filepath.Join(nvdevlib.sysfsRoot, "devices", path)
+		require.NoError(t, os.MkdirAll(dir, 0755))
+		numaNode := "0\n"
+		if busID == "0000:03:00.0" {
+			numaNode = "-1\n"
+		}
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "numa_node"), []byte(numaNode), 0644))
+		link := filepath.Join(nvdevlib.sysfsRoot, "bus", "pci", "devices", busID)
+		require.NoError(t, os.MkdirAll(filepath.Dir(link), 0755))
+		require.NoError(t, os.Symlink(dir, link))
+	}
+
+	devices, err := nvdevlib.enumerateAllPossibleDevices(&Config{flags: &Flags{}})
+	require.NoError(t, err)
+
+	gpu0 := devices["gpu-0"].GetDevice().Basic.Attributes
+	gpu1 := devices["gpu-1"].GetDevice().Basic.Attributes
+	gpu2 := devices["gpu-2"].GetDevice().Basic.Attributes
+
+	require.Equal(t, "pci0000:00", *gpu0[PCIeRootAttribute].StringValue)
+	require.Equal(t, "pci0000:00", *gpu1[PCIeRootAttribute].StringValue)
+	require.Equal(t, "pci0000:80", *gpu2[PCIeRootAttribute].StringValue)
+
+	require.Equal(t, int64(0), *gpu0["numaNode"].IntValue)
+	require.NotContains(t, gpu2, "numaNode")
+
+	require.Equal(t, int64(0), *gpu0["nvlinkIsland"].IntValue)
+	require.Equal(t, int64(0), *gpu1["nvlinkIsland"].IntValue)
+	require.NotContains(t, gpu2, "nvlinkIsland")
+}
+
+func TestNvLinkIslandsWithNvSwitch(t *testing.T) {
+	nvdevlib := newMockDeviceLib(t, "dgx-a100")
+
+	devices, err := nvdevlib.enumerateAllPossibleDevices(&Config{flags: &Flags{}})
+	require.NoError(t, err)
+
+	for name, device := range devices {
+		attributes := device.GetDevice().Basic.Attributes
+		if device.Type() == GpuDeviceType {
+			require.Equal(t, int64(0), *attributes["nvlinkIsland"].IntValue, name)
+		}
+		// Without sysfs entries for the mocked GPUs there is no topology.
+		require.NotContains(t, attributes, PCIeRootAttribute, name)
+	}
+}
@@ -0,0 +1,214 @@
+/*
+ * Copyright (c) 2025, NVIDIA CORPORATION.  All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	cdiapi "tags.cncf.io/container-device-interface/pkg/cdi"
+	cdispec "tags.cncf.io/container-device-interface/specs-go"
+)
+
+const (
+	VfioPciDriver = "vfio-pci"
+	VfioDevRoot   = "/dev/vfio"
+
+	// pciClassBridge is the PCI class code of PCI-to-PCI bridges.
+	pciClassBridge = 0x0604
+)
+
+// bindVfio binds all PCI devices in the IOMMU group of a GPU to the vfio-pci
+// driver, so that the whole group can be passed through to a virtual
+// machine. It returns the IOMMU group. Groups that hold anything but the
+// functions of the GPU itself are refused, as that would take devices away
+// from the host or from other claims.
+func (l deviceLib) bindVfio(pciBusID string) (string, error) {
+	if _, err := os.Stat(filepath.Join(l.sysfsRoot, "bus", "pci", "drivers", VfioPciDriver)); err != nil {
+		return "", fmt.Errorf("%v driver is not available: %w", VfioPciDriver, err)
+	}
+
+	group, devices, err := l.getIommuGroup(pciBusID)
+	if err != nil {
+		return "", err
+	}
+	if err := l.checkIommuGroup(pciBusID, group, devices); err != nil {
+		return "", err
+	}
+
+	for _, device := range devices {
+		driver, err := l.getPciDriver(device)
+		if err != nil {
+			return "", err
+		}
+		if driver == VfioPciDriver {
+			continue
+		}
+		if err := l.probePciDriver(device, driver, VfioPciDriver); err != nil {
+			return "", err
+		}
+	}
+
+	return group, nil
+}
+
+// unbindVfio binds all PCI devices in the IOMMU group of a GPU that are bound
+// to the vfio-pci driver back to their default drivers.
+func (l deviceLib) unbindVfio(pciBusID string) error {
+	_, devices, err := l.getIommuGroup(pciBusID)
+	if err != nil {
+		return err
+	}
+
+	for _, device := range devices {
+		driver, err := l.getPciDriver(device)
+		if err != nil {
+			return err
+		}
+		if driver != "" && driver != VfioPciDriver {
+			continue
+		}
+		if err := l.probePciDriver(device, driver, ""); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// getVfioContainerEdits returns the container edits giving access to the
+// given IOMMU groups through VFIO.
+func getVfioContainerEdits(groups []string) *cdiapi.ContainerEdits {
+	edits := &cdiapi.ContainerEdits{
+		ContainerEdits: &cdispec.ContainerEdits{
+			DeviceNodes: []*cdispec.DeviceNode{
+				{Path: filepath.Join(VfioDevRoot, "vfio")},
+			},
+		},
+	}
+	for _, group := range groups {
+		edits.DeviceNodes = append(edits.DeviceNodes, &cdispec.DeviceNode{
+			Path: filepath.Join(VfioDevRoot, group),
+		})
+	}
+	return edits
+}
+
+// getIommuGroup returns the IOMMU group of a PCI device and the bus IDs of all
+// PCI devices in that group.
+func (l deviceLib) getIommuGroup(pciBusID string) (string, []string, error) {
+	path, err := filepath.EvalSymlinks(filepath.Join(l.sysfsRoot, "bus", "pci", "devices", pciBusID, "iommu_group"))
+	if err != nil {
+		return "", nil, fmt.Errorf("error resolving IOMMU group of PCI device %v: %w", pciBusID, err)
+	}
+
+	entries, err := os.ReadDir(filepath.Join(path, "devices"))
+	if err != nil {
+		return "", nil, fmt.Errorf("error listing IOMMU group of PCI device %v: %w", pciBusID, err)
+	}
+	var devices []string
+	for _, entry := range entries {
+		devices = append(devices, entry.Name())
+	}
+
+	return filepath.Base(path), devices, nil
+}
+
+// checkIommuGroup returns a permanent error if the IOMMU group of a GPU holds
+// bridges or devices other than the functions of the GPU, i.e. devices on a
+// different slot.
+func (l deviceLib) checkIommuGroup(pciBusID string, group string, devices []string) error {
+	slot, _, _ := strings.Cut(pciBusID, ".")
+	for _, device := range devices {
+		class, err := l.getPciClass(device)
+		if err != nil {
+			return err
+		}
+		if class == pciClassBridge {
+			return permanentError{fmt.Errorf("IOMMU group %v of GPU %v holds PCI bridge %v", group, pciBusID, device)}
+		}
+		if deviceSlot, _, _ := strings.Cut(device, "."); deviceSlot != slot {
+			return permanentError{fmt.Errorf("IOMMU group %v of GPU %v holds PCI device %v, which is not a function of the GPU", group, pciBusID, device)}
+		}
+	}
+	return nil
+}
+
+// getPciClass returns the base class and subclass of a PCI device, e.g.
+// 0x0302 for a 3D controller, leaving out its programming interface.
+func (l deviceLib) getPciClass(pciBusID string) (uint32, error) {
+	data, err := os.ReadFile(filepath.Join(l.sysfsRoot, "bus", "pci", "devices", pciBusID, "class"))
+	if err != nil {
+		return 0, fmt.Errorf("error reading class of PCI device %v: %w", pciBusID, err)
+	}
+	class, err := strconv.ParseUint(strings.TrimSpace(string(data)), 0, 32)
+	if err != nil {
+		return 0, fmt.Errorf("error parsing class of PCI device %v: %w", pciBusID, err)
+	}
+	return uint32(class >> 8), nil
+}
+
+// getPciDriver returns the driver a PCI device is bound to, or an empty
+// string if it is not bound to any driver.
+func (l deviceLib) getPciDriver(pciBusID string) (string, error) {
+	path, err := os.Readlink(filepath.Join(l.sysfsRoot, "bus", "pci", "devices", pciBusID, "driver"))
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("error getting driver of PCI device %v: %w", pciBusID, err)
+	}
+	return filepath.Base(path), nil
+}
+
+// probePciDriver unbinds a PCI device from its current driver and lets the
+// kernel bind it to the given driver, or to its default driver if driver is
+// empty.
+func (l deviceLib) probePciDriver(pciBusID string, current string, driver string) error {
+	devicePath := filepath.Join(l.sysfsRoot, "bus", "pci", "devices", pciBusID)
+
+	override := driver
+	if override == "" {
+		override = "\n"
+	}
+	if err := os.WriteFile(filepath.Join(devicePath, "driver_override"), []byte(override), 0200); err != nil {
+		return fmt.Errorf("error setting driver override of PCI device %v: %w", pciBusID, err)
+	}
+
+	if current != "" {
+		if err := os.WriteFile(filepath.Join(devicePath, "driver", "unbind"), []byte(pciBusID), 0200); err != nil {
+			return fmt.Errorf("error unbinding PCI device %v from %v: %w", pciBusID, current, err)
+		}
+	}
+
+	if err := os.WriteFile(filepath.Join(l.sysfsRoot, "bus", "pci", "drivers_probe"), []byte(pciBusID), 0200); err != nil {
+		return fmt.Errorf("error probing driver of PCI device %v: %w", pciBusID, err)
+	}
+
+	bound, err := l.getPciDriver(pciBusID)
+	if err != nil {
+		return err
+	}
+	if driver != "" && bound != driver {
+		return fmt.Errorf("PCI device %v is bound to %q instead of %q", pciBusID, bound, driver)
+	}
+
+	return nil
+}
@@ -0,0 +1,92 @@
+/*
+ * Copyright (c) 2025, NVIDIA CORPORATION.  All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestVfio(t *testing.T) {
+	sysfsRoot := t.TempDir()
+	l := deviceLib{sysfsRoot: sysfsRoot}
+
+	// Build a fake sysfs in which a GPU and its audio function share IOMMU
+	// group 42. Binding does not take effect since nothing backs sysfs.
+	addPciDevice := func(busID, driver, class, group string) {
+		groupDir := filepath.Join(sysfsRoot, "kernel", "iommu_groups", group, "devices")
+		require.NoError(t, os.MkdirAll(groupDir, 0755))
+		dir := filepath.Join(sysfsRoot, "devices", "pci0000:00", busID)
+		require.NoError(t, os.MkdirAll(dir, 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "class"), []byte(class+"\n"), 0644))
+		driverDir := filepath.Join(sysfsRoot, "bus", "pci", "drivers", driver)
+		require.NoError(t, os.MkdirAll(driverDir, 0755))
+		require.NoError(t, os.Symlink(driverDir, filepath.Join(dir, "driver")))
+		require.NoError(t, os.Symlink(filepath.Join(groupDir, ".."), filepath.Join(dir, "iommu_group")))
+		require.NoError(t, os.Symlink(dir, filepath.Join(groupDir, busID)))
+		link := filepath.Join(sysfsRoot, "bus", "pci", "devices", busID)
+		require.NoError(t, os.MkdirAll(filepath.Dir(link), 0755))
+		require.NoError(t, os.Symlink(dir, link))
+	}
+	addPciDevice("0000:01:00.0", "nvidia", "0x030200", "42")
+	addPciDevice("0000:01:00.1", "snd_hda_intel", "0x040300", "42")
+
+	group, devices, err := l.getIommuGroup("0000:01:00.0")
+	require.NoError(t, err)
+	require.Equal(t, "42", group)
+	require.Equal(t, []string{"0000:01:00.0", "0000:01:00.1"}, devices)
+
+	driver, err := l.getPciDriver("0000:01:00.1")
+	require.NoError(t, err)
+	require.Equal(t, "snd_hda_intel", driver)
+
+	_, err = l.bindVfio("0000:01:00.0")
+	require.ErrorContains(t, err, "vfio-pci driver is not available")
+
+	require.NoError(t, os.MkdirAll(filepath.Join(sysfsRoot, "bus", "pci", "drivers", VfioPciDriver), 0755))
+	_, err = l.bindVfio("0000:01:00.0")
+	require.ErrorContains(t, err, `PCI device 0000:01:00.0 is bound to "nvidia" instead of "vfio-pci"`)
+	override, err := os.ReadFile(filepath.Join(sysfsRoot, "bus", "pci", "devices", "0000:01:00.0", "driver_override"))
+	require.NoError(t, err)
+	require.Equal(t, VfioPciDriver, string(override))
+
+	// Devices bound to other drivers are left alone.
+	require.NoError(t, l.unbindVfio("0000:01:00.0"))
+
+	// Groups holding a bridge or another device are refused up front.
+	addPciDevice("0000:02:00.0", "nvidia", "0x030200", "43")
+	addPciDevice("0000:00:02.0", "pcieport", "0x060400", "43")
+	_, err = l.bindVfio("0000:02:00.0")
+	require.True(t, isPermanentError(err))
+	require.ErrorContains(t, err, "IOMMU group 43 of GPU 0000:02:00.0 holds PCI bridge 0000:00:02.0")
+
+	addPciDevice("0000:03:00.0", "nvidia", "0x030200", "44")
+	addPciDevice("0000:04:00.0", "nvidia", "0x030200", "44")
+	_, err = l.bindVfio("0000:03:00.0")
+	require.True(t, isPermanentError(err))
+	require.ErrorContains(t, err, "holds PCI device 0000:04:00.0, which is not a function of the GPU")
+	override, err = os.ReadFile(filepath.Join(sysfsRoot, "bus", "pci", "devices", "0000:03:00.0", "driver_override"))
+	require.ErrorIs(t, err, os.ErrNotExist)
+
+	edits := getVfioContainerEdits([]string{group})
+	require.Len(t, edits.DeviceNodes, 2)
+	require.Equal(t, "/dev/vfio/vfio", edits.DeviceNodes[0].Path)
+	require.Equal(t, "/dev/vfio/42", edits.DeviceNodes[1].Path)
+}
@@ -0,0 +1,123 @@
+/*
+ * Copyright (c) 2025, NVIDIA CORPORATION.  All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package flags
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"path"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/urfave/cli/v2"
+
+	"k8s.io/component-base/metrics/legacyregistry"
+	"k8s.io/klog/v2"
+)
+
+type HTTPEndpointConfig struct {
+	HTTPEndpoint string
+	MetricsPath  string
+	ProfilePath  string
+}
+
+func (h *HTTPEndpointConfig) Flags() []cli.Flag {
+	flags := []cli.Flag{
+		&cli.StringFlag{
+			Category:    "HTTP server:",
+			Name:        "http-endpoint",
+			Usage:       "The TCP network `address` where the HTTP server for diagnostics, including pprof and metrics will listen (example: `:8080`). The default is the empty string, which means the server is disabled.",
+			Destination: &h.HTTPEndpoint,
+			EnvVars:     []string{"HTTP_ENDPOINT"},
+		},
+		&cli.StringFlag{
+			Category:    "HTTP server:",
+			Name:        "metrics-path",
+			Usage:       "The HTTP `path` where Prometheus metrics will be exposed, disabled if empty.",
+			Value:       "/metrics",
+			Destination: &h.MetricsPath,
+			EnvVars:     []string{"METRICS_PATH"},
+		},
+		&cli.StringFlag{
+			Category:    "HTTP server:",
+			Name:        "pprof-path",
+			Usage:       "The HTTP `path` where pprof profiling will be available, disabled if empty.",
+			Destination: &h.ProfilePath,
+			EnvVars:     []string{"PPROF_PATH"},
+		},
+	}
+
+	return flags
+}
+
+// SetupHTTPEndpoint registers the metrics and pprof handlers selected by the
+// flags with mux and serves it on the configured endpoint in the background.
+// It does nothing if no endpoint is configured.
+func (h *HTTPEndpointConfig) SetupHTTPEndpoint(mux *http.ServeMux) error {
+	if h.HTTPEndpoint == "" {
+		return nil
+	}
+
+	if h.MetricsPath != "" {
+		// To collect metrics data from the metric handler itself, we
+		// let it register itself and then collect from that registry.
+		reg := prometheus.NewRegistry()
+		gatherers := prometheus.Gatherers{
+			// Include Go runtime and process metrics:
+			// https://github.com/kubernetes/kubernetes/blob/9780d88cb6a4b5b067256ecb4abf56892093ee87/staging/src/k8s.io/component-base/metrics/legacyregistry/registry.go#L46-L49
+			legacyregistry.DefaultGatherer,
+		}
+		gatherers = append(gatherers, reg)
+
+		actualPath := path.Join("/", h.MetricsPath)
+		klog.InfoS("Starting metrics", "path", actualPath)
+		// This is similar to k8s.io/component-base/metrics HandlerWithReset
+		// except that we gather from multiple sources.
+		mux.Handle(actualPath,
+			promhttp.InstrumentMetricHandler(
+				reg,
+				promhttp.HandlerFor(gatherers, promhttp.HandlerOpts{})))
+	}
+
+	if h.ProfilePath != "" {
+		actualPath := path.Join("/", h.ProfilePath)
+		klog.InfoS("Starting profiling", "path", actualPath)
+		mux.HandleFunc(actualPath, pprof.Index)
+		mux.HandleFunc(path.Join(actualPath, "cmdline"), pprof.Cmdline)
+		mux.HandleFunc(path.Join(actualPath, "profile"), pprof.Profile)
+		mux.HandleFunc(path.Join(actualPath, "symbol"), pprof.Symbol)
+		mux.HandleFunc(path.Join(actualPath, "trace"), pprof.Trace)
+	}
+
+	listener, err := net.Listen("tcp", h.HTTPEndpoint)
+	if err != nil {
+		return fmt.Errorf("listen on HTTP endpoint: %w", err)
+	}
+
+	go func() {
+		klog.InfoS("Starting HTTP server", "endpoint", h.HTTPEndpoint)
+		err := http.Serve(listener, mux)
+		if err != nil {
+			klog.ErrorS(err, "HTTP server failed")
+			klog.FlushAndExit(klog.ExitFlushTimeout, 1)
+		}
+	}()
+
+	return nil
+}
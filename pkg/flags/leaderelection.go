@@ -0,0 +1,96 @@
+/*
+ * Copyright (c) 2025, NVIDIA CORPORATION.  All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package flags
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/urfave/cli/v2"
+)
+
+type LeaderElectionConfig struct {
+	Enabled       bool
+	LeaseName     string
+	LeaseDuration time.Duration
+	RenewDeadline time.Duration
+	RetryPeriod   time.Duration
+}
+
+func (l *LeaderElectionConfig) Flags() []cli.Flag {
+	flags := []cli.Flag{
+		&cli.BoolFlag{
+			Category:    "Leader election:",
+			Name:        "leader-elect",
+			Usage:       "Elect a leader among all replicas through a Lease in the namespace of the pod before doing any work. Replicas that are not the leader stand by with their caches in sync to take over quickly, while still serving the webhook and metrics.",
+			Value:       true,
+			Destination: &l.Enabled,
+			EnvVars:     []string{"LEADER_ELECT"},
+		},
+		&cli.StringFlag{
+			Category:    "Leader election:",
+			Name:        "leader-elect-lease-name",
+			Usage:       "The `name` of the Lease used for leader election.",
+			Value:       "compute-domain-controller",
+			Destination: &l.LeaseName,
+			EnvVars:     []string{"LEADER_ELECT_LEASE_NAME"},
+		},
+		&cli.DurationFlag{
+			Category:    "Leader election:",
+			Name:        "leader-elect-lease-duration",
+			Usage:       "How long standby replicas wait after the last renewal of the Lease before trying to take over from a leader that went away without releasing it.",
+			Value:       15 * time.Second,
+			Destination: &l.LeaseDuration,
+			EnvVars:     []string{"LEADER_ELECT_LEASE_DURATION"},
+		},
+		&cli.DurationFlag{
+			Category:    "Leader election:",
+			Name:        "leader-elect-renew-deadline",
+			Usage:       "How long the leader keeps trying to renew the Lease before giving up leadership. Must be shorter than the lease duration.",
+			Value:       10 * time.Second,
+			Destination: &l.RenewDeadline,
+			EnvVars:     []string{"LEADER_ELECT_RENEW_DEADLINE"},
+		},
+		&cli.DurationFlag{
+			Category:    "Leader election:",
+			Name:        "leader-elect-retry-period",
+			Usage:       "How long to wait between attempts to acquire or renew the Lease.",
+			Value:       2 * time.Second,
+			Destination: &l.RetryPeriod,
+			EnvVars:     []string{"LEADER_ELECT_RETRY_PERIOD"},
+		},
+	}
+
+	return flags
+}
+
+// Validate checks that the lease timings are consistent with each other.
+func (l *LeaderElectionConfig) Validate() error {
+	if !l.Enabled {
+		return nil
+	}
+	if l.LeaseName == "" {
+		return fmt.Errorf("leader election lease name must not be empty")
+	}
+	if l.RenewDeadline >= l.LeaseDuration {
+		return fmt.Errorf("leader election renew deadline (%v) must be shorter than the lease duration (%v)", l.RenewDeadline, l.LeaseDuration)
+	}
+	if l.RetryPeriod <= 0 || l.RetryPeriod >= l.RenewDeadline {
+		return fmt.Errorf("leader election retry period (%v) must be positive and shorter than the renew deadline (%v)", l.RetryPeriod, l.RenewDeadline)
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+/*
+ * Copyright (c) 2025, NVIDIA CORPORATION.  All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package flags
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+	"github.com/urfave/cli/v2"
+
+	"github.com/NVIDIA/k8s-dra-driver-gpu/pkg/nvmlmock"
+)
+
+type NvmlConfig struct {
+	MockProfile string
+}
+
+func (n *NvmlConfig) Flags() []cli.Flag {
+	flags := []cli.Flag{
+		&cli.StringFlag{
+			Category: "NVML:",
+			Name:     "nvml-mock-profile",
+			Usage: "Serve the hardware described by a mock NVML `PROFILE` instead of using the NVML library of the driver. " +
+				"Either the name of a builtin profile (" + strings.Join(nvmlmock.BuiltinProfiles(), ", ") + ") or the path to a YAML file. For testing only.",
+			Destination: &n.MockProfile,
+			EnvVars:     []string{"NVML_MOCK_PROFILE"},
+		},
+	}
+
+	return flags
+}
+
+// NewMockInterface returns the mock NVML library selected by the flags, or
+// nil if the NVML library of the driver should be used.
+func (n *NvmlConfig) NewMockInterface() (nvml.Interface, error) {
+	if n.MockProfile == "" {
+		return nil, nil
+	}
+
+	profile, err := nvmlmock.LoadProfile(n.MockProfile)
+	if err != nil {
+		return nil, fmt.Errorf("load mock NVML profile: %w", err)
+	}
+
+	server, err := nvmlmock.New(profile)
+	if err != nil {
+		return nil, fmt.Errorf("create mock NVML library: %w", err)
+	}
+
+	return server, nil
+}
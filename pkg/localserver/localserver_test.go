@@ -0,0 +1,51 @@
+/*
+ * Copyright (c) 2025, NVIDIA CORPORATION.  All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package localserver
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "local.sock")
+
+	_, err := Get(ctx, path, "/status")
+	require.ErrorContains(t, err, "error connecting to socket")
+
+	s := NewServer(path)
+	s.Handle("GET /status", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"nodeName":"node"}`)
+	}))
+	require.NoError(t, s.Start(ctx))
+	defer func() {
+		require.NoError(t, s.Stop())
+	}()
+
+	body, err := Get(ctx, path, "/status")
+	require.NoError(t, err)
+	require.JSONEq(t, `{"nodeName":"node"}`, string(body))
+
+	_, err = Get(ctx, path, "/unknown")
+	require.ErrorContains(t, err, "404 Not Found")
+}
@@ -0,0 +1,230 @@
+/*
+ * Copyright (c) 2025, NVIDIA CORPORATION.  All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package metrics
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"k8s.io/component-base/metrics"
+	"k8s.io/component-base/metrics/legacyregistry"
+)
+
+const namespace = "nvidia_dra"
+
+// Reasons reported by the prepare and unprepare error counters.
+const (
+	ErrorReasonLock         = "Lock"
+	ErrorReasonInvalidClaim = "InvalidClaim"
+	ErrorReasonCheckpoint   = "Checkpoint"
+	ErrorReasonDevice       = "Device"
+	ErrorReasonCDI          = "CDI"
+	ErrorReasonScrub        = "Scrub"
+	ErrorReasonTimeout      = "Timeout"
+	ErrorReasonCanceled     = "Canceled"
+	ErrorReasonUnknown      = "Unknown"
+)
+
+const (
+	DeviceHealthy   = "Healthy"
+	DeviceUnhealthy = "Unhealthy"
+)
+
+// Claim preparation may include creating MIG devices or waiting for an MPS
+// control daemon or a ComputeDomain to come up, so durations range from
+// milliseconds to minutes.
+var durationBuckets = metrics.ExponentialBuckets(0.005, 2, 16)
+
+var (
+	PrepareDuration = metrics.NewHistogramVec(
+		&metrics.HistogramOpts{
+			Namespace:      namespace,
+			Name:           "prepare_duration_seconds",
+			Help:           "Time taken to prepare the devices of a ResourceClaim.",
+			Buckets:        durationBuckets,
+			StabilityLevel: metrics.ALPHA,
+		},
+		[]string{"driver"},
+	)
+
+	UnprepareDuration = metrics.NewHistogramVec(
+		&metrics.HistogramOpts{
+			Namespace:      namespace,
+			Name:           "unprepare_duration_seconds",
+			Help:           "Time taken to unprepare the devices of a ResourceClaim.",
+			Buckets:        durationBuckets,
+			StabilityLevel: metrics.ALPHA,
+		},
+		[]string{"driver"},
+	)
+
+	PrepareErrors = metrics.NewCounterVec(
+		&metrics.CounterOpts{
+			Namespace:      namespace,
+			Name:           "prepare_errors_total",
+			Help:           "Number of ResourceClaims that failed to be prepared, by reason.",
+			StabilityLevel: metrics.ALPHA,
+		},
+		[]string{"driver", "reason"},
+	)
+
+	UnprepareErrors = metrics.NewCounterVec(
+		&metrics.CounterOpts{
+			Namespace:      namespace,
+			Name:           "unprepare_errors_total",
+			Help:           "Number of ResourceClaims that failed to be unprepared, by reason.",
+			StabilityLevel: metrics.ALPHA,
+		},
+		[]string{"driver", "reason"},
+	)
+
+	FlockWaitDuration = metrics.NewHistogramVec(
+		&metrics.HistogramOpts{
+			Namespace:      namespace,
+			Name:           "flock_wait_duration_seconds",
+			Help:           "Time spent waiting for the node-global prepare/unprepare lock.",
+			Buckets:        durationBuckets,
+			StabilityLevel: metrics.ALPHA,
+		},
+		[]string{"driver"},
+	)
+
+	DeviceLockWaitDuration = metrics.NewHistogramVec(
+		&metrics.HistogramOpts{
+			Namespace:      namespace,
+			Name:           "device_lock_wait_duration_seconds",
+			Help:           "Time spent waiting for the locks of the devices of a ResourceClaim.",
+			Buckets:        durationBuckets,
+			StabilityLevel: metrics.ALPHA,
+		},
+		[]string{"driver"},
+	)
+
+	PreparedClaims = metrics.NewGaugeVec(
+		&metrics.GaugeOpts{
+			Namespace:      namespace,
+			Name:           "prepared_claims",
+			Help:           "Number of ResourceClaims currently prepared on the node.",
+			StabilityLevel: metrics.ALPHA,
+		},
+		[]string{"driver"},
+	)
+
+	AllocatableDevices = metrics.NewGaugeVec(
+		&metrics.GaugeOpts{
+			Namespace:      namespace,
+			Name:           "allocatable_devices",
+			Help:           "Number of devices published by the node, by type and health.",
+			StabilityLevel: metrics.ALPHA,
+		},
+		[]string{"driver", "type", "health"},
+	)
+
+	MpsControlDaemonStartupDuration = metrics.NewHistogramVec(
+		&metrics.HistogramOpts{
+			Namespace:      namespace,
+			Name:           "mps_control_daemon_startup_duration_seconds",
+			Help:           "Time from creating an MPS control daemon until it is ready.",
+			Buckets:        durationBuckets,
+			StabilityLevel: metrics.ALPHA,
+		},
+		[]string{"driver"},
+	)
+
+	LeaderElectionLeader = metrics.NewGaugeVec(
+		&metrics.GaugeOpts{
+			Namespace:      namespace,
+			Name:           "leader_election_leader",
+			Help:           "Whether this replica holds the leader election Lease (1) or stands by (0).",
+			StabilityLevel: metrics.ALPHA,
+		},
+		[]string{"lease"},
+	)
+)
+
+var registerOnce sync.Once
+
+// Register registers all driver metrics with the legacy registry, from which
+// they are served by the HTTP endpoint.
+func Register() {
+	registerOnce.Do(func() {
+		legacyregistry.MustRegister(PrepareDuration)
+		legacyregistry.MustRegister(UnprepareDuration)
+		legacyregistry.MustRegister(PrepareErrors)
+		legacyregistry.MustRegister(UnprepareErrors)
+		legacyregistry.MustRegister(FlockWaitDuration)
+		legacyregistry.MustRegister(DeviceLockWaitDuration)
+		legacyregistry.MustRegister(PreparedClaims)
+		legacyregistry.MustRegister(AllocatableDevices)
+		legacyregistry.MustRegister(MpsControlDaemonStartupDuration)
+		legacyregistry.MustRegister(LeaderElectionLeader)
+	})
+}
+
+// ObservePrepare records the duration of preparing a claim that started at
+// start and, if err is not nil, counts the error by its reason.
+func ObservePrepare(driver string, start time.Time, err error) {
+	PrepareDuration.WithLabelValues(driver).Observe(time.Since(start).Seconds())
+	if err != nil {
+		PrepareErrors.WithLabelValues(driver, ErrorReason(err)).Inc()
+	}
+}
+
+// ObserveUnprepare records the duration of unpreparing a claim that started
+// at start and, if err is not nil, counts the error by its reason.
+func ObserveUnprepare(driver string, start time.Time, err error) {
+	UnprepareDuration.WithLabelValues(driver).Observe(time.Since(start).Seconds())
+	if err != nil {
+		UnprepareErrors.WithLabelValues(driver, ErrorReason(err)).Inc()
+	}
+}
+
+type reasonError struct {
+	reason string
+	error
+}
+
+func (e reasonError) Unwrap() error {
+	return e.error
+}
+
+// WithErrorReason annotates err with the reason it is counted under by the
+// prepare and unprepare error counters. It returns nil if err is nil.
+func WithErrorReason(reason string, err error) error {
+	if err == nil {
+		return nil
+	}
+	return reasonError{reason, err}
+}
+
+// ErrorReason returns the outermost reason err was annotated with. Errors
+// without a reason are classified as timeouts or cancellations if they were
+// caused by their context, and as unknown otherwise.
+func ErrorReason(err error) string {
+	var re reasonError
+	switch {
+	case errors.As(err, &re):
+		return re.reason
+	case errors.Is(err, context.DeadlineExceeded):
+		return ErrorReasonTimeout
+	case errors.Is(err, context.Canceled):
+		return ErrorReasonCanceled
+	}
+	return ErrorReasonUnknown
+}
@@ -0,0 +1,68 @@
+/*
+ * Copyright (c) 2025, NVIDIA CORPORATION.  All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package metrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestErrorReason(t *testing.T) {
+	testCases := []struct {
+		name     string
+		err      error
+		expected string
+	}{
+		{
+			name:     "no reason",
+			err:      errors.New("error"),
+			expected: ErrorReasonUnknown,
+		},
+		{
+			name:     "wrapped reason",
+			err:      fmt.Errorf("outer: %w", WithErrorReason(ErrorReasonCDI, errors.New("error"))),
+			expected: ErrorReasonCDI,
+		},
+		{
+			name:     "outermost reason wins",
+			err:      WithErrorReason(ErrorReasonDevice, WithErrorReason(ErrorReasonCheckpoint, errors.New("error"))),
+			expected: ErrorReasonDevice,
+		},
+		{
+			name:     "deadline exceeded",
+			err:      fmt.Errorf("error: %w", context.DeadlineExceeded),
+			expected: ErrorReasonTimeout,
+		},
+		{
+			name:     "reason takes precedence over context error",
+			err:      WithErrorReason(ErrorReasonLock, fmt.Errorf("error: %w", context.Canceled)),
+			expected: ErrorReasonLock,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.expected, ErrorReason(tc.err))
+		})
+	}
+
+	require.NoError(t, WithErrorReason(ErrorReasonCDI, nil))
+}
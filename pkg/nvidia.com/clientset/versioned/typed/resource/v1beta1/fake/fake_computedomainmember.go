@@ -0,0 +1,129 @@
+/*
+ * Copyright (c) 2023, NVIDIA CORPORATION.  All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Code generated by client-gen. DO NOT EDIT.
+
+package fake
+
+import (
+	"context"
+
+	v1beta1 "github.com/NVIDIA/k8s-dra-driver-gpu/api/nvidia.com/resource/v1beta1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	labels "k8s.io/apimachinery/pkg/labels"
+	types "k8s.io/apimachinery/pkg/types"
+	watch "k8s.io/apimachinery/pkg/watch"
+	testing "k8s.io/client-go/testing"
+)
+
+// FakeComputeDomainMembers implements ComputeDomainMemberInterface
+type FakeComputeDomainMembers struct {
+	Fake *FakeResourceV1beta1
+	ns   string
+}
+
+var computedomainmembersResource = v1beta1.SchemeGroupVersion.WithResource("computedomainmembers")
+
+var computedomainmembersKind = v1beta1.SchemeGroupVersion.WithKind("ComputeDomainMember")
+
+// Get takes name of the computeDomainMember, and returns the corresponding computeDomainMember object, and an error if there is any.
+func (c *FakeComputeDomainMembers) Get(ctx context.Context, name string, options v1.GetOptions) (result *v1beta1.ComputeDomainMember, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewGetAction(computedomainmembersResource, c.ns, name), &v1beta1.ComputeDomainMember{})
+
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*v1beta1.ComputeDomainMember), err
+}
+
+// List takes label and field selectors, and returns the list of ComputeDomainMembers that match those selectors.
+func (c *FakeComputeDomainMembers) List(ctx context.Context, opts v1.ListOptions) (result *v1beta1.ComputeDomainMemberList, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewListAction(computedomainmembersResource, computedomainmembersKind, c.ns, opts), &v1beta1.ComputeDomainMemberList{})
+
+	if obj == nil {
+		return nil, err
+	}
+
+	label, _, _ := testing.ExtractFromListOptions(opts)
+	if label == nil {
+		label = labels.Everything()
+	}
+	list := &v1beta1.ComputeDomainMemberList{ListMeta: obj.(*v1beta1.ComputeDomainMemberList).ListMeta}
+	for _, item := range obj.(*v1beta1.ComputeDomainMemberList).Items {
+		if label.Matches(labels.Set(item.Labels)) {
+			list.Items = append(list.Items, item)
+		}
+	}
+	return list, err
+}
+
+// Watch returns a watch.Interface that watches the requested computeDomainMembers.
+func (c *FakeComputeDomainMembers) Watch(ctx context.Context, opts v1.ListOptions) (watch.Interface, error) {
+	return c.Fake.
+		InvokesWatch(testing.NewWatchAction(computedomainmembersResource, c.ns, opts))
+
+}
+
+// Create takes the representation of a computeDomainMember and creates it.  Returns the server's representation of the computeDomainMember, and an error, if there is any.
+func (c *FakeComputeDomainMembers) Create(ctx context.Context, computeDomainMember *v1beta1.ComputeDomainMember, opts v1.CreateOptions) (result *v1beta1.ComputeDomainMember, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewCreateAction(computedomainmembersResource, c.ns, computeDomainMember), &v1beta1.ComputeDomainMember{})
+
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*v1beta1.ComputeDomainMember), err
+}
+
+// Update takes the representation of a computeDomainMember and updates it. Returns the server's representation of the computeDomainMember, and an error, if there is any.
+func (c *FakeComputeDomainMembers) Update(ctx context.Context, computeDomainMember *v1beta1.ComputeDomainMember, opts v1.UpdateOptions) (result *v1beta1.ComputeDomainMember, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewUpdateAction(computedomainmembersResource, c.ns, computeDomainMember), &v1beta1.ComputeDomainMember{})
+
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*v1beta1.ComputeDomainMember), err
+}
+
+// Delete takes name of the computeDomainMember and deletes it. Returns an error if one occurs.
+func (c *FakeComputeDomainMembers) Delete(ctx context.Context, name string, opts v1.DeleteOptions) error {
+	_, err := c.Fake.
+		Invokes(testing.NewDeleteActionWithOptions(computedomainmembersResource, c.ns, name, opts), &v1beta1.ComputeDomainMember{})
+
+	return err
+}
+
+// DeleteCollection deletes a collection of objects.
+func (c *FakeComputeDomainMembers) DeleteCollection(ctx context.Context, opts v1.DeleteOptions, listOpts v1.ListOptions) error {
+	action := testing.NewDeleteCollectionAction(computedomainmembersResource, c.ns, listOpts)
+
+	_, err := c.Fake.Invokes(action, &v1beta1.ComputeDomainMemberList{})
+	return err
+}
+
+// Patch applies the patch and returns the patched computeDomainMember.
+func (c *FakeComputeDomainMembers) Patch(ctx context.Context, name string, pt types.PatchType, data []byte, opts v1.PatchOptions, subresources ...string) (result *v1beta1.ComputeDomainMember, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewPatchSubresourceAction(computedomainmembersResource, c.ns, name, pt, data, subresources...), &v1beta1.ComputeDomainMember{})
+
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*v1beta1.ComputeDomainMember), err
+}
@@ -32,6 +32,10 @@ func (c *FakeResourceV1beta1) ComputeDomains(namespace string) v1beta1.ComputeDo
 	return &FakeComputeDomains{c, namespace}
 }
 
+func (c *FakeResourceV1beta1) ComputeDomainMembers(namespace string) v1beta1.ComputeDomainMemberInterface {
+	return &FakeComputeDomainMembers{c, namespace}
+}
+
 // RESTClient returns a RESTClient that is used to communicate
 // with API server by this client implementation.
 func (c *FakeResourceV1beta1) RESTClient() rest.Interface {
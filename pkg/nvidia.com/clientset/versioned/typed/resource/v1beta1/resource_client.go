@@ -29,6 +29,7 @@ import (
 type ResourceV1beta1Interface interface {
 	RESTClient() rest.Interface
 	ComputeDomainsGetter
+	ComputeDomainMembersGetter
 }
 
 // ResourceV1beta1Client is used to interact with features provided by the resource.nvidia.com group.
@@ -40,6 +41,10 @@ func (c *ResourceV1beta1Client) ComputeDomains(namespace string) ComputeDomainIn
 	return newComputeDomains(c, namespace)
 }
 
+func (c *ResourceV1beta1Client) ComputeDomainMembers(namespace string) ComputeDomainMemberInterface {
+	return newComputeDomainMembers(c, namespace)
+}
+
 // NewForConfig creates a new ResourceV1beta1Client for the given config.
 // NewForConfig is equivalent to NewForConfigAndClient(c, httpClient),
 // where httpClient was generated with rest.HTTPClientFor(c).
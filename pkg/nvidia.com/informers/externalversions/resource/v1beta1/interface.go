@@ -26,6 +26,8 @@ import (
 type Interface interface {
 	// ComputeDomains returns a ComputeDomainInformer.
 	ComputeDomains() ComputeDomainInformer
+	// ComputeDomainMembers returns a ComputeDomainMemberInformer.
+	ComputeDomainMembers() ComputeDomainMemberInformer
 }
 
 type version struct {
@@ -43,3 +45,8 @@ func New(f internalinterfaces.SharedInformerFactory, namespace string, tweakList
 func (v *version) ComputeDomains() ComputeDomainInformer {
 	return &computeDomainInformer{factory: v.factory, namespace: v.namespace, tweakListOptions: v.tweakListOptions}
 }
+
+// ComputeDomainMembers returns a ComputeDomainMemberInformer.
+func (v *version) ComputeDomainMembers() ComputeDomainMemberInformer {
+	return &computeDomainMemberInformer{factory: v.factory, namespace: v.namespace, tweakListOptions: v.tweakListOptions}
+}
@@ -25,3 +25,11 @@ type ComputeDomainListerExpansion interface{}
 // ComputeDomainNamespaceListerExpansion allows custom methods to be added to
 // ComputeDomainNamespaceLister.
 type ComputeDomainNamespaceListerExpansion interface{}
+
+// ComputeDomainMemberListerExpansion allows custom methods to be added to
+// ComputeDomainMemberLister.
+type ComputeDomainMemberListerExpansion interface{}
+
+// ComputeDomainMemberNamespaceListerExpansion allows custom methods to be added to
+// ComputeDomainMemberNamespaceLister.
+type ComputeDomainMemberNamespaceListerExpansion interface{}
@@ -0,0 +1,305 @@
+/*
+ * Copyright (c) 2025, NVIDIA CORPORATION.  All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package nvmlmock
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"sigs.k8s.io/yaml"
+)
+
+//go:embed profiles/*.yaml
+var builtinProfiles embed.FS
+
+// Profile describes the hardware exposed by a mock NVML library.
+type Profile struct {
+	DriverVersion     string       `json:"driverVersion"`
+	CudaDriverVersion int          `json:"cudaDriverVersion"`
+	Gpus              []GpuProfile `json:"gpus"`
+	Failures          Failures     `json:"failures,omitempty"`
+}
+
+// GpuProfile describes a set of identical GPUs. Optional properties that
+// are left unset are reported as not supported by the GPU. The vGPU scheduler
+// is only available if VgpuHost is set, as on a host running the vGPU
+// manager.
+type GpuProfile struct {
+	Count                 int                `json:"count,omitempty"`
+	ProductName           string             `json:"productName"`
+	Brand                 string             `json:"brand"`
+	Architecture          string             `json:"architecture"`
+	CudaComputeCapability string             `json:"cudaComputeCapability"`
+	Memory                resource.Quantity  `json:"memory"`
+	PciDeviceID           uint32             `json:"pciDeviceId,omitempty"`
+	VbiosVersion          string             `json:"vbiosVersion,omitempty"`
+	EccEnabled            bool               `json:"eccEnabled,omitempty"`
+	PersistenceMode       bool               `json:"persistenceMode,omitempty"`
+	PowerLimitWatts       uint32             `json:"powerLimitWatts,omitempty"`
+	MaxGraphicsClockMHz   uint32             `json:"maxGraphicsClockMHz,omitempty"`
+	MaxMemoryClockMHz     uint32             `json:"maxMemoryClockMHz,omitempty"`
+	VgpuHost              bool               `json:"vgpuHost,omitempty"`
+	MigEnabled            bool               `json:"migEnabled,omitempty"`
+	MigProfiles           []MigProfile       `json:"migProfiles,omitempty"`
+	MigDevices            []MigDeviceProfile `json:"migDevices,omitempty"`
+	NvLink                *NvLinkProfile     `json:"nvlink,omitempty"`
+	Fabric                *FabricProfile     `json:"fabric,omitempty"`
+	Failures              Failures           `json:"failures,omitempty"`
+}
+
+// MigProfile describes a GPU instance profile supported by a GPU together
+// with the placements at which instances of it can be created.
+type MigProfile struct {
+	ID                  int            `json:"id"`
+	SliceCount          uint32         `json:"sliceCount"`
+	InstanceCount       uint32         `json:"instanceCount"`
+	MultiprocessorCount uint32         `json:"multiprocessorCount"`
+	CopyEngineCount     uint32         `json:"copyEngineCount"`
+	DecoderCount        uint32         `json:"decoderCount"`
+	EncoderCount        uint32         `json:"encoderCount"`
+	JpegCount           uint32         `json:"jpegCount"`
+	OfaCount            uint32         `json:"ofaCount"`
+	MemorySizeMB        uint64         `json:"memorySizeMB"`
+	Placements          []MigPlacement `json:"placements"`
+}
+
+// MigPlacement describes the memory slices occupied by a GPU instance.
+type MigPlacement struct {
+	Start uint32 `json:"start"`
+	Size  uint32 `json:"size"`
+}
+
+// MigDeviceProfile describes a MIG device that exists when the mock is
+// created.
+type MigDeviceProfile struct {
+	ProfileID int    `json:"profileId"`
+	Start     uint32 `json:"start"`
+}
+
+// NvLinkProfile describes the NVLinks of a GPU. All links are active. They
+// are either all connected to NVSwitches or connected round-robin to the
+// other GPUs of the same entry in the profile.
+type NvLinkProfile struct {
+	Links    int  `json:"links"`
+	NvSwitch bool `json:"nvswitch,omitempty"`
+}
+
+// FabricProfile describes the NVLink fabric a GPU is attached to.
+type FabricProfile struct {
+	ClusterUUID string `json:"clusterUUID"`
+	CliqueID    uint32 `json:"cliqueID"`
+}
+
+// Failures maps the names of NVML functions (e.g. "SetComputeMode") to the
+// name of the error they should return (e.g. "ERROR_NO_PERMISSION").
+type Failures map[string]string
+
+var architectures = map[string]nvml.DeviceArchitecture{
+	"Kepler":       nvml.DEVICE_ARCH_KEPLER,
+	"Maxwell":      nvml.DEVICE_ARCH_MAXWELL,
+	"Pascal":       nvml.DEVICE_ARCH_PASCAL,
+	"Volta":        nvml.DEVICE_ARCH_VOLTA,
+	"Turing":       nvml.DEVICE_ARCH_TURING,
+	"Ampere":       nvml.DEVICE_ARCH_AMPERE,
+	"Ada Lovelace": nvml.DEVICE_ARCH_ADA,
+	"Hopper":       nvml.DEVICE_ARCH_HOPPER,
+	"Blackwell":    nvml.DEVICE_ARCH_BLACKWELL,
+}
+
+var brands = map[string]nvml.BrandType{
+	"Quadro":     nvml.BRAND_QUADRO,
+	"Tesla":      nvml.BRAND_TESLA,
+	"GeForce":    nvml.BRAND_GEFORCE,
+	"Titan":      nvml.BRAND_TITAN,
+	"QuadroRTX":  nvml.BRAND_QUADRO_RTX,
+	"NvidiaRTX":  nvml.BRAND_NVIDIA_RTX,
+	"Nvidia":     nvml.BRAND_NVIDIA,
+	"GeForceRTX": nvml.BRAND_GEFORCE_RTX,
+	"TitanRTX":   nvml.BRAND_TITAN_RTX,
+}
+
+// BuiltinProfiles returns the names of the profiles that ship with this
+// package.
+func BuiltinProfiles() []string {
+	entries, _ := builtinProfiles.ReadDir("profiles")
+	var names []string
+	for _, e := range entries {
+		names = append(names, strings.TrimSuffix(e.Name(), path.Ext(e.Name())))
+	}
+	return names
+}
+
+// LoadProfile loads a profile by the name of a builtin profile or from the
+// path of a YAML file.
+func LoadProfile(nameOrPath string) (*Profile, error) {
+	data, err := builtinProfiles.ReadFile("profiles/" + nameOrPath + ".yaml")
+	if err != nil {
+		data, err = os.ReadFile(nameOrPath)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("profile %q is neither a builtin profile (%s) nor a readable file: %w", nameOrPath, strings.Join(BuiltinProfiles(), ", "), err)
+	}
+	return ParseProfile(data)
+}
+
+// ParseProfile parses and validates a profile from its YAML representation.
+func ParseProfile(data []byte) (*Profile, error) {
+	var p Profile
+	if err := yaml.UnmarshalStrict(data, &p); err != nil {
+		return nil, fmt.Errorf("error parsing profile: %w", err)
+	}
+	if err := p.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid profile: %w", err)
+	}
+	return &p, nil
+}
+
+// Validate checks that a profile describes hardware that can be mocked.
+func (p *Profile) Validate() error {
+	var errs []error
+	if p.DriverVersion == "" {
+		errs = append(errs, errors.New("driverVersion must be set"))
+	}
+	if p.CudaDriverVersion <= 0 {
+		errs = append(errs, errors.New("cudaDriverVersion must be set"))
+	}
+	if len(p.Gpus) == 0 {
+		errs = append(errs, errors.New("at least one GPU must be defined"))
+	}
+	errs = append(errs, p.Failures.validate())
+	for i, gpu := range p.Gpus {
+		if err := gpu.validate(); err != nil {
+			errs = append(errs, fmt.Errorf("gpus[%d]: %w", i, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (g *GpuProfile) validate() error {
+	var errs []error
+	if g.Count < 0 {
+		errs = append(errs, errors.New("count must not be negative"))
+	}
+	if _, exists := architectures[g.Architecture]; !exists {
+		errs = append(errs, fmt.Errorf("unknown architecture %q", g.Architecture))
+	}
+	if _, exists := brands[g.Brand]; !exists {
+		errs = append(errs, fmt.Errorf("unknown brand %q", g.Brand))
+	}
+	if _, _, err := g.cudaComputeCapability(); err != nil {
+		errs = append(errs, err)
+	}
+	if g.Memory.Sign() <= 0 {
+		errs = append(errs, errors.New("memory must be positive"))
+	}
+	if g.MigEnabled && len(g.MigProfiles) == 0 {
+		errs = append(errs, errors.New("migEnabled requires migProfiles"))
+	}
+	for _, profile := range g.MigProfiles {
+		if profile.ID < 0 || profile.ID > nvml.GPU_INSTANCE_PROFILE_1_SLICE_REV2 {
+			errs = append(errs, fmt.Errorf("unsupported MIG profile ID %d", profile.ID))
+		}
+		if profile.SliceCount == 0 {
+			errs = append(errs, fmt.Errorf("MIG profile %d: sliceCount must be set", profile.ID))
+		}
+	}
+	var placed []MigPlacement
+	for _, mig := range g.MigDevices {
+		if !g.MigEnabled {
+			errs = append(errs, errors.New("migDevices requires migEnabled"))
+			break
+		}
+		placement, err := g.migPlacement(mig)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if slices.ContainsFunc(placed, placement.overlaps) {
+			errs = append(errs, fmt.Errorf("MIG device with profile %d at %d overlaps another MIG device", mig.ProfileID, mig.Start))
+		}
+		placed = append(placed, placement)
+	}
+	if g.NvLink != nil && (g.NvLink.Links <= 0 || g.NvLink.Links > nvml.NVLINK_MAX_LINKS) {
+		errs = append(errs, fmt.Errorf("nvlink.links must be between 1 and %d", nvml.NVLINK_MAX_LINKS))
+	}
+	if g.Fabric != nil && g.Fabric.ClusterUUID == "" {
+		errs = append(errs, errors.New("fabric.clusterUUID must be set"))
+	}
+	errs = append(errs, g.Failures.validate())
+	return errors.Join(errs...)
+}
+
+func (g *GpuProfile) cudaComputeCapability() (int, int, error) {
+	var major, minor int
+	if _, err := fmt.Sscanf(g.CudaComputeCapability, "%d.%d", &major, &minor); err != nil {
+		return 0, 0, fmt.Errorf("invalid cudaComputeCapability %q", g.CudaComputeCapability)
+	}
+	return major, minor, nil
+}
+
+func (g *GpuProfile) migProfile(id int) *MigProfile {
+	for i := range g.MigProfiles {
+		if g.MigProfiles[i].ID == id {
+			return &g.MigProfiles[i]
+		}
+	}
+	return nil
+}
+
+func (g *GpuProfile) migPlacement(mig MigDeviceProfile) (MigPlacement, error) {
+	profile := g.migProfile(mig.ProfileID)
+	if profile == nil {
+		return MigPlacement{}, fmt.Errorf("MIG device references unknown profile %d", mig.ProfileID)
+	}
+	for _, p := range profile.Placements {
+		if p.Start == mig.Start {
+			return p, nil
+		}
+	}
+	return MigPlacement{}, fmt.Errorf("MIG device with profile %d has no placement starting at %d", mig.ProfileID, mig.Start)
+}
+
+func (p MigPlacement) overlaps(other MigPlacement) bool {
+	return p.Start < other.Start+other.Size && other.Start < p.Start+p.Size
+}
+
+func (f Failures) validate() error {
+	var errs []error
+	for function, name := range f {
+		if _, err := parseReturn(name); err != nil {
+			errs = append(errs, fmt.Errorf("failure for %s: %w", function, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// parseReturn maps the name of an NVML return code to its value.
+func parseReturn(name string) (nvml.Return, error) {
+	for r := nvml.SUCCESS; r <= nvml.ERROR_UNKNOWN; r++ {
+		if r.String() == name {
+			return r, nil
+		}
+	}
+	return nvml.SUCCESS, fmt.Errorf("unknown NVML return code %q", name)
+}
@@ -0,0 +1,1380 @@
+/*
+ * Copyright (c) 2025, NVIDIA CORPORATION.  All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package nvmlmock
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+	"github.com/NVIDIA/go-nvml/pkg/nvml/mock"
+)
+
+// uuidNamespace seeds the deterministic UUIDs of mocked GPUs so that they
+// remain stable across restarts of a process using the same profile.
+var uuidNamespace = uuid.MustParse("5c1b6b9c-3d2f-4f0e-9a55-2d3f6f0a8e11")
+
+// Server is a mock NVML library serving the hardware described by a Profile.
+// Any NVML function not backed by the profile panics when called.
+type Server struct {
+	mock.Interface
+	mock.ExtendedInterface
+	sync.Mutex
+
+	profile   *Profile
+	devices   []*Device
+	failures  map[string]nvml.Return
+	eventSets map[*EventSet]struct{}
+	processes map[uint32]string
+}
+
+// Device is a mocked full GPU.
+type Device struct {
+	mock.Device
+
+	server   *Server
+	profile  *GpuProfile
+	index    int
+	uuid     string
+	failures map[string]nvml.Return
+
+	migMode           int
+	gpuInstances      map[uint32]*GpuInstance
+	nextGpuInstanceID uint32
+	migDevices        []*MigDevice
+
+	computeMode    nvml.ComputeMode
+	schedulerState nvml.VgpuSchedulerSetState
+	eventTypes     map[*EventSet]uint64
+
+	powerLimit        uint32
+	persistenceMode   bool
+	applicationClocks map[nvml.ClockType]uint32
+	lockedClocks      map[nvml.ClockType][2]uint32
+
+	drained  bool
+	detached bool
+	resets   int
+}
+
+// GpuInstance is a mocked MIG GPU instance.
+type GpuInstance struct {
+	mock.GpuInstance
+
+	device                *Device
+	profile               *MigProfile
+	info                  nvml.GpuInstanceInfo
+	computeInstances      map[uint32]*ComputeInstance
+	nextComputeInstanceID uint32
+}
+
+// ComputeInstance is a mocked MIG compute instance.
+type ComputeInstance struct {
+	mock.ComputeInstance
+
+	gpuInstance *GpuInstance
+	info        nvml.ComputeInstanceInfo
+	migDevice   *MigDevice
+}
+
+// MigDevice is the device handle of a mocked MIG device.
+type MigDevice struct {
+	mock.Device
+
+	computeInstance *ComputeInstance
+	index           int
+	uuid            string
+}
+
+// EventSet is a mocked NVML event set.
+type EventSet struct {
+	mock.EventSet
+
+	server *Server
+	events chan nvml.EventData
+	done   chan struct{}
+}
+
+var _ nvml.Interface = (*Server)(nil)
+var _ nvml.Device = (*Device)(nil)
+var _ nvml.Device = (*MigDevice)(nil)
+var _ nvml.GpuInstance = (*GpuInstance)(nil)
+var _ nvml.ComputeInstance = (*ComputeInstance)(nil)
+var _ nvml.EventSet = (*EventSet)(nil)
+
+// New returns a mock NVML library serving the hardware described by the
+// given profile.
+func New(profile *Profile) (*Server, error) {
+	if err := profile.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid profile: %w", err)
+	}
+
+	s := &Server{
+		profile:   profile,
+		failures:  parseFailures(profile.Failures),
+		eventSets: make(map[*EventSet]struct{}),
+		processes: make(map[uint32]string),
+	}
+	s.setMockFuncs()
+
+	for i := range profile.Gpus {
+		gpu := &profile.Gpus[i]
+		for range max(gpu.Count, 1) {
+			d := newDevice(s, gpu, len(s.devices))
+			for _, mig := range gpu.MigDevices {
+				placement, _ := gpu.migPlacement(mig)
+				gi, ret := d.createGpuInstance(gpu.migProfile(mig.ProfileID), placement)
+				if ret != nvml.SUCCESS {
+					return nil, fmt.Errorf("error creating MIG device on GPU %d: %v", d.index, ret)
+				}
+				gi.createComputeInstance(ciProfileID(gi.profile.SliceCount))
+			}
+			s.devices = append(s.devices, d)
+		}
+	}
+
+	return s, nil
+}
+
+// InjectEvent delivers an event for the GPU or MIG device with the given
+// UUID to every event set that registered for events of its type.
+func (s *Server) InjectEvent(uuid string, eventType uint64, eventData uint64) error {
+	s.Lock()
+	defer s.Unlock()
+
+	event := nvml.EventData{
+		EventType:         eventType,
+		EventData:         eventData,
+		GpuInstanceId:     0xFFFFFFFF,
+		ComputeInstanceId: 0xFFFFFFFF,
+	}
+
+	var device *Device
+	for _, d := range s.devices {
+		if d.uuid == uuid {
+			device = d
+			event.Device = d
+			break
+		}
+		if m := d.migDeviceByUUID(uuid); m != nil {
+			device = d
+			event.Device = d
+			event.GpuInstanceId = m.computeInstance.gpuInstance.info.Id
+			event.ComputeInstanceId = m.computeInstance.info.Id
+			break
+		}
+	}
+	if device == nil {
+		return fmt.Errorf("unknown device %q", uuid)
+	}
+
+	for set, types := range device.eventTypes {
+		if types&eventType == 0 {
+			continue
+		}
+		select {
+		case set.events <- event:
+		default:
+			return fmt.Errorf("event queue full for device %q", uuid)
+		}
+	}
+
+	return nil
+}
+
+// DetachGpu hides the GPU with the given UUID from NVML, as binding it to
+// another driver such as vfio-pci does. The indices of all GPUs after it shift
+// accordingly.
+func (s *Server) DetachGpu(uuid string) error {
+	return s.setDetached(uuid, true)
+}
+
+// AttachGpu makes a GPU hidden by DetachGpu visible again.
+func (s *Server) AttachGpu(uuid string) error {
+	return s.setDetached(uuid, false)
+}
+
+func (s *Server) setDetached(uuid string, detached bool) error {
+	s.Lock()
+	defer s.Unlock()
+	for _, d := range s.devices {
+		if d.uuid == uuid {
+			d.detached = detached
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown GPU %q", uuid)
+}
+
+// visibleDevices returns the GPUs that are not detached, ordered by index. It
+// must be called with the server lock held.
+func (s *Server) visibleDevices() []*Device {
+	var devices []*Device
+	for _, d := range s.devices {
+		if !d.detached {
+			devices = append(devices, d)
+		}
+	}
+	return devices
+}
+
+// StartProcess simulates a process with the given PID running on the GPU or
+// MIG device with the given UUID.
+func (s *Server) StartProcess(uuid string, pid uint32) error {
+	s.Lock()
+	defer s.Unlock()
+	if s.deviceByUUID(uuid) == nil {
+		return fmt.Errorf("unknown device %q", uuid)
+	}
+	s.processes[pid] = uuid
+	return nil
+}
+
+// StopProcess simulates the process with the given PID exiting.
+func (s *Server) StopProcess(pid uint32) {
+	s.Lock()
+	defer s.Unlock()
+	delete(s.processes, pid)
+}
+
+func (s *Server) runningProcesses(uuid string) []nvml.ProcessInfo {
+	s.Lock()
+	defer s.Unlock()
+	var processes []nvml.ProcessInfo
+	for pid, u := range s.processes {
+		if u == uuid {
+			processes = append(processes, nvml.ProcessInfo{Pid: pid})
+		}
+	}
+	return processes
+}
+
+func (s *Server) failure(function string) nvml.Return {
+	if ret, exists := s.failures[function]; exists {
+		return ret
+	}
+	return nvml.SUCCESS
+}
+
+func (s *Server) deviceByUUID(uuid string) nvml.Device {
+	for _, d := range s.visibleDevices() {
+		if d.uuid == uuid {
+			return d
+		}
+		if m := d.migDeviceByUUID(uuid); m != nil {
+			return m
+		}
+	}
+	return nil
+}
+
+func (s *Server) deviceByPciInfo(info *nvml.PciInfo) *Device {
+	for _, d := range s.visibleDevices() {
+		if d.pciInfo().BusId == info.BusId {
+			return d
+		}
+	}
+	return nil
+}
+
+func (s *Server) setMockFuncs() {
+	s.ExtensionsFunc = func() nvml.ExtendedInterface {
+		return s
+	}
+
+	s.LookupSymbolFunc = func(symbol string) error {
+		return nil
+	}
+
+	s.InitFunc = func() nvml.Return {
+		return s.failure("Init")
+	}
+
+	s.ShutdownFunc = func() nvml.Return {
+		return s.failure("Shutdown")
+	}
+
+	s.SystemGetDriverVersionFunc = func() (string, nvml.Return) {
+		return s.profile.DriverVersion, s.failure("SystemGetDriverVersion")
+	}
+
+	s.SystemGetNVMLVersionFunc = func() (string, nvml.Return) {
+		return fmt.Sprintf("%d.%s", s.profile.CudaDriverVersion/1000, s.profile.DriverVersion), s.failure("SystemGetNVMLVersion")
+	}
+
+	s.SystemGetCudaDriverVersionFunc = func() (int, nvml.Return) {
+		return s.profile.CudaDriverVersion, s.failure("SystemGetCudaDriverVersion")
+	}
+
+	s.DeviceGetCountFunc = func() (int, nvml.Return) {
+		s.Lock()
+		defer s.Unlock()
+		return len(s.visibleDevices()), s.failure("DeviceGetCount")
+	}
+
+	s.DeviceGetHandleByIndexFunc = func(index int) (nvml.Device, nvml.Return) {
+		if ret := s.failure("DeviceGetHandleByIndex"); ret != nvml.SUCCESS {
+			return nil, ret
+		}
+		s.Lock()
+		defer s.Unlock()
+		devices := s.visibleDevices()
+		if index < 0 || index >= len(devices) {
+			return nil, nvml.ERROR_INVALID_ARGUMENT
+		}
+		return devices[index], nvml.SUCCESS
+	}
+
+	s.DeviceGetHandleByUUIDFunc = func(uuid string) (nvml.Device, nvml.Return) {
+		if ret := s.failure("DeviceGetHandleByUUID"); ret != nvml.SUCCESS {
+			return nil, ret
+		}
+		s.Lock()
+		defer s.Unlock()
+		if d := s.deviceByUUID(uuid); d != nil {
+			return d, nvml.SUCCESS
+		}
+		return nil, nvml.ERROR_NOT_FOUND
+	}
+
+	s.DeviceGetHandleByPciBusIdFunc = func(busID string) (nvml.Device, nvml.Return) {
+		if ret := s.failure("DeviceGetHandleByPciBusId"); ret != nvml.SUCCESS {
+			return nil, ret
+		}
+		s.Lock()
+		defer s.Unlock()
+		for _, d := range s.visibleDevices() {
+			if busID == d.pciBusID() {
+				return d, nvml.SUCCESS
+			}
+		}
+		return nil, nvml.ERROR_NOT_FOUND
+	}
+
+	s.EventSetCreateFunc = func() (nvml.EventSet, nvml.Return) {
+		if ret := s.failure("EventSetCreate"); ret != nvml.SUCCESS {
+			return nil, ret
+		}
+		s.Lock()
+		defer s.Unlock()
+		set := newEventSet(s)
+		s.eventSets[set] = struct{}{}
+		return set, nvml.SUCCESS
+	}
+
+	s.DeviceModifyDrainStateFunc = func(info *nvml.PciInfo, state nvml.EnableState) nvml.Return {
+		if ret := s.failure("DeviceModifyDrainState"); ret != nvml.SUCCESS {
+			return ret
+		}
+		s.Lock()
+		defer s.Unlock()
+		d := s.deviceByPciInfo(info)
+		if d == nil {
+			return nvml.ERROR_NOT_FOUND
+		}
+		d.drained = state == nvml.FEATURE_ENABLED
+		return nvml.SUCCESS
+	}
+
+	s.DeviceQueryDrainStateFunc = func(info *nvml.PciInfo) (nvml.EnableState, nvml.Return) {
+		s.Lock()
+		defer s.Unlock()
+		d := s.deviceByPciInfo(info)
+		if d == nil {
+			return nvml.FEATURE_DISABLED, nvml.ERROR_NOT_FOUND
+		}
+		return enableState(d.drained), s.failure("DeviceQueryDrainState")
+	}
+
+	// Removed GPUs are rediscovered immediately, so removing a GPU amounts to
+	// resetting it.
+	s.DeviceRemoveGpu_v2Func = func(info *nvml.PciInfo, gpuState nvml.DetachGpuState, linkState nvml.PcieLinkState) nvml.Return {
+		if ret := s.failure("DeviceRemoveGpu_v2"); ret != nvml.SUCCESS {
+			return ret
+		}
+		s.Lock()
+		defer s.Unlock()
+		d := s.deviceByPciInfo(info)
+		if d == nil {
+			return nvml.ERROR_NOT_FOUND
+		}
+		if !d.drained {
+			return nvml.ERROR_IN_USE
+		}
+		// Event registrations keep the GPU open, just like processes.
+		if len(d.eventTypes) > 0 {
+			return nvml.ERROR_IN_USE
+		}
+		for _, uuid := range s.processes {
+			if uuid == d.uuid || d.migDeviceByUUID(uuid) != nil {
+				return nvml.ERROR_IN_USE
+			}
+		}
+		d.reset()
+		return nvml.SUCCESS
+	}
+}
+
+func newDevice(s *Server, profile *GpuProfile, index int) *Device {
+	d := &Device{
+		server:       s,
+		profile:      profile,
+		index:        index,
+		uuid:         "GPU-" + uuid.NewSHA1(uuidNamespace, []byte(fmt.Sprintf("%s/%d", profile.ProductName, index))).String(),
+		failures:     parseFailures(profile.Failures),
+		gpuInstances: make(map[uint32]*GpuInstance),
+		computeMode:  nvml.COMPUTEMODE_DEFAULT,
+		eventTypes:   make(map[*EventSet]uint64),
+
+		powerLimit:      profile.PowerLimitWatts * 1000,
+		persistenceMode: profile.PersistenceMode,
+		applicationClocks: map[nvml.ClockType]uint32{
+			nvml.CLOCK_GRAPHICS: profile.MaxGraphicsClockMHz,
+			nvml.CLOCK_MEM:      profile.MaxMemoryClockMHz,
+		},
+		lockedClocks: make(map[nvml.ClockType][2]uint32),
+	}
+	if profile.MigEnabled {
+		d.migMode = nvml.DEVICE_MIG_ENABLE
+	}
+	maxMigDevices := 0
+	for _, p := range profile.MigProfiles {
+		maxMigDevices = max(maxMigDevices, int(p.InstanceCount))
+	}
+	d.migDevices = make([]*MigDevice, maxMigDevices)
+	d.setMockFuncs()
+	return d
+}
+
+func (d *Device) failure(function string) nvml.Return {
+	if ret, exists := d.failures[function]; exists {
+		return ret
+	}
+	return d.server.failure(function)
+}
+
+func (d *Device) pciBusID() string {
+	return fmt.Sprintf("00000000:%02X:00.0", d.index+1)
+}
+
+func (d *Device) pciInfo() nvml.PciInfo {
+	info := nvml.PciInfo{
+		Bus:         uint32(d.index + 1),
+		PciDeviceId: d.profile.PciDeviceID,
+	}
+	for i, c := range d.pciBusID() {
+		info.BusId[i] = int8(c)
+	}
+	return info
+}
+
+// nvLink checks that a link exists and returns the GPU at its remote end, or
+// nil if it is connected to an NVSwitch.
+func (d *Device) nvLink(link int) (*Device, nvml.Return) {
+	if d.profile.NvLink == nil {
+		return nil, nvml.ERROR_NOT_SUPPORTED
+	}
+	if link < 0 || link >= d.profile.NvLink.Links {
+		return nil, nvml.ERROR_INVALID_ARGUMENT
+	}
+	if d.profile.NvLink.NvSwitch {
+		return nil, nvml.SUCCESS
+	}
+	var peers []*Device
+	for _, other := range d.server.devices {
+		if other != d && other.profile == d.profile {
+			peers = append(peers, other)
+		}
+	}
+	if len(peers) == 0 {
+		return nil, nvml.ERROR_NOT_SUPPORTED
+	}
+	return peers[link%len(peers)], nvml.SUCCESS
+}
+
+// checkClock checks that a range of clocks of the given type is supported.
+func (d *Device) checkClock(clockType nvml.ClockType, minClock, maxClock uint32) nvml.Return {
+	maxSupported, ret := d.GetMaxClockInfo(clockType)
+	if ret != nvml.SUCCESS {
+		return ret
+	}
+	if minClock == 0 || minClock > maxClock || maxClock > maxSupported {
+		return nvml.ERROR_INVALID_ARGUMENT
+	}
+	return nvml.SUCCESS
+}
+
+func (d *Device) lockClocks(function string, clockType nvml.ClockType, minClock, maxClock uint32) nvml.Return {
+	if ret := d.checkClock(clockType, minClock, maxClock); ret != nvml.SUCCESS {
+		return ret
+	}
+	if ret := d.failure(function); ret != nvml.SUCCESS {
+		return ret
+	}
+	d.server.Lock()
+	defer d.server.Unlock()
+	d.lockedClocks[clockType] = [2]uint32{minClock, maxClock}
+	return nvml.SUCCESS
+}
+
+func (d *Device) unlockClocks(function string, clockType nvml.ClockType) nvml.Return {
+	if _, ret := d.GetMaxClockInfo(clockType); ret != nvml.SUCCESS {
+		return ret
+	}
+	if ret := d.failure(function); ret != nvml.SUCCESS {
+		return ret
+	}
+	d.server.Lock()
+	defer d.server.Unlock()
+	delete(d.lockedClocks, clockType)
+	return nvml.SUCCESS
+}
+
+// LockedClocks returns the range the clocks of the given type are locked to,
+// or false if they are not locked.
+func (d *Device) LockedClocks(clockType nvml.ClockType) ([2]uint32, bool) {
+	d.server.Lock()
+	defer d.server.Unlock()
+	clocks, locked := d.lockedClocks[clockType]
+	return clocks, locked
+}
+
+// reset restores the volatile state of a GPU to its defaults. It must be
+// called with the server lock held.
+func (d *Device) reset() {
+	d.computeMode = nvml.COMPUTEMODE_DEFAULT
+	d.schedulerState = nvml.VgpuSchedulerSetState{}
+	d.powerLimit = d.profile.PowerLimitWatts * 1000
+	d.applicationClocks = map[nvml.ClockType]uint32{
+		nvml.CLOCK_GRAPHICS: d.profile.MaxGraphicsClockMHz,
+		nvml.CLOCK_MEM:      d.profile.MaxMemoryClockMHz,
+	}
+	d.lockedClocks = make(map[nvml.ClockType][2]uint32)
+	d.resets++
+}
+
+// Resets returns the number of times the GPU has been reset.
+func (d *Device) Resets() int {
+	d.server.Lock()
+	defer d.server.Unlock()
+	return d.resets
+}
+
+func (d *Device) serial() string {
+	return fmt.Sprintf("%013d", 1320000000000+d.index)
+}
+
+func (d *Device) migCapable() bool {
+	return len(d.profile.MigProfiles) > 0
+}
+
+func (d *Device) migDeviceByUUID(uuid string) *MigDevice {
+	for _, m := range d.migDevices {
+		if m != nil && m.uuid == uuid {
+			return m
+		}
+	}
+	return nil
+}
+
+func (d *Device) gpuInstanceProfileInfo(profile *MigProfile) nvml.GpuInstanceProfileInfo {
+	return nvml.GpuInstanceProfileInfo{
+		Id:                  uint32(profile.ID),
+		SliceCount:          profile.SliceCount,
+		InstanceCount:       profile.InstanceCount,
+		MultiprocessorCount: profile.MultiprocessorCount,
+		CopyEngineCount:     profile.CopyEngineCount,
+		DecoderCount:        profile.DecoderCount,
+		EncoderCount:        profile.EncoderCount,
+		JpegCount:           profile.JpegCount,
+		OfaCount:            profile.OfaCount,
+		MemorySizeMB:        profile.MemorySizeMB,
+	}
+}
+
+// createGpuInstance must be called with the server lock held.
+func (d *Device) createGpuInstance(profile *MigProfile, placement MigPlacement) (*GpuInstance, nvml.Return) {
+	if d.migMode != nvml.DEVICE_MIG_ENABLE {
+		return nil, nvml.ERROR_NOT_SUPPORTED
+	}
+	for _, gi := range d.gpuInstances {
+		existing := MigPlacement{Start: gi.info.Placement.Start, Size: gi.info.Placement.Size}
+		if existing.overlaps(placement) {
+			return nil, nvml.ERROR_INSUFFICIENT_RESOURCES
+		}
+	}
+
+	gi := &GpuInstance{
+		device:  d,
+		profile: profile,
+		info: nvml.GpuInstanceInfo{
+			Device:    d,
+			Id:        d.nextGpuInstanceID,
+			ProfileId: uint32(profile.ID),
+			Placement: nvml.GpuInstancePlacement{
+				Start: placement.Start,
+				Size:  placement.Size,
+			},
+		},
+		computeInstances: make(map[uint32]*ComputeInstance),
+	}
+	gi.setMockFuncs()
+	d.gpuInstances[gi.info.Id] = gi
+	d.nextGpuInstanceID++
+
+	return gi, nvml.SUCCESS
+}
+
+func (d *Device) setMockFuncs() {
+	d.GetIndexFunc = func() (int, nvml.Return) {
+		d.server.Lock()
+		defer d.server.Unlock()
+		return slices.Index(d.server.visibleDevices(), d), d.failure("GetIndex")
+	}
+
+	d.GetMinorNumberFunc = func() (int, nvml.Return) {
+		return d.index, d.failure("GetMinorNumber")
+	}
+
+	d.GetUUIDFunc = func() (string, nvml.Return) {
+		return d.uuid, d.failure("GetUUID")
+	}
+
+	d.GetNameFunc = func() (string, nvml.Return) {
+		return d.profile.ProductName, d.failure("GetName")
+	}
+
+	d.GetBrandFunc = func() (nvml.BrandType, nvml.Return) {
+		return brands[d.profile.Brand], d.failure("GetBrand")
+	}
+
+	d.GetArchitectureFunc = func() (nvml.DeviceArchitecture, nvml.Return) {
+		return architectures[d.profile.Architecture], d.failure("GetArchitecture")
+	}
+
+	d.GetCudaComputeCapabilityFunc = func() (int, int, nvml.Return) {
+		major, minor, _ := d.profile.cudaComputeCapability()
+		return major, minor, d.failure("GetCudaComputeCapability")
+	}
+
+	d.GetMemoryInfoFunc = func() (nvml.Memory, nvml.Return) {
+		total := uint64(d.profile.Memory.Value())
+		return nvml.Memory{Total: total, Free: total}, d.failure("GetMemoryInfo")
+	}
+
+	d.GetPciInfoFunc = func() (nvml.PciInfo, nvml.Return) {
+		return d.pciInfo(), d.failure("GetPciInfo")
+	}
+
+	d.IsMigDeviceHandleFunc = func() (bool, nvml.Return) {
+		return false, nvml.SUCCESS
+	}
+
+	d.GetMigModeFunc = func() (int, int, nvml.Return) {
+		if !d.migCapable() {
+			return 0, 0, nvml.ERROR_NOT_SUPPORTED
+		}
+		d.server.Lock()
+		defer d.server.Unlock()
+		return d.migMode, d.migMode, d.failure("GetMigMode")
+	}
+
+	d.SetMigModeFunc = func(mode int) (nvml.Return, nvml.Return) {
+		if !d.migCapable() {
+			return nvml.ERROR_NOT_SUPPORTED, nvml.ERROR_NOT_SUPPORTED
+		}
+		if ret := d.failure("SetMigMode"); ret != nvml.SUCCESS {
+			return ret, ret
+		}
+		d.server.Lock()
+		defer d.server.Unlock()
+		if len(d.gpuInstances) > 0 {
+			return nvml.ERROR_IN_USE, nvml.ERROR_IN_USE
+		}
+		d.migMode = mode
+		return nvml.SUCCESS, nvml.SUCCESS
+	}
+
+	d.GetMaxMigDeviceCountFunc = func() (int, nvml.Return) {
+		if !d.migCapable() {
+			return 0, nvml.ERROR_NOT_SUPPORTED
+		}
+		return len(d.migDevices), d.failure("GetMaxMigDeviceCount")
+	}
+
+	d.GetMigDeviceHandleByIndexFunc = func(index int) (nvml.Device, nvml.Return) {
+		if ret := d.failure("GetMigDeviceHandleByIndex"); ret != nvml.SUCCESS {
+			return nil, ret
+		}
+		d.server.Lock()
+		defer d.server.Unlock()
+		if index < 0 || index >= len(d.migDevices) {
+			return nil, nvml.ERROR_INVALID_ARGUMENT
+		}
+		if d.migDevices[index] == nil {
+			return nil, nvml.ERROR_NOT_FOUND
+		}
+		return d.migDevices[index], nvml.SUCCESS
+	}
+
+	d.GetGpuInstanceProfileInfoFunc = func(id int) (nvml.GpuInstanceProfileInfo, nvml.Return) {
+		if id < 0 || id >= nvml.GPU_INSTANCE_PROFILE_COUNT {
+			return nvml.GpuInstanceProfileInfo{}, nvml.ERROR_INVALID_ARGUMENT
+		}
+		profile := d.profile.migProfile(id)
+		if profile == nil {
+			return nvml.GpuInstanceProfileInfo{}, nvml.ERROR_NOT_SUPPORTED
+		}
+		return d.gpuInstanceProfileInfo(profile), d.failure("GetGpuInstanceProfileInfo")
+	}
+
+	d.GetGpuInstancePossiblePlacementsFunc = func(info *nvml.GpuInstanceProfileInfo) ([]nvml.GpuInstancePlacement, nvml.Return) {
+		profile := d.profile.migProfile(int(info.Id))
+		if profile == nil {
+			return nil, nvml.ERROR_INVALID_ARGUMENT
+		}
+		var placements []nvml.GpuInstancePlacement
+		for _, p := range profile.Placements {
+			placements = append(placements, nvml.GpuInstancePlacement{Start: p.Start, Size: p.Size})
+		}
+		return placements, d.failure("GetGpuInstancePossiblePlacements")
+	}
+
+	d.CreateGpuInstanceWithPlacementFunc = func(info *nvml.GpuInstanceProfileInfo, placement *nvml.GpuInstancePlacement) (nvml.GpuInstance, nvml.Return) {
+		if ret := d.failure("CreateGpuInstanceWithPlacement"); ret != nvml.SUCCESS {
+			return nil, ret
+		}
+		profile := d.profile.migProfile(int(info.Id))
+		if profile == nil {
+			return nil, nvml.ERROR_INVALID_ARGUMENT
+		}
+		p := MigPlacement{Start: placement.Start, Size: placement.Size}
+		valid := false
+		for _, candidate := range profile.Placements {
+			valid = valid || candidate == p
+		}
+		if !valid {
+			return nil, nvml.ERROR_INVALID_ARGUMENT
+		}
+		d.server.Lock()
+		defer d.server.Unlock()
+		return d.createGpuInstance(profile, p)
+	}
+
+	d.GetGpuInstancesFunc = func(info *nvml.GpuInstanceProfileInfo) ([]nvml.GpuInstance, nvml.Return) {
+		if ret := d.failure("GetGpuInstances"); ret != nvml.SUCCESS {
+			return nil, ret
+		}
+		d.server.Lock()
+		defer d.server.Unlock()
+		var gis []nvml.GpuInstance
+		for _, gi := range d.gpuInstances {
+			if gi.info.ProfileId == info.Id {
+				gis = append(gis, gi)
+			}
+		}
+		return gis, nvml.SUCCESS
+	}
+
+	d.GetGpuInstanceByIdFunc = func(id int) (nvml.GpuInstance, nvml.Return) {
+		if ret := d.failure("GetGpuInstanceById"); ret != nvml.SUCCESS {
+			return nil, ret
+		}
+		d.server.Lock()
+		defer d.server.Unlock()
+		gi, exists := d.gpuInstances[uint32(id)]
+		if !exists {
+			return nil, nvml.ERROR_NOT_FOUND
+		}
+		return gi, nvml.SUCCESS
+	}
+
+	d.GetGpuFabricInfoFunc = func() (nvml.GpuFabricInfo, nvml.Return) {
+		if d.profile.Fabric == nil {
+			return nvml.GpuFabricInfo{}, nvml.ERROR_NOT_SUPPORTED
+		}
+		clusterUUID, err := uuid.Parse(d.profile.Fabric.ClusterUUID)
+		if err != nil {
+			return nvml.GpuFabricInfo{}, nvml.ERROR_UNKNOWN
+		}
+		info := nvml.GpuFabricInfo{
+			ClusterUuid: [16]uint8(clusterUUID),
+			Status:      uint32(nvml.SUCCESS),
+			CliqueId:    d.profile.Fabric.CliqueID,
+			State:       nvml.GPU_FABRIC_STATE_COMPLETED,
+		}
+		return info, d.failure("GetGpuFabricInfo")
+	}
+
+	d.GetComputeModeFunc = func() (nvml.ComputeMode, nvml.Return) {
+		d.server.Lock()
+		defer d.server.Unlock()
+		return d.computeMode, d.failure("GetComputeMode")
+	}
+
+	d.SetComputeModeFunc = func(mode nvml.ComputeMode) nvml.Return {
+		if ret := d.failure("SetComputeMode"); ret != nvml.SUCCESS {
+			return ret
+		}
+		d.server.Lock()
+		defer d.server.Unlock()
+		d.computeMode = mode
+		return nvml.SUCCESS
+	}
+
+	d.GetVgpuSchedulerCapabilitiesFunc = func() (nvml.VgpuSchedulerCapabilities, nvml.Return) {
+		if !d.profile.VgpuHost {
+			return nvml.VgpuSchedulerCapabilities{}, nvml.ERROR_NOT_SUPPORTED
+		}
+		caps := nvml.VgpuSchedulerCapabilities{
+			SupportedSchedulers: [3]uint32{
+				nvml.VGPU_SCHEDULER_POLICY_BEST_EFFORT,
+				nvml.VGPU_SCHEDULER_POLICY_EQUAL_SHARE,
+				nvml.VGPU_SCHEDULER_POLICY_FIXED_SHARE,
+			},
+			MinTimeslice: 1000,
+			MaxTimeslice: 30000,
+		}
+		return caps, d.failure("GetVgpuSchedulerCapabilities")
+	}
+
+	d.GetVgpuSchedulerStateFunc = func() (nvml.VgpuSchedulerGetState, nvml.Return) {
+		if !d.profile.VgpuHost {
+			return nvml.VgpuSchedulerGetState{}, nvml.ERROR_NOT_SUPPORTED
+		}
+		d.server.Lock()
+		defer d.server.Unlock()
+		state := nvml.VgpuSchedulerGetState{
+			SchedulerPolicy: d.schedulerState.SchedulerPolicy,
+			ArrMode:         d.schedulerState.EnableARRMode,
+		}
+		copy(state.SchedulerParams[:], d.schedulerState.SchedulerParams[:])
+		return state, d.failure("GetVgpuSchedulerState")
+	}
+
+	d.SetVgpuSchedulerStateFunc = func(state *nvml.VgpuSchedulerSetState) nvml.Return {
+		if !d.profile.VgpuHost {
+			return nvml.ERROR_NOT_SUPPORTED
+		}
+		if ret := d.failure("SetVgpuSchedulerState"); ret != nvml.SUCCESS {
+			return ret
+		}
+		d.server.Lock()
+		defer d.server.Unlock()
+		d.schedulerState = *state
+		return nvml.SUCCESS
+	}
+
+	d.GetSupportedEventTypesFunc = func() (uint64, nvml.Return) {
+		return nvml.EventTypeXidCriticalError | nvml.EventTypeDoubleBitEccError | nvml.EventTypeSingleBitEccError, d.failure("GetSupportedEventTypes")
+	}
+
+	d.RegisterEventsFunc = func(eventTypes uint64, set nvml.EventSet) nvml.Return {
+		if ret := d.failure("RegisterEvents"); ret != nvml.SUCCESS {
+			return ret
+		}
+		s, ok := set.(*EventSet)
+		if !ok {
+			return nvml.ERROR_INVALID_ARGUMENT
+		}
+		d.server.Lock()
+		defer d.server.Unlock()
+		d.eventTypes[s] |= eventTypes
+		return nvml.SUCCESS
+	}
+
+	d.GetCurrentClocksEventReasonsFunc = func() (uint64, nvml.Return) {
+		return 0, d.failure("GetCurrentClocksEventReasons")
+	}
+
+	d.GetTotalEccErrorsFunc = func(nvml.MemoryErrorType, nvml.EccCounterType) (uint64, nvml.Return) {
+		return 0, d.failure("GetTotalEccErrors")
+	}
+
+	d.GetRemappedRowsFunc = func() (int, int, bool, bool, nvml.Return) {
+		return 0, 0, false, false, d.failure("GetRemappedRows")
+	}
+
+	d.GetComputeRunningProcessesFunc = func() ([]nvml.ProcessInfo, nvml.Return) {
+		return d.server.runningProcesses(d.uuid), d.failure("GetComputeRunningProcesses")
+	}
+
+	d.GetGraphicsRunningProcessesFunc = func() ([]nvml.ProcessInfo, nvml.Return) {
+		return nil, d.failure("GetGraphicsRunningProcesses")
+	}
+
+	d.GetMPSComputeRunningProcessesFunc = func() ([]nvml.ProcessInfo, nvml.Return) {
+		return nil, d.failure("GetMPSComputeRunningProcesses")
+	}
+
+	d.GetSerialFunc = func() (string, nvml.Return) {
+		return d.serial(), d.failure("GetSerial")
+	}
+
+	d.GetVbiosVersionFunc = func() (string, nvml.Return) {
+		if d.profile.VbiosVersion == "" {
+			return "", nvml.ERROR_NOT_SUPPORTED
+		}
+		return d.profile.VbiosVersion, d.failure("GetVbiosVersion")
+	}
+
+	d.GetEccModeFunc = func() (nvml.EnableState, nvml.EnableState, nvml.Return) {
+		mode := enableState(d.profile.EccEnabled)
+		return mode, mode, d.failure("GetEccMode")
+	}
+
+	d.GetPersistenceModeFunc = func() (nvml.EnableState, nvml.Return) {
+		d.server.Lock()
+		defer d.server.Unlock()
+		return enableState(d.persistenceMode), d.failure("GetPersistenceMode")
+	}
+
+	d.SetPersistenceModeFunc = func(mode nvml.EnableState) nvml.Return {
+		if ret := d.failure("SetPersistenceMode"); ret != nvml.SUCCESS {
+			return ret
+		}
+		d.server.Lock()
+		defer d.server.Unlock()
+		d.persistenceMode = mode == nvml.FEATURE_ENABLED
+		return nvml.SUCCESS
+	}
+
+	d.GetPowerManagementLimitFunc = func() (uint32, nvml.Return) {
+		if d.profile.PowerLimitWatts == 0 {
+			return 0, nvml.ERROR_NOT_SUPPORTED
+		}
+		d.server.Lock()
+		defer d.server.Unlock()
+		return d.powerLimit, d.failure("GetPowerManagementLimit")
+	}
+
+	d.GetPowerManagementDefaultLimitFunc = func() (uint32, nvml.Return) {
+		if d.profile.PowerLimitWatts == 0 {
+			return 0, nvml.ERROR_NOT_SUPPORTED
+		}
+		return d.profile.PowerLimitWatts * 1000, d.failure("GetPowerManagementDefaultLimit")
+	}
+
+	// The power limit can be lowered to half of the default limit.
+	d.GetPowerManagementLimitConstraintsFunc = func() (uint32, uint32, nvml.Return) {
+		if d.profile.PowerLimitWatts == 0 {
+			return 0, 0, nvml.ERROR_NOT_SUPPORTED
+		}
+		return d.profile.PowerLimitWatts * 500, d.profile.PowerLimitWatts * 1000, d.failure("GetPowerManagementLimitConstraints")
+	}
+
+	d.SetPowerManagementLimitFunc = func(limit uint32) nvml.Return {
+		minLimit, maxLimit, ret := d.GetPowerManagementLimitConstraints()
+		if ret != nvml.SUCCESS {
+			return ret
+		}
+		if limit < minLimit || limit > maxLimit {
+			return nvml.ERROR_INVALID_ARGUMENT
+		}
+		if ret := d.failure("SetPowerManagementLimit"); ret != nvml.SUCCESS {
+			return ret
+		}
+		d.server.Lock()
+		defer d.server.Unlock()
+		d.powerLimit = limit
+		return nvml.SUCCESS
+	}
+
+	d.GetApplicationsClockFunc = func(clockType nvml.ClockType) (uint32, nvml.Return) {
+		if _, ret := d.GetMaxClockInfo(clockType); ret != nvml.SUCCESS {
+			return 0, ret
+		}
+		d.server.Lock()
+		defer d.server.Unlock()
+		return d.applicationClocks[clockType], d.failure("GetApplicationsClock")
+	}
+
+	d.SetApplicationsClocksFunc = func(memoryClock uint32, graphicsClock uint32) nvml.Return {
+		if ret := d.checkClock(nvml.CLOCK_MEM, memoryClock, memoryClock); ret != nvml.SUCCESS {
+			return ret
+		}
+		if ret := d.checkClock(nvml.CLOCK_GRAPHICS, graphicsClock, graphicsClock); ret != nvml.SUCCESS {
+			return ret
+		}
+		if ret := d.failure("SetApplicationsClocks"); ret != nvml.SUCCESS {
+			return ret
+		}
+		d.server.Lock()
+		defer d.server.Unlock()
+		d.applicationClocks[nvml.CLOCK_MEM] = memoryClock
+		d.applicationClocks[nvml.CLOCK_GRAPHICS] = graphicsClock
+		return nvml.SUCCESS
+	}
+
+	d.SetGpuLockedClocksFunc = func(minClock uint32, maxClock uint32) nvml.Return {
+		return d.lockClocks("SetGpuLockedClocks", nvml.CLOCK_GRAPHICS, minClock, maxClock)
+	}
+
+	d.ResetGpuLockedClocksFunc = func() nvml.Return {
+		return d.unlockClocks("ResetGpuLockedClocks", nvml.CLOCK_GRAPHICS)
+	}
+
+	d.SetMemoryLockedClocksFunc = func(minClock uint32, maxClock uint32) nvml.Return {
+		return d.lockClocks("SetMemoryLockedClocks", nvml.CLOCK_MEM, minClock, maxClock)
+	}
+
+	d.ResetMemoryLockedClocksFunc = func() nvml.Return {
+		return d.unlockClocks("ResetMemoryLockedClocks", nvml.CLOCK_MEM)
+	}
+
+	d.GetNvLinkStateFunc = func(link int) (nvml.EnableState, nvml.Return) {
+		if _, ret := d.nvLink(link); ret != nvml.SUCCESS {
+			return nvml.FEATURE_DISABLED, ret
+		}
+		return nvml.FEATURE_ENABLED, d.failure("GetNvLinkState")
+	}
+
+	d.GetNvLinkRemoteDeviceTypeFunc = func(link int) (nvml.IntNvLinkDeviceType, nvml.Return) {
+		peer, ret := d.nvLink(link)
+		if ret != nvml.SUCCESS {
+			return nvml.NVLINK_DEVICE_TYPE_UNKNOWN, ret
+		}
+		if peer == nil {
+			return nvml.NVLINK_DEVICE_TYPE_SWITCH, d.failure("GetNvLinkRemoteDeviceType")
+		}
+		return nvml.NVLINK_DEVICE_TYPE_GPU, d.failure("GetNvLinkRemoteDeviceType")
+	}
+
+	d.GetNvLinkRemotePciInfoFunc = func(link int) (nvml.PciInfo, nvml.Return) {
+		peer, ret := d.nvLink(link)
+		if ret != nvml.SUCCESS {
+			return nvml.PciInfo{}, ret
+		}
+		if peer == nil {
+			return nvml.PciInfo{}, nvml.ERROR_NOT_SUPPORTED
+		}
+		return peer.pciInfo(), d.failure("GetNvLinkRemotePciInfo")
+	}
+
+	d.GetMaxClockInfoFunc = func(clockType nvml.ClockType) (uint32, nvml.Return) {
+		var clock uint32
+		switch clockType {
+		case nvml.CLOCK_GRAPHICS:
+			clock = d.profile.MaxGraphicsClockMHz
+		case nvml.CLOCK_MEM:
+			clock = d.profile.MaxMemoryClockMHz
+		}
+		if clock == 0 {
+			return 0, nvml.ERROR_NOT_SUPPORTED
+		}
+		return clock, d.failure("GetMaxClockInfo")
+	}
+}
+
+func enableState(enabled bool) nvml.EnableState {
+	if enabled {
+		return nvml.FEATURE_ENABLED
+	}
+	return nvml.FEATURE_DISABLED
+}
+
+func (gi *GpuInstance) setMockFuncs() {
+	gi.GetInfoFunc = func() (nvml.GpuInstanceInfo, nvml.Return) {
+		return gi.info, gi.device.failure("GpuInstanceGetInfo")
+	}
+
+	gi.GetComputeInstanceProfileInfoFunc = func(id int, engineID int) (nvml.ComputeInstanceProfileInfo, nvml.Return) {
+		if id < 0 || id >= nvml.COMPUTE_INSTANCE_PROFILE_COUNT || engineID < 0 || engineID >= nvml.COMPUTE_INSTANCE_ENGINE_PROFILE_COUNT {
+			return nvml.ComputeInstanceProfileInfo{}, nvml.ERROR_INVALID_ARGUMENT
+		}
+		// Only compute instances spanning the whole GPU instance are
+		// supported by the mock.
+		if id != ciProfileID(gi.profile.SliceCount) {
+			return nvml.ComputeInstanceProfileInfo{}, nvml.ERROR_NOT_SUPPORTED
+		}
+		info := nvml.ComputeInstanceProfileInfo{
+			Id:                    uint32(id),
+			SliceCount:            gi.profile.SliceCount,
+			InstanceCount:         1,
+			MultiprocessorCount:   gi.profile.MultiprocessorCount,
+			SharedCopyEngineCount: gi.profile.CopyEngineCount,
+			SharedDecoderCount:    gi.profile.DecoderCount,
+			SharedEncoderCount:    gi.profile.EncoderCount,
+			SharedJpegCount:       gi.profile.JpegCount,
+			SharedOfaCount:        gi.profile.OfaCount,
+		}
+		return info, gi.device.failure("GetComputeInstanceProfileInfo")
+	}
+
+	gi.CreateComputeInstanceFunc = func(info *nvml.ComputeInstanceProfileInfo) (nvml.ComputeInstance, nvml.Return) {
+		if ret := gi.device.failure("CreateComputeInstance"); ret != nvml.SUCCESS {
+			return nil, ret
+		}
+		gi.device.server.Lock()
+		defer gi.device.server.Unlock()
+		return gi.createComputeInstance(int(info.Id))
+	}
+
+	gi.GetComputeInstancesFunc = func(info *nvml.ComputeInstanceProfileInfo) ([]nvml.ComputeInstance, nvml.Return) {
+		gi.device.server.Lock()
+		defer gi.device.server.Unlock()
+		var cis []nvml.ComputeInstance
+		for _, ci := range gi.computeInstances {
+			if ci.info.ProfileId == info.Id {
+				cis = append(cis, ci)
+			}
+		}
+		return cis, gi.device.failure("GetComputeInstances")
+	}
+
+	gi.GetComputeInstanceByIdFunc = func(id int) (nvml.ComputeInstance, nvml.Return) {
+		gi.device.server.Lock()
+		defer gi.device.server.Unlock()
+		ci, exists := gi.computeInstances[uint32(id)]
+		if !exists {
+			return nil, nvml.ERROR_NOT_FOUND
+		}
+		return ci, gi.device.failure("GetComputeInstanceById")
+	}
+
+	gi.DestroyFunc = func() nvml.Return {
+		if ret := gi.device.failure("GpuInstanceDestroy"); ret != nvml.SUCCESS {
+			return ret
+		}
+		gi.device.server.Lock()
+		defer gi.device.server.Unlock()
+		if len(gi.computeInstances) > 0 {
+			return nvml.ERROR_IN_USE
+		}
+		if _, exists := gi.device.gpuInstances[gi.info.Id]; !exists {
+			return nvml.ERROR_NOT_FOUND
+		}
+		delete(gi.device.gpuInstances, gi.info.Id)
+		return nvml.SUCCESS
+	}
+}
+
+// createComputeInstance must be called with the server lock held.
+func (gi *GpuInstance) createComputeInstance(profileID int) (*ComputeInstance, nvml.Return) {
+	if profileID != ciProfileID(gi.profile.SliceCount) {
+		return nil, nvml.ERROR_NOT_SUPPORTED
+	}
+	if len(gi.computeInstances) > 0 {
+		return nil, nvml.ERROR_INSUFFICIENT_RESOURCES
+	}
+
+	d := gi.device
+	index := -1
+	for i, m := range d.migDevices {
+		if m == nil {
+			index = i
+			break
+		}
+	}
+	if index < 0 {
+		return nil, nvml.ERROR_INSUFFICIENT_RESOURCES
+	}
+
+	ci := &ComputeInstance{
+		gpuInstance: gi,
+		info: nvml.ComputeInstanceInfo{
+			Device:      d,
+			GpuInstance: gi,
+			Id:          gi.nextComputeInstanceID,
+			ProfileId:   uint32(profileID),
+			Placement: nvml.ComputeInstancePlacement{
+				Start: 0,
+				Size:  gi.profile.SliceCount,
+			},
+		},
+	}
+	ci.setMockFuncs()
+
+	m := &MigDevice{
+		computeInstance: ci,
+		index:           index,
+		uuid:            "MIG-" + uuid.New().String(),
+	}
+	m.setMockFuncs()
+
+	ci.migDevice = m
+	gi.computeInstances[ci.info.Id] = ci
+	gi.nextComputeInstanceID++
+	d.migDevices[index] = m
+
+	return ci, nvml.SUCCESS
+}
+
+func (ci *ComputeInstance) setMockFuncs() {
+	d := ci.gpuInstance.device
+
+	ci.GetInfoFunc = func() (nvml.ComputeInstanceInfo, nvml.Return) {
+		return ci.info, d.failure("ComputeInstanceGetInfo")
+	}
+
+	ci.DestroyFunc = func() nvml.Return {
+		if ret := d.failure("ComputeInstanceDestroy"); ret != nvml.SUCCESS {
+			return ret
+		}
+		d.server.Lock()
+		defer d.server.Unlock()
+		if _, exists := ci.gpuInstance.computeInstances[ci.info.Id]; !exists {
+			return nvml.ERROR_NOT_FOUND
+		}
+		delete(ci.gpuInstance.computeInstances, ci.info.Id)
+		d.migDevices[ci.migDevice.index] = nil
+		return nvml.SUCCESS
+	}
+}
+
+func (m *MigDevice) setMockFuncs() {
+	gi := m.computeInstance.gpuInstance
+	parent := gi.device
+
+	m.GetUUIDFunc = func() (string, nvml.Return) {
+		return m.uuid, parent.failure("GetUUID")
+	}
+
+	m.GetIndexFunc = func() (int, nvml.Return) {
+		return m.index, parent.failure("GetIndex")
+	}
+
+	m.GetNameFunc = func() (string, nvml.Return) {
+		return parent.profile.ProductName, parent.failure("GetName")
+	}
+
+	m.GetMinorNumberFunc = func() (int, nvml.Return) {
+		return 0, nvml.ERROR_NOT_SUPPORTED
+	}
+
+	m.IsMigDeviceHandleFunc = func() (bool, nvml.Return) {
+		return true, nvml.SUCCESS
+	}
+
+	m.GetDeviceHandleFromMigDeviceHandleFunc = func() (nvml.Device, nvml.Return) {
+		return parent, nvml.SUCCESS
+	}
+
+	m.GetGpuInstanceIdFunc = func() (int, nvml.Return) {
+		return int(gi.info.Id), nvml.SUCCESS
+	}
+
+	m.GetComputeInstanceIdFunc = func() (int, nvml.Return) {
+		return int(m.computeInstance.info.Id), nvml.SUCCESS
+	}
+
+	m.GetMemoryInfoFunc = func() (nvml.Memory, nvml.Return) {
+		total := gi.profile.MemorySizeMB * 1024 * 1024
+		return nvml.Memory{Total: total, Free: total}, parent.failure("GetMemoryInfo")
+	}
+
+	m.GetComputeRunningProcessesFunc = func() ([]nvml.ProcessInfo, nvml.Return) {
+		processes := parent.server.runningProcesses(m.uuid)
+		for i := range processes {
+			processes[i].GpuInstanceId = gi.info.Id
+			processes[i].ComputeInstanceId = m.computeInstance.info.Id
+		}
+		return processes, parent.failure("GetComputeRunningProcesses")
+	}
+
+	m.GetGraphicsRunningProcessesFunc = func() ([]nvml.ProcessInfo, nvml.Return) {
+		return nil, parent.failure("GetGraphicsRunningProcesses")
+	}
+
+	m.GetMPSComputeRunningProcessesFunc = func() ([]nvml.ProcessInfo, nvml.Return) {
+		return nil, parent.failure("GetMPSComputeRunningProcesses")
+	}
+
+	m.GetAttributesFunc = func() (nvml.DeviceAttributes, nvml.Return) {
+		attributes := nvml.DeviceAttributes{
+			MultiprocessorCount:       gi.profile.MultiprocessorCount,
+			SharedCopyEngineCount:     gi.profile.CopyEngineCount,
+			SharedDecoderCount:        gi.profile.DecoderCount,
+			SharedEncoderCount:        gi.profile.EncoderCount,
+			SharedJpegCount:           gi.profile.JpegCount,
+			SharedOfaCount:            gi.profile.OfaCount,
+			GpuInstanceSliceCount:     gi.profile.SliceCount,
+			ComputeInstanceSliceCount: gi.profile.SliceCount,
+			MemorySizeMB:              gi.profile.MemorySizeMB,
+		}
+		return attributes, parent.failure("GetAttributes")
+	}
+}
+
+func newEventSet(s *Server) *EventSet {
+	set := &EventSet{
+		server: s,
+		events: make(chan nvml.EventData, 64),
+		done:   make(chan struct{}),
+	}
+
+	set.WaitFunc = func(timeout uint32) (nvml.EventData, nvml.Return) {
+		select {
+		case event := <-set.events:
+			return event, nvml.SUCCESS
+		case <-set.done:
+			return nvml.EventData{}, nvml.ERROR_UNINITIALIZED
+		case <-time.After(time.Duration(timeout) * time.Millisecond):
+			return nvml.EventData{}, nvml.ERROR_TIMEOUT
+		}
+	}
+
+	set.FreeFunc = func() nvml.Return {
+		s.Lock()
+		defer s.Unlock()
+		if _, exists := s.eventSets[set]; !exists {
+			return nvml.ERROR_UNINITIALIZED
+		}
+		delete(s.eventSets, set)
+		for _, d := range s.devices {
+			delete(d.eventTypes, set)
+		}
+		close(set.done)
+		return nvml.SUCCESS
+	}
+
+	return set
+}
+
+// ciProfileID returns the ID of the compute instance profile spanning all
+// slices of a GPU instance.
+func ciProfileID(sliceCount uint32) int {
+	switch sliceCount {
+	case 1:
+		return nvml.COMPUTE_INSTANCE_PROFILE_1_SLICE
+	case 2:
+		return nvml.COMPUTE_INSTANCE_PROFILE_2_SLICE
+	case 3:
+		return nvml.COMPUTE_INSTANCE_PROFILE_3_SLICE
+	case 4:
+		return nvml.COMPUTE_INSTANCE_PROFILE_4_SLICE
+	case 6:
+		return nvml.COMPUTE_INSTANCE_PROFILE_6_SLICE
+	case 7:
+		return nvml.COMPUTE_INSTANCE_PROFILE_7_SLICE
+	case 8:
+		return nvml.COMPUTE_INSTANCE_PROFILE_8_SLICE
+	}
+	return -1
+}
+
+func parseFailures(failures Failures) map[string]nvml.Return {
+	parsed := make(map[string]nvml.Return)
+	for function, name := range failures {
+		parsed[function], _ = parseReturn(name)
+	}
+	return parsed
+}
@@ -0,0 +1,194 @@
+/*
+ * Copyright (c) 2025, NVIDIA CORPORATION.  All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package nvmlmock
+
+import (
+	"testing"
+
+	nvdev "github.com/NVIDIA/go-nvlib/pkg/nvlib/device"
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuiltinProfiles(t *testing.T) {
+	testCases := []struct {
+		profile            string
+		expectedGpus       int
+		expectedMigDevices int
+		expectedProfiles   int
+		expectedFabric     bool
+	}{
+		{
+			profile:            "dgx-a100",
+			expectedGpus:       8,
+			expectedMigDevices: 12,
+			expectedProfiles:   19,
+		},
+		{
+			profile:        "gb200",
+			expectedGpus:   4,
+			expectedFabric: true,
+		},
+		{
+			profile:      "l4",
+			expectedGpus: 1,
+		},
+	}
+
+	require.Len(t, BuiltinProfiles(), len(testCases))
+
+	for _, tc := range testCases {
+		t.Run(tc.profile, func(t *testing.T) {
+			profile, err := LoadProfile(tc.profile)
+			require.NoError(t, err)
+
+			server, err := New(profile)
+			require.NoError(t, err)
+
+			devicelib := nvdev.New(server)
+			gpus, migDevices := 0, 0
+			err = devicelib.VisitDevices(func(i int, d nvdev.Device) error {
+				gpus++
+
+				migs, err := d.GetMigDevices()
+				require.NoError(t, err)
+				migDevices += len(migs)
+				for _, mig := range migs {
+					_, err := mig.GetProfile()
+					require.NoError(t, err)
+				}
+
+				profiles, err := d.GetMigProfiles()
+				require.NoError(t, err)
+				require.Len(t, profiles, tc.expectedProfiles)
+
+				attached, err := d.IsFabricAttached()
+				require.NoError(t, err)
+				require.Equal(t, tc.expectedFabric, attached)
+
+				return nil
+			})
+			require.NoError(t, err)
+			require.Equal(t, tc.expectedGpus, gpus)
+			require.Equal(t, tc.expectedMigDevices, migDevices)
+		})
+	}
+}
+
+func TestMigDeviceLifecycle(t *testing.T) {
+	profile, err := LoadProfile("dgx-a100")
+	require.NoError(t, err)
+	server, err := New(profile)
+	require.NoError(t, err)
+
+	device, ret := server.DeviceGetHandleByIndex(7)
+	require.Equal(t, nvml.SUCCESS, ret)
+
+	giProfileInfo, ret := device.GetGpuInstanceProfileInfo(nvml.GPU_INSTANCE_PROFILE_1_SLICE)
+	require.Equal(t, nvml.SUCCESS, ret)
+
+	// Slice 0 is taken by a MIG device from the profile.
+	_, ret = device.CreateGpuInstanceWithPlacement(&giProfileInfo, &nvml.GpuInstancePlacement{Start: 0, Size: 1})
+	require.Equal(t, nvml.ERROR_INSUFFICIENT_RESOURCES, ret)
+
+	gi, ret := device.CreateGpuInstanceWithPlacement(&giProfileInfo, &nvml.GpuInstancePlacement{Start: 2, Size: 1})
+	require.Equal(t, nvml.SUCCESS, ret)
+	ciProfileInfo, ret := gi.GetComputeInstanceProfileInfo(nvml.COMPUTE_INSTANCE_PROFILE_1_SLICE, nvml.COMPUTE_INSTANCE_ENGINE_PROFILE_SHARED)
+	require.Equal(t, nvml.SUCCESS, ret)
+	ci, ret := gi.CreateComputeInstance(&ciProfileInfo)
+	require.Equal(t, nvml.SUCCESS, ret)
+
+	ciInfo, ret := ci.GetInfo()
+	require.Equal(t, nvml.SUCCESS, ret)
+	uuid := ci.(*ComputeInstance).migDevice.uuid
+
+	mig, ret := server.DeviceGetHandleByUUID(uuid)
+	require.Equal(t, nvml.SUCCESS, ret)
+	isMig, ret := mig.IsMigDeviceHandle()
+	require.Equal(t, nvml.SUCCESS, ret)
+	require.True(t, isMig)
+	ciID, ret := mig.GetComputeInstanceId()
+	require.Equal(t, nvml.SUCCESS, ret)
+	require.Equal(t, int(ciInfo.Id), ciID)
+
+	require.Equal(t, nvml.ERROR_IN_USE, gi.Destroy())
+	require.Equal(t, nvml.SUCCESS, ci.Destroy())
+	require.Equal(t, nvml.SUCCESS, gi.Destroy())
+
+	_, ret = server.DeviceGetHandleByUUID(uuid)
+	require.Equal(t, nvml.ERROR_NOT_FOUND, ret)
+}
+
+func TestFailureInjection(t *testing.T) {
+	profile, err := ParseProfile([]byte(`
+driverVersion: 550.54.15
+cudaDriverVersion: 12040
+gpus:
+- count: 2
+  productName: NVIDIA L4
+  brand: Nvidia
+  architecture: Ada Lovelace
+  cudaComputeCapability: "8.9"
+  memory: 23034Mi
+  failures:
+    SetComputeMode: ERROR_NO_PERMISSION
+`))
+	require.NoError(t, err)
+	server, err := New(profile)
+	require.NoError(t, err)
+
+	device, ret := server.DeviceGetHandleByIndex(1)
+	require.Equal(t, nvml.SUCCESS, ret)
+	require.Equal(t, nvml.ERROR_NO_PERMISSION, device.SetComputeMode(nvml.COMPUTEMODE_EXCLUSIVE_PROCESS))
+
+	_, err = ParseProfile([]byte(`
+driverVersion: 550.54.15
+cudaDriverVersion: 12040
+gpus:
+- productName: NVIDIA L4
+  brand: Nvidia
+  architecture: Ada Lovelace
+  cudaComputeCapability: "8.9"
+  memory: 23034Mi
+  failures:
+    SetComputeMode: ERROR_SOMETHING
+`))
+	require.ErrorContains(t, err, "unknown NVML return code")
+}
+
+func TestVgpuSchedulerRequiresVgpuHost(t *testing.T) {
+	profile, err := LoadProfile("l4")
+	require.NoError(t, err)
+	server, err := New(profile)
+	require.NoError(t, err)
+
+	device, ret := server.DeviceGetHandleByIndex(0)
+	require.Equal(t, nvml.SUCCESS, ret)
+	_, ret = device.GetVgpuSchedulerCapabilities()
+	require.Equal(t, nvml.ERROR_NOT_SUPPORTED, ret)
+	require.Equal(t, nvml.ERROR_NOT_SUPPORTED, device.SetVgpuSchedulerState(&nvml.VgpuSchedulerSetState{}))
+
+	profile.Gpus[0].VgpuHost = true
+	server, err = New(profile)
+	require.NoError(t, err)
+
+	device, ret = server.DeviceGetHandleByIndex(0)
+	require.Equal(t, nvml.SUCCESS, ret)
+	_, ret = device.GetVgpuSchedulerCapabilities()
+	require.Equal(t, nvml.SUCCESS, ret)
+	require.Equal(t, nvml.SUCCESS, device.SetVgpuSchedulerState(&nvml.VgpuSchedulerSetState{}))
+}